@@ -0,0 +1,113 @@
+// Command banksim runs the Go bank simulator of internal/banksim as a
+// replacement for the mountebank imposter of docker-compose.yml.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/banksim"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/logging"
+)
+
+func main() {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	latency := fs.Duration("latency", 0, "delay of every answer")
+	jitter := fs.Duration("jitter", 0, "random delay added to latency, up to this duration")
+	errorRate := fs.Float64("error-rate", 0, "fraction of requests answered with -error-status, between 0 and 1")
+	errorStatus := fs.Int("error-status", http.StatusServiceUnavailable, "status of injected errors")
+	declineCodes := fs.String("decline-codes", "", `response codes of declines by last card digit, such as "2=51,4=05", or "default" for those of the mountebank imposter`)
+	seed := fs.Int64("seed", 0, "seed of authorization codes, jitter and injected errors; 0 draws a random one")
+	logLevel := fs.String("log-level", "info", "log level: debug, info, warn or error")
+
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
+
+	opts := []banksim.Option{
+		banksim.WithLatency(*latency, *jitter),
+		banksim.WithErrors(*errorRate, *errorStatus),
+	}
+	if *declineCodes != "" {
+		codes, err := parseDeclineCodes(*declineCodes)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts = append(opts, banksim.WithDeclineCodes(codes))
+	}
+	if *seed != 0 {
+		opts = append(opts, banksim.WithSeed(*seed))
+	}
+
+	if err := run(*addr, banksim.New(opts...), logger); err != nil {
+		logger.Error("bank simulator failed", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func run(addr string, handler http.Handler, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		logger.Info("starting bank simulator", slog.String("addr", addr))
+		errc <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("stopping bank simulator")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// parseDeclineCodes parses the -decline-codes flag.
+func parseDeclineCodes(s string) (map[string]string, error) {
+	if s == "default" {
+		return banksim.DeclineCodes, nil
+	}
+
+	codes := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		digit, code, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || len(digit) != 1 || !strings.Contains("2468", digit) || code == "" {
+			return nil, fmt.Errorf("invalid decline code %q: want <even digit>=<response code>", pair)
+		}
+		codes[digit] = code
+	}
+	return codes, nil
+}
@@ -0,0 +1,157 @@
+// Command gatewayload fires the payment requests of a JSON lines file at a
+// running gateway and reports the latency, outcomes and errors of the
+// responses. With -record and -diff it saves the responses of a run and
+// compares them with a previous one.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/loadtest"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	cfg := loadtest.DefaultConfig
+
+	fs := flag.NewFlagSet("gatewayload", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: gatewayload [flags] <requests.jsonl>")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cfg.URL, "url", cfg.URL, "base URL of the gateway")
+	fs.StringVar(&cfg.Username, "username", "", "merchant ID to authenticate with")
+	fs.StringVar(&cfg.Password, "password", os.Getenv("GATEWAYLOAD_PASSWORD"), "merchant API key, defaults to $GATEWAYLOAD_PASSWORD")
+	fs.Float64Var(&cfg.Rate, "rate", cfg.Rate, "requests started per second, 0 for as fast as -concurrency allows")
+	fs.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "requests in flight at most")
+	fs.DurationVar(&cfg.Duration, "duration", cfg.Duration, "how long to send requests for, going over the file again as needed; 0 sends every request once")
+	fs.DurationVar(&cfg.Timeout, "timeout", cfg.Timeout, "timeout of a single request")
+	jsonReport := fs.Bool("json", false, "print the report as JSON")
+	record := fs.String("record", "", "file to write the responses to, for a later -diff")
+	diff := fs.String("diff", "", "responses recorded by an earlier run to compare this run with")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	requests, err := loadRequests(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	// read before the run, so a wrong path does not waste it
+	var previous []loadtest.Result
+	if *diff != "" {
+		if previous, err = readRecording(*diff); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	results, err := loadtest.Run(ctx, cfg, requests)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	report := loadtest.Summarise(results, time.Since(start))
+
+	if *jsonReport {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = report.Write(stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if *record != "" {
+		if err := writeRecording(*record, results); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	if *diff != "" {
+		diffs := loadtest.Diff(previous, results)
+		for _, d := range diffs {
+			fmt.Fprintln(stderr, d)
+		}
+		if len(diffs) > 0 {
+			fmt.Fprintf(stderr, "%d of %d lines differ from %s\n", len(diffs), len(requests), *diff)
+			return 1
+		}
+		fmt.Fprintf(stderr, "no difference from %s\n", *diff)
+	}
+	return 0
+}
+
+// loadRequests reads the requests file, or stdin when path is "-".
+func loadRequests(path string) ([]loadtest.Request, error) {
+	if path == "-" {
+		return loadtest.Load(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	requests, err := loadtest.Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return requests, nil
+}
+
+func readRecording(path string) ([]loadtest.Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	results, err := loadtest.ReadRecording(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, nil
+}
+
+func writeRecording(path string, results []loadtest.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := loadtest.Record(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
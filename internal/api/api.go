@@ -3,48 +3,203 @@ package api
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"time"
 
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/handlers"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/health"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/logging"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/metrics"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/services"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"golang.org/x/sync/errgroup"
 )
 
+// DefaultRequestTimeout bounds the time spent serving a request.
+const DefaultRequestTimeout = 10 * time.Second
+
+// DefaultBatchMaxSize is the largest size of a payment batch upload in bytes.
+const DefaultBatchMaxSize = 16 << 20
+
+// DefaultDrainTimeout bounds the time spent finishing the requests in flight
+// on shutdown.
+const DefaultDrainTimeout = 30 * time.Second
+
 type Api struct {
 	router           *chi.Mux
 	paymentsHandlers *handlers.PaymentsHandler
+	webhooksHandlers *handlers.WebhooksHandler
+	tokensHandlers   *handlers.TokensHandler
+	batchesHandlers  *handlers.PaymentBatchesHandler
+	batchMaxSize     int64
+	chaosHandlers    *handlers.ChaosHandler
+	chaosToken       string
+	validation       services.ValidationService
+
+	authenticator    *auth.Authenticator
+	idempotencyStore repository.IdempotencyRepository
+	idempotencyTTL   time.Duration
+	statuses         map[string]func() string
+	logger           *slog.Logger
+	metrics          *metrics.Metrics
+	health           *health.Checker
+	requestTimeout   time.Duration
+	drainTimeout     time.Duration
+}
+
+// Option configures optional Api behaviour.
+type Option func(*Api)
+
+// WithIdempotency enables Idempotency-Key handling on payment creation, keeping
+// stored responses in store for ttl.
+func WithIdempotency(store repository.IdempotencyRepository, ttl time.Duration) Option {
+	return func(a *Api) {
+		a.idempotencyStore = store
+		a.idempotencyTTL = ttl
+	}
+}
+
+// WithMerchantAuth requires every /api request to carry valid merchant
+// credentials checked against merchants.
+func WithMerchantAuth(merchants repository.MerchantsRepository) Option {
+	return func(a *Api) {
+		a.authenticator = auth.NewAuthenticator(merchants)
+	}
+}
+
+// WithWebhooks exposes the webhook endpoint and delivery routes backed by webhooks.
+func WithWebhooks(webhooks services.WebhookService) Option {
+	return func(a *Api) {
+		a.webhooksHandlers = handlers.NewWebhooksHandler(a.validation, webhooks)
+	}
+}
+
+// WithCardTokens exposes the card tokenization route backed by tokens.
+func WithCardTokens(tokens services.TokenService) Option {
+	return func(a *Api) {
+		a.tokensHandlers = handlers.NewTokensHandler(a.validation, tokens)
+	}
 }
 
-func New(validation services.ValidationService, paymentSvc services.PaymentService) *Api {
-	a := &Api{}
+// WithPaymentBatches exposes the payment batch routes backed by batches.
+// Uploads larger than maxSize bytes are refused.
+func WithPaymentBatches(batches services.BatchService, maxSize int64) Option {
+	return func(a *Api) {
+		a.batchesHandlers = handlers.NewPaymentBatchesHandler(batches)
+		a.batchMaxSize = maxSize
+	}
+}
+
+// WithChaos serves the settings of chaos on /admin/chaos to the holders of
+// the bearer token.
+func WithChaos(chaos *bank.Chaos, token string) Option {
+	return func(a *Api) {
+		a.chaosHandlers = handlers.NewChaosHandler(chaos)
+		a.chaosToken = token
+	}
+}
+
+// WithLogger sets the logger of the HTTP server and its request log. It
+// defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(a *Api) {
+		a.logger = logger
+	}
+}
+
+// WithMetrics records request metrics in m and serves them on /metrics.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(a *Api) {
+		a.metrics = m
+	}
+}
+
+// WithHealth serves the liveness probe on /healthz/live and the readiness
+// probe, backed by checker, on /healthz/ready.
+func WithHealth(checker *health.Checker) Option {
+	return func(a *Api) {
+		a.health = checker
+	}
+}
+
+// WithRequestTimeout bounds the time spent serving a request to timeout. It
+// defaults to DefaultRequestTimeout.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(a *Api) {
+		a.requestTimeout = timeout
+	}
+}
+
+// WithDrainTimeout bounds the time spent finishing the requests in flight on
+// shutdown to timeout. It defaults to DefaultDrainTimeout.
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(a *Api) {
+		a.drainTimeout = timeout
+	}
+}
+
+// WithStatus reports the state returned by status under name in the ping response.
+func WithStatus(name string, status func() string) Option {
+	return func(a *Api) {
+		if a.statuses == nil {
+			a.statuses = make(map[string]func() string)
+		}
+		a.statuses[name] = status
+	}
+}
+
+func New(validation services.ValidationService, paymentSvc services.PaymentService, opts ...Option) *Api {
+	a := &Api{validation: validation, logger: slog.Default(), requestTimeout: DefaultRequestTimeout, drainTimeout: DefaultDrainTimeout}
 	a.paymentsHandlers = handlers.NewPaymentsHandler(validation, paymentSvc)
 
+	for _, opt := range opts {
+		opt(a)
+	}
+
 	a.setupRouter()
 
 	return a
 }
 
+// Run serves the API on addr until ctx is cancelled, then stops accepting
+// connections and waits for the requests in flight. Requests still running
+// after the drain timeout are cancelled and their connections closed.
 func (a *Api) Run(ctx context.Context, addr string) error {
+	// requests must not be cancelled along with ctx, or nothing would drain
+	requestCtx, cancelRequests := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRequests()
+
 	httpServer := &http.Server{
 		Addr:        addr,
 		Handler:     a.router,
-		BaseContext: func(_ net.Listener) context.Context { return ctx },
+		BaseContext: func(_ net.Listener) context.Context { return requestCtx },
 	}
 
 	g, ctx := errgroup.WithContext(ctx)
 
 	g.Go(func() error {
 		<-ctx.Done()
-		fmt.Printf("shutting down HTTP server\n")
-		return httpServer.Shutdown(ctx)
+		a.logger.InfoContext(ctx, "shutting down HTTP server", slog.Duration("drain_timeout", a.drainTimeout))
+
+		drainCtx, cancel := context.WithTimeout(context.Background(), a.drainTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(drainCtx); err != nil {
+			cancelRequests()
+			httpServer.Close()
+			return fmt.Errorf("HTTP server did not drain within %s: %w", a.drainTimeout, err)
+		}
+		return nil
 	})
 
 	g.Go(func() error {
-		fmt.Printf("starting HTTP server on %s\n", addr)
+		a.logger.InfoContext(ctx, "starting HTTP server", slog.String("addr", addr))
 		err := httpServer.ListenAndServe()
 		if err != nil && err != http.ErrServerClosed {
 			return err
@@ -58,13 +213,71 @@ func (a *Api) Run(ctx context.Context, addr string) error {
 
 func (a *Api) setupRouter() {
 	a.router = chi.NewRouter()
-	a.router.Use(middleware.Logger)
-	a.router.Use(middleware.Recoverer)
-	a.router.Use(middleware.Timeout(10 * time.Second))
+	a.router.Use(tracing.Middleware)
+	a.router.Use(logging.Middleware(a.logger))
+	a.router.Use(a.metrics.Middleware)
+	a.router.Use(problem.Recoverer)
+	a.router.Use(problem.Timeout(a.requestTimeout))
+	a.router.NotFound(problem.NotFound)
+	a.router.MethodNotAllowed(problem.MethodNotAllowed)
 
 	a.router.Get("/ping", a.PingHandler())
 	a.router.Get("/swagger/*", a.SwaggerHandler())
+	if a.metrics != nil {
+		a.router.Method("GET", "/metrics", a.metrics.Handler())
+	}
+	if a.health != nil {
+		a.router.Get("/healthz/live", a.LivenessHandler())
+		a.router.Get("/healthz/ready", a.ReadinessHandler())
+	}
+
+	if a.chaosHandlers != nil {
+		a.router.Route("/admin", func(r chi.Router) {
+			r.Use(auth.BearerToken(a.chaosToken))
+
+			r.Get("/chaos", a.GetChaosHandler())
+			r.Patch("/chaos", a.PatchChaosHandler())
+			r.Delete("/chaos", a.DeleteChaosHandler())
+		})
+	}
+
+	a.router.Route("/api", func(r chi.Router) {
+		if a.authenticator != nil {
+			r.Use(a.authenticator.BasicAuth)
+		}
+
+		r.With(a.idempotencyMiddlewares()...).Post("/payments", a.PostPaymentHandler())
+		r.Get("/payments", a.ListPaymentsHandler())
+		r.Get("/payments/{id}", a.GetPaymentHandler())
+		r.With(a.idempotencyMiddlewares()...).Post("/payments/{id}/captures", a.CapturePaymentHandler())
+		r.With(a.idempotencyMiddlewares()...).Post("/payments/{id}/voids", a.VoidPaymentHandler())
+		r.With(a.idempotencyMiddlewares()...).Post("/payments/{id}/refunds", a.RefundPaymentHandler())
+
+		if a.batchesHandlers != nil {
+			r.With(handlers.MaxBodySize(a.batchMaxSize)).With(a.idempotencyMiddlewares()...).Post("/payment-batches", a.PostPaymentBatchHandler())
+			r.Get("/payment-batches/{id}", a.GetPaymentBatchHandler())
+			r.Get("/payment-batches/{id}/results", a.GetPaymentBatchResultsHandler())
+		}
+
+		if a.tokensHandlers != nil {
+			r.Post("/tokens", a.PostTokenHandler())
+		}
+
+		if a.webhooksHandlers != nil {
+			r.Post("/webhooks", a.RegisterWebhookHandler())
+			r.Get("/webhooks", a.ListWebhooksHandler())
+			r.Delete("/webhooks/{id}", a.DeleteWebhookHandler())
+			r.Get("/webhooks/deliveries", a.ListWebhookDeliveriesHandler())
+			r.Get("/webhooks/deliveries/{id}", a.GetWebhookDeliveryHandler())
+			r.Post("/webhooks/deliveries/{id}/replay", a.ReplayWebhookDeliveryHandler())
+		}
+	})
+}
 
-	a.router.Post("/api/payments", a.PostPaymentHandler())
-	a.router.Get("/api/payments/{id}", a.GetPaymentHandler())
+func (a *Api) idempotencyMiddlewares() []func(http.Handler) http.Handler {
+	var mws []func(http.Handler) http.Handler
+	if a.idempotencyStore != nil {
+		mws = append(mws, handlers.Idempotency(a.idempotencyStore, a.idempotencyTTL))
+	}
+	return mws
 }
@@ -6,24 +6,66 @@ import (
 	"net/http"
 
 	"github.com/cko-recruitment/payment-gateway-challenge-go/docs"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/health"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 type pong struct {
-	Message string `json:"message"`
+	Message string            `json:"message"`
+	Status  map[string]string `json:"status,omitempty"`
 }
 
 // PingHandler returns an http.HandlerFunc that handles HTTP Ping GET requests.
+// The response includes the state of every component registered with WithStatus.
 func (a *Api) PingHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		resp := pong{Message: "pong"}
+		for name, status := range a.statuses {
+			if resp.Status == nil {
+				resp.Status = make(map[string]string, len(a.statuses))
+			}
+			resp.Status[name] = status()
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(pong{Message: "pong"}); err != nil {
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 		}
 	}
 }
 
+// LivenessHandler returns an http.HandlerFunc that reports the gateway is
+// alive. It checks no dependency: a failing bank or store is no reason to
+// restart the gateway.
+func (a *Api) LivenessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, http.StatusOK, map[string]string{"status": health.StatusOK})
+	}
+}
+
+// ReadinessHandler returns an http.HandlerFunc that reports whether the
+// gateway is ready to take traffic, with the outcome of every check. It
+// answers 503 when any check failed.
+func (a *Api) ReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := a.health.Check(r.Context())
+
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		writeHealth(w, status, report)
+	}
+}
+
+func writeHealth(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
 // SwaggerHandler returns an http.HandlerFunc that handles HTTP Swagger related requests.
 func (a *Api) SwaggerHandler() http.HandlerFunc {
 	return httpSwagger.Handler(
@@ -34,19 +76,53 @@ func (a *Api) SwaggerHandler() http.HandlerFunc {
 // PostPaymentHandler returns an http.HandlerFunc that handles Payments POST requests.
 //
 //	@Summary		Process a payment
-//	@Description	Processes a card payment through the payment gateway
+//	@Description	Processes a card payment through the payment gateway. When the bank's answer is lost the payment is returned as Pending with 202 and settled later. Pay with either the card fields or a card token as source.
 //	@Tags			payments
 //	@Accept			json
 //	@Produce		json
-//	@Param			payment	body		models.PaymentRequest	true	"Payment Request"
-//	@Success		200		{object}	models.PaymentResponse
-//	@Failure		400		{object}	models.ErrorResponse
-//	@Failure		500		{object}	models.ErrorResponse
+//	@Param			payment			body		models.PaymentRequest	true	"Payment Request"
+//	@Param			Idempotency-Key	header		string					false	"Key that makes retries of this request safe"
+//	@Success		200				{object}	models.PaymentResponse
+//	@Success		202				{object}	models.PaymentResponse
+//	@Failure		400				{object}	models.Problem
+//	@Failure		401				{object}	models.Problem
+//	@Failure		409				{object}	models.Problem
+//	@Failure		422				{object}	models.Problem
+//	@Failure		500				{object}	models.Problem
+//	@Failure		502				{object}	models.Problem
+//	@Failure		503				{object}	models.Problem
+//	@Security		BasicAuth
 //	@Router			/api/payments [post]
 func (a *Api) PostPaymentHandler() http.HandlerFunc {
 	return a.paymentsHandlers.PostHandler()
 }
 
+// ListPaymentsHandler returns an http.HandlerFunc that handles payment listing requests.
+//
+//	@Summary		List payments
+//	@Description	Lists the merchant's payments, newest first, without their operations. Pass next_cursor from a page as cursor to get the next one.
+//	@Tags			payments
+//	@Produce		json
+//	@Param			status			query		string	false	"Payment status"
+//	@Param			currency		query		string	false	"ISO 4217 currency code"
+//	@Param			min_amount		query		int		false	"Minimum amount in minor units"
+//	@Param			max_amount		query		int		false	"Maximum amount in minor units"
+//	@Param			card_last_four	query		string	false	"Last four digits of the card number"
+//	@Param			created_from	query		string	false	"Created at or after, RFC 3339"
+//	@Param			created_to		query		string	false	"Created before, RFC 3339"
+//	@Param			reference		query		string	false	"Merchant reference"
+//	@Param			limit			query		int		false	"Page size, 1 to 100"	default(20)
+//	@Param			cursor			query		string	false	"Cursor of the page to return"
+//	@Success		200				{object}	models.PaymentListResponse
+//	@Failure		400				{object}	models.Problem
+//	@Failure		401				{object}	models.Problem
+//	@Failure		500				{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/payments [get]
+func (a *Api) ListPaymentsHandler() http.HandlerFunc {
+	return a.paymentsHandlers.ListHandler()
+}
+
 // GetPaymentHandler returns an http.HandlerFunc that handles Payments GET requests.
 //
 //	@Summary		Retrieve payment details
@@ -55,8 +131,289 @@ func (a *Api) PostPaymentHandler() http.HandlerFunc {
 //	@Produce		json
 //	@Param			id	path		string	true	"Payment ID"
 //	@Success		200	{object}	models.PaymentResponse
-//	@Failure		404
+//	@Failure		400	{object}	models.Problem
+//	@Failure		401	{object}	models.Problem
+//	@Failure		404	{object}	models.Problem
+//	@Security		BasicAuth
 //	@Router			/api/payments/{id} [get]
 func (a *Api) GetPaymentHandler() http.HandlerFunc {
 	return a.paymentsHandlers.GetHandler()
 }
+
+// CapturePaymentHandler returns an http.HandlerFunc that handles payment capture requests.
+//
+//	@Summary		Capture a payment
+//	@Description	Captures all or part of the authorized amount. Several partial captures are allowed until a refund is made.
+//	@Tags			payments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		string					true	"Payment ID"
+//	@Param			capture			body		models.OperationRequest	false	"Amount to capture, defaults to the remaining authorized amount"
+//	@Param			Idempotency-Key	header		string					false	"Key that makes retries of this request safe"
+//	@Success		201				{object}	models.OperationResponse
+//	@Failure		400				{object}	models.Problem
+//	@Failure		401				{object}	models.Problem
+//	@Failure		404				{object}	models.Problem
+//	@Failure		409				{object}	models.Problem
+//	@Failure		422				{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/payments/{id}/captures [post]
+func (a *Api) CapturePaymentHandler() http.HandlerFunc {
+	return a.paymentsHandlers.CaptureHandler()
+}
+
+// VoidPaymentHandler returns an http.HandlerFunc that handles payment void requests.
+//
+//	@Summary		Void a payment
+//	@Description	Cancels an authorized payment that has not been captured
+//	@Tags			payments
+//	@Produce		json
+//	@Param			id				path		string	true	"Payment ID"
+//	@Param			Idempotency-Key	header		string	false	"Key that makes retries of this request safe"
+//	@Success		201				{object}	models.OperationResponse
+//	@Failure		400				{object}	models.Problem
+//	@Failure		401				{object}	models.Problem
+//	@Failure		404				{object}	models.Problem
+//	@Failure		409				{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/payments/{id}/voids [post]
+func (a *Api) VoidPaymentHandler() http.HandlerFunc {
+	return a.paymentsHandlers.VoidHandler()
+}
+
+// RefundPaymentHandler returns an http.HandlerFunc that handles payment refund requests.
+//
+//	@Summary		Refund a payment
+//	@Description	Refunds all or part of the captured amount. Several partial refunds are allowed up to the captured amount.
+//	@Tags			payments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		string					true	"Payment ID"
+//	@Param			refund			body		models.OperationRequest	false	"Amount to refund, defaults to the remaining captured amount"
+//	@Param			Idempotency-Key	header		string					false	"Key that makes retries of this request safe"
+//	@Success		201				{object}	models.OperationResponse
+//	@Failure		400				{object}	models.Problem
+//	@Failure		401				{object}	models.Problem
+//	@Failure		404				{object}	models.Problem
+//	@Failure		409				{object}	models.Problem
+//	@Failure		422				{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/payments/{id}/refunds [post]
+func (a *Api) RefundPaymentHandler() http.HandlerFunc {
+	return a.paymentsHandlers.RefundHandler()
+}
+
+// PostPaymentBatchHandler returns an http.HandlerFunc that handles payment batch uploads.
+//
+//	@Summary		Submit a batch of payments
+//	@Description	Queues the payments of a JSON lines upload, one models.PaymentBatchLine per line, to be made in the background. Blank lines are skipped but keep the numbering of the lines in the results. Card details are never stored.
+//	@Tags			payment-batches
+//	@Accept			application/x-ndjson
+//	@Produce		json
+//	@Param			batch			body		models.PaymentBatchLine	true	"Newline-delimited payment requests, each with an optional client_reference"
+//	@Param			Idempotency-Key	header		string					false	"Key that makes retries of this request safe"
+//	@Success		202				{object}	models.PaymentBatchResponse
+//	@Failure		400				{object}	models.Problem
+//	@Failure		401				{object}	models.Problem
+//	@Failure		413				{object}	models.Problem
+//	@Failure		503				{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/payment-batches [post]
+func (a *Api) PostPaymentBatchHandler() http.HandlerFunc {
+	return a.batchesHandlers.PostHandler()
+}
+
+// GetPaymentBatchHandler returns an http.HandlerFunc that handles payment batch retrieval.
+//
+//	@Summary		Retrieve a payment batch
+//	@Description	Returns the progress of a batch, with the number of processed lines by status.
+//	@Tags			payment-batches
+//	@Produce		json
+//	@Param			id	path		string	true	"Batch ID"
+//	@Success		200	{object}	models.PaymentBatchResponse
+//	@Failure		400	{object}	models.Problem
+//	@Failure		401	{object}	models.Problem
+//	@Failure		404	{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/payment-batches/{id} [get]
+func (a *Api) GetPaymentBatchHandler() http.HandlerFunc {
+	return a.batchesHandlers.GetHandler()
+}
+
+// GetPaymentBatchResultsHandler returns an http.HandlerFunc that handles payment batch result downloads.
+//
+//	@Summary		Download the results of a payment batch
+//	@Description	Returns the result of every line processed so far as JSON lines, in line order: the payment made and its status, or why the line was rejected or failed.
+//	@Tags			payment-batches
+//	@Produce		application/x-ndjson
+//	@Param			id	path		string	true	"Batch ID"
+//	@Success		200	{array}		models.PaymentBatchResult
+//	@Failure		400	{object}	models.Problem
+//	@Failure		401	{object}	models.Problem
+//	@Failure		404	{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/payment-batches/{id}/results [get]
+func (a *Api) GetPaymentBatchResultsHandler() http.HandlerFunc {
+	return a.batchesHandlers.ResultsHandler()
+}
+
+// PostTokenHandler returns an http.HandlerFunc that handles card tokenization requests.
+//
+//	@Summary		Tokenize a card
+//	@Description	Stores the card number and expiry date encrypted in the vault and returns a token to pay with as the source of later payments. The CVV is never stored.
+//	@Tags			tokens
+//	@Accept			json
+//	@Produce		json
+//	@Param			card	body		models.CardTokenRequest	true	"Card to tokenize"
+//	@Success		201		{object}	models.CardTokenResponse
+//	@Failure		400		{object}	models.Problem
+//	@Failure		401		{object}	models.Problem
+//	@Failure		500		{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/tokens [post]
+func (a *Api) PostTokenHandler() http.HandlerFunc {
+	return a.tokensHandlers.PostHandler()
+}
+
+// RegisterWebhookHandler returns an http.HandlerFunc that handles webhook endpoint registration.
+//
+//	@Summary		Register a webhook endpoint
+//	@Description	Registers a URL receiving the given payment events. The secret used to sign deliveries is only returned here.
+//	@Tags			webhooks
+//	@Accept			json
+//	@Produce		json
+//	@Param			endpoint	body		models.WebhookEndpointRequest	true	"Webhook endpoint"
+//	@Success		201			{object}	models.WebhookEndpointResponse
+//	@Failure		400			{object}	models.Problem
+//	@Failure		401			{object}	models.Problem
+//	@Failure		500			{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/webhooks [post]
+func (a *Api) RegisterWebhookHandler() http.HandlerFunc {
+	return a.webhooksHandlers.RegisterHandler()
+}
+
+// ListWebhooksHandler returns an http.HandlerFunc that handles webhook endpoint listing requests.
+//
+//	@Summary		List webhook endpoints
+//	@Tags			webhooks
+//	@Produce		json
+//	@Success		200	{array}		models.WebhookEndpointResponse
+//	@Failure		401	{object}	models.Problem
+//	@Failure		500	{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/webhooks [get]
+func (a *Api) ListWebhooksHandler() http.HandlerFunc {
+	return a.webhooksHandlers.ListHandler()
+}
+
+// DeleteWebhookHandler returns an http.HandlerFunc that handles webhook endpoint deletion.
+//
+//	@Summary		Delete a webhook endpoint
+//	@Description	Deletes an endpoint. Deliveries still queued for it are moved to the dead-letter list.
+//	@Tags			webhooks
+//	@Param			id	path	string	true	"Webhook endpoint ID"
+//	@Success		204
+//	@Failure		400	{object}	models.Problem
+//	@Failure		401	{object}	models.Problem
+//	@Failure		404	{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/webhooks/{id} [delete]
+func (a *Api) DeleteWebhookHandler() http.HandlerFunc {
+	return a.webhooksHandlers.DeleteHandler()
+}
+
+// ListWebhookDeliveriesHandler returns an http.HandlerFunc that handles webhook delivery listing requests.
+//
+//	@Summary		List webhook deliveries
+//	@Description	Lists the 100 most recent deliveries. Filter on status=dead to read the dead-letter list.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			status	query		string	false	"Delivery status: pending, succeeded or dead"
+//	@Success		200		{array}		models.WebhookDeliveryResponse
+//	@Failure		400		{object}	models.Problem
+//	@Failure		401		{object}	models.Problem
+//	@Failure		500		{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/webhooks/deliveries [get]
+func (a *Api) ListWebhookDeliveriesHandler() http.HandlerFunc {
+	return a.webhooksHandlers.ListDeliveriesHandler()
+}
+
+// GetWebhookDeliveryHandler returns an http.HandlerFunc that handles webhook delivery GET requests.
+//
+//	@Summary		Retrieve a webhook delivery
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id	path		string	true	"Delivery ID"
+//	@Success		200	{object}	models.WebhookDeliveryResponse
+//	@Failure		400	{object}	models.Problem
+//	@Failure		401	{object}	models.Problem
+//	@Failure		404	{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/webhooks/deliveries/{id} [get]
+func (a *Api) GetWebhookDeliveryHandler() http.HandlerFunc {
+	return a.webhooksHandlers.GetDeliveryHandler()
+}
+
+// ReplayWebhookDeliveryHandler returns an http.HandlerFunc that handles webhook delivery replays.
+//
+//	@Summary		Replay a webhook delivery
+//	@Description	Queues the delivery to be sent again right away with a fresh set of attempts, whatever its status.
+//	@Tags			webhooks
+//	@Produce		json
+//	@Param			id	path		string	true	"Delivery ID"
+//	@Success		202	{object}	models.WebhookDeliveryResponse
+//	@Failure		400	{object}	models.Problem
+//	@Failure		401	{object}	models.Problem
+//	@Failure		404	{object}	models.Problem
+//	@Security		BasicAuth
+//	@Router			/api/webhooks/deliveries/{id}/replay [post]
+func (a *Api) ReplayWebhookDeliveryHandler() http.HandlerFunc {
+	return a.webhooksHandlers.ReplayDeliveryHandler()
+}
+
+// GetChaosHandler returns an http.HandlerFunc that handles chaos settings requests.
+//
+//	@Summary		Retrieve the injected bank faults
+//	@Description	Returns the faults injected in the bank calls, with the part of the script that has not run yet.
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	models.ChaosSettings
+//	@Failure		401	{object}	models.Problem
+//	@Security		AdminToken
+//	@Router			/admin/chaos [get]
+func (a *Api) GetChaosHandler() http.HandlerFunc {
+	return a.chaosHandlers.GetHandler()
+}
+
+// PatchChaosHandler returns an http.HandlerFunc that handles chaos settings changes.
+//
+//	@Summary		Change the injected bank faults
+//	@Description	Changes the faults injected in the bank calls. Fields left out keep their value, rates are merged and a rate of 0 removes it. A script replaces the rest of the current one.
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			settings	body		models.ChaosSettings	true	"Chaos settings"
+//	@Success		200			{object}	models.ChaosSettings
+//	@Failure		400			{object}	models.Problem
+//	@Failure		401			{object}	models.Problem
+//	@Security		AdminToken
+//	@Router			/admin/chaos [patch]
+func (a *Api) PatchChaosHandler() http.HandlerFunc {
+	return a.chaosHandlers.PatchHandler()
+}
+
+// DeleteChaosHandler returns an http.HandlerFunc that stops the fault injection.
+//
+//	@Summary		Stop injecting bank faults
+//	@Description	Clears the rates and the script. The latency, timeout and error status are kept.
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	models.ChaosSettings
+//	@Failure		401	{object}	models.Problem
+//	@Security		AdminToken
+//	@Router			/admin/chaos [delete]
+func (a *Api) DeleteChaosHandler() http.HandlerFunc {
+	return a.chaosHandlers.DeleteHandler()
+}
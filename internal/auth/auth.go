@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+)
+
+type contextKey struct{}
+
+// dummyHash is compared against when the merchant does not exist so that
+// unknown and known merchant IDs take the same time to reject.
+var dummyHash = HashAPIKey("dummy-secret")
+
+// HashAPIKey returns the hex SHA-256 digest of an API secret, as stored in
+// the merchant registry. API secrets are long random strings, so a fast hash
+// keeps them safe without slowing down every request.
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticator checks merchant credentials against the merchant registry.
+type Authenticator struct {
+	merchants repository.MerchantsRepository
+}
+
+func NewAuthenticator(merchants repository.MerchantsRepository) *Authenticator {
+	return &Authenticator{merchants: merchants}
+}
+
+// Authenticate returns the merchant identified by merchantID if secret matches
+// its API key hash, and models.ErrInvalidCredentials otherwise.
+func (a *Authenticator) Authenticate(ctx context.Context, merchantID, secret string) (*models.Merchant, error) {
+	hash := HashAPIKey(secret)
+
+	merchant, err := a.merchants.GetMerchant(ctx, merchantID)
+	if errors.Is(err, models.ErrMerchantNotFound) {
+		subtle.ConstantTimeCompare([]byte(dummyHash), []byte(hash))
+		return nil, models.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(strings.ToLower(merchant.ApiKeyHash)), []byte(hash)) != 1 {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	return merchant, nil
+}
+
+// BasicAuth returns a middleware that requires HTTP Basic credentials, with the
+// merchant ID as user name and the API secret as password. The authenticated
+// merchant ID is stored in the request context.
+func (a *Authenticator) BasicAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		merchantID, secret, ok := r.BasicAuth()
+		if !ok {
+			unauthorized(w, r)
+			return
+		}
+
+		merchant, err := a.Authenticate(ctx, merchantID, secret)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			unauthorized(w, r)
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to authenticate merchant")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithMerchantID(ctx, merchant.Id)))
+	})
+}
+
+// BearerToken returns a middleware that requires the bearer token token, for
+// operator endpoints that merchants must not reach.
+func BearerToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="payment-gateway"`)
+				problem.Error(w, r, http.StatusUnauthorized, models.CodeUnauthorized, models.ErrInvalidCredentials.Error())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithMerchantID returns a copy of ctx carrying the authenticated merchant ID.
+func WithMerchantID(ctx context.Context, merchantID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, merchantID)
+}
+
+// MerchantID returns the authenticated merchant ID stored in ctx, or an empty
+// string if the request was not authenticated.
+func MerchantID(ctx context.Context) string {
+	merchantID, _ := ctx.Value(contextKey{}).(string)
+	return merchantID
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="payment-gateway"`)
+	problem.Error(w, r, http.StatusUnauthorized, models.CodeUnauthorized, models.ErrInvalidCredentials.Error())
+}
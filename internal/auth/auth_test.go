@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAuth(t *testing.T) {
+	authenticator := NewAuthenticator(repository.NewMerchantsRepository([]models.Merchant{
+		{Id: "merchant-1", Name: "Merchant 1", ApiKeyHash: HashAPIKey("secret")},
+	}))
+
+	var gotMerchant string
+	h := authenticator.BasicAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMerchant = MerchantID(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name         string
+		user, secret string
+		withAuth     bool
+		expectedCode int
+	}{
+		{"valid credentials", "merchant-1", "secret", true, http.StatusOK},
+		{"wrong secret", "merchant-1", "wrong", true, http.StatusUnauthorized},
+		{"unknown merchant", "merchant-2", "secret", true, http.StatusUnauthorized},
+		{"missing credentials", "", "", false, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMerchant = ""
+			req := httptest.NewRequest("GET", "/api/payments", nil)
+			if tt.withAuth {
+				req.SetBasicAuth(tt.user, tt.secret)
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusOK {
+				assert.Equal(t, tt.user, gotMerchant)
+			} else {
+				assert.Empty(t, gotMerchant)
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	h := BearerToken("admin-token")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		expectedCode  int
+	}{
+		{"valid token", "Bearer admin-token", http.StatusOK},
+		{"wrong token", "Bearer other-token", http.StatusUnauthorized},
+		{"basic credentials", "Basic YWRtaW4tdG9rZW46", http.StatusUnauthorized},
+		{"missing token", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin/chaos", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
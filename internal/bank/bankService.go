@@ -2,10 +2,54 @@ package bank
 
 import (
 	"context"
+	"errors"
+	"net"
 
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
 )
 
+// ErrBankPaymentNotFound is returned when the acquirer has no payment with the
+// queried reference.
+var ErrBankPaymentNotFound = errors.New("payment not found at bank")
+
+// OutcomeUnknown reports whether err, returned by ProcessPayment, leaves it
+// unknown whether the acquirer authorized the payment. Only errors that show
+// the acquirer never took the request on are known not to be authorized: the
+// connection could not be made, the circuit breaker was open, or the acquirer
+// answered 404, 503 or 429 with a Retry-After. Any other error, including an
+// unexpected status or a response that cannot be read, may follow an
+// authorization.
+func OutcomeUnknown(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return false
+	}
+
+	var retryable *retryableError
+	switch {
+	case errors.Is(err, models.ErrAcquirerUnavailable),
+		errors.Is(err, ErrBankPaymentNotFound),
+		errors.Is(err, ErrBankUnavailable):
+		return false
+	case errors.As(err, &retryable) && errors.Is(retryable.err, ErrBankRateLimited):
+		// only a 429 with a Retry-After is marked retryable
+		return false
+	default:
+		return true
+	}
+}
+
 type Bank interface {
-	ProcessPayment(ctx context.Context, req models.PaymentRequest) (*BankResponse, error)
+	// ProcessPayment asks the acquirer to authorize req. reference identifies the
+	// payment at the bank and must be the same for every retry of the request.
+	// See OutcomeUnknown for the errors after which the payment may still
+	// have been authorized.
+	ProcessPayment(ctx context.Context, reference string, req models.PaymentRequest) (*BankResponse, error)
+	// QueryPayment returns the acquirer's answer for the payment sent with
+	// reference, or ErrBankPaymentNotFound if the acquirer never received it.
+	QueryPayment(ctx context.Context, reference string) (*BankResponse, error)
 }
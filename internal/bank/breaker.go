@@ -0,0 +1,242 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// BreakerState is the state of a CircuitBreaker
+type BreakerState string
+
+const (
+	// BreakerClosed lets every call through while recording outcomes
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects every call until the open timeout has passed
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a limited number of trial calls through to decide
+	// whether to close or open again
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerConfig controls when a CircuitBreaker opens and recovers
+type BreakerConfig struct {
+	// WindowSize is the number of most recent calls the rates are computed over
+	WindowSize int
+	// MinCalls is the number of calls needed in the window before it can open
+	MinCalls int
+	// FailureRateThreshold opens the breaker when this fraction of calls failed
+	FailureRateThreshold float64
+	// SlowCallThreshold is the duration above which a call counts as slow
+	SlowCallThreshold time.Duration
+	// SlowCallRateThreshold opens the breaker when this fraction of calls was slow
+	SlowCallRateThreshold float64
+	// OpenTimeout is how long the breaker stays open before trial calls are allowed
+	OpenTimeout time.Duration
+	// HalfOpenCalls is the number of trial calls that must succeed to close again
+	HalfOpenCalls int
+}
+
+// DefaultBreakerConfig is a conservative configuration for a single acquirer.
+var DefaultBreakerConfig = BreakerConfig{
+	WindowSize:            20,
+	MinCalls:              10,
+	FailureRateThreshold:  0.5,
+	SlowCallThreshold:     5 * time.Second,
+	SlowCallRateThreshold: 0.8,
+	OpenTimeout:           30 * time.Second,
+	HalfOpenCalls:         3,
+}
+
+type callOutcome struct {
+	failed bool
+	slow   bool
+}
+
+// CircuitBreaker is a Bank decorator that stops calling an acquirer which is
+// failing or too slow, and fails fast with models.ErrAcquirerUnavailable instead.
+type CircuitBreaker struct {
+	next Bank
+	cfg  BreakerConfig
+	now  func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	window   []callOutcome
+	pos      int
+	filled   int
+	openedAt time.Time
+	// trials and trialSuccesses count the calls let through while half-open
+	trials         int
+	trialSuccesses int
+}
+
+func NewCircuitBreaker(next Bank, cfg BreakerConfig) *CircuitBreaker {
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = DefaultBreakerConfig.WindowSize
+	}
+	if cfg.MinCalls <= 0 || cfg.MinCalls > cfg.WindowSize {
+		cfg.MinCalls = cfg.WindowSize
+	}
+	if cfg.HalfOpenCalls <= 0 {
+		cfg.HalfOpenCalls = 1
+	}
+
+	return &CircuitBreaker{
+		next:   next,
+		cfg:    cfg,
+		now:    time.Now,
+		state:  BreakerClosed,
+		window: make([]callOutcome, cfg.WindowSize),
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	return b.state
+}
+
+func (b *CircuitBreaker) ProcessPayment(ctx context.Context, reference string, req models.PaymentRequest) (*BankResponse, error) {
+	return b.call(ctx, func() (*BankResponse, error) {
+		return b.next.ProcessPayment(ctx, reference, req)
+	})
+}
+
+func (b *CircuitBreaker) QueryPayment(ctx context.Context, reference string) (*BankResponse, error) {
+	return b.call(ctx, func() (*BankResponse, error) {
+		return b.next.QueryPayment(ctx, reference)
+	})
+}
+
+// call runs fn if the breaker allows it and records its outcome.
+func (b *CircuitBreaker) call(ctx context.Context, fn func() (*BankResponse, error)) (*BankResponse, error) {
+	if !b.allow() {
+		return nil, models.ErrAcquirerUnavailable
+	}
+
+	start := b.now()
+	resp, err := fn()
+	elapsed := b.now().Sub(start)
+
+	// a call abandoned by our own caller says nothing about the acquirer
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		b.release()
+		return resp, err
+	}
+
+	b.record(callOutcome{
+		// the acquirer answering that it does not know a payment is a healthy answer
+		failed: err != nil && !errors.Is(err, ErrBankPaymentNotFound),
+		slow:   b.cfg.SlowCallThreshold > 0 && elapsed > b.cfg.SlowCallThreshold,
+	})
+
+	return resp, err
+}
+
+// allow reports whether a call may go through and reserves a trial slot when
+// half-open.
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	switch b.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.trials >= b.cfg.HalfOpenCalls {
+			return false
+		}
+		b.trials++
+	}
+	return true
+}
+
+// release gives back a trial slot for a call that did not produce an outcome.
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.trials > 0 {
+		b.trials--
+	}
+}
+
+func (b *CircuitBreaker) record(outcome callOutcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if outcome.failed || outcome.slow {
+			b.open()
+			return
+		}
+		b.trialSuccesses++
+		if b.trialSuccesses >= b.cfg.HalfOpenCalls {
+			b.close()
+		}
+	case BreakerClosed:
+		b.window[b.pos] = outcome
+		b.pos = (b.pos + 1) % len(b.window)
+		if b.filled < len(b.window) {
+			b.filled++
+		}
+		if b.shouldOpen() {
+			b.open()
+		}
+	}
+}
+
+func (b *CircuitBreaker) shouldOpen() bool {
+	if b.filled < b.cfg.MinCalls {
+		return false
+	}
+
+	failed, slow := 0, 0
+	for _, o := range b.window[:b.filled] {
+		if o.failed {
+			failed++
+		}
+		if o.slow {
+			slow++
+		}
+	}
+
+	total := float64(b.filled)
+	if b.cfg.FailureRateThreshold > 0 && float64(failed)/total >= b.cfg.FailureRateThreshold {
+		return true
+	}
+	if b.cfg.SlowCallRateThreshold > 0 && float64(slow)/total >= b.cfg.SlowCallRateThreshold {
+		return true
+	}
+	return false
+}
+
+// advance moves an open breaker to half-open once the open timeout passed.
+// Callers must hold b.mu.
+func (b *CircuitBreaker) advance() {
+	if b.state == BreakerOpen && !b.now().Before(b.openedAt.Add(b.cfg.OpenTimeout)) {
+		b.state = BreakerHalfOpen
+		b.trials = 0
+		b.trialSuccesses = 0
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+}
+
+func (b *CircuitBreaker) close() {
+	b.state = BreakerClosed
+	b.filled = 0
+	b.pos = 0
+}
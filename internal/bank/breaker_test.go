@@ -0,0 +1,140 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBank fails while err is set and advances clock by delay on every call.
+type fakeBank struct {
+	err   error
+	delay time.Duration
+	clock *time.Time
+	calls int
+}
+
+func (f *fakeBank) ProcessPayment(ctx context.Context, reference string, req models.PaymentRequest) (*BankResponse, error) {
+	f.calls++
+	*f.clock = f.clock.Add(f.delay)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &BankResponse{Authorized: true}, nil
+}
+
+func (f *fakeBank) QueryPayment(ctx context.Context, reference string) (*BankResponse, error) {
+	return f.ProcessPayment(ctx, reference, models.PaymentRequest{})
+}
+
+var testBreakerConfig = BreakerConfig{
+	WindowSize:            4,
+	MinCalls:              4,
+	FailureRateThreshold:  0.5,
+	SlowCallThreshold:     time.Second,
+	SlowCallRateThreshold: 0.75,
+	OpenTimeout:           time.Minute,
+	HalfOpenCalls:         2,
+}
+
+func newTestBreaker(bank *fakeBank) *CircuitBreaker {
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	bank.clock = &clock
+	b := NewCircuitBreaker(bank, testBreakerConfig)
+	b.now = func() time.Time { return *bank.clock }
+	return b
+}
+
+func call(b *CircuitBreaker, n int) (err error) {
+	for i := 0; i < n; i++ {
+		_, err = b.ProcessPayment(context.Background(), "payment-ref", testPaymentRequest)
+	}
+	return err
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	failure := errors.New("bank down")
+
+	t.Run("stays closed below min calls", func(t *testing.T) {
+		bank := &fakeBank{err: failure}
+		b := newTestBreaker(bank)
+
+		call(b, 3)
+		assert.Equal(t, BreakerClosed, b.State())
+	})
+
+	t.Run("opens on failure rate and fails fast", func(t *testing.T) {
+		bank := &fakeBank{}
+		b := newTestBreaker(bank)
+
+		call(b, 2)
+		bank.err = failure
+		call(b, 2)
+		assert.Equal(t, BreakerOpen, b.State())
+
+		err := call(b, 1)
+		assert.ErrorIs(t, err, models.ErrAcquirerUnavailable)
+		assert.Equal(t, 4, bank.calls)
+	})
+
+	t.Run("opens on slow call rate", func(t *testing.T) {
+		bank := &fakeBank{delay: 2 * time.Second}
+		b := newTestBreaker(bank)
+
+		call(b, 4)
+		assert.Equal(t, BreakerOpen, b.State())
+	})
+
+	t.Run("closes after successful trial calls", func(t *testing.T) {
+		bank := &fakeBank{err: failure}
+		b := newTestBreaker(bank)
+
+		call(b, 4)
+		*bank.clock = bank.clock.Add(time.Minute)
+		assert.Equal(t, BreakerHalfOpen, b.State())
+
+		bank.err = nil
+		call(b, 1)
+		assert.Equal(t, BreakerHalfOpen, b.State())
+		call(b, 1)
+		assert.Equal(t, BreakerClosed, b.State())
+	})
+
+	t.Run("reopens when a trial call fails", func(t *testing.T) {
+		bank := &fakeBank{err: failure}
+		b := newTestBreaker(bank)
+
+		call(b, 4)
+		*bank.clock = bank.clock.Add(time.Minute)
+
+		err := call(b, 1)
+		assert.ErrorIs(t, err, failure)
+		assert.Equal(t, BreakerOpen, b.State())
+	})
+
+	t.Run("does not count unknown payments as failures", func(t *testing.T) {
+		bank := &fakeBank{err: ErrBankPaymentNotFound}
+		b := newTestBreaker(bank)
+
+		for i := 0; i < 4; i++ {
+			b.QueryPayment(context.Background(), "payment-ref")
+		}
+		assert.Equal(t, BreakerClosed, b.State())
+	})
+
+	t.Run("does not count calls cancelled by the caller", func(t *testing.T) {
+		bank := &fakeBank{err: context.Canceled}
+		b := newTestBreaker(bank)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for i := 0; i < 4; i++ {
+			b.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		}
+		assert.Equal(t, BreakerClosed, b.State())
+	})
+}
@@ -0,0 +1,276 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// ChaosFault is a failure the Chaos decorator injects in bank calls
+type ChaosFault string
+
+const (
+	// FaultNone lets the call through untouched
+	FaultNone ChaosFault = "none"
+	// FaultLatency delays the call by ChaosConfig.Latency
+	FaultLatency ChaosFault = "latency"
+	// FaultTimeout waits ChaosConfig.Timeout without calling the bank and
+	// fails like a bank that never answered
+	FaultTimeout ChaosFault = "timeout"
+	// FaultServerError answers with ChaosConfig.ErrorStatus without calling
+	// the bank
+	FaultServerError ChaosFault = "server_error"
+	// FaultMalformedResponse calls the bank and replaces its answer with
+	// invalid JSON
+	FaultMalformedResponse ChaosFault = "malformed_response"
+	// FaultDropConnection calls the bank and drops the connection before its
+	// answer arrives, so the payment may be authorized without the gateway
+	// knowing
+	FaultDropConnection ChaosFault = "drop_connection"
+)
+
+// ChaosFaults lists every fault the Chaos decorator can inject.
+var ChaosFaults = []ChaosFault{FaultLatency, FaultTimeout, FaultServerError, FaultMalformedResponse, FaultDropConnection}
+
+// ChaosConfig controls which faults the Chaos decorator injects. The zero
+// value injects none.
+type ChaosConfig struct {
+	// Rates is the probability of each fault on a call. They must not add up
+	// to more than 1.
+	Rates map[ChaosFault]float64
+	// Script lists the faults of the next calls in order. Rates only apply
+	// once the script has run out. FaultNone lets a call of the script through.
+	Script []ChaosFault
+	// Latency is the delay of FaultLatency
+	Latency time.Duration
+	// Timeout is how long FaultTimeout waits. It defaults to DefaultTimeout.
+	Timeout time.Duration
+	// ErrorStatus is the 5xx status of FaultServerError. It defaults to 503.
+	ErrorStatus int
+	// Queries injects the faults in QueryPayment calls too. Without it only
+	// ProcessPayment fails, so pending payments can still be reconciled.
+	Queries bool
+}
+
+// Validate reports the first invalid setting of c.
+func (c ChaosConfig) Validate() error {
+	total := 0.0
+	for fault, rate := range c.Rates {
+		if !knownFault(fault) {
+			return fmt.Errorf("unknown fault %q", fault)
+		}
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("rate of %s must be in [0, 1], got %g", fault, rate)
+		}
+		total += rate
+	}
+	if total > 1 {
+		return fmt.Errorf("fault rates must not add up to more than 1, got %g", total)
+	}
+	for _, fault := range c.Script {
+		if fault != FaultNone && !knownFault(fault) {
+			return fmt.Errorf("unknown fault %q in script", fault)
+		}
+	}
+	if c.Latency < 0 {
+		return fmt.Errorf("latency must not be negative, got %s", c.Latency)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative, got %s", c.Timeout)
+	}
+	if c.ErrorStatus != 0 && (c.ErrorStatus < 500 || c.ErrorStatus > 599) {
+		return fmt.Errorf("error status must be a 5xx status, got %d", c.ErrorStatus)
+	}
+	return nil
+}
+
+func knownFault(fault ChaosFault) bool {
+	for _, f := range ChaosFaults {
+		if f == fault {
+			return true
+		}
+	}
+	return false
+}
+
+// Chaos injects faults in the calls of the banks it wraps, to rehearse
+// outages of the acquirers. It sits between a Client and its CircuitBreaker,
+// so the faults are seen by the breaker, the payment service and the
+// reconciler, but not by the retries of the Client, which have already run.
+//
+// One Chaos controls every bank it wraps, and its config can be changed while
+// the gateway runs.
+type Chaos struct {
+	mu     sync.Mutex
+	cfg    ChaosConfig
+	script []ChaosFault
+}
+
+// NewChaos returns a Chaos injecting the faults of cfg, which must be valid.
+func NewChaos(cfg ChaosConfig) *Chaos {
+	c := &Chaos{}
+	c.set(cfg)
+	return c
+}
+
+// Config returns the current config, with the part of the script that has
+// not run yet.
+func (c *Chaos) Config() ChaosConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cfg := c.cfg
+	cfg.Rates = make(map[ChaosFault]float64, len(c.cfg.Rates))
+	for fault, rate := range c.cfg.Rates {
+		cfg.Rates[fault] = rate
+	}
+	cfg.Script = append([]ChaosFault(nil), c.script...)
+	return cfg
+}
+
+// SetConfig replaces the config, restarting the script.
+func (c *Chaos) SetConfig(cfg ChaosConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	c.set(cfg)
+	return nil
+}
+
+func (c *Chaos) set(cfg ChaosConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cfg = cfg
+	c.cfg.Rates = make(map[ChaosFault]float64, len(cfg.Rates))
+	for fault, rate := range cfg.Rates {
+		c.cfg.Rates[fault] = rate
+	}
+	c.script = append([]ChaosFault(nil), cfg.Script...)
+}
+
+// Wrap returns next with the faults of c injected in its calls.
+func (c *Chaos) Wrap(next Bank) Bank {
+	return &chaosBank{chaos: c, next: next}
+}
+
+// draw picks the fault of the next call, along with the config it applies with.
+func (c *Chaos) draw(query bool) (ChaosFault, ChaosConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if query && !c.cfg.Queries {
+		return FaultNone, c.cfg
+	}
+	if len(c.script) > 0 {
+		fault := c.script[0]
+		c.script = c.script[1:]
+		return fault, c.cfg
+	}
+
+	// each fault takes its own slice of [0, 1)
+	p := rand.Float64()
+	for _, fault := range ChaosFaults {
+		if p < c.cfg.Rates[fault] {
+			return fault, c.cfg
+		}
+		p -= c.cfg.Rates[fault]
+	}
+	return FaultNone, c.cfg
+}
+
+// chaosBank is a Bank wrapped by a Chaos
+type chaosBank struct {
+	chaos *Chaos
+	next  Bank
+}
+
+func (b *chaosBank) ProcessPayment(ctx context.Context, reference string, req models.PaymentRequest) (*BankResponse, error) {
+	return b.call(ctx, false, reference, func() (*BankResponse, error) {
+		return b.next.ProcessPayment(ctx, reference, req)
+	})
+}
+
+func (b *chaosBank) QueryPayment(ctx context.Context, reference string) (*BankResponse, error) {
+	return b.call(ctx, true, reference, func() (*BankResponse, error) {
+		return b.next.QueryPayment(ctx, reference)
+	})
+}
+
+// call runs fn with the next fault of the Chaos injected.
+func (b *chaosBank) call(ctx context.Context, query bool, reference string, fn func() (*BankResponse, error)) (*BankResponse, error) {
+	fault, cfg := b.chaos.draw(query)
+	if fault == FaultNone {
+		return fn()
+	}
+
+	slog.WarnContext(ctx, "injecting bank fault",
+		slog.String("fault", string(fault)),
+		slog.String("reference", reference),
+		slog.Bool("query", query))
+
+	switch fault {
+	case FaultLatency:
+		if err := sleep(ctx, cfg.Latency); err != nil {
+			return nil, err
+		}
+		return fn()
+	case FaultTimeout:
+		timeout := cfg.Timeout
+		if timeout == 0 {
+			timeout = DefaultTimeout
+		}
+		if err := sleep(ctx, timeout); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to send request to bank: %w", context.DeadlineExceeded)
+	case FaultServerError:
+		status := cfg.ErrorStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		return decodeBankResponse(fakeResponse(status, "{}"))
+	case FaultMalformedResponse:
+		if resp, err := fn(); err != nil {
+			return resp, err
+		}
+		return decodeBankResponse(fakeResponse(http.StatusOK, `{"authorized":tr`))
+	case FaultDropConnection:
+		if resp, err := fn(); err != nil {
+			return resp, err
+		}
+		return nil, fmt.Errorf("failed to read response body: %w", io.ErrUnexpectedEOF)
+	}
+	return nil, errors.New("unknown fault " + string(fault))
+}
+
+// fakeResponse is an answer of the bank decoded as the Client would.
+func fakeResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,174 @@
+package bank
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ChaosConfig
+		wantErr string
+	}{
+		{name: "zero value"},
+		{name: "rates and script", cfg: ChaosConfig{
+			Rates:  map[ChaosFault]float64{FaultServerError: 0.5, FaultLatency: 0.5},
+			Script: []ChaosFault{FaultTimeout, FaultNone, FaultDropConnection},
+		}},
+		{name: "unknown fault", cfg: ChaosConfig{Rates: map[ChaosFault]float64{"fire": 0.1}}, wantErr: `unknown fault "fire"`},
+		{name: "none has no rate", cfg: ChaosConfig{Rates: map[ChaosFault]float64{FaultNone: 0.1}}, wantErr: `unknown fault "none"`},
+		{name: "rate above 1", cfg: ChaosConfig{Rates: map[ChaosFault]float64{FaultTimeout: 1.5}}, wantErr: "rate of timeout"},
+		{name: "rates add up above 1", cfg: ChaosConfig{Rates: map[ChaosFault]float64{FaultTimeout: 0.6, FaultLatency: 0.6}}, wantErr: "add up"},
+		{name: "unknown fault in script", cfg: ChaosConfig{Script: []ChaosFault{"fire"}}, wantErr: "in script"},
+		{name: "negative latency", cfg: ChaosConfig{Latency: -time.Second}, wantErr: "latency"},
+		{name: "client error status", cfg: ChaosConfig{ErrorStatus: http.StatusBadRequest}, wantErr: "5xx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestChaosFaults(t *testing.T) {
+	tests := []struct {
+		fault     ChaosFault
+		wantErr   func(t *testing.T, err error)
+		wantCalls int
+	}{
+		{
+			fault:     FaultNone,
+			wantCalls: 1,
+		},
+		{
+			fault:     FaultLatency,
+			wantCalls: 1,
+		},
+		{
+			fault:   FaultTimeout,
+			wantErr: func(t *testing.T, err error) { assert.ErrorIs(t, err, context.DeadlineExceeded) },
+		},
+		{
+			fault:   FaultServerError,
+			wantErr: func(t *testing.T, err error) { assert.ErrorIs(t, err, ErrBankUnavailable) },
+		},
+		{
+			fault:     FaultMalformedResponse,
+			wantErr:   func(t *testing.T, err error) { assert.ErrorContains(t, err, "failed to unmarshal") },
+			wantCalls: 1,
+		},
+		{
+			fault:     FaultDropConnection,
+			wantErr:   func(t *testing.T, err error) { assert.ErrorIs(t, err, io.ErrUnexpectedEOF) },
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.fault), func(t *testing.T) {
+			acquirer := &stubAcquirer{}
+			chaos := NewChaos(ChaosConfig{
+				Script:  []ChaosFault{tt.fault},
+				Latency: 10 * time.Millisecond,
+				Timeout: 10 * time.Millisecond,
+			})
+
+			start := time.Now()
+			resp, err := chaos.Wrap(acquirer).ProcessPayment(context.Background(), "payment-ref", testPaymentRequest)
+			elapsed := time.Since(start)
+
+			assert.Equal(t, tt.wantCalls, acquirer.calls)
+			if tt.wantErr != nil {
+				tt.wantErr(t, err)
+				assert.Nil(t, resp)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, resp.Authorized)
+			}
+			if tt.fault == FaultLatency || tt.fault == FaultTimeout {
+				assert.GreaterOrEqual(t, elapsed, 10*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestChaosScript(t *testing.T) {
+	acquirer := &stubAcquirer{}
+	chaos := NewChaos(ChaosConfig{
+		Script: []ChaosFault{FaultServerError, FaultNone, FaultServerError},
+	})
+	bank := chaos.Wrap(acquirer)
+
+	var errs []error
+	for i := 0; i < 4; i++ {
+		_, err := bank.ProcessPayment(context.Background(), "payment-ref", testPaymentRequest)
+		errs = append(errs, err)
+	}
+	assert.Error(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Error(t, errs[2])
+	assert.NoError(t, errs[3], "no fault once the script has run out")
+	assert.Equal(t, 2, acquirer.calls)
+
+	assert.Empty(t, chaos.Config().Script)
+	require.NoError(t, chaos.SetConfig(ChaosConfig{Script: []ChaosFault{FaultServerError}}))
+	assert.Equal(t, []ChaosFault{FaultServerError}, chaos.Config().Script, "setting the config restarts the script")
+	assert.Error(t, chaos.SetConfig(ChaosConfig{Script: []ChaosFault{"fire"}}))
+}
+
+func TestChaosRates(t *testing.T) {
+	acquirer := &stubAcquirer{}
+	chaos := NewChaos(ChaosConfig{Rates: map[ChaosFault]float64{FaultServerError: 0.5}})
+	bank := chaos.Wrap(acquirer)
+
+	failed := 0
+	for i := 0; i < 400; i++ {
+		if _, err := bank.ProcessPayment(context.Background(), "payment-ref", testPaymentRequest); err != nil {
+			failed++
+		}
+	}
+	assert.InDelta(t, 200, failed, 60)
+	assert.Equal(t, 400-failed, acquirer.calls)
+}
+
+func TestChaosQueries(t *testing.T) {
+	always := map[ChaosFault]float64{FaultServerError: 1}
+
+	_, err := NewChaos(ChaosConfig{Rates: always}).Wrap(&stubAcquirer{}).QueryPayment(context.Background(), "payment-ref")
+	assert.NoError(t, err, "queries are left alone by default")
+
+	_, err = NewChaos(ChaosConfig{Rates: always, Queries: true}).Wrap(&stubAcquirer{}).QueryPayment(context.Background(), "payment-ref")
+	assert.ErrorIs(t, err, ErrBankUnavailable)
+}
+
+func TestChaosOpensBreaker(t *testing.T) {
+	chaos := NewChaos(ChaosConfig{Rates: map[ChaosFault]float64{FaultServerError: 1}})
+	breaker := NewCircuitBreaker(chaos.Wrap(&stubAcquirer{}), testBreakerConfig)
+
+	for i := 0; i < testBreakerConfig.MinCalls; i++ {
+		breaker.ProcessPayment(context.Background(), "payment-ref", testPaymentRequest)
+	}
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
+
+func TestChaosTimeoutHonoursContext(t *testing.T) {
+	chaos := NewChaos(ChaosConfig{Script: []ChaosFault{FaultTimeout}, Timeout: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := chaos.Wrap(&stubAcquirer{}).ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -4,18 +4,33 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
+	"syscall"
 	"time"
 
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/logging"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/metrics"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	defaultBankURL = "http://localhost:8080"
+	// DefaultBankURL is where the bank simulator listens
+	DefaultBankURL = "http://localhost:8080"
+	// DefaultTimeout bounds every attempt of a bank call
+	DefaultTimeout = 10 * time.Second
 )
 
 // BankRequest represents the request format expected by the bank simulator
@@ -27,35 +42,104 @@ type BankRequest struct {
 	Cvv        string `json:"cvv"`
 }
 
+// LogValue leaves the card number, expiry date and CVV out of log records.
+func (r BankRequest) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("card_number_last_four", utils.GetLastFourDigits(r.CardNumber)),
+		slog.String("currency", r.Currency),
+		slog.Int("amount", r.Amount),
+	)
+}
+
+// String formats the request without its card data.
+func (r BankRequest) String() string {
+	return fmt.Sprintf("{CardNumber:****%s Currency:%s Amount:%d}", utils.GetLastFourDigits(r.CardNumber), r.Currency, r.Amount)
+}
+
+// GoString is String for %#v.
+func (r BankRequest) GoString() string {
+	return "bank.BankRequest" + r.String()
+}
+
 // BankResponse represents the response from the bank simulator
 type BankResponse struct {
 	Authorized        bool   `json:"authorized"`
 	AuthorizationCode string `json:"authorization_code"`
+	// ResponseCode is the ISO 8583 response code of declines, when the bank sends one
+	ResponseCode string `json:"response_code,omitempty"`
+	// DeclineReason is ResponseCode mapped by the Client for declined payments
+	DeclineReason models.DeclineReason `json:"-"`
+	// Acquirer is the name of the acquirer that answered, set by the Router
+	Acquirer string `json:"-"`
 }
 
+// IdempotencyKeyHeader carries the payment reference so the bank can
+// recognise retries of the same payment
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 // Client handles communication with the acquiring bank
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	retry      RetryPolicy
+	metrics    *metrics.Metrics
+	acquirer   string
+}
+
+// ClientOption configures optional Client behaviour
+type ClientOption func(*Client)
+
+// WithRetryPolicy sets how failed bank calls are retried. It defaults to
+// DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithTimeout bounds every attempt of a bank call to timeout. It defaults to
+// DefaultTimeout.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithMetrics records the latency and errors of every attempt in m, labelled
+// with the acquirer name.
+func WithMetrics(m *metrics.Metrics, acquirer string) ClientOption {
+	return func(c *Client) {
+		c.metrics = m
+		c.acquirer = acquirer
+	}
 }
 
 // NewClient creates a new bank client
-func NewClient(url *string) *Client {
-	baseUrl := defaultBankURL
+func NewClient(url *string, opts ...ClientOption) *Client {
+	baseUrl := DefaultBankURL
 	if url != nil && *url != "" {
 		baseUrl = *url
 	}
 
-	return &Client{
+	c := &Client{
 		baseURL: baseUrl,
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: DefaultTimeout,
 		},
+		retry: DefaultRetryPolicy,
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
-// ProcessPayment sends a payment request to the acquiring bank
-func (c *Client) ProcessPayment(ctx context.Context, req models.PaymentRequest) (*BankResponse, error) {
+// ProcessPayment sends a payment request to the acquiring bank. Failures that
+// are safe to retry are retried according to the client's RetryPolicy, and
+// every attempt carries reference in the Idempotency-Key header.
+func (c *Client) ProcessPayment(ctx context.Context, reference string, req models.PaymentRequest) (*BankResponse, error) {
 	// Convert payment request to bank request format
 	bankReq := BankRequest{
 		CardNumber: req.CardNumber,
@@ -71,26 +155,164 @@ func (c *Client) ProcessPayment(ctx context.Context, req models.PaymentRequest)
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	// Create HTTP request
+	// Create HTTP request URL
 	u, err := url.JoinPath(c.baseURL, "payments")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bank URL: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewBuffer(jsonData))
+	return c.call(ctx, "bank.Client.ProcessPayment", "POST", u, reference, jsonData)
+}
+
+// QueryPayment asks the acquiring bank for the outcome of the payment sent
+// with reference. It returns ErrBankPaymentNotFound if the bank never received it.
+func (c *Client) QueryPayment(ctx context.Context, reference string) (*BankResponse, error) {
+	u, err := url.JoinPath(c.baseURL, "payments", url.PathEscape(reference))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bank URL: %w", err)
+	}
+
+	return c.call(ctx, "bank.Client.QueryPayment", "GET", u, reference, nil)
+}
+
+// Ping tells whether the acquiring bank can be reached. Any HTTP answer counts,
+// whatever its status: the bank is only unreachable when no answer arrives.
+// Pings are neither retried nor recorded in the bank call metrics.
+func (c *Client) Ping(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", c.baseURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("bank is unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// call sends a request to the bank, retrying it according to the client's
+// RetryPolicy, in a span named spanName.
+func (c *Client) call(ctx context.Context, spanName, method, u, reference string, jsonData []byte) (_ *BankResponse, err error) {
+	ctx, span := tracing.Start(ctx, spanName, trace.WithAttributes(
+		attribute.String("payment.id", reference),
+		attribute.String("bank.acquirer", c.acquirer),
+	))
+	defer func() { tracing.End(span, err) }()
+
+	var bankResp *BankResponse
+	err = c.retry.do(ctx, func() error {
+		var err error
+		bankResp, err = c.send(ctx, method, u, reference, jsonData)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bankResp, nil
+}
+
+// send makes a single attempt of a bank request in a client span, and logs
+// and records its outcome.
+func (c *Client) send(ctx context.Context, method, u, reference string, jsonData []byte) (*BankResponse, error) {
+	ctx, span := tracing.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(method), semconv.URLFull(u)))
+
+	start := time.Now()
+	bankResp, statusCode, err := c.do(ctx, method, u, reference, jsonData)
+	duration := time.Since(start)
+
+	operation := "process_payment"
+	if method == "GET" {
+		operation = "query_payment"
+	}
+	class := errorClass(statusCode, err)
+	c.metrics.ObserveBankCall(c.acquirer, operation, class, duration)
+
+	if statusCode != 0 {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(statusCode))
+	}
+	if class != "" {
+		span.SetAttributes(semconv.ErrorTypeKey.String(class))
+	}
+	tracing.End(span, err)
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("url", u),
+		slog.String("reference", reference),
+		slog.Int("status", statusCode),
+		slog.Duration("duration", duration),
+	}
+	if err != nil {
+		slog.LogAttrs(ctx, slog.LevelWarn, "bank call failed", append(attrs, slog.Any("error", err))...)
+	} else {
+		slog.LogAttrs(ctx, slog.LevelInfo, "bank call", attrs...)
+	}
+	return bankResp, err
+}
+
+// do sends the request and returns the decoded response along with the HTTP
+// status code, which is 0 if no response was received.
+func (c *Client) do(ctx context.Context, method, u, reference string, jsonData []byte) (*BankResponse, int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(jsonData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, 0, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
+	if jsonData != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set(IdempotencyKeyHeader, reference)
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		httpReq.Header.Set(logging.RequestIDHeader, requestID)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 
 	// Send request
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request to bank: %w", err)
+		return nil, 0, classifyTransportError(fmt.Errorf("failed to send request to bank: %w", err))
 	}
 	defer resp.Body.Close()
 
+	bankResp, err := decodeBankResponse(resp)
+	return bankResp, resp.StatusCode, err
+}
+
+// errorClass sorts the outcome of a bank call attempt into a small set of
+// classes for metrics. It returns "" for successful attempts.
+func errorClass(statusCode int, err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrBankPaymentNotFound):
+		return "not_found"
+	case errors.Is(err, ErrBankUnavailable):
+		return "unavailable"
+	case errors.Is(err, ErrBankRateLimited):
+		return "rate_limited"
+	case statusCode == http.StatusOK:
+		return "invalid_response"
+	case statusCode != 0:
+		return "error_status"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), os.IsTimeout(err):
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	default:
+		return "transport"
+	}
+}
+
+// decodeBankResponse maps the bank's answer to a BankResponse or an error.
+func decodeBankResponse(resp *http.Response) (*BankResponse, error) {
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -99,8 +321,16 @@ func (c *Client) ProcessPayment(ctx context.Context, req models.PaymentRequest)
 
 	// Handle non-200 status codes
 	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == http.StatusServiceUnavailable {
-			return nil, fmt.Errorf("bank service unavailable")
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, ErrBankPaymentNotFound
+		case http.StatusServiceUnavailable:
+			return nil, &retryableError{err: ErrBankUnavailable}
+		case http.StatusTooManyRequests:
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				return nil, &retryableError{err: ErrBankRateLimited, retryAfter: retryAfter}
+			}
+			return nil, ErrBankRateLimited
 		}
 		return nil, fmt.Errorf("bank returned error status %d: %s", resp.StatusCode, string(body))
 	}
@@ -110,6 +340,9 @@ func (c *Client) ProcessPayment(ctx context.Context, req models.PaymentRequest)
 	if err := json.Unmarshal(body, &bankResp); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
+	if !bankResp.Authorized {
+		bankResp.DeclineReason = DeclineReason(bankResp.ResponseCode)
+	}
 
 	return &bankResp, nil
 }
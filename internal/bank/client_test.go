@@ -0,0 +1,318 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/logging"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    50 * time.Millisecond,
+}
+
+var testPaymentRequest = models.PaymentRequest{
+	CardNumber:  "2222405343248877",
+	ExpiryMonth: 4,
+	ExpiryYear:  2035,
+	Currency:    "GBP",
+	Amount:      100,
+	Cvv:         "123",
+}
+
+// scriptedBank replies with the given status codes in order and then with an
+// authorization, recording the Idempotency-Key of every request.
+type scriptedBank struct {
+	mu         sync.Mutex
+	statuses   []int
+	headers    []http.Header
+	references []string
+}
+
+func (b *scriptedBank) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.references = append(b.references, r.Header.Get(IdempotencyKeyHeader))
+	attempt := len(b.references) - 1
+
+	if attempt < len(b.statuses) {
+		if attempt < len(b.headers) {
+			for k, v := range b.headers[attempt] {
+				w.Header()[k] = v
+			}
+		}
+		w.WriteHeader(b.statuses[attempt])
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"authorized":true,"authorization_code":"auth-code"}`))
+}
+
+func newTestClient(t *testing.T, handler http.Handler, policy RetryPolicy) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient(&server.URL, WithRetryPolicy(policy))
+}
+
+func TestClientRetries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retries 503 with the same reference", func(t *testing.T) {
+		bank := &scriptedBank{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+		client := newTestClient(t, bank, testRetryPolicy)
+
+		resp, err := client.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		require.NoError(t, err)
+		assert.True(t, resp.Authorized)
+		assert.Equal(t, []string{"payment-ref", "payment-ref", "payment-ref"}, bank.references)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		bank := &scriptedBank{statuses: []int{503, 503, 503, 503}}
+		client := newTestClient(t, bank, testRetryPolicy)
+
+		_, err := client.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		assert.ErrorIs(t, err, ErrBankUnavailable)
+		assert.Len(t, bank.references, 3)
+	})
+
+	t.Run("retries 429 after Retry-After", func(t *testing.T) {
+		bank := &scriptedBank{
+			statuses: []int{http.StatusTooManyRequests},
+			headers:  []http.Header{{"Retry-After": []string{"0"}}},
+		}
+		client := newTestClient(t, bank, testRetryPolicy)
+
+		_, err := client.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		require.NoError(t, err)
+		assert.Len(t, bank.references, 2)
+	})
+
+	t.Run("does not retry 429 without Retry-After", func(t *testing.T) {
+		bank := &scriptedBank{statuses: []int{http.StatusTooManyRequests}}
+		client := newTestClient(t, bank, testRetryPolicy)
+
+		_, err := client.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		assert.ErrorIs(t, err, ErrBankRateLimited)
+		assert.Len(t, bank.references, 1)
+	})
+
+	t.Run("does not wait for Retry-After beyond max delay", func(t *testing.T) {
+		bank := &scriptedBank{
+			statuses: []int{http.StatusTooManyRequests},
+			headers:  []http.Header{{"Retry-After": []string{"120"}}},
+		}
+		client := newTestClient(t, bank, testRetryPolicy)
+
+		_, err := client.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		assert.ErrorIs(t, err, ErrBankRateLimited)
+		assert.Len(t, bank.references, 1)
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		bank := &scriptedBank{statuses: []int{http.StatusInternalServerError}}
+		client := newTestClient(t, bank, testRetryPolicy)
+
+		_, err := client.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		assert.Error(t, err)
+		assert.Len(t, bank.references, 1)
+	})
+
+	t.Run("stops before the context deadline", func(t *testing.T) {
+		bank := &scriptedBank{statuses: []int{503, 503, 503}}
+		client := newTestClient(t, bank, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+
+		start := time.Now()
+		_, err := client.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		assert.ErrorIs(t, err, ErrBankUnavailable)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("retries refused connections", func(t *testing.T) {
+		// reserve a port and close it so that connecting to it is refused
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := "http://" + l.Addr().String()
+		l.Close()
+
+		client := NewClient(&addr, WithRetryPolicy(testRetryPolicy))
+
+		start := time.Now()
+		_, err = client.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		assert.Error(t, err)
+
+		var retryable *retryableError
+		assert.ErrorAs(t, err, &retryable)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestClientQueryPayment(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/payments/known-ref", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		w.Write([]byte(`{"authorized":true,"authorization_code":"auth-code"}`))
+	})
+	client := newTestClient(t, mux, testRetryPolicy)
+
+	resp, err := client.QueryPayment(ctx, "known-ref")
+	require.NoError(t, err)
+	assert.Equal(t, "auth-code", resp.AuthorizationCode)
+
+	_, err = client.QueryPayment(ctx, "unknown-ref")
+	assert.ErrorIs(t, err, ErrBankPaymentNotFound)
+}
+
+func TestClientForwardsRequestID(t *testing.T) {
+	var received string
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(logging.RequestIDHeader)
+		w.Write([]byte(`{"authorized":true,"authorization_code":"auth-code"}`))
+	}), testRetryPolicy)
+
+	_, err := client.ProcessPayment(logging.WithRequestID(context.Background(), "request-1"), "ref", testPaymentRequest)
+	require.NoError(t, err)
+	assert.Equal(t, "request-1", received)
+}
+
+func TestClientPing(t *testing.T) {
+	ctx := context.Background()
+
+	// the bank simulator only knows its payment routes, any answer will do
+	client := newTestClient(t, http.NotFoundHandler(), testRetryPolicy)
+	assert.NoError(t, client.Ping(ctx))
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	assert.Error(t, NewClient(&server.URL).Ping(ctx))
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		err        error
+		want       string
+	}{
+		{name: "success", statusCode: http.StatusOK, want: ""},
+		{name: "not found", statusCode: http.StatusNotFound, err: ErrBankPaymentNotFound, want: "not_found"},
+		{name: "unavailable", statusCode: http.StatusServiceUnavailable, err: &retryableError{err: ErrBankUnavailable}, want: "unavailable"},
+		{name: "rate limited", statusCode: http.StatusTooManyRequests, err: ErrBankRateLimited, want: "rate_limited"},
+		{name: "bad body", statusCode: http.StatusOK, err: errors.New("failed to unmarshal response"), want: "invalid_response"},
+		{name: "error status", statusCode: http.StatusBadRequest, err: errors.New("bank returned error status 400"), want: "error_status"},
+		{name: "timeout", err: fmt.Errorf("failed to send request to bank: %w", context.DeadlineExceeded), want: "timeout"},
+		{name: "canceled", err: fmt.Errorf("failed to send request to bank: %w", context.Canceled), want: "canceled"},
+		{name: "refused", err: &retryableError{err: syscall.ECONNREFUSED}, want: "connection_refused"},
+		{name: "other", err: errors.New("no such host"), want: "transport"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, errorClass(tt.statusCode, tt.err))
+		})
+	}
+}
+
+func TestOutcomeUnknown(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "success", want: false},
+		{name: "timeout", err: fmt.Errorf("failed to send request to bank: %w", context.DeadlineExceeded), want: true},
+		{name: "canceled", err: fmt.Errorf("failed to send request to bank: %w", context.Canceled), want: true},
+		{name: "connection dropped", err: fmt.Errorf("failed to read response body: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "connection reset", err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, want: true},
+		{name: "dial timeout", err: &net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded}, want: false},
+		{name: "refused", err: &retryableError{err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, want: false},
+		{name: "unavailable", err: &retryableError{err: ErrBankUnavailable}, want: false},
+		{name: "breaker open", err: fmt.Errorf("acquirer simulator: %w", models.ErrAcquirerUnavailable), want: false},
+		{name: "payment not found", err: fmt.Errorf("acquirer simulator: %w", ErrBankPaymentNotFound), want: false},
+		{name: "rate limited with retry after", err: &retryableError{err: ErrBankRateLimited, retryAfter: time.Second}, want: false},
+		{name: "rate limited", err: ErrBankRateLimited, want: true},
+		{name: "error status", err: errors.New("bank returned error status 400"), want: true},
+		{name: "internal server error", err: errors.New("bank returned error status 500: {}"), want: true},
+		{name: "bad gateway", err: errors.New("bank returned error status 502: {}"), want: true},
+		{name: "bad body", err: errors.New("failed to unmarshal response"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, OutcomeUnknown(tt.err))
+		})
+	}
+}
+
+func TestClientOutcomeUnknown(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("connection dropped after the request", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+		}), NoRetries)
+
+		_, err := client.ProcessPayment(ctx, "ref-1", testPaymentRequest)
+		assert.True(t, OutcomeUnknown(err), err)
+	})
+
+	t.Run("bank down", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		_, err := NewClient(&server.URL, WithRetryPolicy(NoRetries)).ProcessPayment(ctx, "ref-1", testPaymentRequest)
+		assert.False(t, OutcomeUnknown(err), err)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	d, ok := parseRetryAfter("3", now)
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, d)
+
+	d, ok = parseRetryAfter(now.Add(5*time.Second).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, d)
+
+	_, ok = parseRetryAfter("", now)
+	assert.False(t, ok)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+}
+
+func TestBackoffIsBounded(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond}
+
+	for attempt := 1; attempt < 70; attempt++ {
+		d := p.backoff(attempt)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, 40*time.Millisecond)
+	}
+}
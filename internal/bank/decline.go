@@ -0,0 +1,31 @@
+package bank
+
+import "github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+
+// declineReasons maps the ISO 8583 response codes acquirers decline with to
+// the gateway's decline reasons. Lost and stolen cards are reported as
+// suspected fraud so that the merchant does not tell the cardholder more
+// than the issuer would.
+var declineReasons = map[string]models.DeclineReason{
+	"05": models.DeclineDoNotHonour,
+	"51": models.DeclineInsufficientFunds,
+	"33": models.DeclineExpiredCard,
+	"54": models.DeclineExpiredCard,
+	"04": models.DeclineSuspectedFraud,
+	"07": models.DeclineSuspectedFraud,
+	"34": models.DeclineSuspectedFraud,
+	"41": models.DeclineSuspectedFraud,
+	"43": models.DeclineSuspectedFraud,
+	"59": models.DeclineSuspectedFraud,
+	"82": models.DeclineInvalidCvv,
+	"N7": models.DeclineInvalidCvv,
+}
+
+// DeclineReason returns the decline reason for an acquirer response code.
+// Missing and unknown codes are reported as do_not_honour, the generic decline.
+func DeclineReason(responseCode string) models.DeclineReason {
+	if reason, ok := declineReasons[responseCode]; ok {
+		return reason
+	}
+	return models.DeclineDoNotHonour
+}
@@ -0,0 +1,98 @@
+package bank
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclineReason(t *testing.T) {
+	tests := []struct {
+		code string
+		want models.DeclineReason
+	}{
+		{"51", models.DeclineInsufficientFunds},
+		{"05", models.DeclineDoNotHonour},
+		{"54", models.DeclineExpiredCard},
+		{"59", models.DeclineSuspectedFraud},
+		{"43", models.DeclineSuspectedFraud},
+		{"N7", models.DeclineInvalidCvv},
+		{"", models.DeclineDoNotHonour},
+		{"ZZ", models.DeclineDoNotHonour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			assert.Equal(t, tt.want, DeclineReason(tt.code))
+		})
+	}
+}
+
+func TestClientDeclineReasons(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want models.DeclineReason
+	}{
+		{name: "authorized", body: `{"authorized":true,"authorization_code":"auth-code"}`},
+		{name: "declined with code", body: `{"authorized":false,"authorization_code":"","response_code":"51"}`, want: models.DeclineInsufficientFunds},
+		{name: "declined without code", body: `{"authorized":false,"authorization_code":""}`, want: models.DeclineDoNotHonour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}), testRetryPolicy)
+
+			resp, err := client.ProcessPayment(context.Background(), "ref", testPaymentRequest)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, resp.DeclineReason)
+		})
+	}
+}
+
+// TestDeclinesImposter checks that the bank simulator declines with known
+// response codes and covers every decline reason.
+func TestDeclinesImposter(t *testing.T) {
+	data, err := os.ReadFile("../../imposters/bank_simulator.ejs")
+	require.NoError(t, err)
+
+	var config struct {
+		Imposters []struct {
+			Stubs []struct {
+				Responses []struct {
+					Is struct {
+						Body struct {
+							Authorized   *bool  `json:"authorized"`
+							ResponseCode string `json:"response_code"`
+						} `json:"body"`
+					} `json:"is"`
+				} `json:"responses"`
+			} `json:"stubs"`
+		} `json:"imposters"`
+	}
+	require.NoError(t, json.Unmarshal(data, &config))
+	require.Len(t, config.Imposters, 1)
+
+	covered := map[models.DeclineReason]bool{}
+	for _, stub := range config.Imposters[0].Stubs {
+		for _, response := range stub.Responses {
+			body := response.Is.Body
+			if body.Authorized == nil || *body.Authorized {
+				continue
+			}
+			assert.Contains(t, declineReasons, body.ResponseCode, "declines must carry a mapped response code")
+			covered[DeclineReason(body.ResponseCode)] = true
+		}
+	}
+	for _, reason := range models.DeclineReasons {
+		assert.True(t, covered[reason], "no card is declined with %s", reason)
+	}
+}
@@ -0,0 +1,134 @@
+package bank
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBankUnavailable = errors.New("bank service unavailable")
+	ErrBankRateLimited = errors.New("bank rate limit exceeded")
+)
+
+// RetryPolicy controls how failed bank calls are retried. Only failures where
+// the bank cannot have processed the payment are retried: refused connections,
+// 503 responses and 429 responses carrying a Retry-After header.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry. It doubles on every
+	// further retry and is randomised with full jitter.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. A Retry-After delay longer than MaxDelay is
+	// not waited for and the call fails instead.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by clients created without WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
+
+// NoRetries makes a single attempt.
+var NoRetries = RetryPolicy{MaxAttempts: 1}
+
+// retryableError marks a failure that is safe to retry, optionally with the
+// delay requested by the bank.
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// classifyTransportError wraps errors from http.Client.Do that are safe to retry.
+func classifyTransportError(err error) error {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return &retryableError{err: err}
+	}
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an
+// HTTP date. ok is false if the header is missing or invalid.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// backoff returns the delay before retry number attempt (starting at 1).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	ceiling := p.MaxDelay
+	if shift := attempt - 1; shift < 32 {
+		if d := p.BaseDelay << shift; d > 0 && (p.MaxDelay <= 0 || d < p.MaxDelay) {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		ceiling = p.BaseDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// do runs call until it succeeds, fails with an error that is not retryable,
+// runs out of attempts or the next delay would pass the context deadline.
+func (p RetryPolicy) do(ctx context.Context, call func() error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = call()
+
+		var retryable *retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt >= attempts {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if retryable.retryAfter > 0 {
+			if p.MaxDelay > 0 && retryable.retryAfter > p.MaxDelay {
+				return err
+			}
+			delay = retryable.retryAfter
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package bank
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// AcquirerConfig describes an acquiring bank reachable over HTTP
+type AcquirerConfig struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// WeightedAcquirer is an acquirer taking Weight parts of a split
+type WeightedAcquirer struct {
+	Acquirer string `json:"acquirer"`
+	Weight   int    `json:"weight"`
+}
+
+// Rule sends the payments matching all of its conditions to Acquirer, or to
+// one of the Split acquirers in proportion to their weights. Empty conditions
+// match every payment.
+type Rule struct {
+	Name       string        `json:"name"`
+	Currencies []string      `json:"currencies,omitempty"`
+	Schemes    []card.Scheme `json:"schemes,omitempty"`
+	// Countries are ISO 3166 alpha-2 codes of the issuing country, looked up
+	// from the card BIN in RoutingConfig.BINCountries
+	Countries []string `json:"countries,omitempty"`
+	// MinAmount and MaxAmount bound the amount in minor units. A zero MaxAmount
+	// has no upper bound.
+	MinAmount int                `json:"min_amount,omitempty"`
+	MaxAmount int                `json:"max_amount,omitempty"`
+	Acquirer  string             `json:"acquirer,omitempty"`
+	Split     []WeightedAcquirer `json:"split,omitempty"`
+	// Fallback takes the payment when the chosen acquirer is unavailable
+	Fallback string `json:"fallback,omitempty"`
+}
+
+// RoutingConfig lists the acquirers and the rules choosing between them
+type RoutingConfig struct {
+	Acquirers []AcquirerConfig `json:"acquirers"`
+	// Default takes the payments no rule matches, and Fallback takes them when
+	// Default is unavailable
+	Default  string `json:"default"`
+	Fallback string `json:"fallback,omitempty"`
+	Rules    []Rule `json:"rules,omitempty"`
+	// BINCountries maps card number prefixes to the issuing country. The
+	// longest matching prefix wins.
+	BINCountries map[string]string `json:"bin_countries,omitempty"`
+}
+
+// DefaultRoutingConfig sends every payment to the bank simulator.
+var DefaultRoutingConfig = RoutingConfig{
+	Acquirers: []AcquirerConfig{{Name: "simulator", URL: DefaultBankURL}},
+	Default:   "simulator",
+}
+
+// LoadRoutingConfig reads a JSON RoutingConfig from path.
+func LoadRoutingConfig(path string) (RoutingConfig, error) {
+	var cfg RoutingConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read routing file: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse routing file: %w", err)
+	}
+
+	// the client falls back to DefaultBankURL for URLs it cannot use, which
+	// would silently send the payments to the wrong bank
+	for _, acquirer := range cfg.Acquirers {
+		u, err := url.Parse(acquirer.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return cfg, fmt.Errorf("acquirer %q: url must be an absolute http or https URL, got %q", acquirer.Name, acquirer.URL)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Registry holds the acquirers payments can be routed to, keyed by name.
+type Registry struct {
+	acquirers map[string]Bank
+}
+
+func NewRegistry() *Registry {
+	return &Registry{acquirers: make(map[string]Bank)}
+}
+
+// Register adds acquirer under name. Names must be unique.
+func (r *Registry) Register(name string, acquirer Bank) error {
+	if name == "" {
+		return errors.New("acquirer name must not be empty")
+	}
+	if _, exists := r.acquirers[name]; exists {
+		return fmt.Errorf("acquirer %q is already registered", name)
+	}
+	r.acquirers[name] = acquirer
+	return nil
+}
+
+// Get returns the acquirer registered under name.
+func (r *Registry) Get(name string) (Bank, bool) {
+	acquirer, ok := r.acquirers[name]
+	return acquirer, ok
+}
+
+// Names returns the registered acquirer names in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.acquirers))
+	for name := range r.acquirers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Router is a Bank that sends each payment to the acquirer chosen by the
+// first matching rule, and fails over to the rule's fallback when the chosen
+// acquirer is unavailable before it could authorize the payment.
+type Router struct {
+	registry *Registry
+	cfg      RoutingConfig
+}
+
+// route is the outcome of evaluating the rules for a payment
+type route struct {
+	acquirer string
+	fallback string
+}
+
+// NewRouter checks that every acquirer named in cfg is registered.
+func NewRouter(registry *Registry, cfg RoutingConfig) (*Router, error) {
+	known := func(name string) error {
+		if _, ok := registry.Get(name); !ok {
+			return fmt.Errorf("unknown acquirer %q", name)
+		}
+		return nil
+	}
+
+	if err := known(cfg.Default); err != nil {
+		return nil, fmt.Errorf("default acquirer: %w", err)
+	}
+	if cfg.Fallback != "" {
+		if err := known(cfg.Fallback); err != nil {
+			return nil, fmt.Errorf("fallback acquirer: %w", err)
+		}
+	}
+
+	for _, rule := range cfg.Rules {
+		if (rule.Acquirer == "") == (len(rule.Split) == 0) {
+			return nil, fmt.Errorf("rule %q must have either an acquirer or a split", rule.Name)
+		}
+		names := []string{rule.Acquirer, rule.Fallback}
+		for _, w := range rule.Split {
+			if w.Weight <= 0 {
+				return nil, fmt.Errorf("rule %q: split weights must be positive", rule.Name)
+			}
+			names = append(names, w.Acquirer)
+		}
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			if err := known(name); err != nil {
+				return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+			}
+		}
+	}
+
+	return &Router{registry: registry, cfg: cfg}, nil
+}
+
+// ProcessPayment sends req to the acquirer chosen for it and records that
+// acquirer in the response.
+func (r *Router) ProcessPayment(ctx context.Context, reference string, req models.PaymentRequest) (*BankResponse, error) {
+	rt := r.route(reference, req)
+
+	resp, err := r.process(ctx, rt.acquirer, reference, req)
+	if err != nil && rt.fallback != "" && rt.fallback != rt.acquirer && canFailOver(err) {
+		resp, err = r.process(ctx, rt.fallback, reference, req)
+	}
+
+	return resp, err
+}
+
+// QueryPayment asks every acquirer for the payment, since a payment may have
+// been failed over from the acquirer its rule chose.
+func (r *Router) QueryPayment(ctx context.Context, reference string) (*BankResponse, error) {
+	var errs []error
+	for _, name := range r.registry.Names() {
+		acquirer, _ := r.registry.Get(name)
+
+		resp, err := acquirer.QueryPayment(ctx, reference)
+		if err == nil {
+			resp.Acquirer = name
+			return resp, nil
+		}
+		if !errors.Is(err, ErrBankPaymentNotFound) {
+			errs = append(errs, fmt.Errorf("acquirer %s: %w", name, err))
+		}
+	}
+
+	// only report the payment as unknown when every acquirer said so
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return nil, ErrBankPaymentNotFound
+}
+
+func (r *Router) process(ctx context.Context, name, reference string, req models.PaymentRequest) (*BankResponse, error) {
+	acquirer, _ := r.registry.Get(name)
+
+	resp, err := acquirer.ProcessPayment(ctx, reference, req)
+	if err != nil {
+		return nil, fmt.Errorf("acquirer %s: %w", name, err)
+	}
+	resp.Acquirer = name
+	return resp, nil
+}
+
+// route returns the acquirers of the first rule matching req.
+func (r *Router) route(reference string, req models.PaymentRequest) route {
+	scheme := card.DetectScheme(req.CardNumber)
+	country := r.binCountry(req.CardNumber)
+
+	for _, rule := range r.cfg.Rules {
+		if !rule.matches(req, scheme, country) {
+			continue
+		}
+		acquirer := rule.Acquirer
+		if acquirer == "" {
+			acquirer = pickWeighted(rule.Split, reference)
+		}
+		return route{acquirer: acquirer, fallback: rule.Fallback}
+	}
+
+	return route{acquirer: r.cfg.Default, fallback: r.cfg.Fallback}
+}
+
+func (r *Router) binCountry(cardNumber string) string {
+	country, longest := "", 0
+	for prefix, c := range r.cfg.BINCountries {
+		if len(prefix) > longest && strings.HasPrefix(cardNumber, prefix) {
+			country, longest = c, len(prefix)
+		}
+	}
+	return country
+}
+
+func (rule Rule) matches(req models.PaymentRequest, scheme card.Scheme, country string) bool {
+	if len(rule.Currencies) > 0 && !slices.Contains(rule.Currencies, req.Currency) {
+		return false
+	}
+	if len(rule.Schemes) > 0 && !slices.Contains(rule.Schemes, scheme) {
+		return false
+	}
+	if len(rule.Countries) > 0 && !slices.Contains(rule.Countries, country) {
+		return false
+	}
+	if req.Amount < rule.MinAmount {
+		return false
+	}
+	if rule.MaxAmount > 0 && req.Amount > rule.MaxAmount {
+		return false
+	}
+	return true
+}
+
+// pickWeighted chooses from split by hashing reference, so that every retry
+// of a payment goes to the same acquirer.
+func pickWeighted(split []WeightedAcquirer, reference string) string {
+	total := 0
+	for _, w := range split {
+		total += w.Weight
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(reference))
+	n := int(h.Sum32() % uint32(total))
+
+	for _, w := range split {
+		if n < w.Weight {
+			return w.Acquirer
+		}
+		n -= w.Weight
+	}
+	return split[len(split)-1].Acquirer
+}
+
+// canFailOver reports whether err proves the acquirer did not authorize the
+// payment, so that sending it to another acquirer cannot charge the card twice.
+func canFailOver(err error) bool {
+	return errors.Is(err, ErrBankUnavailable) || errors.Is(err, models.ErrAcquirerUnavailable)
+}
@@ -0,0 +1,185 @@
+package bank
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubAcquirer answers every call with err, or with an authorization when err is nil.
+type stubAcquirer struct {
+	err      error
+	queryErr error
+	calls    int
+}
+
+func (s *stubAcquirer) ProcessPayment(ctx context.Context, reference string, req models.PaymentRequest) (*BankResponse, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &BankResponse{Authorized: true, AuthorizationCode: "auth-code"}, nil
+}
+
+func (s *stubAcquirer) QueryPayment(ctx context.Context, reference string) (*BankResponse, error) {
+	if s.queryErr != nil {
+		return nil, s.queryErr
+	}
+	return &BankResponse{Authorized: true, AuthorizationCode: "auth-code"}, nil
+}
+
+func newTestRouter(t *testing.T, cfg RoutingConfig, acquirers map[string]*stubAcquirer) *Router {
+	t.Helper()
+	registry := NewRegistry()
+	for name, a := range acquirers {
+		require.NoError(t, registry.Register(name, a))
+	}
+	router, err := NewRouter(registry, cfg)
+	require.NoError(t, err)
+	return router
+}
+
+func TestRouterRules(t *testing.T) {
+	ctx := context.Background()
+	cfg := RoutingConfig{
+		Default: "default",
+		Rules: []Rule{
+			{Name: "eur", Currencies: []string{"EUR"}, Acquirer: "eur"},
+			{Name: "amex", Schemes: []card.Scheme{card.SchemeAmex}, Acquirer: "amex"},
+			{Name: "us cards", Countries: []string{"US"}, Acquirer: "us"},
+			{Name: "large", MinAmount: 100_000, Acquirer: "large"},
+		},
+		BINCountries: map[string]string{"4": "GB", "411111": "US"},
+	}
+	acquirers := map[string]*stubAcquirer{"default": {}, "eur": {}, "amex": {}, "us": {}, "large": {}}
+	router := newTestRouter(t, cfg, acquirers)
+
+	tests := []struct {
+		name     string
+		req      models.PaymentRequest
+		acquirer string
+	}{
+		{"currency", models.PaymentRequest{CardNumber: "4242424242424242", Currency: "EUR", Amount: 100}, "eur"},
+		{"scheme", models.PaymentRequest{CardNumber: "378282246310005", Currency: "GBP", Amount: 100}, "amex"},
+		{"bin country", models.PaymentRequest{CardNumber: "4111111111111111", Currency: "GBP", Amount: 100}, "us"},
+		{"amount band", models.PaymentRequest{CardNumber: "4242424242424242", Currency: "GBP", Amount: 250_000}, "large"},
+		{"no rule matches", models.PaymentRequest{CardNumber: "4242424242424242", Currency: "GBP", Amount: 100}, "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := router.ProcessPayment(ctx, "payment-ref", tt.req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.acquirer, resp.Acquirer)
+		})
+	}
+}
+
+func TestRouterWeightedSplit(t *testing.T) {
+	ctx := context.Background()
+	cfg := RoutingConfig{
+		Default: "a",
+		Rules: []Rule{{
+			Name:  "a/b",
+			Split: []WeightedAcquirer{{Acquirer: "a", Weight: 3}, {Acquirer: "b", Weight: 1}},
+		}},
+	}
+	router := newTestRouter(t, cfg, map[string]*stubAcquirer{"a": {}, "b": {}})
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		resp, err := router.ProcessPayment(ctx, fmt.Sprintf("payment-%d", i), testPaymentRequest)
+		require.NoError(t, err)
+		counts[resp.Acquirer]++
+	}
+	assert.InDelta(t, 3000, counts["a"], 200)
+	assert.InDelta(t, 1000, counts["b"], 200)
+
+	// retries of a payment keep going to the same acquirer
+	first, _ := router.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+	again, _ := router.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+	assert.Equal(t, first.Acquirer, again.Acquirer)
+}
+
+func TestRouterFailover(t *testing.T) {
+	ctx := context.Background()
+	cfg := RoutingConfig{Default: "primary", Fallback: "secondary"}
+
+	t.Run("fails over when the primary is unavailable", func(t *testing.T) {
+		for _, err := range []error{ErrBankUnavailable, models.ErrAcquirerUnavailable} {
+			primary, secondary := &stubAcquirer{err: err}, &stubAcquirer{}
+			router := newTestRouter(t, cfg, map[string]*stubAcquirer{"primary": primary, "secondary": secondary})
+
+			resp, err := router.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+			require.NoError(t, err)
+			assert.Equal(t, "secondary", resp.Acquirer)
+		}
+	})
+
+	t.Run("does not fail over when the outcome is unknown", func(t *testing.T) {
+		primary, secondary := &stubAcquirer{err: context.DeadlineExceeded}, &stubAcquirer{}
+		router := newTestRouter(t, cfg, map[string]*stubAcquirer{"primary": primary, "secondary": secondary})
+
+		_, err := router.ProcessPayment(ctx, "payment-ref", testPaymentRequest)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 0, secondary.calls)
+	})
+}
+
+func TestRouterQueryPayment(t *testing.T) {
+	ctx := context.Background()
+	cfg := RoutingConfig{Default: "a"}
+
+	router := newTestRouter(t, cfg, map[string]*stubAcquirer{
+		"a": {queryErr: ErrBankPaymentNotFound},
+		"b": {},
+	})
+	resp, err := router.QueryPayment(ctx, "payment-ref")
+	require.NoError(t, err)
+	assert.Equal(t, "b", resp.Acquirer)
+
+	router = newTestRouter(t, cfg, map[string]*stubAcquirer{
+		"a": {queryErr: ErrBankPaymentNotFound},
+		"b": {queryErr: ErrBankUnavailable},
+	})
+	_, err = router.QueryPayment(ctx, "payment-ref")
+	assert.ErrorIs(t, err, ErrBankUnavailable)
+}
+
+func TestNewRouterRejectsUnknownAcquirers(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register("a", &stubAcquirer{}))
+	assert.Error(t, registry.Register("a", &stubAcquirer{}))
+
+	_, err := NewRouter(registry, RoutingConfig{Default: "missing"})
+	assert.Error(t, err)
+
+	_, err = NewRouter(registry, RoutingConfig{Default: "a", Rules: []Rule{{Name: "r", Acquirer: "a", Fallback: "missing"}}})
+	assert.Error(t, err)
+
+	_, err = NewRouter(registry, RoutingConfig{Default: "a", Rules: []Rule{{Name: "r"}}})
+	assert.Error(t, err)
+}
+
+func TestLoadRoutingConfigValidatesURLs(t *testing.T) {
+	load := func(t *testing.T, url string) error {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "routing.json")
+		content := fmt.Sprintf(`{"acquirers": [{"name": "primary", "url": %q}], "default": "primary"}`, url)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		_, err := LoadRoutingConfig(path)
+		return err
+	}
+
+	assert.NoError(t, load(t, "http://localhost:8080"))
+	assert.NoError(t, load(t, "https://acquirer.example.com/api"))
+	for _, url := range []string{"", "localhost:8080", "htp://localhost:8080", "ftp://acquirer.example.com", "/payments", "http://"} {
+		assert.ErrorContains(t, load(t, url), `acquirer "primary"`, url)
+	}
+}
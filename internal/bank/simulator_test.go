@@ -0,0 +1,126 @@
+package bank
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/banksim"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSimulatorClient returns a client of a bank simulator built with opts.
+func newSimulatorClient(t *testing.T, opts ...banksim.Option) *Client {
+	t.Helper()
+	server := httptest.NewServer(banksim.New(opts...))
+	t.Cleanup(server.Close)
+	return NewClient(&server.URL, WithRetryPolicy(testRetryPolicy))
+}
+
+func simulatorPayment(cardNumber, cvv string) models.PaymentRequest {
+	req := testPaymentRequest
+	req.CardNumber = cardNumber
+	req.Cvv = cvv
+	return req
+}
+
+func TestClientWithSimulator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("authorized", func(t *testing.T) {
+		client := newSimulatorClient(t, banksim.WithSeed(1))
+
+		resp, err := client.ProcessPayment(ctx, "payment-ref", simulatorPayment("2222405343248877", "123"))
+		require.NoError(t, err)
+		assert.True(t, resp.Authorized)
+		assert.NotEmpty(t, resp.AuthorizationCode)
+
+		queried, err := client.QueryPayment(ctx, "payment-ref")
+		require.NoError(t, err)
+		assert.Equal(t, resp, queried)
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		client := newSimulatorClient(t)
+
+		resp, err := client.ProcessPayment(ctx, "payment-ref", simulatorPayment("2222405343248872", "123"))
+		require.NoError(t, err)
+		assert.False(t, resp.Authorized)
+		assert.Equal(t, models.DeclineDoNotHonour, resp.DeclineReason)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		client := newSimulatorClient(t)
+
+		_, err := client.ProcessPayment(ctx, "payment-ref", simulatorPayment("2222405343248870", "123"))
+		assert.ErrorIs(t, err, ErrBankUnavailable)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		client := newSimulatorClient(t)
+
+		_, err := client.QueryPayment(ctx, "unknown-ref")
+		assert.ErrorIs(t, err, ErrBankPaymentNotFound)
+	})
+
+	t.Run("injected errors are retried", func(t *testing.T) {
+		client := newSimulatorClient(t, banksim.WithSeed(3), banksim.WithErrors(0.5, http.StatusServiceUnavailable))
+
+		// with three attempts, seven payments in eight get through
+		authorized := 0
+		for i := 0; i < 40; i++ {
+			resp, err := client.ProcessPayment(ctx, fmt.Sprintf("payment-ref-%d", i), simulatorPayment("2222405343248877", "123"))
+			if err != nil {
+				assert.ErrorIs(t, err, ErrBankUnavailable)
+				continue
+			}
+			assert.True(t, resp.Authorized)
+			authorized++
+		}
+		assert.Greater(t, authorized, 20)
+	})
+
+	t.Run("latency beyond the timeout", func(t *testing.T) {
+		server := httptest.NewServer(banksim.New(banksim.WithLatency(time.Second, 0)))
+		t.Cleanup(server.Close)
+		client := NewClient(&server.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}), WithTimeout(20*time.Millisecond))
+
+		_, err := client.ProcessPayment(ctx, "payment-ref", simulatorPayment("2222405343248877", "123"))
+		assert.Error(t, err)
+	})
+}
+
+func TestClientDeclineReasonsWithSimulator(t *testing.T) {
+	client := newSimulatorClient(t, banksim.WithDeclineCodes(banksim.DeclineCodes))
+
+	tests := []struct {
+		cardNumber string
+		cvv        string
+		want       models.DeclineReason
+	}{
+		{"2222405343248872", "123", models.DeclineInsufficientFunds},
+		{"2222405343248874", "123", models.DeclineDoNotHonour},
+		{"2222405343248876", "123", models.DeclineExpiredCard},
+		{"2222405343248878", "123", models.DeclineSuspectedFraud},
+		{"2222405343248877", banksim.InvalidCvv, models.DeclineInvalidCvv},
+	}
+
+	covered := map[models.DeclineReason]bool{}
+	for _, tt := range tests {
+		t.Run(string(tt.want), func(t *testing.T) {
+			resp, err := client.ProcessPayment(context.Background(), tt.cardNumber+tt.cvv, simulatorPayment(tt.cardNumber, tt.cvv))
+			require.NoError(t, err)
+			assert.False(t, resp.Authorized)
+			assert.Equal(t, tt.want, resp.DeclineReason)
+		})
+		covered[tt.want] = true
+	}
+	for _, reason := range models.DeclineReasons {
+		assert.True(t, covered[reason], "no card is declined with %s", reason)
+	}
+}
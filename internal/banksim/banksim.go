@@ -0,0 +1,271 @@
+// Package banksim is a Go implementation of the mountebank bank simulator in
+// imposters/bank_simulator.ejs, so that the gateway can be run and tested
+// without Docker. It follows the same rules on the last digit of the card
+// number: odd digits are authorized, even ones declined and 0 answers 503.
+//
+// Declines carry the imposter's response codes when decline codes are
+// enabled. On top of the imposter it can delay answers, inject errors and make
+// the authorization codes deterministic. It also remembers payments by their
+// Idempotency-Key: a retry gets the first answer again, and
+// GET /payments/{key} answers with it.
+package banksim
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	mathrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKeyHeader is the header payments are remembered by.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// InvalidCvv is the CVV declined with N7 when decline codes are enabled.
+const InvalidCvv = "000"
+
+// DeclineCodes are the response codes of imposters/bank_simulator.ejs,
+// by the last digit of the card number.
+var DeclineCodes = map[string]string{
+	"2": "51",
+	"4": "05",
+	"6": "54",
+	"8": "59",
+}
+
+// notSupported is the imposter's answer to requests no stub matches.
+var notSupported = map[string]string{
+	"errorMessage": "The request supplied is not supported by the simulator",
+}
+
+// PaymentResponse is the answer to an accepted payment.
+type PaymentResponse struct {
+	Authorized        bool   `json:"authorized"`
+	AuthorizationCode string `json:"authorization_code"`
+	ResponseCode      string `json:"response_code,omitempty"`
+}
+
+// Simulator is an http.Handler acting as the acquiring bank.
+type Simulator struct {
+	latency      time.Duration
+	jitter       time.Duration
+	errorRate    float64
+	errorStatus  int
+	declineCodes map[string]string
+
+	mu sync.Mutex
+	// random draws the jitter, the injected errors and, when seeded, the
+	// authorization codes
+	random   *mathrand.Rand
+	seeded   bool
+	payments map[string]answer
+}
+
+// answer is a response the simulator gave, kept to be given again.
+type answer struct {
+	status int
+	body   any
+}
+
+// Option configures optional Simulator behaviour
+type Option func(*Simulator)
+
+// WithLatency delays every answer by latency plus a random duration up to jitter.
+func WithLatency(latency, jitter time.Duration) Option {
+	return func(s *Simulator) {
+		s.latency = latency
+		s.jitter = jitter
+	}
+}
+
+// WithErrors answers the given fraction of requests, between 0 and 1, with
+// status instead of processing them. Payments answered with an injected
+// error are not remembered, like payments the bank never received.
+func WithErrors(rate float64, status int) Option {
+	return func(s *Simulator) {
+		s.errorRate = rate
+		s.errorStatus = status
+	}
+}
+
+// WithDeclineCodes declines payments with the response code in codes for the
+// last digit of their card number, and declines the CVV InvalidCvv with N7,
+// like imposters/bank_simulator.ejs. Declines of digits missing from
+// codes carry no response code.
+func WithDeclineCodes(codes map[string]string) Option {
+	return func(s *Simulator) {
+		s.declineCodes = codes
+	}
+}
+
+// WithSeed makes the authorization codes, the latency jitter and the
+// injected errors deterministic.
+func WithSeed(seed int64) Option {
+	return func(s *Simulator) {
+		s.random = mathrand.New(mathrand.NewSource(seed))
+		s.seeded = true
+	}
+}
+
+// New returns a simulator with the imposter's behaviour, changed by opts.
+func New(opts ...Option) *Simulator {
+	var seed [8]byte
+	_, _ = rand.Read(seed[:])
+
+	s := &Simulator{
+		random:   mathrand.New(mathrand.NewSource(int64(binary.LittleEndian.Uint64(seed[:])))),
+		payments: make(map[string]answer),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status, body := s.handle(r)
+	if delay := s.delay(); delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	slog.DebugContext(r.Context(), "bank simulator answered",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (s *Simulator) handle(r *http.Request) (int, any) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/payments":
+		if s.injectError() {
+			return s.errorStatus, struct{}{}
+		}
+		return s.processPayment(r)
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/payments/"):
+		if s.injectError() {
+			return s.errorStatus, struct{}{}
+		}
+		return s.queryPayment(strings.TrimPrefix(r.URL.Path, "/payments/"))
+	default:
+		return http.StatusBadRequest, notSupported
+	}
+}
+
+func (s *Simulator) processPayment(r *http.Request) (int, any) {
+	var fields map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil || !hasRequiredFields(fields) {
+		return http.StatusBadRequest, map[string]string{
+			"error_message": "Not all required properties were sent in the request",
+		}
+	}
+	cardNumber, _ := fields["card_number"].(string)
+	cvv, _ := fields["cvv"].(string)
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, ok := s.payments[key]; ok && key != "" {
+		return previous.status, previous.body
+	}
+
+	a := s.decide(cardNumber, cvv)
+	if key != "" {
+		s.payments[key] = a
+	}
+	return a.status, a.body
+}
+
+// hasRequiredFields tells whether the payment has every field the imposter
+// requires. Like mountebank's exists predicate, empty strings count as missing.
+func hasRequiredFields(fields map[string]any) bool {
+	for _, name := range []string{"card_number", "expiry_date", "currency", "amount", "cvv"} {
+		switch v := fields[name].(type) {
+		case nil:
+			return false
+		case string:
+			if v == "" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// decide applies the imposter's rules to a payment. s.mu must be held.
+func (s *Simulator) decide(cardNumber, cvv string) answer {
+	if cardNumber == "" {
+		return answer{status: http.StatusBadRequest, body: notSupported}
+	}
+	lastDigit := cardNumber[len(cardNumber)-1:]
+
+	switch {
+	case lastDigit == "0":
+		return answer{status: http.StatusServiceUnavailable, body: struct{}{}}
+	case s.declineCodes != nil && cvv == InvalidCvv:
+		return answer{status: http.StatusOK, body: PaymentResponse{ResponseCode: "N7"}}
+	case strings.Contains("13579", lastDigit):
+		return answer{status: http.StatusOK, body: PaymentResponse{Authorized: true, AuthorizationCode: s.authorizationCode()}}
+	case strings.Contains("2468", lastDigit):
+		return answer{status: http.StatusOK, body: PaymentResponse{ResponseCode: s.declineCodes[lastDigit]}}
+	default:
+		return answer{status: http.StatusBadRequest, body: notSupported}
+	}
+}
+
+func (s *Simulator) queryPayment(key string) (int, any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.payments[key]
+	if !ok || a.status != http.StatusOK {
+		return http.StatusNotFound, struct{}{}
+	}
+	return a.status, a.body
+}
+
+// authorizationCode returns a random UUID, drawn from the seeded source when
+// there is one. s.mu must be held.
+func (s *Simulator) authorizationCode() string {
+	if !s.seeded {
+		return uuid.NewString()
+	}
+	var b [16]byte
+	s.random.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return uuid.UUID(b).String()
+}
+
+func (s *Simulator) injectError() bool {
+	if s.errorRate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.random.Float64() < s.errorRate
+}
+
+func (s *Simulator) delay() time.Duration {
+	if s.jitter <= 0 {
+		return s.latency
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latency + time.Duration(s.random.Int63n(int64(s.jitter)))
+}
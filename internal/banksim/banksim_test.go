@@ -0,0 +1,199 @@
+package banksim
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func payment(cardNumber, cvv string) map[string]any {
+	return map[string]any{
+		"card_number": cardNumber,
+		"expiry_date": "04/2035",
+		"currency":    "GBP",
+		"amount":      100,
+		"cvv":         cvv,
+	}
+}
+
+func post(t *testing.T, s *Simulator, key string, body any) (int, map[string]any) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/payments", bytes.NewReader(data))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return serve(t, s, req)
+}
+
+func serve(t *testing.T, s *Simulator, req *http.Request) (int, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return rec.Code, body
+}
+
+func TestSimulator(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		body       any
+		wantStatus int
+		wantBody   map[string]any
+	}{
+		{
+			name:       "even last digit is declined",
+			body:       payment("2222405343248872", "123"),
+			wantStatus: http.StatusOK,
+			wantBody:   map[string]any{"authorized": false, "authorization_code": ""},
+		},
+		{
+			name:       "last digit 0 is unavailable",
+			body:       payment("2222405343248870", "123"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   map[string]any{},
+		},
+		{
+			name:       "missing field",
+			body:       map[string]any{"card_number": "2222405343248877", "currency": "GBP", "amount": 100, "cvv": "123"},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   map[string]any{"error_message": "Not all required properties were sent in the request"},
+		},
+		{
+			name:       "empty field",
+			body:       payment("2222405343248877", ""),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   map[string]any{"error_message": "Not all required properties were sent in the request"},
+		},
+		{
+			name:       "card number not ending in a digit",
+			body:       payment("222240534324887x", "123"),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   map[string]any{"errorMessage": "The request supplied is not supported by the simulator"},
+		},
+		{
+			name:       "decline code",
+			opts:       []Option{WithDeclineCodes(DeclineCodes)},
+			body:       payment("2222405343248872", "123"),
+			wantStatus: http.StatusOK,
+			wantBody:   map[string]any{"authorized": false, "authorization_code": "", "response_code": "51"},
+		},
+		{
+			name:       "invalid cvv",
+			opts:       []Option{WithDeclineCodes(DeclineCodes)},
+			body:       payment("2222405343248877", InvalidCvv),
+			wantStatus: http.StatusOK,
+			wantBody:   map[string]any{"authorized": false, "authorization_code": "", "response_code": "N7"},
+		},
+		{
+			name:       "invalid cvv without decline codes",
+			body:       payment("2222405343248872", InvalidCvv),
+			wantStatus: http.StatusOK,
+			wantBody:   map[string]any{"authorized": false, "authorization_code": ""},
+		},
+		{
+			name:       "injected error",
+			opts:       []Option{WithErrors(1, http.StatusBadGateway)},
+			body:       payment("2222405343248877", "123"),
+			wantStatus: http.StatusBadGateway,
+			wantBody:   map[string]any{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := post(t, New(tt.opts...), "", tt.body)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantBody, body)
+		})
+	}
+
+	t.Run("odd last digit is authorized", func(t *testing.T) {
+		status, body := post(t, New(), "", payment("2222405343248877", "123"))
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, true, body["authorized"])
+		_, err := uuid.Parse(body["authorization_code"].(string))
+		assert.NoError(t, err)
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		status, body := serve(t, New(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, map[string]any{"errorMessage": "The request supplied is not supported by the simulator"}, body)
+	})
+}
+
+func TestSimulatorIdempotency(t *testing.T) {
+	s := New()
+
+	_, first := post(t, s, "payment-ref", payment("2222405343248877", "123"))
+	_, retry := post(t, s, "payment-ref", payment("2222405343248877", "123"))
+	assert.Equal(t, first, retry, "a retry gets the first answer")
+
+	_, other := post(t, s, "other-ref", payment("2222405343248877", "123"))
+	assert.NotEqual(t, first["authorization_code"], other["authorization_code"])
+
+	status, queried := serve(t, s, httptest.NewRequest(http.MethodGet, "/payments/payment-ref", nil))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, first, queried)
+
+	status, _ = serve(t, s, httptest.NewRequest(http.MethodGet, "/payments/unknown-ref", nil))
+	assert.Equal(t, http.StatusNotFound, status)
+
+	post(t, s, "unavailable-ref", payment("2222405343248870", "123"))
+	status, _ = serve(t, s, httptest.NewRequest(http.MethodGet, "/payments/unavailable-ref", nil))
+	assert.Equal(t, http.StatusNotFound, status, "payments answered with an error are not found")
+}
+
+func TestSimulatorSeed(t *testing.T) {
+	codes := func() []any {
+		s := New(WithSeed(42))
+		var codes []any
+		for i := 0; i < 3; i++ {
+			_, body := post(t, s, "", payment("2222405343248877", "123"))
+			codes = append(codes, body["authorization_code"])
+		}
+		return codes
+	}
+
+	first := codes()
+	assert.Equal(t, first, codes())
+	assert.NotEqual(t, first[0], first[1])
+	_, err := uuid.Parse(first[0].(string))
+	assert.NoError(t, err)
+}
+
+func TestSimulatorErrorRate(t *testing.T) {
+	s := New(WithSeed(1), WithErrors(0.5, http.StatusInternalServerError))
+
+	errors := 0
+	for i := 0; i < 200; i++ {
+		if status, _ := post(t, s, "", payment("2222405343248877", "123")); status == http.StatusInternalServerError {
+			errors++
+		}
+	}
+	assert.InDelta(t, 100, errors, 30)
+}
+
+func TestSimulatorLatency(t *testing.T) {
+	s := New(WithLatency(20*time.Millisecond, 10*time.Millisecond))
+
+	start := time.Now()
+	post(t, s, "", payment("2222405343248877", "123"))
+	elapsed := time.Since(start)
+
+	assert.GreaterOrEqual(t, elapsed, 20*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+}
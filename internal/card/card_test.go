@@ -0,0 +1,72 @@
+package card
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		number   string
+		expected bool
+	}{
+		{"4111111111111111", true},
+		{"4111111111111112", false},
+		{"378282246310005", true},
+		{"2222405343248877", true},
+		{"79927398713", true},
+		{"79927398710", false},
+		{"", false},
+		{"4111-1111", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Luhn(tt.number))
+		})
+	}
+}
+
+func TestDetectScheme(t *testing.T) {
+	tests := []struct {
+		number   string
+		expected Scheme
+	}{
+		{"4111111111111111", SchemeVisa},
+		{"5555555555554444", SchemeMastercard},
+		{"2222405343248877", SchemeMastercard},
+		{"2720990000000000", SchemeMastercard},
+		{"2721000000000000", SchemeUnknown},
+		{"378282246310005", SchemeAmex},
+		{"340000000000009", SchemeAmex},
+		{"6011111111111117", SchemeDiscover},
+		{"6445644564456445", SchemeDiscover},
+		{"6221260000000000", SchemeDiscover},
+		{"6200000000000005", SchemeUnionPay},
+		{"3530111333300000", SchemeJCB},
+		{"30569309025904", SchemeDiners},
+		{"36227206271667", SchemeDiners},
+		{"1234567812345678", SchemeUnknown},
+		{"4", SchemeVisa},
+		{"", SchemeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			assert.Equal(t, tt.expected, DetectScheme(tt.number))
+		})
+	}
+}
+
+func TestSchemeRules(t *testing.T) {
+	rules, ok := SchemeAmex.Rules()
+	assert.True(t, ok)
+	assert.Equal(t, 4, rules.CvvLength)
+	assert.True(t, SchemeAmex.ValidLength(15))
+	assert.False(t, SchemeAmex.ValidLength(16))
+
+	_, ok = SchemeUnknown.Rules()
+	assert.False(t, ok)
+	assert.False(t, SchemeUnknown.ValidLength(16))
+}
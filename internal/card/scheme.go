@@ -0,0 +1,126 @@
+package card
+
+import (
+	"slices"
+	"strconv"
+)
+
+// Scheme is a card network such as Visa or Mastercard
+type Scheme string
+
+const (
+	SchemeUnknown    Scheme = ""
+	SchemeVisa       Scheme = "visa"
+	SchemeMastercard Scheme = "mastercard"
+	SchemeAmex       Scheme = "amex"
+	SchemeDiscover   Scheme = "discover"
+	SchemeJCB        Scheme = "jcb"
+	SchemeUnionPay   Scheme = "unionpay"
+	SchemeDiners     Scheme = "diners"
+)
+
+// Rules describes the card number and CVV format of a scheme
+type Rules struct {
+	Lengths   []int
+	CvvLength int
+}
+
+var schemeRules = map[Scheme]Rules{
+	SchemeVisa:       {Lengths: []int{13, 16, 19}, CvvLength: 3},
+	SchemeMastercard: {Lengths: []int{16}, CvvLength: 3},
+	SchemeAmex:       {Lengths: []int{15}, CvvLength: 4},
+	SchemeDiscover:   {Lengths: []int{16, 17, 18, 19}, CvvLength: 3},
+	SchemeJCB:        {Lengths: []int{16, 17, 18, 19}, CvvLength: 3},
+	SchemeUnionPay:   {Lengths: []int{16, 17, 18, 19}, CvvLength: 3},
+	SchemeDiners:     {Lengths: []int{14, 15, 16, 17, 18, 19}, CvvLength: 3},
+}
+
+// binRange matches card numbers whose first digits, read as a number of the
+// same length as from, fall within [from, to].
+type binRange struct {
+	from, to int
+	digits   int
+	scheme   Scheme
+}
+
+// binRanges are the issuer identification ranges of the supported schemes.
+// When ranges overlap the longest one wins, so co-branded Discover cards in
+// the UnionPay 62 range are detected as Discover.
+var binRanges = []binRange{
+	{4, 4, 1, SchemeVisa},
+	{51, 55, 2, SchemeMastercard},
+	{2221, 2720, 4, SchemeMastercard},
+	{34, 34, 2, SchemeAmex},
+	{37, 37, 2, SchemeAmex},
+	{6011, 6011, 4, SchemeDiscover},
+	{644, 649, 3, SchemeDiscover},
+	{65, 65, 2, SchemeDiscover},
+	{622126, 622925, 6, SchemeDiscover},
+	{3528, 3589, 4, SchemeJCB},
+	{62, 62, 2, SchemeUnionPay},
+	{81, 81, 2, SchemeUnionPay},
+	{300, 305, 3, SchemeDiners},
+	{3095, 3095, 4, SchemeDiners},
+	{36, 36, 2, SchemeDiners},
+	{38, 39, 2, SchemeDiners},
+}
+
+// DetectScheme returns the scheme of a card number from its BIN, or
+// SchemeUnknown if it does not belong to a supported scheme.
+func DetectScheme(number string) Scheme {
+	scheme := SchemeUnknown
+	longest := 0
+
+	for _, r := range binRanges {
+		if r.digits > len(number) || r.digits <= longest {
+			continue
+		}
+
+		prefix, err := strconv.Atoi(number[:r.digits])
+		if err != nil {
+			continue
+		}
+
+		if prefix >= r.from && prefix <= r.to {
+			scheme = r.scheme
+			longest = r.digits
+		}
+	}
+
+	return scheme
+}
+
+// Rules returns the formatting rules of the scheme. ok is false for
+// SchemeUnknown.
+func (s Scheme) Rules() (rules Rules, ok bool) {
+	rules, ok = schemeRules[s]
+	return rules, ok
+}
+
+// ValidLength reports whether a card number of length n is valid for the scheme.
+func (s Scheme) ValidLength(n int) bool {
+	rules, ok := s.Rules()
+	return ok && slices.Contains(rules.Lengths, n)
+}
+
+// DisplayName returns a human readable name of the scheme.
+func (s Scheme) DisplayName() string {
+	switch s {
+	case SchemeVisa:
+		return "Visa"
+	case SchemeMastercard:
+		return "Mastercard"
+	case SchemeAmex:
+		return "American Express"
+	case SchemeDiscover:
+		return "Discover"
+	case SchemeJCB:
+		return "JCB"
+	case SchemeUnionPay:
+		return "UnionPay"
+	case SchemeDiners:
+		return "Diners Club"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,407 @@
+// Package config holds the settings of the gateway. They are read from a
+// YAML or TOML file, then from GATEWAY_* environment variables and finally
+// from command line flags, each source overriding the ones before it.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/api"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/currency"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/health"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/services"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/vault"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete configuration of the gateway.
+type Config struct {
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Bank     BankConfig     `yaml:"bank" toml:"bank"`
+	Store    StoreConfig    `yaml:"store" toml:"store"`
+	Payments PaymentsConfig `yaml:"payments" toml:"payments"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Vault    VaultConfig    `yaml:"vault" toml:"vault"`
+	Log      LogConfig      `yaml:"log" toml:"log"`
+	Tracing  TracingConfig  `yaml:"tracing" toml:"tracing"`
+	Webhooks WebhooksConfig `yaml:"webhooks" toml:"webhooks"`
+	Features FeaturesConfig `yaml:"features" toml:"features"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	// Addr is the address the server listens on
+	Addr string `yaml:"addr" toml:"addr"`
+	// RequestTimeout bounds the time spent serving a request
+	RequestTimeout time.Duration `yaml:"request_timeout" toml:"request_timeout"`
+	// ShutdownDelay is how long readiness fails before the server stops on shutdown
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" toml:"shutdown_delay"`
+	// DrainTimeout is how long the requests in flight may take to finish once the server stops
+	DrainTimeout time.Duration `yaml:"drain_timeout" toml:"drain_timeout"`
+	// HealthCacheTTL is how long the outcome of the readiness checks is reused
+	HealthCacheTTL time.Duration `yaml:"health_cache_ttl" toml:"health_cache_ttl"`
+}
+
+// BankConfig configures the calls to the acquiring banks.
+type BankConfig struct {
+	// URL is the bank the payments go to when there is no routing file
+	URL string `yaml:"url" toml:"url"`
+	// Timeout bounds every attempt of a bank call
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	// MaxAttempts is the number of attempts per call for failures safe to retry
+	MaxAttempts int `yaml:"max_attempts" toml:"max_attempts"`
+	// RoutingFile is a JSON file with the acquirers and the rules routing
+	// payments between them
+	RoutingFile string        `yaml:"routing_file" toml:"routing_file"`
+	Breaker     BreakerConfig `yaml:"breaker" toml:"breaker"`
+	Chaos       ChaosConfig   `yaml:"chaos" toml:"chaos"`
+}
+
+// BreakerConfig configures the circuit breaker of every acquirer.
+type BreakerConfig struct {
+	FailureRate float64       `yaml:"failure_rate" toml:"failure_rate"`
+	SlowCall    time.Duration `yaml:"slow_call" toml:"slow_call"`
+	OpenTimeout time.Duration `yaml:"open_timeout" toml:"open_timeout"`
+}
+
+// ChaosConfig configures the faults injected in the bank calls when
+// features.chaos is on, see bank.ChaosConfig.
+type ChaosConfig struct {
+	// The rates are the probabilities of each fault on a payment
+	LatencyRate   float64 `yaml:"latency_rate" toml:"latency_rate"`
+	TimeoutRate   float64 `yaml:"timeout_rate" toml:"timeout_rate"`
+	ErrorRate     float64 `yaml:"error_rate" toml:"error_rate"`
+	MalformedRate float64 `yaml:"malformed_rate" toml:"malformed_rate"`
+	DropRate      float64 `yaml:"drop_rate" toml:"drop_rate"`
+	// Script lists the faults of the first payments in order
+	Script      []string      `yaml:"script,omitempty" toml:"script,omitempty"`
+	Latency     time.Duration `yaml:"latency" toml:"latency"`
+	ErrorStatus int           `yaml:"error_status" toml:"error_status"`
+	Queries     bool          `yaml:"queries" toml:"queries"`
+	// AdminToken is the bearer token of the /admin/chaos endpoint, which is
+	// only served when it is set. It is a secret.
+	AdminToken string `yaml:"admin_token" toml:"admin_token"`
+}
+
+// StoreConfig configures the repositories.
+type StoreConfig struct {
+	// Driver is memory, sqlite or postgres
+	Driver string `yaml:"driver" toml:"driver"`
+	// DSN locates the sqlite or postgres database. It is a secret.
+	DSN string `yaml:"dsn" toml:"dsn"`
+	// IdempotencyTTL is how long responses are kept for Idempotency-Key retries
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" toml:"idempotency_ttl"`
+}
+
+// PaymentsConfig configures payment processing.
+type PaymentsConfig struct {
+	// Currencies are the ISO 4217 codes payments may be made in
+	Currencies         []string      `yaml:"currencies" toml:"currencies"`
+	ReconcileInterval  time.Duration `yaml:"reconcile_interval" toml:"reconcile_interval"`
+	ReconcileVoidAfter time.Duration `yaml:"reconcile_void_after" toml:"reconcile_void_after"`
+	// BatchConcurrency is the number of payments of a batch made at the same time
+	BatchConcurrency int `yaml:"batch_concurrency" toml:"batch_concurrency"`
+	BatchMaxLines    int `yaml:"batch_max_lines" toml:"batch_max_lines"`
+	// BatchMaxSize is the largest size of a batch upload in bytes
+	BatchMaxSize int `yaml:"batch_max_size" toml:"batch_max_size"`
+	// BatchQueueSize is the number of batches that may wait to be processed
+	BatchQueueSize int `yaml:"batch_queue_size" toml:"batch_queue_size"`
+}
+
+// AuthConfig configures merchant authentication.
+type AuthConfig struct {
+	// MerchantsFile is a JSON file with the merchant registry and hashed API keys
+	MerchantsFile string `yaml:"merchants_file" toml:"merchants_file"`
+}
+
+// VaultConfig configures the key sealing card tokens and authorization codes.
+// The key is either set directly with Key and KeyID, or read with its older
+// versions from KeysFile, see vault.FileKeyProvider.
+type VaultConfig struct {
+	// KeyID names Key in the vault records encrypted with it
+	KeyID string `yaml:"key_id" toml:"key_id"`
+	// Key is the base64 encoded 32 byte key encryption key. It is a secret.
+	Key string `yaml:"key" toml:"key"`
+	// KeysFile is a JSON file with every version of the key, newest last
+	KeysFile string `yaml:"keys_file" toml:"keys_file"`
+	// RequireCvv requires a CVV with payments by card token
+	RequireCvv bool `yaml:"require_cvv" toml:"require_cvv"`
+}
+
+// LogConfig configures the logger.
+type LogConfig struct {
+	Level slog.Level `yaml:"level" toml:"level"`
+}
+
+// TracingConfig configures the span exporter, see tracing.Config.
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" toml:"exporter"`
+	File     string `yaml:"file" toml:"file"`
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+}
+
+// WebhooksConfig configures the delivery of payment events.
+type WebhooksConfig struct {
+	// AllowInsecureEndpoints accepts plain http endpoints and endpoints on
+	// loopback, private and link-local addresses, which only development
+	// environments want
+	AllowInsecureEndpoints bool `yaml:"allow_insecure_endpoints" toml:"allow_insecure_endpoints"`
+}
+
+// FeaturesConfig turns optional features of the gateway on and off.
+type FeaturesConfig struct {
+	Idempotency    bool `yaml:"idempotency" toml:"idempotency"`
+	Webhooks       bool `yaml:"webhooks" toml:"webhooks"`
+	Metrics        bool `yaml:"metrics" toml:"metrics"`
+	Reconciliation bool `yaml:"reconciliation" toml:"reconciliation"`
+	// Tokenization needs a vault key, so it is off by default
+	Tokenization bool `yaml:"tokenization" toml:"tokenization"`
+	// Chaos injects faults in the bank calls, which only test environments want
+	Chaos   bool `yaml:"chaos" toml:"chaos"`
+	Batches bool `yaml:"batches" toml:"batches"`
+}
+
+// Default returns the configuration used for every setting no source sets.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:           ":8090",
+			RequestTimeout: api.DefaultRequestTimeout,
+			DrainTimeout:   api.DefaultDrainTimeout,
+			HealthCacheTTL: health.DefaultConfig.CacheTTL,
+		},
+		Bank: BankConfig{
+			URL:         bank.DefaultBankURL,
+			Timeout:     bank.DefaultTimeout,
+			MaxAttempts: bank.DefaultRetryPolicy.MaxAttempts,
+			Breaker: BreakerConfig{
+				FailureRate: bank.DefaultBreakerConfig.FailureRateThreshold,
+				SlowCall:    bank.DefaultBreakerConfig.SlowCallThreshold,
+				OpenTimeout: bank.DefaultBreakerConfig.OpenTimeout,
+			},
+			Chaos: ChaosConfig{
+				Latency:     time.Second,
+				ErrorStatus: http.StatusServiceUnavailable,
+			},
+		},
+		Store: StoreConfig{
+			Driver:         "memory",
+			IdempotencyTTL: 24 * time.Hour,
+		},
+		Payments: PaymentsConfig{
+			Currencies:         append([]string(nil), currency.DefaultCodes...),
+			ReconcileInterval:  services.DefaultReconcilerConfig.Interval,
+			ReconcileVoidAfter: services.DefaultReconcilerConfig.VoidAfter,
+			BatchConcurrency:   services.DefaultBatchConfig.Concurrency,
+			BatchMaxLines:      services.DefaultBatchConfig.MaxLines,
+			BatchMaxSize:       api.DefaultBatchMaxSize,
+			BatchQueueSize:     services.DefaultBatchConfig.QueueSize,
+		},
+		Auth:  AuthConfig{MerchantsFile: "merchants.json"},
+		Vault: VaultConfig{KeyID: "primary"},
+		Log:   LogConfig{Level: slog.LevelInfo},
+		Tracing: TracingConfig{
+			Exporter: tracing.ExporterNone,
+		},
+		Features: FeaturesConfig{
+			Idempotency:    true,
+			Webhooks:       true,
+			Metrics:        true,
+			Reconciliation: true,
+			Batches:        true,
+		},
+	}
+}
+
+// Validate reports every invalid setting of c, naming each by its key in
+// the config file.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: "+format, append([]any{key}, args...)...))
+	}
+	positive := func(key string, d time.Duration) {
+		if d <= 0 {
+			invalid(key, "must be a positive duration, got %s", d)
+		}
+	}
+
+	if c.Server.Addr == "" {
+		invalid("server.addr", "must not be empty")
+	}
+	positive("server.request_timeout", c.Server.RequestTimeout)
+	if c.Server.ShutdownDelay < 0 {
+		invalid("server.shutdown_delay", "must not be negative, got %s", c.Server.ShutdownDelay)
+	}
+	positive("server.drain_timeout", c.Server.DrainTimeout)
+	positive("server.health_cache_ttl", c.Server.HealthCacheTTL)
+
+	if u, err := url.Parse(c.Bank.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("bank.url", "must be an absolute http or https URL, got %q", c.Bank.URL)
+	}
+	positive("bank.timeout", c.Bank.Timeout)
+	if c.Bank.MaxAttempts < 1 {
+		invalid("bank.max_attempts", "must be at least 1, got %d", c.Bank.MaxAttempts)
+	}
+	if c.Bank.Breaker.FailureRate <= 0 || c.Bank.Breaker.FailureRate > 1 {
+		invalid("bank.breaker.failure_rate", "must be in (0, 1], got %g", c.Bank.Breaker.FailureRate)
+	}
+	positive("bank.breaker.slow_call", c.Bank.Breaker.SlowCall)
+	positive("bank.breaker.open_timeout", c.Bank.Breaker.OpenTimeout)
+	if c.Features.Chaos {
+		if err := c.Bank.Chaos.Bank(c.Bank.Timeout).Validate(); err != nil {
+			invalid("bank.chaos", "%v", err)
+		}
+	}
+
+	switch c.Store.Driver {
+	case "memory":
+	case "sqlite", "postgres":
+		if c.Store.DSN == "" {
+			invalid("store.dsn", "is required by the %s store", c.Store.Driver)
+		}
+	default:
+		invalid("store.driver", "must be memory, sqlite or postgres, got %q", c.Store.Driver)
+	}
+	positive("store.idempotency_ttl", c.Store.IdempotencyTTL)
+
+	if _, err := currency.NewSet(c.Payments.Currencies...); err != nil {
+		invalid("payments.currencies", "%v", err)
+	}
+	positive("payments.reconcile_interval", c.Payments.ReconcileInterval)
+	positive("payments.reconcile_void_after", c.Payments.ReconcileVoidAfter)
+	if c.Features.Batches {
+		atLeastOne := func(key string, n int) {
+			if n < 1 {
+				invalid(key, "must be at least 1, got %d", n)
+			}
+		}
+		atLeastOne("payments.batch_concurrency", c.Payments.BatchConcurrency)
+		atLeastOne("payments.batch_max_lines", c.Payments.BatchMaxLines)
+		atLeastOne("payments.batch_max_size", c.Payments.BatchMaxSize)
+		atLeastOne("payments.batch_queue_size", c.Payments.BatchQueueSize)
+	}
+
+	if c.Auth.MerchantsFile == "" {
+		invalid("auth.merchants_file", "must not be empty")
+	}
+
+	if c.Features.Tokenization {
+		switch {
+		case c.Vault.Key == "" && c.Vault.KeysFile == "":
+			invalid("vault.key", "or vault.keys_file is required by features.tokenization")
+		case c.Vault.Key != "":
+			if c.Vault.KeyID == "" {
+				invalid("vault.key_id", "must not be empty")
+			}
+			if _, err := vault.ParseKey(c.Vault.Key); err != nil {
+				invalid("vault.key", "%v", err)
+			}
+		}
+	}
+	if c.Vault.Key != "" && c.Vault.KeysFile != "" {
+		invalid("vault.keys_file", "must not be set together with vault.key")
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		invalid("tracing.exporter", "must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of c with its secrets masked, fit for printing.
+func (c Config) Redacted() Config {
+	c.Store.DSN = redactDSN(c.Store.DSN)
+	if c.Vault.Key != "" {
+		c.Vault.Key = redacted
+	}
+	if c.Bank.Chaos.AdminToken != "" {
+		c.Bank.Chaos.AdminToken = redacted
+	}
+	c.Bank.Chaos.Script = append([]string(nil), c.Bank.Chaos.Script...)
+	c.Payments.Currencies = append([]string(nil), c.Payments.Currencies...)
+	return c
+}
+
+// ErrNoVaultKey is returned by VaultConfig.KeyProvider when no key is configured.
+var ErrNoVaultKey = errors.New("no vault key is configured, set vault.key or vault.keys_file")
+
+// KeyProvider returns the provider of the configured vault keys.
+func (c VaultConfig) KeyProvider() (vault.KeyProvider, error) {
+	switch {
+	case c.KeysFile != "":
+		return vault.FileKeyProvider{Path: c.KeysFile}, nil
+	case c.Key != "":
+		key, err := vault.ParseKey(c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("vault.key: %w", err)
+		}
+		return vault.StaticKey{ID: c.KeyID, Key: key}, nil
+	default:
+		return nil, ErrNoVaultKey
+	}
+}
+
+// Bank returns the config of the bank.Chaos decorator, whose timeouts last
+// as long as the bank calls may.
+func (c ChaosConfig) Bank(timeout time.Duration) bank.ChaosConfig {
+	cfg := bank.ChaosConfig{
+		Rates:       make(map[bank.ChaosFault]float64),
+		Latency:     c.Latency,
+		Timeout:     timeout,
+		ErrorStatus: c.ErrorStatus,
+		Queries:     c.Queries,
+	}
+	for fault, rate := range map[bank.ChaosFault]float64{
+		bank.FaultLatency:           c.LatencyRate,
+		bank.FaultTimeout:           c.TimeoutRate,
+		bank.FaultServerError:       c.ErrorRate,
+		bank.FaultMalformedResponse: c.MalformedRate,
+		bank.FaultDropConnection:    c.DropRate,
+	} {
+		if rate != 0 {
+			cfg.Rates[fault] = rate
+		}
+	}
+	for _, fault := range c.Script {
+		cfg.Script = append(cfg.Script, bank.ChaosFault(fault))
+	}
+	return cfg
+}
+
+// Print writes c as YAML with its secrets redacted. The output is a valid
+// config file.
+func (c Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	return enc.Close()
+}
+
+// redacted replaces secrets in printed configs.
+const redacted = "xxxxx"
+
+// dsnPassword matches the password of key=value DSNs and of URL query parameters.
+var dsnPassword = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|[^\s&]+)`)
+
+// redactDSN masks the passwords in dsn, keeping the rest readable.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		dsn = u.Redacted()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
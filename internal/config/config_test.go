@@ -0,0 +1,379 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load("gateway", nil, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, ":8090", cfg.Server.Addr)
+	assert.Equal(t, 10*time.Second, cfg.Bank.Timeout)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "gateway.yaml", `
+server:
+  addr: ":9000"
+  request_timeout: 20s
+bank:
+  url: http://bank.file:8080
+  max_attempts: 5
+log:
+  level: debug
+payments:
+  currencies: [GBP, EUR]
+`)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want func(*Config)
+	}{
+		{
+			name: "file overrides defaults",
+			args: []string{"-config", file},
+			want: func(c *Config) {
+				c.Server.Addr = ":9000"
+				c.Server.RequestTimeout = 20 * time.Second
+				c.Bank.URL = "http://bank.file:8080"
+				c.Bank.MaxAttempts = 5
+				c.Log.Level = slog.LevelDebug
+				c.Payments.Currencies = []string{"GBP", "EUR"}
+			},
+		},
+		{
+			name: "environment overrides file",
+			args: []string{"-config", file},
+			env:  map[string]string{"GATEWAY_BANK_URL": "http://bank.env:8080", "GATEWAY_FEATURES_WEBHOOKS": "false"},
+			want: func(c *Config) {
+				c.Server.Addr = ":9000"
+				c.Server.RequestTimeout = 20 * time.Second
+				c.Bank.URL = "http://bank.env:8080"
+				c.Bank.MaxAttempts = 5
+				c.Log.Level = slog.LevelDebug
+				c.Payments.Currencies = []string{"GBP", "EUR"}
+				c.Features.Webhooks = false
+			},
+		},
+		{
+			name: "flags override environment and file",
+			args: []string{"-bank-url", "http://bank.flag:8080", "-bank-max-attempts=2", "-currencies", "USD", "-log-level", "WARN"},
+			env:  map[string]string{"GATEWAY_CONFIG": file, "GATEWAY_BANK_URL": "http://bank.env:8080"},
+			want: func(c *Config) {
+				c.Server.Addr = ":9000"
+				c.Server.RequestTimeout = 20 * time.Second
+				c.Bank.URL = "http://bank.flag:8080"
+				c.Bank.MaxAttempts = 2
+				c.Log.Level = slog.LevelWarn
+				c.Payments.Currencies = []string{"USD"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := Default()
+			tt.want(&want)
+
+			cfg, err := Load("gateway", tt.args, env(tt.env))
+			require.NoError(t, err)
+			assert.Equal(t, want, cfg)
+		})
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	file := writeFile(t, "gateway.toml", `
+[server]
+addr = ":9000"
+shutdown_delay = "15s"
+
+[bank.breaker]
+failure_rate = 0.25
+
+[features]
+metrics = false
+`)
+
+	cfg, err := Load("gateway", []string{"-config", file}, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, ":9000", cfg.Server.Addr)
+	assert.Equal(t, 15*time.Second, cfg.Server.ShutdownDelay)
+	assert.Equal(t, 0.25, cfg.Bank.Breaker.FailureRate)
+	assert.False(t, cfg.Features.Metrics)
+	assert.Equal(t, Default().Bank.Breaker.SlowCall, cfg.Bank.Breaker.SlowCall)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "unknown yaml key",
+			args:    []string{"-config", writeFile(t, "gateway.yaml", "bank:\n  urll: http://bank\n")},
+			wantErr: "field urll not found",
+		},
+		{
+			name:    "unknown toml key",
+			args:    []string{"-config", writeFile(t, "gateway.toml", "[bank]\nurll = \"http://bank\"\n")},
+			wantErr: "unknown keys in config file",
+		},
+		{
+			name:    "missing file",
+			args:    []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")},
+			wantErr: "failed to read config file",
+		},
+		{
+			name:    "bad environment value",
+			env:     map[string]string{"GATEWAY_BANK_TIMEOUT": "soon"},
+			wantErr: "GATEWAY_BANK_TIMEOUT",
+		},
+		{
+			name:    "bad flag value",
+			args:    []string{"-bank-max-attempts", "many"},
+			wantErr: "bank-max-attempts",
+		},
+		{
+			name:    "unexpected argument",
+			args:    []string{"serve"},
+			wantErr: "unexpected arguments: serve",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load("gateway", tt.args, env(tt.env))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	stderr := os.Stderr
+	os.Stderr, _ = os.Open(os.DevNull)
+	t.Cleanup(func() { os.Stderr = stderr })
+
+	_, err := Load("gateway", []string{"-h"}, env(nil))
+	assert.ErrorIs(t, err, flag.ErrHelp)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr []string
+	}{
+		{name: "defaults are valid", modify: func(*Config) {}},
+		{name: "sqlite store", modify: func(c *Config) { c.Store.Driver, c.Store.DSN = "sqlite", "file:payments.db" }},
+		{
+			name: "every invalid setting is reported",
+			modify: func(c *Config) {
+				c.Server.Addr = ""
+				c.Server.RequestTimeout = 0
+				c.Bank.URL = "localhost:8080"
+				c.Bank.MaxAttempts = 0
+				c.Bank.Breaker.FailureRate = 1.5
+				c.Store.Driver = "sqlite"
+				c.Payments.Currencies = []string{"XXX"}
+				c.Tracing.Exporter = "jaeger"
+			},
+			wantErr: []string{
+				"server.addr",
+				"server.request_timeout",
+				"bank.url",
+				"bank.max_attempts",
+				"bank.breaker.failure_rate",
+				"store.dsn",
+				"payments.currencies",
+				"tracing.exporter",
+			},
+		},
+		{name: "unknown store driver", modify: func(c *Config) { c.Store.Driver = "mysql" }, wantErr: []string{"store.driver"}},
+		{
+			name: "batch settings",
+			modify: func(c *Config) {
+				c.Payments.BatchConcurrency = 0
+				c.Payments.BatchQueueSize = -1
+			},
+			wantErr: []string{"payments.batch_concurrency", "payments.batch_queue_size"},
+		},
+		{
+			name: "batch settings are ignored without batches",
+			modify: func(c *Config) {
+				c.Features.Batches = false
+				c.Payments.BatchConcurrency = 0
+			},
+		},
+		{name: "negative shutdown delay", modify: func(c *Config) { c.Server.ShutdownDelay = -time.Second }, wantErr: []string{"server.shutdown_delay"}},
+		{name: "zero drain timeout", modify: func(c *Config) { c.Server.DrainTimeout = 0 }, wantErr: []string{"server.drain_timeout"}},
+		{name: "tokenization needs a vault key", modify: func(c *Config) { c.Features.Tokenization = true }, wantErr: []string{"vault.key"}},
+		{
+			name: "vault key must be 32 bytes",
+			modify: func(c *Config) {
+				c.Features.Tokenization = true
+				c.Vault.Key = base64.StdEncoding.EncodeToString([]byte("short"))
+			},
+			wantErr: []string{"vault.key"},
+		},
+		{
+			name: "tokenization with a keys file",
+			modify: func(c *Config) {
+				c.Features.Tokenization = true
+				c.Vault.KeysFile = "keys.json"
+			},
+		},
+		{
+			name: "both a vault key and a keys file",
+			modify: func(c *Config) {
+				c.Vault.Key = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+				c.Vault.KeysFile = "keys.json"
+			},
+			wantErr: []string{"vault.keys_file"},
+		},
+		{
+			name: "tokenization with a vault key",
+			modify: func(c *Config) {
+				c.Features.Tokenization = true
+				c.Vault.Key = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+			},
+		},
+		{
+			name: "chaos",
+			modify: func(c *Config) {
+				c.Features.Chaos = true
+				c.Bank.Chaos.ErrorRate = 0.5
+				c.Bank.Chaos.Script = []string{"timeout", "none", "drop_connection"}
+			},
+		},
+		{
+			name: "chaos rates add up above 1",
+			modify: func(c *Config) {
+				c.Features.Chaos = true
+				c.Bank.Chaos.ErrorRate = 0.6
+				c.Bank.Chaos.DropRate = 0.6
+			},
+			wantErr: []string{"bank.chaos"},
+		},
+		{
+			name: "unknown chaos fault",
+			modify: func(c *Config) {
+				c.Features.Chaos = true
+				c.Bank.Chaos.Script = []string{"fire"}
+			},
+			wantErr: []string{"bank.chaos"},
+		},
+		{
+			name:   "chaos settings are ignored when chaos is off",
+			modify: func(c *Config) { c.Bank.Chaos.Script = []string{"fire"} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, key := range tt.wantErr {
+				assert.Contains(t, err.Error(), key+":")
+			}
+		})
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{dsn: "file:payments.db", want: "file:payments.db"},
+		{dsn: "postgres://gateway:s3cret@db:5432/payments?sslmode=disable", want: "postgres://gateway:xxxxx@db:5432/payments?sslmode=disable"},
+		{dsn: "postgres://db/payments?user=gateway&password=s3cret&sslmode=disable", want: "postgres://db/payments?user=gateway&password=xxxxx&sslmode=disable"},
+		{dsn: "host=db user=gateway password=s3cret dbname=payments", want: "host=db user=gateway password=xxxxx dbname=payments"},
+		{dsn: "host=db password='s3 cret' dbname=payments", want: "host=db password=xxxxx dbname=payments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactDSN(tt.dsn))
+		})
+	}
+}
+
+func TestPrint(t *testing.T) {
+	cfg := Default()
+	cfg.Store.Driver = "postgres"
+	cfg.Store.DSN = "postgres://gateway:s3cret@db:5432/payments"
+	cfg.Vault.Key = "a2V5LWVuY3J5cHRpb24ta2V5"
+	cfg.Bank.Chaos.AdminToken = "chaos-t0ken"
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Print(&out))
+	assert.NotContains(t, out.String(), "s3cret")
+	assert.NotContains(t, out.String(), cfg.Vault.Key)
+	assert.NotContains(t, out.String(), cfg.Bank.Chaos.AdminToken)
+	assert.Contains(t, out.String(), "request_timeout: 10s")
+	assert.Contains(t, out.String(), "level: INFO")
+	assert.Equal(t, "postgres://gateway:s3cret@db:5432/payments", cfg.Store.DSN, "printing must not change the config")
+
+	// the output is a config file that loads back to the redacted config
+	path := writeFile(t, "printed.yaml", out.String())
+	loaded, err := Load("gateway", []string{"-config", path}, env(nil))
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Redacted(), loaded)
+}
+
+func TestSettings(t *testing.T) {
+	cfg := Default()
+	flags := make(map[string]bool)
+	envs := make(map[string]bool)
+	for _, s := range cfg.settings() {
+		assert.False(t, flags[s.flag], "duplicate flag %s", s.flag)
+		assert.False(t, envs[s.env()], "duplicate variable %s", s.env())
+		flags[s.flag], envs[s.env()] = true, true
+	}
+
+	assert.True(t, envs["GATEWAY_SERVER_ADDR"])
+	assert.True(t, envs["GATEWAY_BANK_BREAKER_FAILURE_RATE"])
+	assert.True(t, envs["GATEWAY_FEATURES_WEBHOOKS"])
+	// flags keep the names they had before the config file existed
+	assert.True(t, flags["store"])
+	assert.True(t, flags["bank-max-attempts"])
+	assert.True(t, flags["log-level"])
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the name of every environment variable read by Load. The
+// rest of the name is the key of the setting in the config file, upper cased
+// with dots replaced by underscores, such as GATEWAY_BANK_MAX_ATTEMPTS.
+const EnvPrefix = "GATEWAY_"
+
+// ConfigFileEnv names the config file when the -config flag is not given.
+const ConfigFileEnv = EnvPrefix + "CONFIG"
+
+// setting binds a field of Config to its key in the config file, its
+// environment variable and its command line flag.
+type setting struct {
+	key   string
+	flag  string
+	usage string
+	value flag.Value
+}
+
+// env returns the name of the environment variable of s.
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(s.key, ".", "_"))
+}
+
+// settings lists every setting of c. Flags keep the names they had before
+// the config file existed.
+func (c *Config) settings() []setting {
+	return []setting{
+		{"server.addr", "addr", "address the HTTP server listens on", (*stringValue)(&c.Server.Addr)},
+		{"server.request_timeout", "request-timeout", "time limit for serving a request", (*durationValue)(&c.Server.RequestTimeout)},
+		{"server.shutdown_delay", "shutdown-delay", "how long the gateway reports it is not ready before it stops serving on shutdown", (*durationValue)(&c.Server.ShutdownDelay)},
+		{"server.drain_timeout", "drain-timeout", "how long the requests in flight may take to finish once the gateway stops serving", (*durationValue)(&c.Server.DrainTimeout)},
+		{"server.health_cache_ttl", "health-cache-ttl", "how long the outcome of the readiness checks is reused", (*durationValue)(&c.Server.HealthCacheTTL)},
+		{"bank.url", "bank-url", "URL of the acquiring bank when there is no routing file", (*stringValue)(&c.Bank.URL)},
+		{"bank.timeout", "bank-timeout", "time limit for every attempt of a bank call", (*durationValue)(&c.Bank.Timeout)},
+		{"bank.max_attempts", "bank-max-attempts", "attempts per bank call for failures that are safe to retry", (*intValue)(&c.Bank.MaxAttempts)},
+		{"bank.routing_file", "routing", "JSON file with the acquirers and the rules routing payments between them, defaults to the bank URL only", (*stringValue)(&c.Bank.RoutingFile)},
+		{"bank.breaker.failure_rate", "breaker-failure-rate", "fraction of failed bank calls that opens the circuit breaker", (*floatValue)(&c.Bank.Breaker.FailureRate)},
+		{"bank.breaker.slow_call", "breaker-slow-call", "bank call duration counted as slow by the circuit breaker", (*durationValue)(&c.Bank.Breaker.SlowCall)},
+		{"bank.breaker.open_timeout", "breaker-open-timeout", "how long the circuit breaker stays open before trial calls", (*durationValue)(&c.Bank.Breaker.OpenTimeout)},
+		{"bank.chaos.latency_rate", "chaos-latency-rate", "probability of delaying a payment by the chaos latency, with features.chaos", (*floatValue)(&c.Bank.Chaos.LatencyRate)},
+		{"bank.chaos.timeout_rate", "chaos-timeout-rate", "probability of a payment timing out without reaching the bank, with features.chaos", (*floatValue)(&c.Bank.Chaos.TimeoutRate)},
+		{"bank.chaos.error_rate", "chaos-error-rate", "probability of a payment failing with the chaos error status, with features.chaos", (*floatValue)(&c.Bank.Chaos.ErrorRate)},
+		{"bank.chaos.malformed_rate", "chaos-malformed-rate", "probability of the bank's answer to a payment being replaced with invalid JSON, with features.chaos", (*floatValue)(&c.Bank.Chaos.MalformedRate)},
+		{"bank.chaos.drop_rate", "chaos-drop-rate", "probability of the connection dropping once the bank processed a payment, with features.chaos", (*floatValue)(&c.Bank.Chaos.DropRate)},
+		{"bank.chaos.script", "chaos-script", "comma separated faults of the first payments: none, latency, timeout, server_error, malformed_response or drop_connection", (*listValue)(&c.Bank.Chaos.Script)},
+		{"bank.chaos.latency", "chaos-latency", "delay of the latency fault", (*durationValue)(&c.Bank.Chaos.Latency)},
+		{"bank.chaos.error_status", "chaos-error-status", "5xx status of the server_error fault", (*intValue)(&c.Bank.Chaos.ErrorStatus)},
+		{"bank.chaos.queries", "chaos-queries", "inject the faults in the payment queries of the reconciler too", (*boolValue)(&c.Bank.Chaos.Queries)},
+		{"bank.chaos.admin_token", "chaos-admin-token", "bearer token of the /admin/chaos endpoint, which is only served when set", (*stringValue)(&c.Bank.Chaos.AdminToken)},
+		{"store.driver", "store", "payments store backend: memory, sqlite or postgres", (*stringValue)(&c.Store.Driver)},
+		{"store.dsn", "store-dsn", "data source name for the sqlite or postgres store", (*stringValue)(&c.Store.DSN)},
+		{"store.idempotency_ttl", "idempotency-ttl", "how long responses are kept for Idempotency-Key retries", (*durationValue)(&c.Store.IdempotencyTTL)},
+		{"payments.currencies", "currencies", "comma separated ISO 4217 codes payments may be made in", (*listValue)(&c.Payments.Currencies)},
+		{"payments.reconcile_interval", "reconcile-interval", "how often pending payments are reconciled with the bank", (*durationValue)(&c.Payments.ReconcileInterval)},
+		{"payments.reconcile_void_after", "reconcile-void-after", "how long a payment may stay pending before it is voided", (*durationValue)(&c.Payments.ReconcileVoidAfter)},
+		{"payments.batch_concurrency", "batch-concurrency", "payments of a batch made at the same time", (*intValue)(&c.Payments.BatchConcurrency)},
+		{"payments.batch_max_lines", "batch-max-lines", "largest number of payments in a batch", (*intValue)(&c.Payments.BatchMaxLines)},
+		{"payments.batch_max_size", "batch-max-size", "largest size of a batch upload in bytes", (*intValue)(&c.Payments.BatchMaxSize)},
+		{"payments.batch_queue_size", "batch-queue-size", "batches that may wait to be processed before uploads are refused", (*intValue)(&c.Payments.BatchQueueSize)},
+		{"auth.merchants_file", "merchants", "JSON file with the merchant registry and hashed API keys", (*stringValue)(&c.Auth.MerchantsFile)},
+		{"vault.key_id", "vault-key-id", "name of the vault key in the records it encrypts", (*stringValue)(&c.Vault.KeyID)},
+		{"vault.key", "vault-key", "base64 encoded 32 byte key encrypting the card tokens and authorization codes, such as the output of openssl rand -base64 32", (*stringValue)(&c.Vault.Key)},
+		{"vault.keys_file", "vault-keys-file", "JSON file with every version of the vault key, newest last", (*stringValue)(&c.Vault.KeysFile)},
+		{"vault.require_cvv", "vault-require-cvv", "require a CVV with payments by card token", (*boolValue)(&c.Vault.RequireCvv)},
+		{"log.level", "log-level", "minimum level of the records logged: DEBUG, INFO, WARN or ERROR", textValue{&c.Log.Level}},
+		{"tracing.exporter", "trace-exporter", "where spans are exported: none, stdout or otlp", (*stringValue)(&c.Tracing.Exporter)},
+		{"tracing.file", "trace-file", "file receiving the spans of the stdout exporter instead of stdout", (*stringValue)(&c.Tracing.File)},
+		{"tracing.endpoint", "trace-endpoint", "OTLP/HTTP collector URL, defaults to the OTEL_EXPORTER_OTLP_* environment variables", (*stringValue)(&c.Tracing.Endpoint)},
+		{"webhooks.allow_insecure_endpoints", "webhook-allow-insecure-endpoints", "accept http webhook endpoints and endpoints on loopback or private networks, for development only", (*boolValue)(&c.Webhooks.AllowInsecureEndpoints)},
+		{"features.idempotency", "feature-idempotency", "honour Idempotency-Key headers", (*boolValue)(&c.Features.Idempotency)},
+		{"features.webhooks", "feature-webhooks", "serve the webhook routes and deliver payment events", (*boolValue)(&c.Features.Webhooks)},
+		{"features.metrics", "feature-metrics", "record Prometheus metrics and serve them on /metrics", (*boolValue)(&c.Features.Metrics)},
+		{"features.reconciliation", "feature-reconciliation", "reconcile pending payments with the bank in the background", (*boolValue)(&c.Features.Reconciliation)},
+		{"features.tokenization", "feature-tokenization", "store cards in the vault and accept payments by card token, needs a vault key", (*boolValue)(&c.Features.Tokenization)},
+		{"features.chaos", "feature-chaos", "inject the bank.chaos faults in the bank calls, for test environments only", (*boolValue)(&c.Features.Chaos)},
+		{"features.batches", "feature-batches", "accept batches of payments uploaded as JSON lines", (*boolValue)(&c.Features.Batches)},
+	}
+}
+
+// Load builds the configuration from its defaults, the config file, the
+// environment and the command line flags in args, in increasing order of
+// precedence, and validates it. The config file is given with the -config
+// flag or the GATEWAY_CONFIG variable and is read as TOML when its name ends
+// in .toml and as YAML otherwise. lookupEnv is usually os.LookupEnv.
+func Load(name string, args []string, lookupEnv func(string) (string, bool)) (Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	file := fs.String("config", "", "YAML or TOML config file, defaults to $"+ConfigFileEnv)
+	for _, s := range settings {
+		fs.Var(s.value, s.flag, s.usage+" (env "+s.env()+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	// flags come last, so remember them and start over from the defaults
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name != "config" {
+			flags[f.Name] = f.Value.String()
+		}
+	})
+	cfg = Default()
+
+	if *file == "" {
+		*file, _ = lookupEnv(ConfigFileEnv)
+	}
+	if *file != "" {
+		if err := loadFile(*file, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if v, ok := lookupEnv(s.env()); ok {
+			if err := s.value.Set(v); err != nil {
+				return Config{}, fmt.Errorf("invalid value %q for %s: %w", v, s.env(), err)
+			}
+		}
+	}
+
+	for name, v := range flags {
+		if err := fs.Lookup(name).Value.Set(v); err != nil {
+			return Config{}, fmt.Errorf("invalid value %q for flag -%s: %w", v, name, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile decodes the config file at path over cfg. Keys the file does not
+// set keep their value, and unknown keys are errors so typos do not go unnoticed.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, key := range undecoded {
+				keys[i] = key.String()
+			}
+			sort.Strings(keys)
+			return fmt.Errorf("unknown keys in config file %s: %s", path, strings.Join(keys, ", "))
+		}
+		return nil
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.New("not an integer")
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type floatValue float64
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return errors.New("not a number")
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.New("not a duration such as 1m30s")
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return errors.New("not a boolean")
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+// IsBoolFlag lets -feature-webhooks stand for -feature-webhooks=true.
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+// listValue is a comma separated list. Setting it replaces the whole list.
+type listValue []string
+
+func (v *listValue) Set(s string) error {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*v = list
+	return nil
+}
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+type textVar interface {
+	encoding.TextMarshaler
+	encoding.TextUnmarshaler
+}
+
+type textValue struct{ v textVar }
+
+func (v textValue) Set(s string) error { return v.v.UnmarshalText([]byte(s)) }
+
+func (v textValue) String() string {
+	if v.v == nil {
+		return ""
+	}
+	b, _ := v.v.MarshalText()
+	return string(b)
+}
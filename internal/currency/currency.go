@@ -0,0 +1,133 @@
+package currency
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// iso4217 is the list of active ISO 4217 currencies with their minor units.
+// Fund codes, precious metals and other codes without minor units are left out
+// because they cannot be charged to a card.
+//
+//go:embed iso4217.csv
+var iso4217 string
+
+// maxMajorUnits is the largest amount, in major units, accepted for any currency.
+const maxMajorUnits = 999_999_999
+
+// DefaultCodes are the currencies enabled when none are configured.
+var DefaultCodes = []string{"USD", "GBP", "EUR"}
+
+// Currency describes an ISO 4217 currency
+type Currency struct {
+	Code    string
+	Numeric string
+	Name    string
+	// Exponent is the number of minor unit digits, e.g. 2 for USD and 0 for JPY
+	Exponent int
+}
+
+var currencies = mustParse(iso4217)
+
+func mustParse(data string) map[string]Currency {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("invalid ISO 4217 dataset: %v", err))
+	}
+
+	result := make(map[string]Currency, len(records))
+	for _, r := range records[1:] {
+		exponent, err := strconv.Atoi(r[2])
+		if err != nil {
+			panic(fmt.Sprintf("invalid minor units for %s: %v", r[0], err))
+		}
+		result[r[0]] = Currency{Code: r[0], Numeric: r[1], Name: r[3], Exponent: exponent}
+	}
+	return result
+}
+
+// Lookup returns the ISO 4217 currency with the given alphabetic code.
+func Lookup(code string) (Currency, bool) {
+	c, ok := currencies[code]
+	return c, ok
+}
+
+// MaxAmount is the largest amount in minor units accepted for the currency.
+func (c Currency) MaxAmount() int {
+	return maxMajorUnits*pow10(c.Exponent) + pow10(c.Exponent) - 1
+}
+
+// Format renders an amount in minor units as a decimal string using the
+// currency's exponent, e.g. 1050 is "10.50" in USD, "1050" in JPY and "1.050"
+// in BHD.
+func (c Currency) Format(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	if c.Exponent == 0 {
+		return sign + strconv.Itoa(amount)
+	}
+
+	unit := pow10(c.Exponent)
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/unit, c.Exponent, amount%unit)
+}
+
+// Set is a set of enabled currencies
+type Set struct {
+	currencies map[string]Currency
+}
+
+// NewSet returns the set of the given ISO 4217 codes. It fails if a code is
+// not a known currency.
+func NewSet(codes ...string) (*Set, error) {
+	s := &Set{currencies: make(map[string]Currency, len(codes))}
+	for _, code := range codes {
+		c, ok := Lookup(strings.ToUpper(strings.TrimSpace(code)))
+		if !ok {
+			return nil, fmt.Errorf("unknown ISO 4217 currency %q", code)
+		}
+		s.currencies[c.Code] = c
+	}
+	return s, nil
+}
+
+// Get returns the enabled currency with the given code.
+func (s *Set) Get(code string) (Currency, bool) {
+	c, ok := s.currencies[code]
+	return c, ok
+}
+
+// Codes returns the enabled currency codes in alphabetical order.
+func (s *Set) Codes() []string {
+	codes := make([]string, 0, len(s.currencies))
+	for code := range s.currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// FormatAmount formats amount for any ISO 4217 currency. Unknown codes are
+// formatted without a decimal point.
+func FormatAmount(code string, amount int) string {
+	c, ok := Lookup(code)
+	if !ok {
+		return strconv.Itoa(amount)
+	}
+	return c.Format(amount)
+}
+
+func pow10(n int) int {
+	result := 1
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
@@ -0,0 +1,79 @@
+package currency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code     string
+		exponent int
+	}{
+		{"USD", 2},
+		{"JPY", 0},
+		{"KRW", 0},
+		{"BHD", 3},
+		{"KWD", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			c, ok := Lookup(tt.code)
+			require.True(t, ok)
+			assert.Equal(t, tt.exponent, c.Exponent)
+		})
+	}
+
+	_, ok := Lookup("XAU")
+	assert.False(t, ok, "precious metals have no minor units and are not listed")
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		code     string
+		amount   int
+		expected string
+	}{
+		{"USD", 1050, "10.50"},
+		{"USD", 5, "0.05"},
+		{"USD", -1050, "-10.50"},
+		{"JPY", 1050, "1050"},
+		{"BHD", 1050, "1.050"},
+		{"KWD", 7, "0.007"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code+" "+tt.expected, func(t *testing.T) {
+			assert.Equal(t, tt.expected, FormatAmount(tt.code, tt.amount))
+		})
+	}
+
+	assert.Equal(t, "1050", FormatAmount("ZZZ", 1050))
+}
+
+func TestMaxAmount(t *testing.T) {
+	usd, _ := Lookup("USD")
+	jpy, _ := Lookup("JPY")
+	bhd, _ := Lookup("BHD")
+
+	assert.Equal(t, "999999999.99", usd.Format(usd.MaxAmount()))
+	assert.Equal(t, "999999999", jpy.Format(jpy.MaxAmount()))
+	assert.Equal(t, "999999999.999", bhd.Format(bhd.MaxAmount()))
+}
+
+func TestSet(t *testing.T) {
+	s, err := NewSet("usd", " JPY", "GBP")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GBP", "JPY", "USD"}, s.Codes())
+
+	_, ok := s.Get("JPY")
+	assert.True(t, ok)
+	_, ok = s.Get("EUR")
+	assert.False(t, ok)
+
+	_, err = NewSet("USD", "ABC")
+	assert.Error(t, err)
+}
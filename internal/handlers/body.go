@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
+)
+
+// MaxBodySize returns a middleware that fails the reads of request bodies
+// larger than limit bytes with an *http.MaxBytesError. It must come before
+// any middleware reading the body, such as Idempotency.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bodyTooLarge writes a payload_too_large problem and returns true when err
+// comes from reading a body past the limit of MaxBodySize.
+func bodyTooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+	problem.Error(w, r, http.StatusRequestEntityTooLarge, models.CodePayloadTooLarge,
+		fmt.Sprintf("the request body is larger than %d bytes", maxBytesErr.Limit))
+	return true
+}
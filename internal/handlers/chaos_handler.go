@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
+)
+
+type ChaosHandler struct {
+	chaos *bank.Chaos
+}
+
+func NewChaosHandler(chaos *bank.Chaos) *ChaosHandler {
+	return &ChaosHandler{chaos: chaos}
+}
+
+// GetHandler returns an http.HandlerFunc that answers with the faults being
+// injected, and the part of the script that has not run yet.
+func (h *ChaosHandler) GetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeChaosSettings(w, h.chaos.Config())
+	}
+}
+
+// PatchHandler returns an http.HandlerFunc that changes the faults being
+// injected. Fields left out of the body keep their value and rates are
+// merged, so a rate is removed by setting it to 0. A script in the body
+// replaces the rest of the current one.
+func (h *ChaosHandler) PatchHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings := toChaosSettings(h.chaos.Config())
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			problem.Error(w, r, http.StatusBadRequest, models.CodeMalformedRequest, "the request body is not valid JSON")
+			return
+		}
+
+		cfg, err := fromChaosSettings(settings)
+		if err == nil {
+			err = h.chaos.SetConfig(cfg)
+		}
+		if err != nil {
+			problem.Error(w, r, http.StatusBadRequest, models.CodeValidationFailed, err.Error())
+			return
+		}
+
+		writeChaosSettings(w, h.chaos.Config())
+	}
+}
+
+// DeleteHandler returns an http.HandlerFunc that stops injecting faults. The
+// latency, timeout and error status are kept for the next faults.
+func (h *ChaosHandler) DeleteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := h.chaos.Config()
+		cfg.Rates, cfg.Script = nil, nil
+		if err := h.chaos.SetConfig(cfg); err != nil {
+			problem.Internal(w, r, err, "Failed to stop injecting faults")
+			return
+		}
+
+		writeChaosSettings(w, h.chaos.Config())
+	}
+}
+
+func writeChaosSettings(w http.ResponseWriter, cfg bank.ChaosConfig) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(toChaosSettings(cfg))
+}
+
+func toChaosSettings(cfg bank.ChaosConfig) models.ChaosSettings {
+	settings := models.ChaosSettings{
+		Rates:       make(map[string]float64, len(cfg.Rates)),
+		Script:      make([]string, len(cfg.Script)),
+		Latency:     cfg.Latency.String(),
+		Timeout:     cfg.Timeout.String(),
+		ErrorStatus: cfg.ErrorStatus,
+		Queries:     cfg.Queries,
+	}
+	for fault, rate := range cfg.Rates {
+		settings.Rates[string(fault)] = rate
+	}
+	for i, fault := range cfg.Script {
+		settings.Script[i] = string(fault)
+	}
+	return settings
+}
+
+func fromChaosSettings(settings models.ChaosSettings) (bank.ChaosConfig, error) {
+	latency, err := time.ParseDuration(settings.Latency)
+	if err != nil {
+		return bank.ChaosConfig{}, errors.New("latency is not a duration such as 1.5s")
+	}
+	timeout, err := time.ParseDuration(settings.Timeout)
+	if err != nil {
+		return bank.ChaosConfig{}, errors.New("timeout is not a duration such as 10s")
+	}
+
+	cfg := bank.ChaosConfig{
+		Rates:       make(map[bank.ChaosFault]float64, len(settings.Rates)),
+		Latency:     latency,
+		Timeout:     timeout,
+		ErrorStatus: settings.ErrorStatus,
+		Queries:     settings.Queries,
+	}
+	for fault, rate := range settings.Rates {
+		if rate != 0 {
+			cfg.Rates[bank.ChaosFault(fault)] = rate
+		}
+	}
+	for _, fault := range settings.Script {
+		cfg.Script = append(cfg.Script, bank.ChaosFault(fault))
+	}
+	return cfg, nil
+}
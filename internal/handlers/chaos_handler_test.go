@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosHandler(t *testing.T) {
+	chaos := bank.NewChaos(bank.ChaosConfig{
+		Rates:   map[bank.ChaosFault]float64{bank.FaultServerError: 0.2},
+		Latency: time.Second,
+		Timeout: 10 * time.Second,
+	})
+	h := NewChaosHandler(chaos)
+
+	r := chi.NewRouter()
+	r.Get("/admin/chaos", h.GetHandler())
+	r.Patch("/admin/chaos", h.PatchHandler())
+	r.Delete("/admin/chaos", h.DeleteHandler())
+
+	do := func(t *testing.T, method, body string) (int, models.ChaosSettings) {
+		t.Helper()
+		req := httptest.NewRequest(method, "/admin/chaos", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var settings models.ChaosSettings
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &settings))
+		}
+		return w.Code, settings
+	}
+
+	t.Run("get", func(t *testing.T) {
+		status, settings := do(t, "GET", "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, map[string]float64{"server_error": 0.2}, settings.Rates)
+		assert.Equal(t, "1s", settings.Latency)
+		assert.Equal(t, "10s", settings.Timeout)
+	})
+
+	t.Run("patch", func(t *testing.T) {
+		status, settings := do(t, "PATCH", `{"rates":{"server_error":0,"drop_connection":0.5},"script":["timeout","none"],"latency":"250ms"}`)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, map[string]float64{"drop_connection": 0.5}, settings.Rates)
+		assert.Equal(t, []string{"timeout", "none"}, settings.Script)
+		assert.Equal(t, "250ms", settings.Latency)
+		assert.Equal(t, "10s", settings.Timeout, "fields left out keep their value")
+
+		cfg := chaos.Config()
+		assert.Equal(t, map[bank.ChaosFault]float64{bank.FaultDropConnection: 0.5}, cfg.Rates)
+		assert.Equal(t, 250*time.Millisecond, cfg.Latency)
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		for _, body := range []string{
+			`{`,
+			`{"latency":"soon"}`,
+			`{"rates":{"fire":0.5}}`,
+			`{"rates":{"timeout":0.9}}`,
+			`{"error_status":404}`,
+		} {
+			status, _ := do(t, "PATCH", body)
+			assert.Equal(t, http.StatusBadRequest, status, body)
+		}
+		assert.Equal(t, map[bank.ChaosFault]float64{bank.FaultDropConnection: 0.5}, chaos.Config().Rates, "invalid settings change nothing")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		status, settings := do(t, "DELETE", "")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Empty(t, settings.Rates)
+		assert.Empty(t, settings.Script)
+		assert.Equal(t, "250ms", settings.Latency)
+	})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	DefaultIdempotencyTTL    = 24 * time.Hour
+	maxIdempotencyKeyLength  = 255
+)
+
+// Idempotency returns a middleware that makes requests carrying an Idempotency-Key
+// header safe to retry. The first request for a key is processed and its response
+// stored for ttl. A repeat with the same body replays the stored response, a repeat
+// with a different body is rejected with 422 and a repeat that arrives while the
+// first request is still being processed is rejected with 409.
+//
+// Server errors are not stored, so a request that failed with a 5xx can be retried
+// with the same key. Handlers must therefore answer a 5xx only when the request
+// changed nothing a retry could repeat: once the bank may have authorized a
+// payment it is answered as pending with 202, which is stored.
+func Idempotency(store repository.IdempotencyRepository, ttl time.Duration) func(http.Handler) http.Handler {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				problem.Validation(w, r, []models.ValidationError{{Field: IdempotencyKeyHeader, Message: "Idempotency-Key must be at most 255 characters"}})
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if bodyTooLarge(w, r, err) {
+				return
+			}
+			if err != nil {
+				problem.Error(w, r, http.StatusBadRequest, models.CodeMalformedRequest, "the request body could not be read")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// keys are scoped per merchant so merchants cannot collide or replay each other's responses
+			key = auth.MerchantID(ctx) + ":" + key
+			hash := requestHash(r, body)
+			record, created, err := store.Reserve(ctx, key, hash, ttl)
+			if err != nil {
+				problem.Internal(w, r, err, "Failed to process idempotency key")
+				return
+			}
+
+			if !created {
+				switch {
+				case record.RequestHash != hash:
+					problem.Error(w, r, http.StatusUnprocessableEntity, models.CodeIdempotencyKeyReused, models.ErrIdempotencyKeyMismatch.Error())
+				case !record.Completed:
+					problem.Error(w, r, http.StatusConflict, models.CodeIdempotencyKeyInUse, models.ErrIdempotencyInFlight.Error())
+				default:
+					if record.ContentType != "" {
+						w.Header().Set("Content-Type", record.ContentType)
+					}
+					w.Header().Set(IdempotentReplayedHeader, "true")
+					w.WriteHeader(record.StatusCode)
+					w.Write(record.Body)
+				}
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			completed := false
+			defer func() {
+				// release the key if the handler panicked or failed so the client can retry,
+				// even when the request was cancelled meanwhile
+				if !completed {
+					store.Release(context.WithoutCancel(ctx), key)
+				}
+			}()
+
+			next.ServeHTTP(rec, r)
+
+			if rec.statusCode >= http.StatusInternalServerError {
+				return
+			}
+			if err := store.Complete(context.WithoutCancel(ctx), key, rec.statusCode, rec.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// requestHash fingerprints the parts of a request that must match for a replay.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes the response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
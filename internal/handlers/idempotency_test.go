@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	newHandler := func(calls *int, status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls++
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"id":"created-id"}`))
+		})
+	}
+
+	post := func(h http.Handler, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/payments", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("replays stored response for same key and body", func(t *testing.T) {
+		calls := 0
+		h := Idempotency(repository.NewIdempotencyRepository(), time.Hour)(newHandler(&calls, http.StatusOK))
+
+		first := post(h, "key-1", `{"amount":100}`)
+		second := post(h, "key-1", `{"amount":100}`)
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "application/json", second.Header().Get("Content-Type"))
+		assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("rejects same key with different body", func(t *testing.T) {
+		calls := 0
+		h := Idempotency(repository.NewIdempotencyRepository(), time.Hour)(newHandler(&calls, http.StatusOK))
+
+		post(h, "key-1", `{"amount":100}`)
+		w := post(h, "key-1", `{"amount":200}`)
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, models.CodeIdempotencyKeyReused, decodeProblem(t, w).Code)
+	})
+
+	t.Run("rejects repeat while first request is in flight", func(t *testing.T) {
+		store := repository.NewIdempotencyRepository()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		h := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		done := make(chan struct{})
+		go func() {
+			post(h, "key-1", `{"amount":100}`)
+			close(done)
+		}()
+		<-started
+
+		w := post(h, "key-1", `{"amount":100}`)
+		close(release)
+		<-done
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, models.CodeIdempotencyKeyInUse, decodeProblem(t, w).Code)
+	})
+
+	t.Run("allows retry after server error", func(t *testing.T) {
+		calls := 0
+		h := Idempotency(repository.NewIdempotencyRepository(), time.Hour)(newHandler(&calls, http.StatusBadGateway))
+
+		post(h, "key-1", `{"amount":100}`)
+		w := post(h, "key-1", `{"amount":100}`)
+
+		assert.Equal(t, 2, calls)
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+	})
+
+	t.Run("releases key of a cancelled request", func(t *testing.T) {
+		calls := 0
+		store := cancelAwareStore{repository.NewIdempotencyRepository()}
+		h := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("POST", "/api/payments", strings.NewReader(`{"amount":100}`)).WithContext(ctx)
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		cancel()
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		post(h, "key-1", `{"amount":100}`)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("processes again after ttl expires", func(t *testing.T) {
+		calls := 0
+		h := Idempotency(repository.NewIdempotencyRepository(), time.Nanosecond)(newHandler(&calls, http.StatusOK))
+
+		post(h, "key-1", `{"amount":100}`)
+		time.Sleep(time.Millisecond)
+		post(h, "key-1", `{"amount":100}`)
+
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("scopes keys per merchant", func(t *testing.T) {
+		calls := 0
+		h := Idempotency(repository.NewIdempotencyRepository(), time.Hour)(newHandler(&calls, http.StatusOK))
+
+		for _, merchant := range []string{"merchant-1", "merchant-2"} {
+			req := httptest.NewRequest("POST", "/api/payments", strings.NewReader(`{"amount":100}`))
+			req = req.WithContext(auth.WithMerchantID(req.Context(), merchant))
+			req.Header.Set(IdempotencyKeyHeader, "key-1")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("passes through without key", func(t *testing.T) {
+		calls := 0
+		h := Idempotency(repository.NewIdempotencyRepository(), time.Hour)(newHandler(&calls, http.StatusOK))
+
+		post(h, "", `{"amount":100}`)
+		post(h, "", `{"amount":100}`)
+
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("refuses bodies past the size limit", func(t *testing.T) {
+		calls := 0
+		h := MaxBodySize(8)(Idempotency(repository.NewIdempotencyRepository(), time.Hour)(newHandler(&calls, http.StatusOK)))
+
+		w := post(h, "key-1", `{"amount":100}`)
+
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, models.CodePayloadTooLarge, decodeProblem(t, w).Code)
+	})
+}
+
+// cancelAwareStore fails like the SQL store does when the context is cancelled.
+type cancelAwareStore struct {
+	repository.IdempotencyRepository
+}
+
+func (s cancelAwareStore) Release(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.IdempotencyRepository.Release(ctx, key)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type PaymentBatchesHandler struct {
+	batches services.BatchService
+}
+
+func NewPaymentBatchesHandler(batches services.BatchService) *PaymentBatchesHandler {
+	return &PaymentBatchesHandler{batches: batches}
+}
+
+// PostHandler returns an http.HandlerFunc that queues the newline-delimited
+// payment requests of the body to be paid. It answers before any payment is
+// made, the outcome is read from the batch status and results.
+func (h *PaymentBatchesHandler) PostHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		response, err := h.batches.SubmitBatch(ctx, r.Body)
+		if bodyTooLarge(w, r, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, models.ErrEmptyPaymentBatch):
+			problem.Error(w, r, http.StatusBadRequest, models.CodeValidationFailed, err.Error())
+			return
+		case errors.Is(err, models.ErrPaymentBatchTooLarge), errors.Is(err, models.ErrPaymentBatchLineTooLong):
+			problem.Error(w, r, http.StatusRequestEntityTooLarge, models.CodePayloadTooLarge, err.Error())
+			return
+		case errors.Is(err, models.ErrPaymentBatchQueueFull):
+			problem.Error(w, r, http.StatusServiceUnavailable, models.CodePaymentBatchQueueFull, err.Error()+", try again later")
+			return
+		case err != nil:
+			problem.Internal(w, r, err, "Failed to submit payment batch")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/payment-batches/"+response.Id)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetHandler returns an http.HandlerFunc that returns the progress of a batch.
+func (h *PaymentBatchesHandler) GetHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if !validID(w, r, id) {
+			return
+		}
+
+		response, err := h.batches.GetBatch(r.Context(), id)
+		if errors.Is(err, models.ErrPaymentBatchNotFound) {
+			problem.Error(w, r, http.StatusNotFound, models.CodeNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to read payment batch")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ResultsHandler returns an http.HandlerFunc that writes the results of the
+// lines of a batch processed so far as JSON lines, in line order.
+func (h *PaymentBatchesHandler) ResultsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if !validID(w, r, id) {
+			return
+		}
+
+		results, err := h.batches.ListBatchResults(r.Context(), id)
+		if errors.Is(err, models.ErrPaymentBatchNotFound) {
+			problem.Error(w, r, http.StatusNotFound, models.CodeNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to read payment batch results")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+id+`.jsonl"`)
+		enc := json.NewEncoder(w)
+		for _, result := range results {
+			enc.Encode(result)
+		}
+	}
+}
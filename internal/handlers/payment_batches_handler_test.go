@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	mock_services "github.com/cko-recruitment/payment-gateway-challenge-go/internal/services/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentBatchesHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockBatchSvc := mock_services.NewMockBatchService(ctrl)
+
+	batches := NewPaymentBatchesHandler(mockBatchSvc)
+
+	r := chi.NewRouter()
+	r.Post("/api/payment-batches", batches.PostHandler())
+	r.Get("/api/payment-batches/{id}", batches.GetHandler())
+	r.Get("/api/payment-batches/{id}/results", batches.ResultsHandler())
+
+	id := uuid.New().String()
+
+	t.Run("submit", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/payment-batches", bytes.NewBufferString("{}\n{}\n"))
+		w := httptest.NewRecorder()
+
+		mockBatchSvc.EXPECT().SubmitBatch(gomock.Any(), gomock.Any()).
+			Return(&models.PaymentBatchResponse{Id: id, Status: models.BatchProcessing, Total: 2}, nil)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "/api/payment-batches/"+id, w.Header().Get("Location"))
+		assert.Contains(t, w.Body.String(), `"status":"processing"`)
+	})
+
+	t.Run("submit errors", func(t *testing.T) {
+		for err, status := range map[error]int{
+			models.ErrEmptyPaymentBatch:       http.StatusBadRequest,
+			models.ErrPaymentBatchTooLarge:    http.StatusRequestEntityTooLarge,
+			models.ErrPaymentBatchLineTooLong: http.StatusRequestEntityTooLarge,
+			models.ErrPaymentBatchQueueFull:   http.StatusServiceUnavailable,
+		} {
+			req := httptest.NewRequest("POST", "/api/payment-batches", bytes.NewBufferString(""))
+			w := httptest.NewRecorder()
+
+			mockBatchSvc.EXPECT().SubmitBatch(gomock.Any(), gomock.Any()).Return(nil, err)
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, status, w.Code, err.Error())
+		}
+	})
+
+	t.Run("submit too large", func(t *testing.T) {
+		limited := chi.NewRouter()
+		limited.With(MaxBodySize(8)).Post("/api/payment-batches", batches.PostHandler())
+
+		req := httptest.NewRequest("POST", "/api/payment-batches", bytes.NewBufferString("{}\n{}\n{}\n"))
+		w := httptest.NewRecorder()
+
+		mockBatchSvc.EXPECT().SubmitBatch(gomock.Any(), gomock.Any()).DoAndReturn(
+			func(_ context.Context, body io.Reader) (*models.PaymentBatchResponse, error) {
+				_, err := io.ReadAll(body)
+				return nil, fmt.Errorf("failed to read payment batch: %w", err)
+			})
+
+		limited.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Equal(t, models.CodePayloadTooLarge, decodeProblem(t, w).Code)
+	})
+
+	t.Run("get unknown batch", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/payment-batches/"+id, nil)
+		w := httptest.NewRecorder()
+
+		mockBatchSvc.EXPECT().GetBatch(gomock.Any(), id).Return(nil, models.ErrPaymentBatchNotFound)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("results", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/payment-batches/"+id+"/results", nil)
+		w := httptest.NewRecorder()
+
+		mockBatchSvc.EXPECT().ListBatchResults(gomock.Any(), id).Return([]models.PaymentBatchResult{
+			{Line: 1, ClientReference: "a", Status: models.StatusAuthorized, PaymentId: "payment-1"},
+			{Line: 2, ClientReference: "b", Status: models.StatusRejected, Errors: []models.ValidationError{{Field: "cvv", Message: "is required"}}},
+		}, nil)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+		var lines []models.PaymentBatchResult
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var result models.PaymentBatchResult
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+			lines = append(lines, result)
+		}
+		require.Len(t, lines, 2)
+		assert.Equal(t, "payment-1", lines[0].PaymentId)
+		assert.Equal(t, "cvv", lines[1].Errors[0].Field)
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/payment-batches/not-a-uuid/results", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
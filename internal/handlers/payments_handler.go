@@ -1,12 +1,23 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/services"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
@@ -31,18 +42,17 @@ func (h *PaymentsHandler) GetHandler() http.HandlerFunc {
 		ctx := r.Context()
 		id := chi.URLParam(r, "id")
 
-		if err := uuid.Validate(id); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+		if !validID(w, r, id) {
 			return
 		}
 
 		response, err := h.paymentProcessor.GetPayment(ctx, id)
 		if err != nil {
 			if errors.Is(err, models.ErrPaymentNotFound) {
-				w.WriteHeader(http.StatusNotFound)
+				problem.Error(w, r, http.StatusNotFound, models.CodeNotFound, err.Error())
 				return
 			}
-			w.WriteHeader(http.StatusInternalServerError)
+			problem.Internal(w, r, err, "Failed to read payment")
 			return
 		}
 
@@ -54,39 +64,159 @@ func (h *PaymentsHandler) GetHandler() http.HandlerFunc {
 	}
 }
 
+// ListHandler returns an http.HandlerFunc that handles HTTP GET requests
+// listing the merchant's payments. Filters, the page size and the cursor are
+// read from the query string.
+func (h *PaymentsHandler) ListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		filter, validationErrors := parsePaymentFilter(r.URL.Query())
+
+		limit := models.DefaultPageSize
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > models.MaxPageSize {
+				validationErrors = append(validationErrors, models.ValidationError{
+					Field:   "limit",
+					Message: fmt.Sprintf("limit must be between 1 and %d", models.MaxPageSize),
+				})
+			}
+			limit = n
+		}
+
+		if len(validationErrors) > 0 {
+			problem.Validation(w, r, validationErrors)
+			return
+		}
+
+		response, err := h.paymentProcessor.ListPayments(ctx, filter, r.URL.Query().Get("cursor"), limit)
+		if errors.Is(err, models.ErrInvalidCursor) {
+			problem.Validation(w, r, []models.ValidationError{{Field: "cursor", Message: err.Error()}})
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to list payments")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// parsePaymentFilter reads the payment filters from the query string.
+func parsePaymentFilter(q url.Values) (models.PaymentFilter, []models.ValidationError) {
+	var (
+		filter models.PaymentFilter
+		errs   []models.ValidationError
+	)
+
+	if status := q.Get("status"); status != "" {
+		filter.Status = models.PaymentStatus(status)
+		if !slices.Contains(models.PaymentStatuses, filter.Status) {
+			errs = append(errs, models.ValidationError{Field: "status", Message: "unknown payment status"})
+		}
+	}
+
+	filter.Currency = strings.ToUpper(q.Get("currency"))
+	filter.Reference = q.Get("reference")
+
+	if last4 := q.Get("card_last_four"); last4 != "" {
+		if len(last4) != 4 || strings.Trim(last4, "0123456789") != "" {
+			errs = append(errs, models.ValidationError{Field: "card_last_four", Message: "card_last_four must be 4 digits"})
+		}
+		filter.CardNumberLastFour = last4
+	}
+
+	filter.MinAmount = parsePositiveInt(q, "min_amount", &errs)
+	filter.MaxAmount = parsePositiveInt(q, "max_amount", &errs)
+	filter.CreatedFrom = parseTimestamp(q, "created_from", &errs)
+	filter.CreatedTo = parseTimestamp(q, "created_to", &errs)
+
+	return filter, errs
+}
+
+func parsePositiveInt(q url.Values, field string, errs *[]models.ValidationError) int {
+	raw := q.Get(field)
+	if raw == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		*errs = append(*errs, models.ValidationError{Field: field, Message: field + " must be a positive integer"})
+		return 0
+	}
+	return n
+}
+
+func parseTimestamp(q url.Values, field string, errs *[]models.ValidationError) time.Time {
+	raw := q.Get(field)
+	if raw == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		*errs = append(*errs, models.ValidationError{Field: field, Message: field + " must be an RFC 3339 timestamp"})
+	}
+	return t
+}
+
+// validID reports whether id is a UUID, writing a validation problem when it
+// is not.
+func validID(w http.ResponseWriter, r *http.Request, id string) bool {
+	if err := uuid.Validate(id); err != nil {
+		problem.Validation(w, r, []models.ValidationError{{Field: "id", Message: "id must be a UUID"}})
+		return false
+	}
+	return true
+}
+
 // PostHandler returns an http.HandlerFunc that handles HTTP POST requests to process payments.
 func (h *PaymentsHandler) PostHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := r.Context()
+		ctx, span := tracing.Start(r.Context(), "PaymentsHandler.PostHandler")
+		defer span.End()
 		w.Header().Set("Content-Type", "application/json")
 
 		// Parse request body
 		var req models.PaymentRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{
-				Error: "Invalid request body",
-			})
+			problem.Error(w, r, http.StatusBadRequest, models.CodeMalformedRequest, "the request body is not valid JSON")
 			return
 		}
 
 		// Validate request
 		validationErrors := h.validator.ValidatePaymentRequest(ctx, req)
 		if len(validationErrors) > 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(models.ErrorResponse{
-				Error:  string(services.StatusRejected),
-				Errors: validationErrors,
-			})
+			problem.Validation(w, r, validationErrors)
 			return
 		}
 
 		response, err := h.paymentProcessor.CreatePayment(ctx, req)
+		if errors.Is(err, models.ErrCardTokenNotFound) || errors.Is(err, models.ErrCardExpired) {
+			problem.Validation(w, r, []models.ValidationError{{Field: "source", Message: err.Error()}})
+			return
+		}
+		if errors.Is(err, models.ErrAcquirerUnavailable) {
+			problem.Error(w, r, http.StatusServiceUnavailable, models.CodeAcquirerUnavailable, "the acquirer is unavailable, try again later")
+			return
+		}
+		if errors.Is(err, models.ErrAcquirerFailed) {
+			// the acquirer's error is logged, not shown to the merchant
+			slog.ErrorContext(ctx, "payment processing failed", slog.Any("error", err))
+			problem.Error(w, r, http.StatusBadGateway, models.CodePaymentFailed, "the payment could not be processed")
+			return
+		}
 		if err != nil {
-			w.WriteHeader(http.StatusBadGateway)
-			json.NewEncoder(w).Encode(models.ErrorResponse{
-				Error: "Payment processing failed: " + err.Error(),
-			})
+			problem.Internal(w, r, err, "Failed to process payment")
+			return
+		}
+
+		// the bank outcome is unknown until the payment is reconciled
+		if response.Status == models.StatusPending {
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(response)
 			return
 		}
 
@@ -97,3 +227,67 @@ func (h *PaymentsHandler) PostHandler() http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// CaptureHandler returns an http.HandlerFunc that captures funds of an authorized payment.
+func (h *PaymentsHandler) CaptureHandler() http.HandlerFunc {
+	return h.operationHandler(true, func(ctx context.Context, id string, amount int) (*models.OperationResponse, error) {
+		return h.paymentProcessor.CapturePayment(ctx, id, amount)
+	})
+}
+
+// VoidHandler returns an http.HandlerFunc that voids an authorized payment.
+func (h *PaymentsHandler) VoidHandler() http.HandlerFunc {
+	return h.operationHandler(false, func(ctx context.Context, id string, _ int) (*models.OperationResponse, error) {
+		return h.paymentProcessor.VoidPayment(ctx, id)
+	})
+}
+
+// RefundHandler returns an http.HandlerFunc that refunds captured funds of a payment.
+func (h *PaymentsHandler) RefundHandler() http.HandlerFunc {
+	return h.operationHandler(true, func(ctx context.Context, id string, amount int) (*models.OperationResponse, error) {
+		return h.paymentProcessor.RefundPayment(ctx, id, amount)
+	})
+}
+
+// operationHandler handles the requests that move an existing payment through
+// its lifecycle. When withAmount is set the optional request body is decoded
+// into a models.OperationRequest.
+func (h *PaymentsHandler) operationHandler(withAmount bool, apply func(ctx context.Context, id string, amount int) (*models.OperationResponse, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		id := chi.URLParam(r, "id")
+
+		if !validID(w, r, id) {
+			return
+		}
+
+		var req models.OperationRequest
+		if withAmount {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+				problem.Error(w, r, http.StatusBadRequest, models.CodeMalformedRequest, "the request body is not valid JSON")
+				return
+			}
+		}
+
+		response, err := apply(ctx, id, req.Amount)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrPaymentNotFound):
+				problem.Error(w, r, http.StatusNotFound, models.CodeNotFound, err.Error())
+			case errors.Is(err, models.ErrInvalidTransition):
+				problem.Error(w, r, http.StatusConflict, models.CodeInvalidTransition, err.Error())
+			case errors.Is(err, models.ErrPaymentVersionConflict):
+				problem.Error(w, r, http.StatusConflict, models.CodeVersionConflict, err.Error())
+			case errors.Is(err, models.ErrInvalidOperationAmount):
+				problem.Error(w, r, http.StatusUnprocessableEntity, models.CodeInvalidAmount, err.Error())
+			default:
+				problem.Internal(w, r, err, "Payment operation failed")
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
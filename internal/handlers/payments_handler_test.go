@@ -3,17 +3,21 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
 	mock_services "github.com/cko-recruitment/payment-gateway-challenge-go/internal/services/mocks"
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetPaymentHandler(t *testing.T) {
@@ -63,6 +67,21 @@ func TestGetPaymentHandler(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, models.CodeNotFound, decodeProblem(t, w).Code)
+	})
+
+	t.Run("GET StorageError", func(t *testing.T) {
+		someUid := uuid.New().String()
+
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/payments/%s", someUid), nil)
+		w := httptest.NewRecorder()
+
+		// Mock: storage failure
+		mockPaymentSvc.EXPECT().GetPayment(gomock.Any(), someUid).Return(nil, errors.New("database is locked"))
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
 	})
 
 	t.Run("POST CreatePayment Success", func(t *testing.T) {
@@ -128,4 +147,252 @@ func TestGetPaymentHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
+
+	t.Run("POST CreatePayment Pending", func(t *testing.T) {
+		createReq := models.PaymentRequest{CardNumber: "4111111111111111", Currency: "GBP", Amount: 100}
+
+		body, _ := json.Marshal(createReq)
+		req := httptest.NewRequest("POST", "/api/payments", bytes.NewReader(body))
+
+		mockValidator.EXPECT().ValidatePaymentRequest(gomock.Any(), createReq).Return(nil)
+		mockPaymentSvc.EXPECT().CreatePayment(gomock.Any(), createReq).
+			Return(&models.PaymentResponse{Id: "pending-id", Status: models.StatusPending}, nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Contains(t, w.Body.String(), `"Pending"`)
+	})
+
+	t.Run("POST CreatePayment AcquirerUnavailable", func(t *testing.T) {
+		createReq := models.PaymentRequest{CardNumber: "4111111111111111", Currency: "GBP", Amount: 100}
+
+		body, _ := json.Marshal(createReq)
+		req := httptest.NewRequest("POST", "/api/payments", bytes.NewReader(body))
+
+		mockValidator.EXPECT().ValidatePaymentRequest(gomock.Any(), createReq).Return(nil)
+		mockPaymentSvc.EXPECT().CreatePayment(gomock.Any(), createReq).
+			Return(nil, fmt.Errorf("failed to process payment: %w", models.ErrAcquirerUnavailable))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, models.CodeAcquirerUnavailable, decodeProblem(t, w).Code)
+	})
+
+	t.Run("POST CreatePayment AcquirerFailed", func(t *testing.T) {
+		createReq := models.PaymentRequest{CardNumber: "4111111111111111", Currency: "GBP", Amount: 100}
+
+		body, _ := json.Marshal(createReq)
+		req := httptest.NewRequest("POST", "/api/payments", bytes.NewReader(body))
+
+		mockValidator.EXPECT().ValidatePaymentRequest(gomock.Any(), createReq).Return(nil)
+		mockPaymentSvc.EXPECT().CreatePayment(gomock.Any(), createReq).
+			Return(nil, fmt.Errorf("%w: bank returned error status 400: card 4111 rejected", models.ErrAcquirerFailed))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Equal(t, models.CodePaymentFailed, decodeProblem(t, w).Code)
+		assert.NotContains(t, w.Body.String(), "status 400")
+	})
+
+	t.Run("POST CreatePayment StorageError", func(t *testing.T) {
+		createReq := models.PaymentRequest{CardNumber: "4111111111111111", Currency: "GBP", Amount: 100}
+
+		body, _ := json.Marshal(createReq)
+		req := httptest.NewRequest("POST", "/api/payments", bytes.NewReader(body))
+
+		mockValidator.EXPECT().ValidatePaymentRequest(gomock.Any(), createReq).Return(nil)
+		mockPaymentSvc.EXPECT().CreatePayment(gomock.Any(), createReq).
+			Return(nil, errors.New("failed to store payment: dial tcp 10.0.0.7:5432: connection refused"))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, models.CodeInternalError, decodeProblem(t, w).Code)
+		assert.NotContains(t, w.Body.String(), "10.0.0.7")
+	})
+
+	t.Run("POST CreatePayment UnknownCardToken", func(t *testing.T) {
+		createReq := models.PaymentRequest{Source: "tok_0123", Currency: "GBP", Amount: 100}
+
+		body, _ := json.Marshal(createReq)
+		req := httptest.NewRequest("POST", "/api/payments", bytes.NewReader(body))
+
+		mockValidator.EXPECT().ValidatePaymentRequest(gomock.Any(), createReq).Return(nil)
+		mockPaymentSvc.EXPECT().CreatePayment(gomock.Any(), createReq).Return(nil, models.ErrCardTokenNotFound)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, models.CodeValidationFailed, decodeProblem(t, w).Code)
+		assert.Contains(t, w.Body.String(), `"source"`)
+	})
+}
+
+func TestListPaymentsHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockValidator := mock_services.NewMockValidationService(ctrl)
+	mockPaymentSvc := mock_services.NewMockPaymentService(ctrl)
+
+	payments := NewPaymentsHandler(mockValidator, mockPaymentSvc)
+
+	r := chi.NewRouter()
+	r.Get("/api/payments", payments.ListHandler())
+
+	t.Run("GET ListPayments with filters", func(t *testing.T) {
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		want := models.PaymentFilter{
+			Status:             models.StatusAuthorized,
+			Currency:           "GBP",
+			MinAmount:          100,
+			MaxAmount:          500,
+			CardNumberLastFour: "8877",
+			CreatedFrom:        from,
+			Reference:          "order-1",
+		}
+		mockPaymentSvc.EXPECT().ListPayments(gomock.Any(), want, "next-page", 10).
+			Return(&models.PaymentListResponse{Data: []models.PaymentResponse{{Id: "payment-id"}}, NextCursor: "after"}, nil)
+
+		req := httptest.NewRequest("GET", "/api/payments?status=Authorized&currency=gbp&min_amount=100&max_amount=500"+
+			"&card_last_four=8877&created_from=2024-01-01T00:00:00Z&reference=order-1&limit=10&cursor=next-page", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"next_cursor":"after"`)
+	})
+
+	t.Run("GET ListPayments InvalidQuery", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/payments?status=Unknown&min_amount=-1&created_to=yesterday&limit=1000&card_last_four=12", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		resp := decodeProblem(t, w)
+		assert.Equal(t, models.CodeValidationFailed, resp.Code)
+		assert.Len(t, resp.Errors, 5)
+	})
+
+	t.Run("GET ListPayments InvalidCursor", func(t *testing.T) {
+		mockPaymentSvc.EXPECT().ListPayments(gomock.Any(), models.PaymentFilter{}, "bad", models.DefaultPageSize).
+			Return(nil, models.ErrInvalidCursor)
+
+		req := httptest.NewRequest("GET", "/api/payments?cursor=bad", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestPaymentOperationHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockValidator := mock_services.NewMockValidationService(ctrl)
+	mockPaymentSvc := mock_services.NewMockPaymentService(ctrl)
+
+	payments := NewPaymentsHandler(mockValidator, mockPaymentSvc)
+
+	r := chi.NewRouter()
+	r.Post("/api/payments/{id}/captures", payments.CaptureHandler())
+	r.Post("/api/payments/{id}/voids", payments.VoidHandler())
+	r.Post("/api/payments/{id}/refunds", payments.RefundHandler())
+
+	t.Run("POST Capture Success", func(t *testing.T) {
+		someUid := uuid.New().String()
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/payments/%s/captures", someUid), bytes.NewReader([]byte(`{"amount":50}`)))
+
+		mockPaymentSvc.EXPECT().CapturePayment(gomock.Any(), someUid, 50).Return(&models.OperationResponse{
+			Id:            "capture-id",
+			PaymentId:     someUid,
+			Type:          models.OperationCapture,
+			Amount:        50,
+			PaymentStatus: models.StatusPartiallyCaptured,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"capture-id"`)
+	})
+
+	t.Run("POST Capture without body captures remaining amount", func(t *testing.T) {
+		someUid := uuid.New().String()
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/payments/%s/captures", someUid), nil)
+
+		mockPaymentSvc.EXPECT().CapturePayment(gomock.Any(), someUid, 0).Return(&models.OperationResponse{Id: "capture-id"}, nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("POST Refund IllegalTransition", func(t *testing.T) {
+		someUid := uuid.New().String()
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/payments/%s/refunds", someUid), bytes.NewReader([]byte(`{"amount":50}`)))
+
+		mockPaymentSvc.EXPECT().RefundPayment(gomock.Any(), someUid, 50).Return(nil, fmt.Errorf("%w: cannot refund a Voided payment", models.ErrInvalidTransition))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, models.CodeInvalidTransition, decodeProblem(t, w).Code)
+	})
+
+	t.Run("POST Refund AmountTooLarge", func(t *testing.T) {
+		someUid := uuid.New().String()
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/payments/%s/refunds", someUid), bytes.NewReader([]byte(`{"amount":5000}`)))
+
+		mockPaymentSvc.EXPECT().RefundPayment(gomock.Any(), someUid, 5000).Return(nil, models.ErrInvalidOperationAmount)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, models.CodeInvalidAmount, decodeProblem(t, w).Code)
+	})
+
+	t.Run("POST Void PaymentNotFound", func(t *testing.T) {
+		someUid := uuid.New().String()
+		req := httptest.NewRequest("POST", fmt.Sprintf("/api/payments/%s/voids", someUid), nil)
+
+		mockPaymentSvc.EXPECT().VoidPayment(gomock.Any(), someUid).Return(nil, models.ErrPaymentNotFound)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("POST Void InvalidId", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/payments/not-a-uuid/voids", nil)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		resp := decodeProblem(t, w)
+		assert.Equal(t, models.CodeValidationFailed, resp.Code)
+		assert.Equal(t, []models.ValidationError{{Field: "id", Message: "id must be a UUID"}}, resp.Errors)
+	})
+}
+
+// decodeProblem decodes the problem details written to w.
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) models.Problem {
+	t.Helper()
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	var p models.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, w.Code, p.Status)
+	return p
 }
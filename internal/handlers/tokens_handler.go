@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/services"
+)
+
+type TokensHandler struct {
+	validator services.ValidationService
+	tokens    services.TokenService
+}
+
+func NewTokensHandler(validator services.ValidationService, tokens services.TokenService) *TokensHandler {
+	return &TokensHandler{
+		validator: validator,
+		tokens:    tokens,
+	}
+}
+
+// PostHandler returns an http.HandlerFunc that stores a card in the vault and
+// answers with the token standing for it.
+func (h *TokensHandler) PostHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var req models.CardTokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			problem.Error(w, r, http.StatusBadRequest, models.CodeMalformedRequest, "the request body is not valid JSON")
+			return
+		}
+
+		if validationErrors := h.validator.ValidateCardTokenRequest(ctx, req); len(validationErrors) > 0 {
+			problem.Validation(w, r, validationErrors)
+			return
+		}
+
+		response, err := h.tokens.Tokenize(ctx, req)
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to tokenize card")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	mock_services "github.com/cko-recruitment/payment-gateway-challenge-go/internal/services/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokensHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockValidator := mock_services.NewMockValidationService(ctrl)
+	mockTokenSvc := mock_services.NewMockTokenService(ctrl)
+
+	r := chi.NewRouter()
+	r.Post("/api/tokens", NewTokensHandler(mockValidator, mockTokenSvc).PostHandler())
+
+	body := `{"card_number":"2222405343248877","expiry_month":4,"expiry_year":2035}`
+	card := models.CardTokenRequest{CardNumber: "2222405343248877", ExpiryMonth: 4, ExpiryYear: 2035}
+
+	t.Run("tokenize", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/tokens", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+
+		mockValidator.EXPECT().ValidateCardTokenRequest(gomock.Any(), card).Return(nil)
+		mockTokenSvc.EXPECT().Tokenize(gomock.Any(), card).
+			Return(&models.CardTokenResponse{Token: "tok_0123", CardNumberLastFour: "8877"}, nil)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"token":"tok_0123"`)
+		assert.NotContains(t, w.Body.String(), card.CardNumber)
+	})
+
+	t.Run("invalid card", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/tokens", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+
+		mockValidator.EXPECT().ValidateCardTokenRequest(gomock.Any(), card).
+			Return([]models.ValidationError{{Field: "card_number", Message: "card_number is invalid"}})
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"card_number"`)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/tokens", bytes.NewBufferString(`{`))
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("vault failure", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/tokens", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+
+		mockValidator.EXPECT().ValidateCardTokenRequest(gomock.Any(), card).Return(nil)
+		mockTokenSvc.EXPECT().Tokenize(gomock.Any(), card).Return(nil, errors.New("disk full"))
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), "disk full")
+	})
+}
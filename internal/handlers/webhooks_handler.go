@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/problem"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/services"
+	"github.com/go-chi/chi/v5"
+)
+
+type WebhooksHandler struct {
+	validator services.ValidationService
+	webhooks  services.WebhookService
+}
+
+func NewWebhooksHandler(validator services.ValidationService, webhooks services.WebhookService) *WebhooksHandler {
+	return &WebhooksHandler{
+		validator: validator,
+		webhooks:  webhooks,
+	}
+}
+
+// RegisterHandler returns an http.HandlerFunc that registers a webhook endpoint
+// for the authenticated merchant.
+func (h *WebhooksHandler) RegisterHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var req models.WebhookEndpointRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			problem.Error(w, r, http.StatusBadRequest, models.CodeMalformedRequest, "the request body is not valid JSON")
+			return
+		}
+
+		if validationErrors := h.validator.ValidateWebhookEndpoint(ctx, req); len(validationErrors) > 0 {
+			problem.Validation(w, r, validationErrors)
+			return
+		}
+
+		response, err := h.webhooks.RegisterEndpoint(ctx, req)
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to register webhook endpoint")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ListHandler returns an http.HandlerFunc that lists the merchant's webhook endpoints.
+func (h *WebhooksHandler) ListHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response, err := h.webhooks.ListEndpoints(r.Context())
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to list webhook endpoints")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// DeleteHandler returns an http.HandlerFunc that deletes a webhook endpoint.
+// Deliveries still queued for it are dead-lettered when next attempted.
+func (h *WebhooksHandler) DeleteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if !validID(w, r, id) {
+			return
+		}
+
+		err := h.webhooks.DeleteEndpoint(r.Context(), id)
+		if errors.Is(err, models.ErrWebhookEndpointNotFound) {
+			problem.Error(w, r, http.StatusNotFound, models.CodeNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to delete webhook endpoint")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListDeliveriesHandler returns an http.HandlerFunc that lists the merchant's
+// most recent webhook deliveries, optionally filtered by status.
+func (h *WebhooksHandler) ListDeliveriesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := models.DeliveryStatus(r.URL.Query().Get("status"))
+		if status != "" && !slices.Contains(models.DeliveryStatuses, status) {
+			problem.Validation(w, r, []models.ValidationError{{Field: "status", Message: "unknown delivery status"}})
+			return
+		}
+
+		response, err := h.webhooks.ListDeliveries(r.Context(), status)
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to list webhook deliveries")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetDeliveryHandler returns an http.HandlerFunc that returns a webhook delivery.
+func (h *WebhooksHandler) GetDeliveryHandler() http.HandlerFunc {
+	return h.deliveryHandler(http.StatusOK, h.webhooks.GetDelivery)
+}
+
+// ReplayDeliveryHandler returns an http.HandlerFunc that queues a webhook
+// delivery to be sent again.
+func (h *WebhooksHandler) ReplayDeliveryHandler() http.HandlerFunc {
+	return h.deliveryHandler(http.StatusAccepted, h.webhooks.ReplayDelivery)
+}
+
+func (h *WebhooksHandler) deliveryHandler(statusCode int, apply func(ctx context.Context, id string) (*models.WebhookDeliveryResponse, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if !validID(w, r, id) {
+			return
+		}
+
+		response, err := apply(r.Context(), id)
+		if errors.Is(err, models.ErrWebhookDeliveryNotFound) {
+			problem.Error(w, r, http.StatusNotFound, models.CodeNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, err, "Failed to read webhook delivery")
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(response)
+	}
+}
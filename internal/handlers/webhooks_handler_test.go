@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	mock_services "github.com/cko-recruitment/payment-gateway-challenge-go/internal/services/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooksHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockValidator := mock_services.NewMockValidationService(ctrl)
+	mockWebhookSvc := mock_services.NewMockWebhookService(ctrl)
+
+	webhooks := NewWebhooksHandler(mockValidator, mockWebhookSvc)
+
+	r := chi.NewRouter()
+	r.Post("/api/webhooks", webhooks.RegisterHandler())
+	r.Delete("/api/webhooks/{id}", webhooks.DeleteHandler())
+	r.Get("/api/webhooks/deliveries", webhooks.ListDeliveriesHandler())
+	r.Post("/api/webhooks/deliveries/{id}/replay", webhooks.ReplayDeliveryHandler())
+
+	t.Run("register", func(t *testing.T) {
+		body := `{"url":"https://merchant.example/webhooks","events":["payment.captured"]}`
+		req := httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+
+		mockValidator.EXPECT().ValidateWebhookEndpoint(gomock.Any(), gomock.Any()).Return(nil)
+		mockWebhookSvc.EXPECT().RegisterEndpoint(gomock.Any(), models.WebhookEndpointRequest{
+			URL:    "https://merchant.example/webhooks",
+			Events: []models.EventType{models.EventPaymentCaptured},
+		}).Return(&models.WebhookEndpointResponse{Id: "endpoint-1", Secret: "whsec_test"}, nil)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"whsec_test"`)
+	})
+
+	t.Run("register invalid endpoint", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/webhooks", bytes.NewBufferString(`{"url":"nope"}`))
+		w := httptest.NewRecorder()
+
+		mockValidator.EXPECT().ValidateWebhookEndpoint(gomock.Any(), gomock.Any()).
+			Return([]models.ValidationError{{Field: "url", Message: "url must be an absolute http or https URL"}})
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"url"`)
+	})
+
+	t.Run("delete unknown endpoint", func(t *testing.T) {
+		id := uuid.New().String()
+		req := httptest.NewRequest("DELETE", "/api/webhooks/"+id, nil)
+		w := httptest.NewRecorder()
+
+		mockWebhookSvc.EXPECT().DeleteEndpoint(gomock.Any(), id).Return(models.ErrWebhookEndpointNotFound)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("list dead deliveries", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/webhooks/deliveries?status=dead", nil)
+		w := httptest.NewRecorder()
+
+		mockWebhookSvc.EXPECT().ListDeliveries(gomock.Any(), models.DeliveryDead).
+			Return([]models.WebhookDeliveryResponse{{Id: "delivery-1", Status: models.DeliveryDead}}, nil)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"delivery-1"`)
+	})
+
+	t.Run("list unknown status", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/webhooks/deliveries?status=lost", nil)
+		w := httptest.NewRecorder()
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		id := uuid.New().String()
+		req := httptest.NewRequest("POST", "/api/webhooks/deliveries/"+id+"/replay", nil)
+		w := httptest.NewRecorder()
+
+		mockWebhookSvc.EXPECT().ReplayDelivery(gomock.Any(), id).
+			Return(&models.WebhookDeliveryResponse{Id: id, Status: models.DeliveryPending}, nil)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+	})
+
+	t.Run("replay unknown delivery", func(t *testing.T) {
+		id := uuid.New().String()
+		req := httptest.NewRequest("POST", "/api/webhooks/deliveries/"+id+"/replay", nil)
+		w := httptest.NewRecorder()
+
+		mockWebhookSvc.EXPECT().ReplayDelivery(gomock.Any(), id).Return(nil, models.ErrWebhookDeliveryNotFound)
+
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
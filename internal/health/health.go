@@ -0,0 +1,199 @@
+// Package health decides whether the gateway is ready to take traffic. A
+// Checker runs pluggable checks of the dependencies of the gateway and caches
+// their outcome, so that frequent readiness probes do not hammer the store or
+// the acquiring banks.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Outcomes of a check and of the whole report.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// ShutdownCheck is the name of the built-in check failing once shutdown started.
+const ShutdownCheck = "shutdown"
+
+// ErrShuttingDown is reported by the shutdown check once StartShutdown was called.
+var ErrShuttingDown = errors.New("shutdown in progress")
+
+// CheckFunc reports whether a dependency is usable, returning nil when it is.
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of a single check.
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+	// Informational is set on checks that are reported without affecting
+	// readiness
+	Informational bool `json:"informational,omitempty"`
+}
+
+// Report is the outcome of every check. Its Status is StatusOK only when
+// every check that is not informational passed.
+type Report struct {
+	Status    string                 `json:"status"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Checks    map[string]CheckResult `json:"checks"`
+}
+
+// Ready tells whether every check passed.
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Config sets how the checks are run.
+type Config struct {
+	// CacheTTL is how long the outcome of the checks is reused
+	CacheTTL time.Duration
+	// Timeout bounds every check
+	Timeout time.Duration
+}
+
+var DefaultConfig = Config{
+	CacheTTL: 5 * time.Second,
+	Timeout:  2 * time.Second,
+}
+
+type namedCheck struct {
+	name          string
+	check         CheckFunc
+	informational bool
+}
+
+// Checker aggregates the readiness checks of the gateway.
+type Checker struct {
+	cfg          Config
+	now          func() time.Time
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+
+	// mu serialises runs of the checks, so that concurrent probes share a
+	// single run instead of starting their own
+	mu       sync.Mutex
+	cached   Report
+	cachedAt time.Time
+}
+
+func NewChecker(cfg Config) *Checker {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultConfig.Timeout
+	}
+	return &Checker{cfg: cfg, now: time.Now}
+}
+
+// Register adds check to the readiness checks under name. Checks must be
+// registered before the Checker is used.
+func (c *Checker) Register(name string, check CheckFunc) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// RegisterInformational adds check to the report under name without letting
+// its failure make the gateway unready. It suits dependencies the gateway can
+// do without, such as one of several acquirers.
+func (c *Checker) RegisterInformational(name string, check CheckFunc) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check, informational: true})
+}
+
+// StartShutdown makes the gateway report that it is not ready, so that load
+// balancers stop sending it traffic before it stops serving.
+func (c *Checker) StartShutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// Check returns the outcome of the checks, running them again when the cached
+// outcome is older than the cache TTL. The shutdown check is never cached.
+func (c *Checker) Check(ctx context.Context) Report {
+	cached := c.dependencies(ctx)
+
+	report := Report{
+		Status:    cached.Status,
+		CheckedAt: cached.CheckedAt,
+		Checks:    make(map[string]CheckResult, len(cached.Checks)+1),
+	}
+	for name, result := range cached.Checks {
+		report.Checks[name] = result
+	}
+
+	shutdown := CheckResult{Status: StatusOK, Duration: time.Duration(0).String()}
+	if c.shuttingDown.Load() {
+		shutdown.Status = StatusFail
+		shutdown.Error = ErrShuttingDown.Error()
+		report.Status = StatusFail
+	}
+	report.Checks[ShutdownCheck] = shutdown
+
+	return report
+}
+
+// dependencies returns the cached outcome of the registered checks, or runs
+// them when it is stale.
+func (c *Checker) dependencies(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if !c.cachedAt.IsZero() && now.Sub(c.cachedAt) < c.cfg.CacheTTL {
+		return c.cached
+	}
+
+	// the outcome is shared with other probes, so it must not depend on
+	// whether the probe that happened to run the checks went away
+	ctx = context.WithoutCancel(ctx)
+
+	results := make([]CheckResult, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check CheckFunc) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check.check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, CheckedAt: now, Checks: make(map[string]CheckResult, len(c.checks))}
+	for i, check := range c.checks {
+		results[i].Informational = check.informational
+		report.Checks[check.name] = results[i]
+		if results[i].Status != StatusOK && !check.informational {
+			report.Status = StatusFail
+		}
+	}
+
+	c.cached, c.cachedAt = report, now
+	return report
+}
+
+// run runs a single check, failing it when it does not finish within the
+// configured timeout.
+func (c *Checker) run(ctx context.Context, check CheckFunc) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	// a check ignoring its context must not hold up the whole report
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := CheckResult{Status: StatusOK, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
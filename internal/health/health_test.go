@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckerAggregatesChecks(t *testing.T) {
+	tests := []struct {
+		name       string
+		storeErr   error
+		bankErr    error
+		wantStatus string
+	}{
+		{name: "all checks pass", wantStatus: StatusOK},
+		{name: "store fails", storeErr: errors.New("connection refused"), wantStatus: StatusFail},
+		{name: "bank fails", bankErr: errors.New("bank is unreachable"), wantStatus: StatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker(DefaultConfig)
+			checker.Register("store", func(context.Context) error { return tt.storeErr })
+			checker.Register("acquirer:simulator", func(context.Context) error { return tt.bankErr })
+
+			report := checker.Check(context.Background())
+			assert.Equal(t, tt.wantStatus, report.Status)
+			assert.Equal(t, tt.wantStatus == StatusOK, report.Ready())
+			require.Len(t, report.Checks, 3)
+			assert.Equal(t, StatusOK, report.Checks[ShutdownCheck].Status)
+
+			for name, err := range map[string]error{"store": tt.storeErr, "acquirer:simulator": tt.bankErr} {
+				result := report.Checks[name]
+				if err == nil {
+					assert.Equal(t, StatusOK, result.Status, name)
+					assert.Empty(t, result.Error, name)
+				} else {
+					assert.Equal(t, StatusFail, result.Status, name)
+					assert.Equal(t, err.Error(), result.Error, name)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckerInformationalChecks(t *testing.T) {
+	checker := NewChecker(DefaultConfig)
+	checker.Register("store", func(context.Context) error { return nil })
+	checker.RegisterInformational("acquirer:simulator", func(context.Context) error { return errors.New("bank is unreachable") })
+
+	report := checker.Check(context.Background())
+	assert.True(t, report.Ready())
+
+	result := report.Checks["acquirer:simulator"]
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Equal(t, "bank is unreachable", result.Error)
+	assert.True(t, result.Informational)
+	assert.False(t, report.Checks["store"].Informational)
+}
+
+func TestCheckerCachesOutcome(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	checker := NewChecker(Config{CacheTTL: 5 * time.Second, Timeout: time.Second})
+	checker.now = func() time.Time { return now }
+
+	var calls atomic.Int32
+	checker.Register("acquirer:simulator", func(context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+
+	checker.Check(context.Background())
+	now = now.Add(4 * time.Second)
+	report := checker.Check(context.Background())
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, now.Add(-4*time.Second), report.CheckedAt)
+
+	now = now.Add(time.Second)
+	checker.Check(context.Background())
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestCheckerShutdownIsNotCached(t *testing.T) {
+	checker := NewChecker(Config{CacheTTL: time.Hour})
+	checker.Register("store", func(context.Context) error { return nil })
+
+	require.True(t, checker.Check(context.Background()).Ready())
+
+	checker.StartShutdown()
+	report := checker.Check(context.Background())
+	assert.False(t, report.Ready())
+	assert.Equal(t, StatusFail, report.Checks[ShutdownCheck].Status)
+	assert.Equal(t, ErrShuttingDown.Error(), report.Checks[ShutdownCheck].Error)
+	assert.Equal(t, StatusOK, report.Checks["store"].Status)
+}
+
+func TestCheckerTimesOutSlowChecks(t *testing.T) {
+	checker := NewChecker(Config{Timeout: 10 * time.Millisecond})
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	// the check ignores its context, the report must not wait for it
+	checker.Register("acquirer:simulator", func(context.Context) error {
+		<-release
+		return nil
+	})
+
+	start := time.Now()
+	report := checker.Check(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, report.Ready())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["acquirer:simulator"].Error)
+}
+
+func TestCheckerIgnoresCancelledProbe(t *testing.T) {
+	checker := NewChecker(DefaultConfig)
+	checker.Register("store", func(ctx context.Context) error { return ctx.Err() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.True(t, checker.Check(ctx).Ready())
+}
@@ -0,0 +1,118 @@
+package loadtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Record writes results as JSON lines, to be compared with a later run by Diff.
+func Record(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	for _, result := range results {
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadRecording reads the results written by Record.
+func ReadRecording(r io.Reader) ([]Result, error) {
+	var results []Result
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+	for n := 1; scanner.Scan(); n++ {
+		var result Result
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
+
+// Difference is a request whose result changed between two runs. Before or
+// After is nil when the request is only in one of them.
+type Difference struct {
+	Line   int
+	Before *Result
+	After  *Result
+}
+
+func (d Difference) String() string {
+	switch {
+	case d.Before == nil:
+		return fmt.Sprintf("line %d: not in the previous run, now %s", d.Line, describe(*d.After))
+	case d.After == nil:
+		return fmt.Sprintf("line %d: %s, now not sent", d.Line, describe(*d.Before))
+	default:
+		return fmt.Sprintf("line %d: %s, now %s", d.Line, describe(*d.Before), describe(*d.After))
+	}
+}
+
+// compared returns the fields of result that a Diff compares: all but the
+// latency, which recordings do not keep.
+func compared(result Result) Result {
+	result.Latency = 0
+	if len(result.Errors) == 0 {
+		result.Errors = nil
+	}
+	return result
+}
+
+// describe writes the fields of result that a Diff compares.
+func describe(result Result) string {
+	s := fmt.Sprintf("%d %s", result.HTTPStatus, result.Outcome)
+	if result.ClientReference != "" {
+		s = result.ClientReference + " " + s
+	}
+	if result.DeclineReason != "" {
+		s += " (" + string(result.DeclineReason) + ")"
+	}
+	if result.ErrorClass != "" {
+		s += " (" + result.ErrorClass + ")"
+	}
+	for _, e := range result.Errors {
+		s += fmt.Sprintf(" [%s: %s]", e.Field, e.Message)
+	}
+	return s
+}
+
+// Diff compares the results of two runs line by line, and returns the lines
+// whose result changed in line order. Only the first result of a line counts,
+// so runs that went over the file more than once compare their first pass.
+func Diff(before, after []Result) []Difference {
+	first := func(results []Result) map[int]*Result {
+		byLine := make(map[int]*Result)
+		for i := range results {
+			if _, ok := byLine[results[i].Line]; !ok {
+				byLine[results[i].Line] = &results[i]
+			}
+		}
+		return byLine
+	}
+	beforeByLine, afterByLine := first(before), first(after)
+
+	lines := make(map[int]bool)
+	for line := range beforeByLine {
+		lines[line] = true
+	}
+	for line := range afterByLine {
+		lines[line] = true
+	}
+
+	var diffs []Difference
+	for line := range lines {
+		b, a := beforeByLine[line], afterByLine[line]
+		if b != nil && a != nil && reflect.DeepEqual(compared(*b), compared(*a)) {
+			continue
+		}
+		diffs = append(diffs, Difference{Line: line, Before: b, After: a})
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Line < diffs[j].Line })
+	return diffs
+}
@@ -0,0 +1,279 @@
+// Package loadtest fires the payment requests of a JSON lines file at a
+// running gateway, at a given rate and concurrency, and reports the latency,
+// the outcomes and the errors of the responses. The file has the format of
+// payment batch uploads: a models.PaymentBatchLine per line.
+//
+// The responses of a run can be recorded and compared with those of an
+// earlier run, to catch changes of behaviour of the gateway.
+package loadtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// maxLineSize is the largest line Load reads.
+const maxLineSize = 64 * 1024
+
+// Request is a payment request of the file, with the number of its line.
+type Request struct {
+	Line            int
+	ClientReference string
+	Body            []byte
+}
+
+// Load reads the payment requests of r, skipping blank lines. The requests
+// are sent as they are written, so that invalid ones can be tested too, but
+// every line must be a JSON object.
+func Load(r io.Reader) ([]Request, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+
+	var requests []Request
+	for n := 1; scanner.Scan(); n++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var line models.PaymentBatchLine
+		if err := json.Unmarshal(text, &line); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		requests = append(requests, Request{
+			Line:            n,
+			ClientReference: line.ClientReference,
+			Body:            append([]byte(nil), text...),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, errors.New("no payment requests")
+	}
+	return requests, nil
+}
+
+// Config controls a load test run
+type Config struct {
+	// URL is the base URL of the gateway, such as http://localhost:8090
+	URL string
+	// Username and Password are the merchant credentials
+	Username string
+	Password string
+	// Rate is the number of requests started per second, 0 for as fast as
+	// Concurrency allows
+	Rate float64
+	// Concurrency is the number of requests in flight at most
+	Concurrency int
+	// Duration is how long requests are sent for, going over the file again
+	// as often as needed. 0 sends every request of the file once.
+	Duration time.Duration
+	// Timeout bounds a single request
+	Timeout time.Duration
+}
+
+// DefaultConfig sends the file once, 10 requests at a time.
+var DefaultConfig = Config{
+	URL:         "http://localhost:8090",
+	Concurrency: 10,
+	Timeout:     30 * time.Second,
+}
+
+// Result is the outcome of a request. Latency is left out of its JSON, which
+// is what recordings hold.
+type Result struct {
+	Line            int    `json:"line"`
+	ClientReference string `json:"client_reference,omitempty"`
+	// HTTPStatus is 0 when no response was received
+	HTTPStatus int `json:"http_status"`
+	// Outcome is the status of the payment made, Rejected when the request
+	// was not valid, or Error
+	Outcome       string                   `json:"outcome"`
+	DeclineReason models.DeclineReason     `json:"decline_reason,omitempty"`
+	Errors        []models.ValidationError `json:"errors,omitempty"`
+	// ErrorClass tells why the outcome is Error
+	ErrorClass string        `json:"error_class,omitempty"`
+	Latency    time.Duration `json:"-"`
+}
+
+// Outcomes that are not a payment status.
+const (
+	OutcomeRejected = string(models.StatusRejected)
+	OutcomeError    = "Error"
+)
+
+// Run sends requests to the gateway until they have all been sent once, or
+// until cfg.Duration is over, and returns the results in the order the
+// requests were sent. It stops early when ctx is cancelled.
+func Run(ctx context.Context, cfg Config, requests []Request) ([]Result, error) {
+	if len(requests) == 0 {
+		return nil, errors.New("no payment requests")
+	}
+	if cfg.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1, got %d", cfg.Concurrency)
+	}
+	if cfg.Rate < 0 {
+		return nil, fmt.Errorf("rate must not be negative, got %g", cfg.Rate)
+	}
+
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	client := &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: cfg.Concurrency,
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	type job struct {
+		seq int
+		req Request
+	}
+	jobs := make(chan job)
+	var (
+		mu      sync.Mutex
+		results = make(map[int]Result)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				// the requests in flight are finished when the run is over
+				result := send(context.WithoutCancel(ctx), client, cfg, j.req)
+				mu.Lock()
+				results[j.seq] = result
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if cfg.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+dispatch:
+	for seq := 0; cfg.Duration > 0 || seq < len(requests); seq++ {
+		if tick != nil && seq > 0 {
+			select {
+			case <-ctx.Done():
+				break dispatch
+			case <-tick:
+			}
+		}
+		// a worker may be free as well once ctx is done
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- job{seq: seq, req: requests[seq%len(requests)]}:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// every request handed out was sent, so the sequence numbers have no gap
+	ordered := make([]Result, len(results))
+	for seq, result := range results {
+		ordered[seq] = result
+	}
+	return ordered, nil
+}
+
+// send makes the payment of req and returns its result.
+func send(ctx context.Context, client *http.Client, cfg Config, req Request) Result {
+	result := Result{Line: req.Line, ClientReference: req.ClientReference, Outcome: OutcomeError}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(cfg.URL, "/")+"/api/payments", bytes.NewReader(req.Body))
+	if err != nil {
+		result.ErrorClass = "invalid_request"
+		return result
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if cfg.Username != "" {
+		httpReq.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		result.Latency = time.Since(start)
+		result.ErrorClass = classifyError(err)
+		return result
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	result.Latency = time.Since(start)
+	result.HTTPStatus = resp.StatusCode
+	if err != nil {
+		result.ErrorClass = classifyError(err)
+		return result
+	}
+
+	readResponse(&result, resp.StatusCode, body)
+	return result
+}
+
+// readResponse sets the outcome of result from the response of the gateway.
+func readResponse(result *Result, statusCode int, body []byte) {
+	switch {
+	case statusCode == http.StatusOK || statusCode == http.StatusAccepted:
+		var payment models.PaymentResponse
+		if err := json.Unmarshal(body, &payment); err != nil || payment.Status == "" {
+			result.ErrorClass = "invalid_response"
+			return
+		}
+		result.Outcome = string(payment.Status)
+		result.DeclineReason = payment.DeclineReason
+	case statusCode == http.StatusBadRequest:
+		var p models.Problem
+		if err := json.Unmarshal(body, &p); err != nil || p.Code == "" {
+			result.ErrorClass = "invalid_response"
+			return
+		}
+		result.Outcome = OutcomeRejected
+		result.Errors = p.Errors
+	default:
+		result.ErrorClass = fmt.Sprintf("http_%d", statusCode)
+	}
+}
+
+// classifyError names the kind of failure of a request that got no response.
+func classifyError(err error) string {
+	var netErr interface{ Timeout() bool }
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case strings.Contains(err.Error(), "connection refused"):
+		return "connection_refused"
+	case strings.Contains(err.Error(), "connection reset"):
+		return "connection_reset"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection_closed"
+	default:
+		return "transport_error"
+	}
+}
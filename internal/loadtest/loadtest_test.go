@@ -0,0 +1,214 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGateway answers payments like the gateway in front of the bank
+// simulator: by the last digit of the card number, odd digits are
+// authorized, 0 is unavailable and other digits are declined. A CVV of 0
+// is rejected.
+type fakeGateway struct {
+	inFlight, maxInFlight atomic.Int32
+	delay                 time.Duration
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := g.inFlight.Add(1)
+	defer g.inFlight.Add(-1)
+	for {
+		seen := g.maxInFlight.Load()
+		if n <= seen || g.maxInFlight.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	time.Sleep(g.delay)
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != "merchant" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req models.PaymentRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	w.Header().Set("Content-Type", "application/json")
+	if req.Cvv == "0" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.Problem{
+			Status: http.StatusBadRequest,
+			Code:   models.CodeValidationFailed,
+			Errors: []models.ValidationError{{Field: "cvv", Message: "cvv must be 3-4 digits"}},
+		})
+		return
+	}
+	switch last := req.CardNumber[len(req.CardNumber)-1]; {
+	case last == '0':
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(models.Problem{Status: http.StatusServiceUnavailable, Code: models.CodeAcquirerUnavailable})
+	case (last-'0')%2 == 1:
+		json.NewEncoder(w).Encode(models.PaymentResponse{Id: "payment-id", Status: models.StatusAuthorized})
+	default:
+		json.NewEncoder(w).Encode(models.PaymentResponse{Id: "payment-id", Status: models.StatusDeclined, DeclineReason: models.DeclineInsufficientFunds})
+	}
+}
+
+const testRequests = `{"client_reference": "a", "card_number": "2222405343248877", "cvv": "123"}
+
+{"client_reference": "b", "card_number": "2222405343248872", "cvv": "123"}
+{"client_reference": "c", "card_number": "2222405343248870", "cvv": "123"}
+{"client_reference": "d", "card_number": "2222405343248877", "cvv": "0"}
+`
+
+func loadTestRequests(t *testing.T) []Request {
+	t.Helper()
+	requests, err := Load(strings.NewReader(testRequests))
+	require.NoError(t, err)
+	return requests
+}
+
+func testConfig(url string) Config {
+	cfg := DefaultConfig
+	cfg.URL, cfg.Username, cfg.Password = url, "merchant", "secret"
+	return cfg
+}
+
+func TestLoad(t *testing.T) {
+	requests := loadTestRequests(t)
+	require.Len(t, requests, 4)
+	assert.Equal(t, 1, requests[0].Line)
+	assert.Equal(t, "a", requests[0].ClientReference)
+	assert.Equal(t, 3, requests[1].Line, "blank lines keep the numbering")
+
+	_, err := Load(strings.NewReader("{}\nnot json\n"))
+	assert.ErrorContains(t, err, "line 2")
+
+	_, err = Load(strings.NewReader("\n"))
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	gateway := &fakeGateway{delay: 10 * time.Millisecond}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	cfg := testConfig(server.URL)
+	cfg.Concurrency = 2
+	results, err := Run(context.Background(), cfg, loadTestRequests(t))
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	assert.LessOrEqual(t, gateway.maxInFlight.Load(), int32(2))
+
+	assert.Equal(t, Result{Line: 1, ClientReference: "a", HTTPStatus: 200, Outcome: "Authorized"}, compared(results[0]))
+	assert.Equal(t, Result{Line: 3, ClientReference: "b", HTTPStatus: 200, Outcome: "Declined", DeclineReason: models.DeclineInsufficientFunds}, compared(results[1]))
+	assert.Equal(t, Result{Line: 4, ClientReference: "c", HTTPStatus: 503, Outcome: OutcomeError, ErrorClass: "http_503"}, compared(results[2]))
+	assert.Equal(t, OutcomeRejected, results[3].Outcome)
+	assert.Equal(t, "cvv", results[3].Errors[0].Field)
+	for _, result := range results {
+		assert.GreaterOrEqual(t, result.Latency, 10*time.Millisecond)
+	}
+
+	report := Summarise(results, time.Second)
+	assert.Equal(t, map[string]int{"Authorized": 1, "Declined": 1, "Error": 1, "Rejected": 1}, report.Outcomes)
+	assert.Equal(t, map[string]int{"http_503": 1}, report.Errors)
+
+	var out bytes.Buffer
+	require.NoError(t, report.Write(&out))
+	assert.Contains(t, out.String(), "requests  4 in 1s (4.0/s)")
+	assert.Contains(t, out.String(), "http_503")
+}
+
+func TestRunForDuration(t *testing.T) {
+	server := httptest.NewServer(&fakeGateway{})
+	defer server.Close()
+
+	cfg := testConfig(server.URL)
+	cfg.Rate = 100
+	cfg.Duration = 300 * time.Millisecond
+	results, err := Run(context.Background(), cfg, loadTestRequests(t))
+	require.NoError(t, err)
+
+	assert.InDelta(t, 30, len(results), 10, "the rate holds for the duration")
+	for i, result := range results {
+		assert.Equal(t, loadTestRequests(t)[i%4].Line, result.Line, "the file is sent again in order")
+	}
+}
+
+func TestRunErrorClasses(t *testing.T) {
+	server := httptest.NewServer(&fakeGateway{delay: 100 * time.Millisecond})
+	defer server.Close()
+	requests := loadTestRequests(t)[:1]
+
+	cfg := testConfig(server.URL)
+	cfg.Timeout = 10 * time.Millisecond
+	results, err := Run(context.Background(), cfg, requests)
+	require.NoError(t, err)
+	assert.Equal(t, "timeout", results[0].ErrorClass)
+	assert.Equal(t, 0, results[0].HTTPStatus)
+
+	cfg = testConfig(server.URL)
+	cfg.Password = "wrong"
+	results, err = Run(context.Background(), cfg, requests)
+	require.NoError(t, err)
+	assert.Equal(t, "http_401", results[0].ErrorClass)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	results, err = Run(context.Background(), testConfig(closed.URL), requests)
+	require.NoError(t, err)
+	assert.Equal(t, "connection_refused", results[0].ErrorClass)
+}
+
+func TestSummarisePercentiles(t *testing.T) {
+	var results []Result
+	for i := 100; i >= 1; i-- {
+		results = append(results, Result{Outcome: "Authorized", Latency: time.Duration(i) * time.Millisecond})
+	}
+
+	report := Summarise(results, 2*time.Second)
+	assert.Equal(t, 50*time.Millisecond, report.Latency["p50"])
+	assert.Equal(t, 99*time.Millisecond, report.Latency["p99"])
+	assert.Equal(t, 100*time.Millisecond, report.Latency["max"])
+	assert.Equal(t, 50.0, report.Throughput)
+
+	assert.Equal(t, time.Duration(0), Summarise(nil, 0).Latency["p50"])
+}
+
+func TestDiff(t *testing.T) {
+	before := []Result{
+		{Line: 1, HTTPStatus: 200, Outcome: "Authorized", Latency: time.Second},
+		{Line: 2, HTTPStatus: 200, Outcome: "Declined", DeclineReason: models.DeclineInsufficientFunds},
+		{Line: 3, HTTPStatus: 400, Outcome: "Rejected", Errors: []models.ValidationError{{Field: "cvv", Message: "required"}}},
+		{Line: 4, HTTPStatus: 200, Outcome: "Authorized"},
+	}
+
+	var recording bytes.Buffer
+	require.NoError(t, Record(&recording, before))
+	recorded, err := ReadRecording(&recording)
+	require.NoError(t, err)
+	assert.Empty(t, Diff(recorded, before), "latency is not compared")
+
+	after := []Result{
+		{Line: 1, HTTPStatus: 200, Outcome: "Authorized", Latency: 2 * time.Second},
+		{Line: 2, HTTPStatus: 200, Outcome: "Declined", DeclineReason: models.DeclineDoNotHonour},
+		{Line: 3, HTTPStatus: 400, Outcome: "Rejected", Errors: []models.ValidationError{{Field: "cvv", Message: "required"}}},
+		{Line: 5, HTTPStatus: 503, Outcome: OutcomeError, ErrorClass: "http_503"},
+		{Line: 1, HTTPStatus: 503, Outcome: OutcomeError, ErrorClass: "http_503"},
+	}
+	diffs := Diff(recorded, after)
+	require.Len(t, diffs, 3)
+	assert.Equal(t, "line 2: 200 Declined (insufficient_funds), now 200 Declined (do_not_honour)", diffs[0].String())
+	assert.Equal(t, "line 4: 200 Authorized, now not sent", diffs[1].String())
+	assert.Equal(t, "line 5: not in the previous run, now 503 Error (http_503)", diffs[2].String())
+}
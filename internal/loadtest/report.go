@@ -0,0 +1,105 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// Percentiles are the latency percentiles of a Report.
+var Percentiles = []float64{50, 90, 95, 99}
+
+// Report summarises the results of a run.
+type Report struct {
+	Requests int           `json:"requests"`
+	Elapsed  time.Duration `json:"elapsed_ns"`
+	// Throughput is the number of requests completed per second
+	Throughput float64 `json:"throughput"`
+	// Latency holds the Percentiles, keyed like p99, and the max
+	Latency  map[string]time.Duration `json:"latency_ns"`
+	Outcomes map[string]int           `json:"outcomes"`
+	Errors   map[string]int           `json:"errors"`
+}
+
+// Summarise returns the report of results, collected over elapsed.
+func Summarise(results []Result, elapsed time.Duration) Report {
+	report := Report{
+		Requests: len(results),
+		Elapsed:  elapsed,
+		Latency:  make(map[string]time.Duration),
+		Outcomes: make(map[string]int),
+		Errors:   make(map[string]int),
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(len(results)) / elapsed.Seconds()
+	}
+
+	latencies := make([]time.Duration, len(results))
+	for i, result := range results {
+		latencies[i] = result.Latency
+		report.Outcomes[result.Outcome]++
+		if result.ErrorClass != "" {
+			report.Errors[result.ErrorClass]++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	for _, p := range Percentiles {
+		report.Latency[percentileKey(p)] = percentile(latencies, p)
+	}
+	report.Latency["max"] = percentile(latencies, 100)
+	return report
+}
+
+func percentileKey(p float64) string {
+	return fmt.Sprintf("p%g", p)
+}
+
+// percentile returns the nearest-rank percentile p of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
+
+// Write prints the report as a table.
+func (r Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "requests\t%d in %s (%.1f/s)\n", r.Requests, r.Elapsed.Round(time.Millisecond), r.Throughput)
+
+	fmt.Fprint(tw, "latency")
+	for _, p := range Percentiles {
+		key := percentileKey(p)
+		fmt.Fprintf(tw, "\t%s %s", key, r.Latency[key].Round(time.Microsecond))
+	}
+	fmt.Fprintf(tw, "\tmax %s\n", r.Latency["max"].Round(time.Microsecond))
+
+	writeCounts(tw, "outcomes", r.Outcomes)
+	writeCounts(tw, "errors", r.Errors)
+	return tw.Flush()
+}
+
+// writeCounts prints counts under title, largest first.
+func writeCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	fmt.Fprintln(w, title)
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s\t%d\n", key, counts[key])
+	}
+}
@@ -0,0 +1,185 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCardNumber = "2222405343248877"
+
+var testPaymentRequest = models.PaymentRequest{
+	CardNumber:  testCardNumber,
+	ExpiryMonth: 4,
+	ExpiryYear:  2035,
+	Currency:    "GBP",
+	Amount:      100,
+	Cvv:         "123",
+	Reference:   "order-1",
+}
+
+// unredacted has the card data of a payment request without its LogValue method.
+type unredacted struct {
+	CardNumber string `json:"card_number"`
+	Cvv        string
+	Nested     map[string]any
+}
+
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+	}{
+		{
+			name: "payment request",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", slog.Any("request", testPaymentRequest))
+			},
+		},
+		{
+			name: "payment request pointer",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", "request", &testPaymentRequest)
+			},
+		},
+		{
+			name: "struct without LogValue",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", slog.Any("request", unredacted{
+					CardNumber: testCardNumber,
+					Cvv:        "123",
+					Nested:     map[string]any{"expiry_date": "04/35", "note": "card " + testCardNumber},
+				}))
+			},
+		},
+		{
+			name: "sensitive keys",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", slog.String("card_number", testCardNumber), slog.String("cvv", "123"), slog.Int("expiry_year", 2035))
+			},
+		},
+		{
+			name: "card number in a message attribute",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", slog.String("body", "card 2222 4053 4324 8877 declined"))
+			},
+		},
+		{
+			name: "card number in an error",
+			log: func(logger *slog.Logger) {
+				logger.Error("failed", slog.Any("error", errors.New(`bank returned {"card_number":"`+testCardNumber+`"}`)))
+			},
+		},
+		{
+			name: "formatted payment request",
+			log: func(logger *slog.Logger) {
+				logger.Info("request", slog.Any("request", []models.PaymentRequest{testPaymentRequest}))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(New(&buf, slog.LevelInfo))
+
+			out := buf.String()
+			require.NotEmpty(t, out)
+			assert.NotContains(t, out, testCardNumber)
+			assert.NotContains(t, out, "2222 4053 4324 8877")
+			assert.NotContains(t, out, "123\"")
+			assert.NotContains(t, out, "2035")
+			assert.NotContains(t, out, "04/35")
+		})
+	}
+}
+
+func TestRedactionKeepsOtherData(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, slog.LevelInfo).Info("request",
+		slog.Any("request", testPaymentRequest),
+		slog.String("payment_id", "8a1c2f3e-0000-4000-8000-000000000001"),
+		slog.String("order", "1234567890123"),
+	)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, map[string]any{
+		"card_number_last_four": "8877",
+		"currency":              "GBP",
+		"amount":                float64(100),
+		"reference":             "order-1",
+	}, record["request"])
+	assert.Equal(t, "8a1c2f3e-0000-4000-8000-000000000001", record["payment_id"])
+	// not a valid card number
+	assert.Equal(t, "1234567890123", record["order"])
+}
+
+func TestPaymentRequestFormatting(t *testing.T) {
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		out := fmt.Sprintf(format, testPaymentRequest)
+		assert.NotContains(t, out, testCardNumber, format)
+		assert.NotContains(t, out, "123", format)
+		assert.Contains(t, out, "8877", format)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	var handlerRequestID string
+	handler := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerRequestID = RequestID(r.Context())
+		logger.InfoContext(r.Context(), "handling")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	t.Run("generated", func(t *testing.T) {
+		buf.Reset()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/payments", nil))
+
+		require.NotEmpty(t, handlerRequestID)
+		assert.Equal(t, handlerRequestID, w.Header().Get(RequestIDHeader))
+
+		lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+		require.Len(t, lines, 2)
+		for _, line := range lines {
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(line, &record))
+			assert.Equal(t, handlerRequestID, record["request_id"])
+		}
+
+		var served map[string]any
+		require.NoError(t, json.Unmarshal(lines[1], &served))
+		assert.Equal(t, float64(http.StatusCreated), served["status"])
+		assert.Equal(t, "/api/payments", served["path"])
+	})
+
+	t.Run("from the client", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/payments", nil)
+		req.Header.Set(RequestIDHeader, "client-id")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, "client-id", handlerRequestID)
+		assert.Equal(t, "client-id", w.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("without a request", func(t *testing.T) {
+		buf.Reset()
+		logger.InfoContext(context.Background(), "background")
+		assert.NotContains(t, buf.String(), "request_id")
+	})
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+)
+
+// Redacted replaces card data in log records.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the attribute and field names whose values are never
+// logged, normalised by normaliseKey. They match both the JSON names and the
+// Go field names of the card data in requests.
+var sensitiveKeys = map[string]bool{
+	"cardnumber":  true,
+	"pan":         true,
+	"cvv":         true,
+	"cvc":         true,
+	"expirydate":  true,
+	"expirymonth": true,
+	"expiryyear":  true,
+}
+
+// panPattern matches runs of 12 to 19 digits, optionally grouped with spaces
+// or dashes as card numbers are usually written.
+var panPattern = regexp.MustCompile(`\d(?:[ -]?\d){11,18}`)
+
+func normaliseKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// redactAttr is the slog.HandlerOptions.ReplaceAttr of the gateway loggers.
+// Values of sensitive keys are dropped, card numbers are masked out of strings
+// and values logged with slog.Any are redacted field by field.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[normaliseKey(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(RedactString(a.Value.String()))
+	case slog.KindAny:
+		a.Value = slog.AnyValue(redactAny(a.Value.Any()))
+	}
+	return a
+}
+
+// RedactString masks every Luhn valid card number in s.
+func RedactString(s string) string {
+	return panPattern.ReplaceAllStringFunc(s, func(match string) string {
+		digits := strings.NewReplacer(" ", "", "-", "").Replace(match)
+		if !card.Luhn(digits) {
+			return match
+		}
+		return Redacted
+	})
+}
+
+// redactAny returns a copy of v safe to log. Errors and values that cannot
+// be encoded as JSON are logged as redacted strings, anything else as its
+// JSON form with the sensitive fields redacted.
+func redactAny(v any) any {
+	if err, ok := v.(error); ok {
+		return RedactString(err.Error())
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return RedactString(fmt.Sprintf("%+v", v))
+	}
+	var decoded any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&decoded); err != nil {
+		return RedactString(string(data))
+	}
+	return redactJSON(decoded)
+}
+
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if sensitiveKeys[normaliseKey(key)] {
+				v[key] = Redacted
+				continue
+			}
+			v[key] = redactJSON(value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = redactJSON(value)
+		}
+		return v
+	case string:
+		return RedactString(v)
+	default:
+		return v
+	}
+}
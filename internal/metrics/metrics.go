@@ -0,0 +1,184 @@
+// Package metrics holds the Prometheus metrics of the gateway. Every label has
+// a bounded set of values: routes are chi route patterns, currencies and card
+// schemes are limited by validation and acquirers by the routing config, so
+// no label ever holds a payment, merchant or request ID.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gateway"
+
+// Metrics is the set of gateway metrics together with the registry that
+// exposes them. A nil *Metrics is valid and records nothing, so components can
+// be built without metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests       *prometheus.CounterVec
+	httpDuration       *prometheus.HistogramVec
+	payments           *prometheus.CounterVec
+	paymentDeclines    *prometheus.CounterVec
+	bankDuration       *prometheus.HistogramVec
+	bankErrors         *prometheus.CounterVec
+	repositoryDuration *prometheus.HistogramVec
+}
+
+// New creates the gateway metrics in a registry of their own, along with the
+// Go runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests served, by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to serve HTTP requests, by route pattern, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		payments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payments_total",
+			Help:      "Payments processed, by outcome status, currency and card scheme.",
+		}, []string{"status", "currency", "scheme"}),
+		paymentDeclines: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payment_declines_total",
+			Help:      "Payments declined by the acquirers, by acquirer and decline reason.",
+		}, []string{"acquirer", "reason"}),
+		bankDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "bank_request_duration_seconds",
+			Help:      "Duration of single attempts of acquiring bank calls, by acquirer, operation and error class.",
+			Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"acquirer", "operation", "error_class"}),
+		bankErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bank_errors_total",
+			Help:      "Failed attempts of acquiring bank calls, by acquirer, operation and error class.",
+		}, []string{"acquirer", "operation", "error_class"}),
+		repositoryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_operation_duration_seconds",
+			Help:      "Duration of storage operations, by repository, operation and result: ok, not_found or error.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"repository", "operation", "result"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.payments,
+		m.paymentDeclines,
+		m.bankDuration,
+		m.bankErrors,
+		m.repositoryDuration,
+	)
+	return m
+}
+
+// Registry returns the registry holding the metrics, so that other
+// components can register their own collectors.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts and times the requests served by a chi router. It must
+// be installed on the router itself so the matched route pattern is known
+// once the request has been served. Requests matching no route are recorded
+// under the route "unmatched".
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"route": route, "method": method(r), "status": strconv.Itoa(status)}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// method returns the request method, or "other" for methods outside the
+// standard ones so that clients cannot create label values.
+func method(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return r.Method
+	}
+	return "other"
+}
+
+// ObservePayment counts a payment that reached status.
+func (m *Metrics) ObservePayment(status, currency, scheme string) {
+	if m == nil {
+		return
+	}
+	m.payments.WithLabelValues(status, currency, scheme).Inc()
+}
+
+// ObserveDecline counts a payment declined by acquirer for reason.
+func (m *Metrics) ObserveDecline(acquirer, reason string) {
+	if m == nil {
+		return
+	}
+	m.paymentDeclines.WithLabelValues(acquirer, reason).Inc()
+}
+
+// ObserveBankCall records a single attempt of a bank call. errorClass is
+// empty for successful attempts.
+func (m *Metrics) ObserveBankCall(acquirer, operation, errorClass string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	class := errorClass
+	if class == "" {
+		class = "none"
+	} else {
+		m.bankErrors.WithLabelValues(acquirer, operation, class).Inc()
+	}
+	m.bankDuration.WithLabelValues(acquirer, operation, class).Observe(duration.Seconds())
+}
+
+// ObserveRepositoryOperation records the duration of a storage operation.
+// result is "ok", "not_found" or "error".
+func (m *Metrics) ObserveRepositoryOperation(repository, operation, result string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.repositoryDuration.WithLabelValues(repository, operation, result).Observe(duration.Seconds())
+}
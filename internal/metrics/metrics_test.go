@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestMiddlewareUsesRoutePatterns(t *testing.T) {
+	m := New()
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/api/payments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, target := range []string{"/api/payments/1", "/api/payments/2", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/api/payments/3", nil))
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `gateway_http_requests_total{method="GET",route="/api/payments/{id}",status="404"} 2`)
+	assert.Contains(t, out, `gateway_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, out, `gateway_http_requests_total{method="other",route="unmatched",status="405"} 1`)
+	assert.Contains(t, out, `gateway_http_request_duration_seconds_count{method="GET",route="/api/payments/{id}",status="404"} 2`)
+	assert.NotContains(t, out, "/api/payments/1")
+}
+
+func TestObservers(t *testing.T) {
+	m := New()
+	m.ObservePayment("Authorized", "GBP", "visa")
+	m.ObserveDecline("simulator", "insufficient_funds")
+	m.ObserveBankCall("simulator", "process_payment", "", 20*time.Millisecond)
+	m.ObserveBankCall("simulator", "process_payment", "timeout", time.Second)
+	m.ObserveRepositoryOperation("payments", "get_payment", "not_found", time.Millisecond)
+
+	out := scrape(t, m)
+	assert.Contains(t, out, `gateway_payments_total{currency="GBP",scheme="visa",status="Authorized"} 1`)
+	assert.Contains(t, out, `gateway_payment_declines_total{acquirer="simulator",reason="insufficient_funds"} 1`)
+	assert.Contains(t, out, `gateway_bank_request_duration_seconds_count{acquirer="simulator",error_class="none",operation="process_payment"} 1`)
+	assert.Contains(t, out, `gateway_bank_errors_total{acquirer="simulator",error_class="timeout",operation="process_payment"} 1`)
+	assert.NotContains(t, out, `gateway_bank_errors_total{acquirer="simulator",error_class="none"`)
+	assert.Contains(t, out, `gateway_repository_operation_duration_seconds_count{operation="get_payment",repository="payments",result="not_found"} 1`)
+}
+
+func TestNilMetricsRecordNothing(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.ObservePayment("Authorized", "GBP", "visa")
+		m.ObserveDecline("simulator", "do_not_honour")
+		m.ObserveBankCall("simulator", "process_payment", "timeout", time.Second)
+		m.ObserveRepositoryOperation("payments", "get_payment", "ok", time.Millisecond)
+
+		handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	})
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/utils"
+)
+
+var (
+	ErrCardTokenNotFound = errors.New("card token not found")
+	ErrCardExpired       = errors.New("card has expired")
+)
+
+// CardTokenRequest is the card data to store in the vault. The CVV is never stored.
+type CardTokenRequest struct {
+	CardNumber  string `json:"card_number"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+}
+
+// LogValue leaves the card number out of log records.
+func (r CardTokenRequest) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("card_number_last_four", utils.GetLastFourDigits(r.CardNumber)))
+}
+
+// String formats the request without its card number.
+func (r CardTokenRequest) String() string {
+	return fmt.Sprintf("{CardNumber:****%s}", utils.GetLastFourDigits(r.CardNumber))
+}
+
+// GoString is String for %#v.
+func (r CardTokenRequest) GoString() string {
+	return "models.CardTokenRequest" + r.String()
+}
+
+type CardTokenResponse struct {
+	// Token stands for the card in payment requests, as their source
+	Token              string      `json:"token"`
+	CardNumberLastFour string      `json:"card_number_last_four"`
+	CardScheme         card.Scheme `json:"card_scheme"`
+	ExpiryMonth        int         `json:"expiry_month"`
+	ExpiryYear         int         `json:"expiry_year"`
+	CreatedAt          time.Time   `json:"created_at"`
+}
+
+// CardToken is the vault record of a card. Only the card number is secret,
+// and it is only stored encrypted.
+type CardToken struct {
+	Id                 string
+	MerchantId         string
+	CardNumberLastFour string
+	CardScheme         card.Scheme
+	ExpiryMonth        int
+	ExpiryYear         int
+	// KeyID names the key encryption key that wrapped DataKey
+	KeyID string
+	// DataKey is the wrapped key EncryptedCardNumber is encrypted with
+	DataKey             []byte
+	EncryptedCardNumber []byte
+	CreatedAt           time.Time
+}
+
+// Expired tells whether the card expired before now.
+func (t CardToken) Expired(now time.Time) bool {
+	return t.ExpiryYear < now.Year() || (t.ExpiryYear == now.Year() && t.ExpiryMonth < int(now.Month()))
+}
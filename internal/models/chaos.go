@@ -0,0 +1,14 @@
+package models
+
+// ChaosSettings are the faults injected in the bank calls, as served and
+// changed by the chaos admin endpoint. Durations are written like 1.5s.
+type ChaosSettings struct {
+	// Rates is the probability of each fault on a call
+	Rates map[string]float64 `json:"rates" example:"server_error:0.2,drop_connection:0.1"`
+	// Script lists the faults of the next calls in order, before Rates apply
+	Script      []string `json:"script" example:"timeout,none,drop_connection"`
+	Latency     string   `json:"latency" example:"1s"`
+	Timeout     string   `json:"timeout" example:"10s"`
+	ErrorStatus int      `json:"error_status" example:"503"`
+	Queries     bool     `json:"queries"`
+}
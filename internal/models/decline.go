@@ -0,0 +1,20 @@
+package models
+
+// DeclineReason tells the merchant why a payment was declined, in the same
+// terms whichever acquirer declined it.
+type DeclineReason string
+
+const (
+	DeclineInsufficientFunds DeclineReason = "insufficient_funds"
+	// DeclineDoNotHonour is the generic decline, used when the issuer gives
+	// no reason or one the gateway does not know
+	DeclineDoNotHonour    DeclineReason = "do_not_honour"
+	DeclineExpiredCard    DeclineReason = "expired_card"
+	DeclineSuspectedFraud DeclineReason = "suspected_fraud"
+	DeclineInvalidCvv     DeclineReason = "invalid_cvv"
+)
+
+// DeclineReasons lists every decline reason.
+var DeclineReasons = []DeclineReason{
+	DeclineInsufficientFunds, DeclineDoNotHonour, DeclineExpiredCard, DeclineSuspectedFraud, DeclineInvalidCvv,
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrKeyRotationNotFound = errors.New("key rotation not found")
+
+// KeyRotation is the progress of re-encrypting the records of a table under
+// a new key. It is saved after every batch so that an interrupted run can
+// resume where it stopped.
+type KeyRotation struct {
+	// Name identifies the records being re-encrypted, such as card_tokens
+	Name string
+	// KeyID is the key the records are re-encrypted under
+	KeyID string
+	// LastID is the ID of the last record done, records are done in ID order
+	LastID      string
+	Reencrypted int
+	Completed   bool
+	StartedAt   time.Time
+	UpdatedAt   time.Time
+}
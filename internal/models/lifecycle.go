@@ -0,0 +1,202 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidTransition      = errors.New("operation not allowed for the payment status")
+	ErrInvalidOperationAmount = errors.New("invalid operation amount")
+	ErrPaymentVersionConflict = errors.New("payment was modified concurrently")
+)
+
+// PaymentStatus is the lifecycle state of a payment
+type PaymentStatus string
+
+const (
+	// StatusPending is a payment sent to the acquirer whose outcome is not known yet
+	StatusPending           PaymentStatus = "Pending"
+	StatusAuthorized        PaymentStatus = "Authorized"
+	StatusDeclined          PaymentStatus = "Declined"
+	StatusRejected          PaymentStatus = "Rejected"
+	StatusPartiallyCaptured PaymentStatus = "PartiallyCaptured"
+	StatusCaptured          PaymentStatus = "Captured"
+	StatusVoided            PaymentStatus = "Voided"
+	StatusPartiallyRefunded PaymentStatus = "PartiallyRefunded"
+	StatusRefunded          PaymentStatus = "Refunded"
+)
+
+// PaymentStatuses lists every payment status
+var PaymentStatuses = []PaymentStatus{
+	StatusPending, StatusAuthorized, StatusDeclined, StatusRejected, StatusPartiallyCaptured,
+	StatusCaptured, StatusVoided, StatusPartiallyRefunded, StatusRefunded,
+}
+
+// transitions lists the statuses each status may move to. Anything not listed
+// here is an illegal transition. A pending payment is only voided by the
+// gateway itself, through VoidPending.
+var transitions = map[PaymentStatus][]PaymentStatus{
+	StatusPending:           {StatusAuthorized, StatusDeclined},
+	StatusAuthorized:        {StatusPartiallyCaptured, StatusCaptured, StatusVoided},
+	StatusPartiallyCaptured: {StatusPartiallyCaptured, StatusCaptured, StatusPartiallyRefunded, StatusRefunded},
+	StatusCaptured:          {StatusPartiallyRefunded, StatusRefunded},
+	StatusPartiallyRefunded: {StatusPartiallyRefunded, StatusRefunded},
+}
+
+// CanTransitionTo reports whether a payment in status s may move to next.
+func (s PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// OperationType identifies an action taken on a payment after authorization
+type OperationType string
+
+const (
+	OperationCapture OperationType = "capture"
+	OperationVoid    OperationType = "void"
+	OperationRefund  OperationType = "refund"
+)
+
+// Operation is a child record of a payment describing a single capture, void or refund
+type Operation struct {
+	Id        string
+	PaymentId string
+	Type      OperationType
+	Amount    int
+	CreatedAt time.Time
+}
+
+type OperationRequest struct {
+	// Amount in minor units. When omitted the full remaining amount is used.
+	Amount int `json:"amount,omitempty"`
+}
+
+type OperationResponse struct {
+	Id              string        `json:"id"`
+	PaymentId       string        `json:"payment_id"`
+	Type            OperationType `json:"type"`
+	Amount          int           `json:"amount"`
+	AmountFormatted string        `json:"amount_formatted"`
+	CreatedAt       time.Time     `json:"created_at"`
+	// PaymentStatus is the payment status right after this operation. It is only
+	// set on responses to the operation request itself.
+	PaymentStatus PaymentStatus `json:"payment_status,omitempty"`
+}
+
+// Settle records the answer of the named acquirer for a pending payment.
+// declineReason is ignored for authorized payments.
+func (p *Payment) Settle(acquirer string, authorized bool, authorizationCode string, declineReason DeclineReason) error {
+	next := StatusDeclined
+	if authorized {
+		next = StatusAuthorized
+	}
+	if !p.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: cannot settle a %s payment", ErrInvalidTransition, p.Status)
+	}
+
+	p.Status = next
+	p.Acquirer = acquirer
+	p.AuthorizationCode = authorizationCode
+	p.DeclineReason = ""
+	if !authorized {
+		p.DeclineReason = declineReason
+	}
+	return nil
+}
+
+// Capture captures amount of the authorized funds. A zero amount captures
+// everything that is left. Several partial captures are allowed until the
+// authorized amount is used up or the first refund is made.
+func (p *Payment) Capture(id string, amount int, at time.Time) (Operation, error) {
+	remaining := p.Amount - p.CapturedAmount
+	if amount == 0 {
+		amount = remaining
+	}
+	if !p.Status.CanTransitionTo(StatusCaptured) {
+		return Operation{}, p.transitionError(OperationCapture)
+	}
+	if amount <= 0 || amount > remaining {
+		return Operation{}, fmt.Errorf("%w: capture amount must be between 1 and %d", ErrInvalidOperationAmount, remaining)
+	}
+
+	next := StatusPartiallyCaptured
+	if p.CapturedAmount+amount == p.Amount {
+		next = StatusCaptured
+	}
+
+	return p.apply(Operation{Id: id, Type: OperationCapture, Amount: amount, CreatedAt: at}, next)
+}
+
+// Void cancels an authorization that has not been captured.
+func (p *Payment) Void(id string, at time.Time) (Operation, error) {
+	return p.apply(Operation{Id: id, Type: OperationVoid, Amount: p.Amount, CreatedAt: at}, StatusVoided)
+}
+
+// VoidPending voids a pending payment that the acquirer did not authorize or
+// that was not settled in time. Merchants cannot void pending payments, since
+// the acquirer may still authorize them, so it is not reachable through Void.
+func (p *Payment) VoidPending(id string, at time.Time) (Operation, error) {
+	if p.Status != StatusPending {
+		return Operation{}, p.transitionError(OperationVoid)
+	}
+	return p.record(Operation{Id: id, Type: OperationVoid, Amount: p.Amount, CreatedAt: at}, StatusVoided), nil
+}
+
+// Refund returns amount of the captured funds. A zero amount refunds
+// everything that is left. Several partial refunds are allowed up to the
+// captured amount.
+func (p *Payment) Refund(id string, amount int, at time.Time) (Operation, error) {
+	remaining := p.CapturedAmount - p.RefundedAmount
+	if amount == 0 {
+		amount = remaining
+	}
+	if !p.Status.CanTransitionTo(StatusRefunded) {
+		return Operation{}, p.transitionError(OperationRefund)
+	}
+	if amount <= 0 || amount > remaining {
+		return Operation{}, fmt.Errorf("%w: refund amount must be between 1 and %d", ErrInvalidOperationAmount, remaining)
+	}
+
+	next := StatusPartiallyRefunded
+	if p.RefundedAmount+amount == p.CapturedAmount {
+		next = StatusRefunded
+	}
+
+	return p.apply(Operation{Id: id, Type: OperationRefund, Amount: amount, CreatedAt: at}, next)
+}
+
+// apply moves the payment to next and records op, or fails without changing
+// the payment if the transition is illegal.
+func (p *Payment) apply(op Operation, next PaymentStatus) (Operation, error) {
+	if !p.Status.CanTransitionTo(next) {
+		return Operation{}, p.transitionError(op.Type)
+	}
+	return p.record(op, next), nil
+}
+
+// record moves the payment to next and records op without checking the
+// transition.
+func (p *Payment) record(op Operation, next PaymentStatus) Operation {
+	op.PaymentId = p.Id
+	switch op.Type {
+	case OperationCapture:
+		p.CapturedAmount += op.Amount
+	case OperationRefund:
+		p.RefundedAmount += op.Amount
+	}
+	p.Status = next
+	p.Operations = append(p.Operations, op)
+
+	return op
+}
+
+func (p *Payment) transitionError(op OperationType) error {
+	return fmt.Errorf("%w: cannot %s a %s payment", ErrInvalidTransition, op, p.Status)
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentLifecycle(t *testing.T) {
+	now := time.Now()
+	authorized := func() *Payment {
+		return &Payment{Id: "payment-id", Status: StatusAuthorized, Amount: 1000}
+	}
+
+	t.Run("partial captures then full capture", func(t *testing.T) {
+		p := authorized()
+
+		op, err := p.Capture("op-1", 400, now)
+		require.NoError(t, err)
+		assert.Equal(t, Operation{Id: "op-1", PaymentId: "payment-id", Type: OperationCapture, Amount: 400, CreatedAt: now}, op)
+		assert.Equal(t, StatusPartiallyCaptured, p.Status)
+
+		op, err = p.Capture("op-2", 0, now)
+		require.NoError(t, err)
+		assert.Equal(t, 600, op.Amount)
+		assert.Equal(t, StatusCaptured, p.Status)
+		assert.Equal(t, 1000, p.CapturedAmount)
+		assert.Len(t, p.Operations, 2)
+	})
+
+	t.Run("capture more than authorized", func(t *testing.T) {
+		p := authorized()
+
+		_, err := p.Capture("op-1", 1001, now)
+		assert.ErrorIs(t, err, ErrInvalidOperationAmount)
+		assert.Equal(t, StatusAuthorized, p.Status)
+		assert.Empty(t, p.Operations)
+	})
+
+	t.Run("multiple partial refunds up to captured amount", func(t *testing.T) {
+		p := authorized()
+		_, err := p.Capture("op-1", 600, now)
+		require.NoError(t, err)
+
+		_, err = p.Refund("op-2", 200, now)
+		require.NoError(t, err)
+		assert.Equal(t, StatusPartiallyRefunded, p.Status)
+
+		_, err = p.Refund("op-3", 401, now)
+		assert.ErrorIs(t, err, ErrInvalidOperationAmount)
+
+		_, err = p.Refund("op-4", 400, now)
+		require.NoError(t, err)
+		assert.Equal(t, StatusRefunded, p.Status)
+		assert.Equal(t, 600, p.RefundedAmount)
+	})
+
+	t.Run("no capture after refund", func(t *testing.T) {
+		p := authorized()
+		_, err := p.Capture("op-1", 600, now)
+		require.NoError(t, err)
+		_, err = p.Refund("op-2", 100, now)
+		require.NoError(t, err)
+
+		_, err = p.Capture("op-3", 100, now)
+		assert.ErrorIs(t, err, ErrInvalidTransition)
+	})
+
+	t.Run("void authorized payment", func(t *testing.T) {
+		p := authorized()
+
+		op, err := p.Void("op-1", now)
+		require.NoError(t, err)
+		assert.Equal(t, OperationVoid, op.Type)
+		assert.Equal(t, StatusVoided, p.Status)
+	})
+
+	t.Run("void pending payment", func(t *testing.T) {
+		p := &Payment{Id: "payment-id", Status: StatusPending, Amount: 1000}
+
+		op, err := p.VoidPending("op-1", now)
+		require.NoError(t, err)
+		assert.Equal(t, OperationVoid, op.Type)
+		assert.Equal(t, "payment-id", op.PaymentId)
+		assert.Equal(t, StatusVoided, p.Status)
+	})
+
+	t.Run("settle pending payment", func(t *testing.T) {
+		p := &Payment{Id: "payment-id", Status: StatusPending, Amount: 1000}
+
+		require.NoError(t, p.Settle("simulator", true, "auth-code", DeclineDoNotHonour))
+		assert.Equal(t, StatusAuthorized, p.Status)
+		assert.Equal(t, "auth-code", p.AuthorizationCode)
+		assert.Equal(t, "simulator", p.Acquirer)
+		assert.Empty(t, p.DeclineReason, "authorized payments have no decline reason")
+		assert.Empty(t, p.Operations)
+	})
+
+	t.Run("decline pending payment", func(t *testing.T) {
+		p := &Payment{Id: "payment-id", Status: StatusPending, Amount: 1000}
+
+		require.NoError(t, p.Settle("simulator", false, "", DeclineInsufficientFunds))
+		assert.Equal(t, StatusDeclined, p.Status)
+		assert.Equal(t, DeclineInsufficientFunds, p.DeclineReason)
+	})
+
+	illegal := []struct {
+		name   string
+		status PaymentStatus
+		apply  func(p *Payment) error
+	}{
+		{"refund voided", StatusVoided, func(p *Payment) error { _, err := p.Refund("op", 100, now); return err }},
+		{"refund authorized", StatusAuthorized, func(p *Payment) error { _, err := p.Refund("op", 100, now); return err }},
+		{"capture voided", StatusVoided, func(p *Payment) error { _, err := p.Capture("op", 100, now); return err }},
+		{"capture declined", StatusDeclined, func(p *Payment) error { _, err := p.Capture("op", 100, now); return err }},
+		{"capture captured", StatusCaptured, func(p *Payment) error { _, err := p.Capture("op", 0, now); return err }},
+		{"void captured", StatusCaptured, func(p *Payment) error { _, err := p.Void("op", now); return err }},
+		{"void partially captured", StatusPartiallyCaptured, func(p *Payment) error { _, err := p.Void("op", now); return err }},
+		{"void rejected", StatusRejected, func(p *Payment) error { _, err := p.Void("op", now); return err }},
+		{"void refunded", StatusRefunded, func(p *Payment) error { _, err := p.Void("op", now); return err }},
+		{"void pending", StatusPending, func(p *Payment) error { _, err := p.Void("op", now); return err }},
+		{"void pending authorized", StatusAuthorized, func(p *Payment) error { _, err := p.VoidPending("op", now); return err }},
+		{"capture pending", StatusPending, func(p *Payment) error { _, err := p.Capture("op", 100, now); return err }},
+		{"settle authorized", StatusAuthorized, func(p *Payment) error { return p.Settle("simulator", false, "", DeclineDoNotHonour) }},
+	}
+	for _, tt := range illegal {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Payment{Id: "payment-id", Status: tt.status, Amount: 1000, CapturedAmount: 1000}
+
+			err := tt.apply(p)
+			assert.ErrorIs(t, err, ErrInvalidTransition)
+			assert.Equal(t, tt.status, p.Status)
+			assert.Empty(t, p.Operations)
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "errors"
+
+var (
+	ErrMerchantNotFound   = errors.New("merchant not found")
+	ErrInvalidCredentials = errors.New("invalid merchant credentials")
+)
+
+// Merchant is a client of the gateway allowed to create and read its own payments
+type Merchant struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// ApiKeyHash is the hex SHA-256 digest of the merchant's API secret
+	ApiKeyHash string `json:"api_key_hash"`
+}
@@ -1,40 +1,115 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/utils"
+)
 
 var (
-	ErrPaymentNotFound = errors.New("payment not found")
+	ErrPaymentNotFound      = errors.New("payment not found")
+	ErrPaymentAlreadyExists = errors.New("payment already exists")
+	ErrAcquirerUnavailable  = errors.New("acquirer unavailable")
+	// ErrAcquirerFailed wraps the errors of an acquirer that did not authorize a payment
+	ErrAcquirerFailed = errors.New("acquirer failed to process the payment")
 )
 
+// PaymentRequest is paid either with the card fields or with a card token as
+// source, in which case the card fields are filled from the vault just before
+// the payment is sent to the bank.
 type PaymentRequest struct {
+	// Source is a card token returned by POST /api/tokens
+	Source      string `json:"source,omitempty"`
 	CardNumber  string `json:"card_number"`
 	ExpiryMonth int    `json:"expiry_month"`
 	ExpiryYear  int    `json:"expiry_year"`
 	Currency    string `json:"currency"`
 	Amount      int    `json:"amount"`
 	Cvv         string `json:"cvv"`
+	// Reference is the merchant's own identifier for the payment, such as an order number
+	Reference string `json:"reference,omitempty"`
+}
+
+// LogValue leaves the card number, expiry date and CVV out of log records.
+func (r PaymentRequest) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("card_number_last_four", utils.GetLastFourDigits(r.CardNumber)),
+		slog.String("currency", r.Currency),
+		slog.Int("amount", r.Amount),
+		slog.String("reference", r.Reference),
+	}
+	if r.Source != "" {
+		attrs = append(attrs, slog.String("source", r.Source))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// String formats the request without its card data.
+func (r PaymentRequest) String() string {
+	return fmt.Sprintf("{Source:%s CardNumber:****%s Currency:%s Amount:%d Reference:%s}",
+		r.Source, utils.GetLastFourDigits(r.CardNumber), r.Currency, r.Amount, r.Reference)
+}
+
+// GoString is String for %#v.
+func (r PaymentRequest) GoString() string {
+	return "models.PaymentRequest" + r.String()
 }
 
 type PaymentResponse struct {
-	Id                 string `json:"id"`
-	Status             string `json:"status"`
-	CardNumberLastFour string `json:"card_number_last_four"`
-	ExpiryMonth        int    `json:"expiry_month"`
-	ExpiryYear         int    `json:"expiry_year"`
-	Currency           string `json:"currency"`
-	Amount             int    `json:"amount"`
+	Id                 string              `json:"id"`
+	Status             PaymentStatus       `json:"status"`
+	CardNumberLastFour string              `json:"card_number_last_four"`
+	CardScheme         card.Scheme         `json:"card_scheme"`
+	ExpiryMonth        int                 `json:"expiry_month"`
+	ExpiryYear         int                 `json:"expiry_year"`
+	Currency           string              `json:"currency"`
+	Amount             int                 `json:"amount"`
+	AmountFormatted    string              `json:"amount_formatted"`
+	DeclineReason      DeclineReason       `json:"decline_reason,omitempty"`
+	CapturedAmount     int                 `json:"captured_amount"`
+	RefundedAmount     int                 `json:"refunded_amount"`
+	Reference          string              `json:"reference,omitempty"`
+	CreatedAt          time.Time           `json:"created_at"`
+	Operations         []OperationResponse `json:"operations,omitempty"`
 }
 
 // Payment represents the internal storage model
 type Payment struct {
 	Id                 string
-	Status             string
+	MerchantId         string
+	Status             PaymentStatus
 	CardNumberLastFour string
+	CardScheme         card.Scheme
 	ExpiryMonth        int
 	ExpiryYear         int
 	Currency           string
 	Amount             int
 	AuthorizationCode  string
+	// Acquirer is the name of the acquiring bank that processed the payment
+	Acquirer string
+	// DeclineReason is why the acquirer declined the payment, if it did
+	DeclineReason  DeclineReason
+	CapturedAmount int
+	RefundedAmount int
+	Reference      string
+	Operations     []Operation
+	CreatedAt      time.Time
+	// Version is incremented on every update and used for optimistic locking
+	Version int
+}
+
+// SealedAuthorizationCode is the authorization code of a payment as the SQL
+// store keeps it, sealed with the vault key. Codes stored while no vault key
+// was configured have no KeyID, and their Ciphertext is the code itself.
+type SealedAuthorizationCode struct {
+	PaymentId  string
+	KeyID      string
+	DataKey    []byte
+	Ciphertext []byte
 }
 
 // ValidationError represents validation errors
@@ -42,8 +117,3 @@ type ValidationError struct {
 	Field   string `json:"field"`
 	Message string `json:"message"`
 }
-
-type ErrorResponse struct {
-	Error  string            `json:"error"`
-	Errors []ValidationError `json:"errors,omitempty"`
-}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPaymentBatchNotFound    = errors.New("payment batch not found")
+	ErrEmptyPaymentBatch       = errors.New("payment batch has no payments")
+	ErrPaymentBatchTooLarge    = errors.New("payment batch has too many payments")
+	ErrPaymentBatchLineTooLong = errors.New("payment batch line is too long")
+	// ErrPaymentBatchQueueFull is returned when too many batches wait to be processed
+	ErrPaymentBatchQueueFull = errors.New("too many payment batches are waiting to be processed")
+)
+
+type PaymentBatchStatus string
+
+const (
+	BatchProcessing PaymentBatchStatus = "processing"
+	BatchCompleted  PaymentBatchStatus = "completed"
+	// BatchInterrupted batches were stopped by a shutdown of the gateway.
+	// Their lines that were not processed have failed.
+	BatchInterrupted PaymentBatchStatus = "interrupted"
+)
+
+// StatusFailed is the status of batch lines whose payment could not be made,
+// such as when the acquirer was unavailable. No payment is created for them.
+const StatusFailed PaymentStatus = "Failed"
+
+// PaymentBatchLine is a line of a batch upload: a payment request along with
+// the client's reference for the line.
+type PaymentBatchLine struct {
+	// ClientReference identifies the line in the results
+	ClientReference string `json:"client_reference"`
+	PaymentRequest
+}
+
+// PaymentBatch is the progress of a batch of payments. The counts are the
+// number of processed lines by status.
+type PaymentBatch struct {
+	Id          string
+	MerchantId  string
+	Status      PaymentBatchStatus
+	Total       int
+	Processed   int
+	Authorized  int
+	Declined    int
+	Pending     int
+	Rejected    int
+	Failed      int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CompletedAt time.Time
+}
+
+// Count adds a processed line with result to the counts of b.
+func (b *PaymentBatch) Count(result PaymentBatchResult) {
+	b.Processed++
+	switch result.Status {
+	case StatusAuthorized:
+		b.Authorized++
+	case StatusDeclined:
+		b.Declined++
+	case StatusPending:
+		b.Pending++
+	case StatusRejected:
+		b.Rejected++
+	default:
+		b.Failed++
+	}
+}
+
+// PaymentBatchResult is the outcome of a line of a batch.
+type PaymentBatchResult struct {
+	// Line is the number of the line in the upload, starting at 1
+	Line            int    `json:"line"`
+	ClientReference string `json:"client_reference,omitempty"`
+	// Status is the status of the payment made, Rejected when the line is
+	// not a valid payment request, or Failed when the payment could not be made
+	Status        PaymentStatus     `json:"status"`
+	PaymentId     string            `json:"payment_id,omitempty"`
+	DeclineReason DeclineReason     `json:"decline_reason,omitempty"`
+	Errors        []ValidationError `json:"errors,omitempty"`
+	Error         string            `json:"error,omitempty"`
+}
+
+type PaymentBatchResponse struct {
+	Id          string             `json:"id"`
+	Status      PaymentBatchStatus `json:"status"`
+	Total       int                `json:"total"`
+	Processed   int                `json:"processed"`
+	Authorized  int                `json:"authorized"`
+	Declined    int                `json:"declined"`
+	Pending     int                `json:"pending"`
+	Rejected    int                `json:"rejected"`
+	Failed      int                `json:"failed"`
+	CreatedAt   time.Time          `json:"created_at"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// PaymentFilter selects payments. Zero fields match every payment.
+type PaymentFilter struct {
+	MerchantId         string
+	Status             PaymentStatus
+	Currency           string
+	MinAmount          int
+	MaxAmount          int
+	CardNumberLastFour string
+	// CreatedFrom is inclusive and CreatedTo exclusive
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Reference   string
+}
+
+// Matches reports whether payment is selected by the filter.
+func (f PaymentFilter) Matches(payment Payment) bool {
+	switch {
+	case f.MerchantId != "" && payment.MerchantId != f.MerchantId,
+		f.Status != "" && payment.Status != f.Status,
+		f.Currency != "" && payment.Currency != f.Currency,
+		f.MinAmount > 0 && payment.Amount < f.MinAmount,
+		f.MaxAmount > 0 && payment.Amount > f.MaxAmount,
+		f.CardNumberLastFour != "" && payment.CardNumberLastFour != f.CardNumberLastFour,
+		!f.CreatedFrom.IsZero() && payment.CreatedAt.Before(f.CreatedFrom),
+		!f.CreatedTo.IsZero() && !payment.CreatedAt.Before(f.CreatedTo),
+		f.Reference != "" && payment.Reference != f.Reference:
+		return false
+	}
+	return true
+}
+
+// PaymentCursor is the position of a payment in the listing order, newest
+// first with ties broken by descending ID.
+type PaymentCursor struct {
+	CreatedAt time.Time
+	Id        string
+}
+
+// Before reports whether payment comes after the cursor in the listing order.
+func (c PaymentCursor) Before(payment Payment) bool {
+	if payment.CreatedAt.Equal(c.CreatedAt) {
+		return payment.Id < c.Id
+	}
+	return payment.CreatedAt.Before(c.CreatedAt)
+}
+
+// PaymentQuery asks for at most Limit payments matching the filter, starting
+// after the After cursor when it is set.
+type PaymentQuery struct {
+	PaymentFilter
+	After *PaymentCursor
+	Limit int
+}
+
+type PaymentListResponse struct {
+	Data []PaymentResponse `json:"data"`
+	// NextCursor is passed as cursor to get the next page. It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
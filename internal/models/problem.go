@@ -0,0 +1,45 @@
+package models
+
+// ErrorCode identifies the kind of a Problem. Codes are stable, clients may
+// branch on them, unlike on titles and details which may be reworded.
+type ErrorCode string
+
+const (
+	// CodeMalformedRequest is a body that could not be read, such as invalid JSON
+	CodeMalformedRequest ErrorCode = "malformed_request"
+	// CodeValidationFailed comes with the invalid fields in Problem.Errors
+	CodeValidationFailed      ErrorCode = "validation_failed"
+	CodeUnauthorized          ErrorCode = "unauthorized"
+	CodeNotFound              ErrorCode = "not_found"
+	CodeMethodNotAllowed      ErrorCode = "method_not_allowed"
+	CodeIdempotencyKeyInUse   ErrorCode = "idempotency_key_in_use"
+	CodeIdempotencyKeyReused  ErrorCode = "idempotency_key_reused"
+	CodeInvalidTransition     ErrorCode = "invalid_transition"
+	CodeVersionConflict       ErrorCode = "version_conflict"
+	CodeInvalidAmount         ErrorCode = "invalid_amount"
+	CodePayloadTooLarge       ErrorCode = "payload_too_large"
+	CodeAcquirerUnavailable   ErrorCode = "acquirer_unavailable"
+	CodePaymentFailed         ErrorCode = "payment_failed"
+	CodePaymentBatchQueueFull ErrorCode = "payment_batch_queue_full"
+	CodeTimeout               ErrorCode = "timeout"
+	CodeInternalError         ErrorCode = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details body, served as
+// application/problem+json by every error response of the gateway.
+type Problem struct {
+	// Type is a URI naming the kind of problem, made from Code
+	Type  string `json:"type" example:"urn:payment-gateway:problem:validation_failed"`
+	Title string `json:"title" example:"Request is invalid"`
+	// Status repeats the HTTP status code of the response
+	Status int       `json:"status" example:"400"`
+	Code   ErrorCode `json:"code" example:"validation_failed"`
+	// Detail explains this occurrence of the problem. It never holds
+	// internal error messages.
+	Detail string `json:"detail,omitempty" example:"card number is invalid"`
+	// Instance is the path of the request
+	Instance string `json:"instance,omitempty" example:"/api/payments"`
+	// RequestId is the X-Request-Id of the request, to quote to support
+	RequestId string            `json:"request_id,omitempty" example:"8f14e45f-ceea-467f-a0e6-0c2a2e5ab2a1"`
+	Errors    []ValidationError `json:"errors,omitempty"`
+}
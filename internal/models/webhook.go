@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// EventType names a change of a payment that merchants can subscribe to
+type EventType string
+
+const (
+	EventPaymentAuthorized EventType = "payment.authorized"
+	EventPaymentDeclined   EventType = "payment.declined"
+	EventPaymentCaptured   EventType = "payment.captured"
+	EventPaymentVoided     EventType = "payment.voided"
+	EventPaymentRefunded   EventType = "payment.refunded"
+)
+
+// EventTypes lists every event type
+var EventTypes = []EventType{
+	EventPaymentAuthorized, EventPaymentDeclined, EventPaymentCaptured, EventPaymentVoided, EventPaymentRefunded,
+}
+
+var statusEvents = map[PaymentStatus]EventType{
+	StatusAuthorized:        EventPaymentAuthorized,
+	StatusDeclined:          EventPaymentDeclined,
+	StatusPartiallyCaptured: EventPaymentCaptured,
+	StatusCaptured:          EventPaymentCaptured,
+	StatusVoided:            EventPaymentVoided,
+	StatusPartiallyRefunded: EventPaymentRefunded,
+	StatusRefunded:          EventPaymentRefunded,
+}
+
+// EventTypeFor returns the event emitted when a payment moves to status.
+// Statuses without an event, such as Pending, return false.
+func EventTypeFor(status PaymentStatus) (EventType, bool) {
+	t, ok := statusEvents[status]
+	return t, ok
+}
+
+// PaymentEvent is the JSON body of a webhook delivery
+type PaymentEvent struct {
+	Id         string          `json:"id"`
+	Type       EventType       `json:"type"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Data       PaymentResponse `json:"data"`
+	MerchantId string          `json:"-"`
+}
+
+// WebhookEndpoint is a merchant URL receiving events
+type WebhookEndpoint struct {
+	Id         string
+	MerchantId string
+	URL        string
+	// Secret is the HMAC-SHA256 key deliveries to the endpoint are signed with
+	Secret     string
+	EventTypes []EventType
+	CreatedAt  time.Time
+}
+
+// Subscribes reports whether the endpoint receives events of type t.
+func (e WebhookEndpoint) Subscribes(t EventType) bool {
+	for _, et := range e.EventTypes {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is the state of a webhook delivery
+type DeliveryStatus string
+
+const (
+	// DeliveryPending is waiting for its next attempt
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySucceeded was acknowledged by the endpoint with a 2xx response
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead ran out of attempts and sits in the dead-letter list until replayed
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// DeliveryStatuses lists every delivery status
+var DeliveryStatuses = []DeliveryStatus{DeliveryPending, DeliverySucceeded, DeliveryDead}
+
+// WebhookDelivery is a single event queued for a single endpoint
+type WebhookDelivery struct {
+	Id             string
+	EndpointId     string
+	MerchantId     string
+	EventId        string
+	EventType      EventType
+	Payload        []byte
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type WebhookEndpointRequest struct {
+	URL    string      `json:"url"`
+	Events []EventType `json:"events"`
+}
+
+type WebhookEndpointResponse struct {
+	Id     string      `json:"id"`
+	URL    string      `json:"url"`
+	Events []EventType `json:"events"`
+	// Secret is only returned when the endpoint is registered
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WebhookDeliveryResponse struct {
+	Id             string         `json:"id"`
+	EndpointId     string         `json:"endpoint_id"`
+	EventId        string         `json:"event_id"`
+	EventType      EventType      `json:"event_type"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	NextAttemptAt  *time.Time     `json:"next_attempt_at,omitempty"`
+	LastStatusCode int            `json:"last_status_code,omitempty"`
+	LastError      string         `json:"last_error,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
@@ -0,0 +1,62 @@
+package problem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Recoverer is a middleware that recovers from panics of the next handler,
+// logs them with their stack and answers with an internal_error problem
+// unless a response was already started.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				// the server aborts the response on purpose
+				panic(rvr)
+			}
+
+			slog.ErrorContext(r.Context(), "request panicked",
+				slog.Any("error", fmt.Errorf("%v", rvr)),
+				slog.String("stack", string(debug.Stack())),
+			)
+			if ww.Status() == 0 {
+				Error(ww, r, http.StatusInternalServerError, models.CodeInternalError, "the request could not be served")
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// Timeout returns a middleware that cancels the context of requests after
+// timeout. A request whose handler gave up without writing a response gets a
+// timeout problem.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if ww.Status() == 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				Error(ww, r, http.StatusGatewayTimeout, models.CodeTimeout, fmt.Sprintf("the request was not served within %s", timeout))
+			}
+		})
+	}
+}
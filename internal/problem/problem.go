@@ -0,0 +1,87 @@
+// Package problem writes the error responses of the gateway as RFC 7807
+// problem details, see models.Problem.
+package problem
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/logging"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// ContentType is the media type of problem details.
+const ContentType = "application/problem+json"
+
+// TypePrefix is prepended to the code of a problem to make its type.
+const TypePrefix = "urn:payment-gateway:problem:"
+
+// titles are the titles of the problems by code, which do not change from
+// one occurrence to the next.
+var titles = map[models.ErrorCode]string{
+	models.CodeMalformedRequest:      "Request body is malformed",
+	models.CodeValidationFailed:      "Request is invalid",
+	models.CodeUnauthorized:          "Credentials are missing or invalid",
+	models.CodeNotFound:              "Resource not found",
+	models.CodeMethodNotAllowed:      "Method not allowed",
+	models.CodeIdempotencyKeyInUse:   "Idempotency key is in use",
+	models.CodeIdempotencyKeyReused:  "Idempotency key was used for another request",
+	models.CodeInvalidTransition:     "Payment does not allow this operation",
+	models.CodeVersionConflict:       "Payment was changed concurrently",
+	models.CodeInvalidAmount:         "Amount is invalid for this payment",
+	models.CodePayloadTooLarge:       "Request is too large",
+	models.CodeAcquirerUnavailable:   "Acquirer unavailable",
+	models.CodePaymentFailed:         "Payment could not be processed",
+	models.CodePaymentBatchQueueFull: "Too many payment batches are waiting",
+	models.CodeTimeout:               "Request timed out",
+	models.CodeInternalError:         "Internal error",
+}
+
+// Write writes p with the status p.Status. The type, title, instance and
+// request ID are filled in when p leaves them out.
+func Write(w http.ResponseWriter, r *http.Request, p models.Problem) {
+	if p.Type == "" {
+		p.Type = TypePrefix + string(p.Code)
+	}
+	if p.Title == "" {
+		p.Title = titles[p.Code]
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestId == "" {
+		p.RequestId = logging.RequestID(r.Context())
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error writes the problem code with status. detail is shown to the client,
+// so it must not hold internal error messages.
+func Error(w http.ResponseWriter, r *http.Request, status int, code models.ErrorCode, detail string) {
+	Write(w, r, models.Problem{Status: status, Code: code, Detail: detail})
+}
+
+// Validation writes a 400 listing the invalid fields of the request.
+func Validation(w http.ResponseWriter, r *http.Request, errs []models.ValidationError) {
+	Write(w, r, models.Problem{Status: http.StatusBadRequest, Code: models.CodeValidationFailed, Errors: errs})
+}
+
+// Internal logs err and writes a 500 with the public detail instead.
+func Internal(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	slog.ErrorContext(r.Context(), "request failed", slog.String("detail", detail), slog.Any("error", err))
+	Error(w, r, http.StatusInternalServerError, models.CodeInternalError, detail)
+}
+
+// NotFound is the http.HandlerFunc of the routes that do not exist.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusNotFound, models.CodeNotFound, "no route matches "+r.URL.Path)
+}
+
+// MethodNotAllowed is the http.HandlerFunc of the routes that exist for other methods.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+}
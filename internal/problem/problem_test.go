@@ -0,0 +1,122 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/logging"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, h http.Handler) (*httptest.ResponseRecorder, models.Problem) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/api/payments/some-id", nil)
+	req = req.WithContext(logging.WithRequestID(req.Context(), "req-1"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var p models.Problem
+	if w.Body.Len() > 0 {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	}
+	return w, p
+}
+
+func TestError(t *testing.T) {
+	w, p := serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, http.StatusNotFound, models.CodeNotFound, models.ErrPaymentNotFound.Error())
+	}))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, models.Problem{
+		Type:      "urn:payment-gateway:problem:not_found",
+		Title:     "Resource not found",
+		Status:    http.StatusNotFound,
+		Code:      models.CodeNotFound,
+		Detail:    models.ErrPaymentNotFound.Error(),
+		Instance:  "/api/payments/some-id",
+		RequestId: "req-1",
+	}, p)
+}
+
+func TestTitles(t *testing.T) {
+	codes := []models.ErrorCode{
+		models.CodeMalformedRequest, models.CodeValidationFailed, models.CodeUnauthorized, models.CodeNotFound,
+		models.CodeMethodNotAllowed, models.CodeIdempotencyKeyInUse, models.CodeIdempotencyKeyReused,
+		models.CodeInvalidTransition, models.CodeVersionConflict, models.CodeInvalidAmount, models.CodePayloadTooLarge,
+		models.CodeAcquirerUnavailable, models.CodePaymentFailed, models.CodePaymentBatchQueueFull, models.CodeTimeout,
+		models.CodeInternalError,
+	}
+	for _, code := range codes {
+		assert.NotEmpty(t, titles[code], code)
+	}
+}
+
+func TestInternal(t *testing.T) {
+	w, p := serve(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Internal(w, r, errors.New("database is locked"), "Failed to read payment")
+	}))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, models.CodeInternalError, p.Code)
+	assert.Equal(t, "Failed to read payment", p.Detail)
+	assert.NotContains(t, w.Body.String(), "database is locked")
+}
+
+func TestRecoverer(t *testing.T) {
+	t.Run("panic", func(t *testing.T) {
+		w, p := serve(t, Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("nil map")
+		})))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, models.CodeInternalError, p.Code)
+		assert.NotContains(t, w.Body.String(), "nil map")
+	})
+
+	t.Run("panic after response started", func(t *testing.T) {
+		w, _ := serve(t, Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			panic("nil map")
+		})))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			serve(t, Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic(http.ErrAbortHandler)
+			})))
+		})
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	t.Run("gave up", func(t *testing.T) {
+		w, p := serve(t, Timeout(10*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		})))
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, models.CodeTimeout, p.Code)
+	})
+
+	t.Run("answered", func(t *testing.T) {
+		w, p := serve(t, Timeout(10*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			Error(w, r, http.StatusServiceUnavailable, models.CodeAcquirerUnavailable, "")
+		})))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, models.CodeAcquirerUnavailable, p.Code)
+	})
+}
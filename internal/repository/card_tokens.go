@@ -0,0 +1,21 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// CardTokensRepository is the vault store. It only ever sees card numbers encrypted.
+type CardTokensRepository interface {
+	AddToken(ctx context.Context, token models.CardToken) error
+	// GetToken returns models.ErrCardTokenNotFound if no token has the given ID.
+	GetToken(ctx context.Context, id string) (*models.CardToken, error)
+	// ListTokens returns up to limit tokens with an ID greater than after, in
+	// ID order.
+	ListTokens(ctx context.Context, after string, limit int) ([]models.CardToken, error)
+	// UpdateTokenKey stores the key ID, data key and encrypted card number of
+	// token, which was re-encrypted under another key. It returns
+	// models.ErrCardTokenNotFound if no token has token.Id.
+	UpdateTokenKey(ctx context.Context, token models.CardToken) error
+}
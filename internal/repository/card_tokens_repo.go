@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type inMemCardTokensStore struct {
+	mu     sync.Mutex
+	tokens map[string]models.CardToken
+}
+
+func NewCardTokensRepository() CardTokensRepository {
+	return &inMemCardTokensStore{
+		tokens: make(map[string]models.CardToken),
+	}
+}
+
+func (s *inMemCardTokensStore) AddToken(ctx context.Context, token models.CardToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.Id] = cloneToken(token)
+	return nil
+}
+
+func (s *inMemCardTokensStore) GetToken(ctx context.Context, id string) (*models.CardToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.tokens[id]
+	if !exists {
+		return nil, models.ErrCardTokenNotFound
+	}
+	token = cloneToken(token)
+	return &token, nil
+}
+
+func (s *inMemCardTokensStore) ListTokens(ctx context.Context, after string, limit int) ([]models.CardToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []models.CardToken
+	for id, token := range s.tokens {
+		if id > after {
+			tokens = append(tokens, cloneToken(token))
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Id < tokens[j].Id })
+	if len(tokens) > limit {
+		tokens = tokens[:limit]
+	}
+	return tokens, nil
+}
+
+func (s *inMemCardTokensStore) UpdateTokenKey(ctx context.Context, token models.CardToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, exists := s.tokens[token.Id]
+	if !exists {
+		return models.ErrCardTokenNotFound
+	}
+	stored.KeyID = token.KeyID
+	stored.DataKey = token.DataKey
+	stored.EncryptedCardNumber = token.EncryptedCardNumber
+	s.tokens[token.Id] = cloneToken(stored)
+	return nil
+}
+
+// cloneToken copies the byte slices of token so callers cannot change stored records.
+func cloneToken(token models.CardToken) models.CardToken {
+	token.DataKey = append([]byte(nil), token.DataKey...)
+	token.EncryptedCardNumber = append([]byte(nil), token.EncryptedCardNumber...)
+	return token
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+const cardTokenColumns = `id, merchant_id, card_number_last_four, card_scheme, expiry_month, expiry_year,
+	key_id, data_key, encrypted_card_number, created_at`
+
+type sqlCardTokensStore struct {
+	*Database
+}
+
+func NewSQLCardTokensRepository(db *Database) CardTokensRepository {
+	return &sqlCardTokensStore{Database: db}
+}
+
+func (s *sqlCardTokensStore) AddToken(ctx context.Context, token models.CardToken) error {
+	if _, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO card_tokens (`+cardTokenColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		token.Id,
+		token.MerchantId,
+		token.CardNumberLastFour,
+		string(token.CardScheme),
+		token.ExpiryMonth,
+		token.ExpiryYear,
+		token.KeyID,
+		token.DataKey,
+		token.EncryptedCardNumber,
+		token.CreatedAt.UnixMicro(),
+	); err != nil {
+		return fmt.Errorf("failed to insert card token: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlCardTokensStore) GetToken(ctx context.Context, id string) (*models.CardToken, error) {
+	token, err := scanToken(s.db.QueryRowContext(ctx, s.rebind(`
+		SELECT `+cardTokenColumns+`
+		FROM card_tokens
+		WHERE id = ?`), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrCardTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read card token: %w", err)
+	}
+	return token, nil
+}
+
+func (s *sqlCardTokensStore) ListTokens(ctx context.Context, after string, limit int) ([]models.CardToken, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT `+cardTokenColumns+`
+		FROM card_tokens
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?`), after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list card tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []models.CardToken
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list card tokens: %w", err)
+		}
+		tokens = append(tokens, *token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list card tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+func (s *sqlCardTokensStore) UpdateTokenKey(ctx context.Context, token models.CardToken) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(`
+		UPDATE card_tokens
+		SET key_id = ?, data_key = ?, encrypted_card_number = ?
+		WHERE id = ?`),
+		token.KeyID,
+		token.DataKey,
+		token.EncryptedCardNumber,
+		token.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update card token: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ErrCardTokenNotFound
+	}
+	return nil
+}
+
+// scanToken reads a row of cardTokenColumns.
+func scanToken(row interface{ Scan(...any) error }) (*models.CardToken, error) {
+	var (
+		token     models.CardToken
+		scheme    string
+		createdAt int64
+	)
+	err := row.Scan(
+		&token.Id,
+		&token.MerchantId,
+		&token.CardNumberLastFour,
+		&scheme,
+		&token.ExpiryMonth,
+		&token.ExpiryYear,
+		&token.KeyID,
+		&token.DataKey,
+		&token.EncryptedCardNumber,
+		&createdAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.CardScheme = card.Scheme(scheme)
+	token.CreatedAt = time.UnixMicro(createdAt).UTC()
+	return &token, nil
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type IdempotencyRepository interface {
+	// Reserve claims the key for a new request. If the key is already known the
+	// existing record is returned and created is false.
+	Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (record *models.IdempotencyRecord, created bool, err error)
+	// Complete stores the final response for a reserved key.
+	Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error
+	// Release forgets a reserved key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
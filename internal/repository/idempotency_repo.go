@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+const idempotencySweepInterval = time.Minute
+
+type inMemIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]models.IdempotencyRecord
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewIdempotencyRepository() IdempotencyRepository {
+	return &inMemIdempotencyStore{
+		records: make(map[string]models.IdempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+func (s *inMemIdempotencyStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*models.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if record, exists := s.records[key]; exists && now.Before(record.ExpiresAt) {
+		return &record, false, nil
+	}
+
+	record := models.IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(ttl),
+	}
+	s.records[key] = record
+
+	return &record, true, nil
+}
+
+func (s *inMemIdempotencyStore) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[key]
+	if !exists {
+		return nil
+	}
+
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = append([]byte(nil), body...)
+	s.records[key] = record
+
+	return nil
+}
+
+func (s *inMemIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+// sweep drops expired records. It runs at most once per idempotencySweepInterval
+// so that Reserve stays cheap. Callers must hold s.mu.
+func (s *inMemIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
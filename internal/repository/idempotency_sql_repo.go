@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type sqlIdempotencyStore struct {
+	*Database
+	now func() time.Time
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewSQLIdempotencyRepository(db *Database) IdempotencyRepository {
+	return &sqlIdempotencyStore{Database: db, now: time.Now}
+}
+
+func (s *sqlIdempotencyStore) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (*models.IdempotencyRecord, bool, error) {
+	now := s.now()
+	expiresAt := now.Add(ttl)
+	s.sweep(ctx, now)
+
+	// Claim the key if it is new, or take it over if the previous record expired.
+	res, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO idempotency_keys (idempotency_key, request_hash, completed, status_code, content_type, body, expires_at)
+		VALUES (?, ?, ?, 0, '', NULL, ?)
+		ON CONFLICT (idempotency_key) DO UPDATE SET
+			request_hash = excluded.request_hash,
+			completed = excluded.completed,
+			status_code = 0,
+			content_type = '',
+			body = NULL,
+			expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= ?`),
+		key, requestHash, false, expiresAt.UnixMicro(), now.UnixMicro())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 1 {
+		return &models.IdempotencyRecord{
+			Key:         key,
+			RequestHash: requestHash,
+			ExpiresAt:   expiresAt,
+		}, true, nil
+	}
+
+	record, err := s.get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return record, false, nil
+}
+
+func (s *sqlIdempotencyStore) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`
+		UPDATE idempotency_keys
+		SET completed = ?, status_code = ?, content_type = ?, body = ?
+		WHERE idempotency_key = ?`),
+		true, statusCode, contentType, body, key)
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sqlIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM idempotency_keys WHERE idempotency_key = ?`), key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sqlIdempotencyStore) get(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`
+		SELECT idempotency_key, request_hash, completed, status_code, content_type, body, expires_at
+		FROM idempotency_keys
+		WHERE idempotency_key = ?`), key)
+
+	var (
+		record    models.IdempotencyRecord
+		expiresAt int64
+	)
+	err := row.Scan(
+		&record.Key,
+		&record.RequestHash,
+		&record.Completed,
+		&record.StatusCode,
+		&record.ContentType,
+		&record.Body,
+		&expiresAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("idempotency key %q vanished while reserving", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+	record.ExpiresAt = time.UnixMicro(expiresAt)
+
+	return &record, nil
+}
+
+// sweep deletes expired keys at most once per idempotencySweepInterval. Expired
+// keys are also taken over on Reserve, so a failed sweep is harmless.
+func (s *sqlIdempotencyStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < idempotencySweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	s.db.ExecContext(ctx, s.rebind(`DELETE FROM idempotency_keys WHERE expires_at <= ?`), now.UnixMicro())
+}
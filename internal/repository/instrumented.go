@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/metrics"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// repositoryNames are the interfaces the decorators wrap, by metric label.
+var repositoryNames = map[string]string{
+	"payments":        "PaymentsRepository",
+	"idempotency":     "IdempotencyRepository",
+	"webhooks":        "WebhooksRepository",
+	"card_tokens":     "CardTokensRepository",
+	"key_rotations":   "KeyRotationsRepository",
+	"payment_batches": "PaymentBatchesRepository",
+}
+
+// instrument starts a span for operation, the name of a method of
+// repository, and returns the context to run it with along with the function
+// to call with its outcome. The function ends the span and records the
+// duration of the operation in m. Lookups of missing records are not
+// failures of the store and are recorded with their own result.
+//
+// Only operations that are part of a trace get a span, so that the polling
+// of the background jobs does not start a trace every second.
+func instrument(ctx context.Context, m *metrics.Metrics, repository, operation string) (context.Context, func(err error)) {
+	start := time.Now()
+	span := trace.SpanFromContext(ctx)
+	if span.SpanContext().IsValid() {
+		ctx, span = tracing.Start(ctx, repositoryNames[repository]+"."+operation,
+			trace.WithAttributes(attribute.String("db.operation", operation)))
+	}
+
+	return ctx, func(err error) {
+		result := "ok"
+		switch {
+		case err == nil:
+		case errors.Is(err, models.ErrPaymentNotFound),
+			errors.Is(err, models.ErrWebhookEndpointNotFound),
+			errors.Is(err, models.ErrWebhookDeliveryNotFound),
+			errors.Is(err, models.ErrCardTokenNotFound),
+			errors.Is(err, models.ErrKeyRotationNotFound),
+			errors.Is(err, models.ErrPaymentBatchNotFound):
+			result = "not_found"
+		default:
+			result = "error"
+		}
+		m.ObserveRepositoryOperation(repository, snakeCase(operation), result, time.Since(start))
+
+		if !span.SpanContext().IsValid() {
+			return
+		}
+		if result == "not_found" {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
+
+// snakeCase turns a method name such as GetPayment into get_payment.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+type instrumentedPayments struct {
+	next    PaymentsRepository
+	metrics *metrics.Metrics
+}
+
+// InstrumentPayments traces every operation of next and records its duration in m.
+func InstrumentPayments(next PaymentsRepository, m *metrics.Metrics) PaymentsRepository {
+	return &instrumentedPayments{next: next, metrics: m}
+}
+
+func (r *instrumentedPayments) GetPayment(ctx context.Context, id string) (payment *models.Payment, err error) {
+	ctx, done := instrument(ctx, r.metrics, "payments", "GetPayment")
+	defer func() { done(err) }()
+	return r.next.GetPayment(ctx, id)
+}
+
+func (r *instrumentedPayments) AddPayment(ctx context.Context, payment models.Payment) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "payments", "AddPayment")
+	defer func() { done(err) }()
+	return r.next.AddPayment(ctx, payment)
+}
+
+func (r *instrumentedPayments) UpdatePayment(ctx context.Context, payment models.Payment, event *models.PaymentEvent, ops ...models.Operation) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "payments", "UpdatePayment")
+	defer func() { done(err) }()
+	return r.next.UpdatePayment(ctx, payment, event, ops...)
+}
+
+func (r *instrumentedPayments) UnpublishedEvents(ctx context.Context, createdBefore time.Time, limit int) (events []models.PaymentEvent, err error) {
+	ctx, done := instrument(ctx, r.metrics, "payments", "UnpublishedEvents")
+	defer func() { done(err) }()
+	return r.next.UnpublishedEvents(ctx, createdBefore, limit)
+}
+
+func (r *instrumentedPayments) MarkEventPublished(ctx context.Context, id string) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "payments", "MarkEventPublished")
+	defer func() { done(err) }()
+	return r.next.MarkEventPublished(ctx, id)
+}
+
+func (r *instrumentedPayments) PendingPayments(ctx context.Context, createdBefore time.Time) (payments []models.Payment, err error) {
+	ctx, done := instrument(ctx, r.metrics, "payments", "PendingPayments")
+	defer func() { done(err) }()
+	return r.next.PendingPayments(ctx, createdBefore)
+}
+
+func (r *instrumentedPayments) ListPayments(ctx context.Context, query models.PaymentQuery) (payments []models.Payment, err error) {
+	ctx, done := instrument(ctx, r.metrics, "payments", "ListPayments")
+	defer func() { done(err) }()
+	return r.next.ListPayments(ctx, query)
+}
+
+type instrumentedIdempotency struct {
+	next    IdempotencyRepository
+	metrics *metrics.Metrics
+}
+
+// InstrumentIdempotency traces every operation of next and records its duration in m.
+func InstrumentIdempotency(next IdempotencyRepository, m *metrics.Metrics) IdempotencyRepository {
+	return &instrumentedIdempotency{next: next, metrics: m}
+}
+
+func (r *instrumentedIdempotency) Reserve(ctx context.Context, key, requestHash string, ttl time.Duration) (record *models.IdempotencyRecord, created bool, err error) {
+	ctx, done := instrument(ctx, r.metrics, "idempotency", "Reserve")
+	defer func() { done(err) }()
+	return r.next.Reserve(ctx, key, requestHash, ttl)
+}
+
+func (r *instrumentedIdempotency) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "idempotency", "Complete")
+	defer func() { done(err) }()
+	return r.next.Complete(ctx, key, statusCode, contentType, body)
+}
+
+func (r *instrumentedIdempotency) Release(ctx context.Context, key string) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "idempotency", "Release")
+	defer func() { done(err) }()
+	return r.next.Release(ctx, key)
+}
+
+type instrumentedWebhooks struct {
+	next    WebhooksRepository
+	metrics *metrics.Metrics
+}
+
+// InstrumentWebhooks traces every operation of next and records its duration in m.
+func InstrumentWebhooks(next WebhooksRepository, m *metrics.Metrics) WebhooksRepository {
+	return &instrumentedWebhooks{next: next, metrics: m}
+}
+
+func (r *instrumentedWebhooks) AddEndpoint(ctx context.Context, endpoint models.WebhookEndpoint) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "webhooks", "AddEndpoint")
+	defer func() { done(err) }()
+	return r.next.AddEndpoint(ctx, endpoint)
+}
+
+func (r *instrumentedWebhooks) GetEndpoint(ctx context.Context, id string) (endpoint *models.WebhookEndpoint, err error) {
+	ctx, done := instrument(ctx, r.metrics, "webhooks", "GetEndpoint")
+	defer func() { done(err) }()
+	return r.next.GetEndpoint(ctx, id)
+}
+
+func (r *instrumentedWebhooks) ListEndpoints(ctx context.Context, merchantID string) (endpoints []models.WebhookEndpoint, err error) {
+	ctx, done := instrument(ctx, r.metrics, "webhooks", "ListEndpoints")
+	defer func() { done(err) }()
+	return r.next.ListEndpoints(ctx, merchantID)
+}
+
+func (r *instrumentedWebhooks) DeleteEndpoint(ctx context.Context, id string) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "webhooks", "DeleteEndpoint")
+	defer func() { done(err) }()
+	return r.next.DeleteEndpoint(ctx, id)
+}
+
+func (r *instrumentedWebhooks) AddDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "webhooks", "AddDeliveries")
+	defer func() { done(err) }()
+	return r.next.AddDeliveries(ctx, deliveries)
+}
+
+func (r *instrumentedWebhooks) GetDelivery(ctx context.Context, id string) (delivery *models.WebhookDelivery, err error) {
+	ctx, done := instrument(ctx, r.metrics, "webhooks", "GetDelivery")
+	defer func() { done(err) }()
+	return r.next.GetDelivery(ctx, id)
+}
+
+func (r *instrumentedWebhooks) ListDeliveries(ctx context.Context, merchantID string, status models.DeliveryStatus, limit int) (deliveries []models.WebhookDelivery, err error) {
+	ctx, done := instrument(ctx, r.metrics, "webhooks", "ListDeliveries")
+	defer func() { done(err) }()
+	return r.next.ListDeliveries(ctx, merchantID, status, limit)
+}
+
+func (r *instrumentedWebhooks) ClaimDueDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) (deliveries []models.WebhookDelivery, err error) {
+	ctx, done := instrument(ctx, r.metrics, "webhooks", "ClaimDueDeliveries")
+	defer func() { done(err) }()
+	return r.next.ClaimDueDeliveries(ctx, now, leaseUntil, limit)
+}
+
+func (r *instrumentedWebhooks) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "webhooks", "UpdateDelivery")
+	defer func() { done(err) }()
+	return r.next.UpdateDelivery(ctx, delivery)
+}
+
+type instrumentedCardTokens struct {
+	next    CardTokensRepository
+	metrics *metrics.Metrics
+}
+
+// InstrumentCardTokens traces every operation of next and records its duration in m.
+func InstrumentCardTokens(next CardTokensRepository, m *metrics.Metrics) CardTokensRepository {
+	return &instrumentedCardTokens{next: next, metrics: m}
+}
+
+func (r *instrumentedCardTokens) AddToken(ctx context.Context, token models.CardToken) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "card_tokens", "AddToken")
+	defer func() { done(err) }()
+	return r.next.AddToken(ctx, token)
+}
+
+func (r *instrumentedCardTokens) GetToken(ctx context.Context, id string) (token *models.CardToken, err error) {
+	ctx, done := instrument(ctx, r.metrics, "card_tokens", "GetToken")
+	defer func() { done(err) }()
+	return r.next.GetToken(ctx, id)
+}
+
+func (r *instrumentedCardTokens) ListTokens(ctx context.Context, after string, limit int) (tokens []models.CardToken, err error) {
+	ctx, done := instrument(ctx, r.metrics, "card_tokens", "ListTokens")
+	defer func() { done(err) }()
+	return r.next.ListTokens(ctx, after, limit)
+}
+
+func (r *instrumentedCardTokens) UpdateTokenKey(ctx context.Context, token models.CardToken) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "card_tokens", "UpdateTokenKey")
+	defer func() { done(err) }()
+	return r.next.UpdateTokenKey(ctx, token)
+}
+
+type instrumentedKeyRotations struct {
+	next    KeyRotationsRepository
+	metrics *metrics.Metrics
+}
+
+// InstrumentKeyRotations traces every operation of next and records its duration in m.
+func InstrumentKeyRotations(next KeyRotationsRepository, m *metrics.Metrics) KeyRotationsRepository {
+	return &instrumentedKeyRotations{next: next, metrics: m}
+}
+
+func (r *instrumentedKeyRotations) GetRotation(ctx context.Context, name string) (rotation *models.KeyRotation, err error) {
+	ctx, done := instrument(ctx, r.metrics, "key_rotations", "GetRotation")
+	defer func() { done(err) }()
+	return r.next.GetRotation(ctx, name)
+}
+
+func (r *instrumentedKeyRotations) SaveRotation(ctx context.Context, rotation models.KeyRotation) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "key_rotations", "SaveRotation")
+	defer func() { done(err) }()
+	return r.next.SaveRotation(ctx, rotation)
+}
+
+type instrumentedPaymentBatches struct {
+	next    PaymentBatchesRepository
+	metrics *metrics.Metrics
+}
+
+// InstrumentPaymentBatches traces every operation of next and records its duration in m.
+func InstrumentPaymentBatches(next PaymentBatchesRepository, m *metrics.Metrics) PaymentBatchesRepository {
+	return &instrumentedPaymentBatches{next: next, metrics: m}
+}
+
+func (r *instrumentedPaymentBatches) AddBatch(ctx context.Context, batch models.PaymentBatch) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "payment_batches", "AddBatch")
+	defer func() { done(err) }()
+	return r.next.AddBatch(ctx, batch)
+}
+
+func (r *instrumentedPaymentBatches) GetBatch(ctx context.Context, id string) (batch *models.PaymentBatch, err error) {
+	ctx, done := instrument(ctx, r.metrics, "payment_batches", "GetBatch")
+	defer func() { done(err) }()
+	return r.next.GetBatch(ctx, id)
+}
+
+func (r *instrumentedPaymentBatches) UpdateBatch(ctx context.Context, batch models.PaymentBatch) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "payment_batches", "UpdateBatch")
+	defer func() { done(err) }()
+	return r.next.UpdateBatch(ctx, batch)
+}
+
+func (r *instrumentedPaymentBatches) AddBatchResults(ctx context.Context, batchID string, results []models.PaymentBatchResult) (err error) {
+	ctx, done := instrument(ctx, r.metrics, "payment_batches", "AddBatchResults")
+	defer func() { done(err) }()
+	return r.next.AddBatchResults(ctx, batchID, results)
+}
+
+func (r *instrumentedPaymentBatches) ListBatchResults(ctx context.Context, batchID string) (results []models.PaymentBatchResult, err error) {
+	ctx, done := instrument(ctx, r.metrics, "payment_batches", "ListBatchResults")
+	defer func() { done(err) }()
+	return r.next.ListBatchResults(ctx, batchID)
+}
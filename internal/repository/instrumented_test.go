@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/metrics"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentPayments(t *testing.T) {
+	ctx := context.Background()
+	m := metrics.New()
+	repo := InstrumentPayments(NewPaymentsRepository(), m)
+
+	require.NoError(t, repo.AddPayment(ctx, models.Payment{Id: "payment-id"}))
+	_, err := repo.GetPayment(ctx, "payment-id")
+	require.NoError(t, err)
+	_, err = repo.GetPayment(ctx, "missing")
+	assert.ErrorIs(t, err, models.ErrPaymentNotFound)
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	out := w.Body.String()
+	assert.Contains(t, out, `gateway_repository_operation_duration_seconds_count{operation="add_payment",repository="payments",result="ok"} 1`)
+	assert.Contains(t, out, `gateway_repository_operation_duration_seconds_count{operation="get_payment",repository="payments",result="ok"} 1`)
+	assert.Contains(t, out, `gateway_repository_operation_duration_seconds_count{operation="get_payment",repository="payments",result="not_found"} 1`)
+}
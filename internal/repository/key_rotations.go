@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// KeyRotationsRepository keeps the progress of the re-encryption runs.
+type KeyRotationsRepository interface {
+	// GetRotation returns models.ErrKeyRotationNotFound if no rotation has the given name.
+	GetRotation(ctx context.Context, name string) (*models.KeyRotation, error)
+	// SaveRotation creates or replaces the rotation with rotation.Name.
+	SaveRotation(ctx context.Context, rotation models.KeyRotation) error
+}
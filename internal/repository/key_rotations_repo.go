@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type inMemKeyRotationsStore struct {
+	mu        sync.Mutex
+	rotations map[string]models.KeyRotation
+}
+
+func NewKeyRotationsRepository() KeyRotationsRepository {
+	return &inMemKeyRotationsStore{
+		rotations: make(map[string]models.KeyRotation),
+	}
+}
+
+func (s *inMemKeyRotationsStore) GetRotation(ctx context.Context, name string) (*models.KeyRotation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rotation, exists := s.rotations[name]
+	if !exists {
+		return nil, models.ErrKeyRotationNotFound
+	}
+	return &rotation, nil
+}
+
+func (s *inMemKeyRotationsStore) SaveRotation(ctx context.Context, rotation models.KeyRotation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rotations[rotation.Name] = rotation
+	return nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type sqlKeyRotationsStore struct {
+	*Database
+}
+
+func NewSQLKeyRotationsRepository(db *Database) KeyRotationsRepository {
+	return &sqlKeyRotationsStore{Database: db}
+}
+
+func (s *sqlKeyRotationsStore) GetRotation(ctx context.Context, name string) (*models.KeyRotation, error) {
+	var (
+		rotation             models.KeyRotation
+		startedAt, updatedAt int64
+	)
+	err := s.db.QueryRowContext(ctx, s.rebind(`
+		SELECT name, key_id, last_id, reencrypted, completed, started_at, updated_at
+		FROM key_rotations
+		WHERE name = ?`), name).Scan(
+		&rotation.Name,
+		&rotation.KeyID,
+		&rotation.LastID,
+		&rotation.Reencrypted,
+		&rotation.Completed,
+		&startedAt,
+		&updatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrKeyRotationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key rotation: %w", err)
+	}
+
+	rotation.StartedAt = time.UnixMicro(startedAt).UTC()
+	rotation.UpdatedAt = time.UnixMicro(updatedAt).UTC()
+	return &rotation, nil
+}
+
+func (s *sqlKeyRotationsStore) SaveRotation(ctx context.Context, rotation models.KeyRotation) error {
+	if _, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO key_rotations (name, key_id, last_id, reencrypted, completed, started_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			key_id = excluded.key_id,
+			last_id = excluded.last_id,
+			reencrypted = excluded.reencrypted,
+			completed = excluded.completed,
+			started_at = excluded.started_at,
+			updated_at = excluded.updated_at`),
+		rotation.Name,
+		rotation.KeyID,
+		rotation.LastID,
+		rotation.Reencrypted,
+		rotation.Completed,
+		rotation.StartedAt.UnixMicro(),
+		rotation.UpdatedAt.UnixMicro(),
+	); err != nil {
+		return fmt.Errorf("failed to save key rotation: %w", err)
+	}
+	return nil
+}
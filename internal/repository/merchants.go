@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type MerchantsRepository interface {
+	// GetMerchant returns models.ErrMerchantNotFound if no merchant has the given ID.
+	GetMerchant(ctx context.Context, id string) (*models.Merchant, error)
+}
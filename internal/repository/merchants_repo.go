@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type inMemMerchantsStore struct {
+	merchants map[string]models.Merchant
+}
+
+// NewMerchantsRepository returns a read-only registry of the given merchants.
+func NewMerchantsRepository(merchants []models.Merchant) MerchantsRepository {
+	s := &inMemMerchantsStore{
+		merchants: make(map[string]models.Merchant, len(merchants)),
+	}
+	for _, m := range merchants {
+		s.merchants[m.Id] = m
+	}
+	return s
+}
+
+// LoadMerchantsRepository reads a JSON array of merchants from path.
+func LoadMerchantsRepository(path string) (MerchantsRepository, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read merchants file: %w", err)
+	}
+
+	var merchants []models.Merchant
+	if err := json.Unmarshal(data, &merchants); err != nil {
+		return nil, fmt.Errorf("failed to parse merchants file: %w", err)
+	}
+
+	for _, m := range merchants {
+		if m.Id == "" || m.ApiKeyHash == "" {
+			return nil, fmt.Errorf("merchant %q must have an id and api_key_hash", m.Name)
+		}
+		if digest, err := hex.DecodeString(m.ApiKeyHash); err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("merchant %q: api_key_hash must be the hex SHA-256 digest of the API secret", m.Id)
+		}
+	}
+
+	return NewMerchantsRepository(merchants), nil
+}
+
+func (s *inMemMerchantsStore) GetMerchant(ctx context.Context, id string) (*models.Merchant, error) {
+	if m, exists := s.merchants[id]; exists {
+		return &m, nil
+	}
+	return nil, models.ErrMerchantNotFound
+}
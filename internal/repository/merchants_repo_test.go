@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMerchantsRepository(t *testing.T) {
+	load := func(t *testing.T, content string) (MerchantsRepository, error) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "merchants.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return LoadMerchantsRepository(path)
+	}
+
+	t.Run("digest", func(t *testing.T) {
+		repo, err := load(t, `[{"id":"merchant-1","api_key_hash":"298754db2dbab6ec62605ceb0379eb7ee376580359449efe0caa3aa06cd56736"}]`)
+		require.NoError(t, err)
+
+		merchant, err := repo.GetMerchant(context.Background(), "merchant-1")
+		require.NoError(t, err)
+		assert.Equal(t, "merchant-1", merchant.Id)
+	})
+
+	t.Run("bcrypt hash", func(t *testing.T) {
+		_, err := load(t, `[{"id":"merchant-1","api_key_hash":"$2a$10$rIKbzIGRo/nOWYwqo2UOMOh/oEcj6RaeErDIIgWbPdQEg3ygNxS.6"}]`)
+		assert.ErrorContains(t, err, "SHA-256")
+	})
+
+	t.Run("missing hash", func(t *testing.T) {
+		_, err := load(t, `[{"id":"merchant-1"}]`)
+		assert.ErrorContains(t, err, "api_key_hash")
+	})
+}
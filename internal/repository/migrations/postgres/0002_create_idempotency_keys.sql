@@ -0,0 +1,11 @@
+CREATE TABLE idempotency_keys (
+    idempotency_key TEXT    PRIMARY KEY,
+    request_hash    TEXT    NOT NULL,
+    completed       BOOLEAN NOT NULL DEFAULT FALSE,
+    status_code     INTEGER NOT NULL DEFAULT 0,
+    content_type    TEXT    NOT NULL DEFAULT '',
+    body            BYTEA,
+    expires_at      BIGINT  NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
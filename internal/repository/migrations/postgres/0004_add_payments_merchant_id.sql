@@ -0,0 +1,3 @@
+ALTER TABLE payments ADD COLUMN merchant_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_payments_merchant_id ON payments (merchant_id);
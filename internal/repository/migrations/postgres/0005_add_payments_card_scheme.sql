@@ -0,0 +1 @@
+ALTER TABLE payments ADD COLUMN card_scheme TEXT NOT NULL DEFAULT '';
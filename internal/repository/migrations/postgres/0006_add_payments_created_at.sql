@@ -0,0 +1,3 @@
+ALTER TABLE payments ADD COLUMN created_at BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_payments_status_created_at ON payments (status, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE payments ADD COLUMN reference TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_payments_merchant_created_at ON payments (merchant_id, created_at, id);
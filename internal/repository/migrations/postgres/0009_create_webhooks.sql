@@ -0,0 +1,29 @@
+CREATE TABLE webhook_endpoints (
+    id          TEXT   PRIMARY KEY,
+    merchant_id TEXT   NOT NULL,
+    url         TEXT   NOT NULL,
+    secret      TEXT   NOT NULL,
+    event_types TEXT   NOT NULL,
+    created_at  BIGINT NOT NULL
+);
+
+CREATE INDEX idx_webhook_endpoints_merchant_id ON webhook_endpoints (merchant_id);
+
+CREATE TABLE webhook_deliveries (
+    id               TEXT    PRIMARY KEY,
+    endpoint_id      TEXT    NOT NULL,
+    merchant_id      TEXT    NOT NULL,
+    event_id         TEXT    NOT NULL,
+    event_type       TEXT    NOT NULL,
+    payload          BYTEA   NOT NULL,
+    status           TEXT    NOT NULL,
+    attempts         INTEGER NOT NULL,
+    next_attempt_at  BIGINT  NOT NULL,
+    last_status_code INTEGER NOT NULL,
+    last_error       TEXT    NOT NULL,
+    created_at       BIGINT  NOT NULL,
+    updated_at       BIGINT  NOT NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_merchant ON webhook_deliveries (merchant_id, created_at);
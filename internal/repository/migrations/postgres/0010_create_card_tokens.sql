@@ -0,0 +1,12 @@
+CREATE TABLE card_tokens (
+    id                    TEXT    PRIMARY KEY,
+    merchant_id           TEXT    NOT NULL,
+    card_number_last_four TEXT    NOT NULL,
+    card_scheme           TEXT    NOT NULL,
+    expiry_month          INTEGER NOT NULL,
+    expiry_year           INTEGER NOT NULL,
+    key_id                TEXT    NOT NULL,
+    data_key              BYTEA   NOT NULL,
+    encrypted_card_number BYTEA   NOT NULL,
+    created_at            BIGINT  NOT NULL
+);
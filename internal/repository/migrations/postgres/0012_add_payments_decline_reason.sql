@@ -0,0 +1 @@
+ALTER TABLE payments ADD COLUMN decline_reason TEXT NOT NULL DEFAULT '';
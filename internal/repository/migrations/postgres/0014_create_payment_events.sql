@@ -0,0 +1,8 @@
+CREATE TABLE payment_events (
+    id          TEXT   PRIMARY KEY,
+    merchant_id TEXT   NOT NULL,
+    payload     TEXT   NOT NULL,
+    created_at  BIGINT NOT NULL
+);
+
+CREATE INDEX idx_payment_events_created_at ON payment_events (created_at);
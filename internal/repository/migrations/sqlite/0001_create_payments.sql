@@ -0,0 +1,10 @@
+CREATE TABLE payments (
+    id                    TEXT    PRIMARY KEY,
+    status                TEXT    NOT NULL,
+    card_number_last_four TEXT    NOT NULL,
+    expiry_month          INTEGER NOT NULL,
+    expiry_year           INTEGER NOT NULL,
+    currency              TEXT    NOT NULL,
+    amount                INTEGER NOT NULL,
+    authorization_code    TEXT    NOT NULL DEFAULT ''
+);
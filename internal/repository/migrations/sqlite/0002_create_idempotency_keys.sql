@@ -0,0 +1,11 @@
+CREATE TABLE idempotency_keys (
+    idempotency_key TEXT    PRIMARY KEY,
+    request_hash    TEXT    NOT NULL,
+    completed       INTEGER NOT NULL DEFAULT 0,
+    status_code     INTEGER NOT NULL DEFAULT 0,
+    content_type    TEXT    NOT NULL DEFAULT '',
+    body            BLOB,
+    expires_at      INTEGER NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
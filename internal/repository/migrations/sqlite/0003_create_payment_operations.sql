@@ -0,0 +1,13 @@
+ALTER TABLE payments ADD COLUMN captured_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN refunded_amount INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE payment_operations (
+    id         TEXT    PRIMARY KEY,
+    payment_id TEXT    NOT NULL REFERENCES payments (id),
+    type       TEXT    NOT NULL,
+    amount     INTEGER NOT NULL,
+    created_at BIGINT  NOT NULL
+);
+
+CREATE INDEX idx_payment_operations_payment_id ON payment_operations (payment_id, created_at);
@@ -0,0 +1 @@
+ALTER TABLE payments ADD COLUMN acquirer TEXT NOT NULL DEFAULT '';
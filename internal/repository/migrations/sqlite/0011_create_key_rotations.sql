@@ -0,0 +1,9 @@
+CREATE TABLE key_rotations (
+    name        TEXT    PRIMARY KEY,
+    key_id      TEXT    NOT NULL,
+    last_id     TEXT    NOT NULL,
+    reencrypted INTEGER NOT NULL,
+    completed   INTEGER NOT NULL DEFAULT 0,
+    started_at  BIGINT  NOT NULL,
+    updated_at  BIGINT  NOT NULL
+);
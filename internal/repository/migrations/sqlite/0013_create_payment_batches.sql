@@ -0,0 +1,27 @@
+CREATE TABLE payment_batches (
+    id           TEXT    PRIMARY KEY,
+    merchant_id  TEXT    NOT NULL,
+    status       TEXT    NOT NULL,
+    total        INTEGER NOT NULL,
+    processed    INTEGER NOT NULL,
+    authorized   INTEGER NOT NULL,
+    declined     INTEGER NOT NULL,
+    pending      INTEGER NOT NULL,
+    rejected     INTEGER NOT NULL,
+    failed       INTEGER NOT NULL,
+    created_at   BIGINT  NOT NULL,
+    updated_at   BIGINT  NOT NULL,
+    completed_at BIGINT  NOT NULL
+);
+
+CREATE TABLE payment_batch_results (
+    batch_id         TEXT    NOT NULL,
+    line             INTEGER NOT NULL,
+    client_reference TEXT    NOT NULL,
+    status           TEXT    NOT NULL,
+    payment_id       TEXT    NOT NULL,
+    decline_reason   TEXT    NOT NULL,
+    errors           TEXT    NOT NULL,
+    error            TEXT    NOT NULL,
+    PRIMARY KEY (batch_id, line)
+);
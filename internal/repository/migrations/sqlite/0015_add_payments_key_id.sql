@@ -0,0 +1,3 @@
+ALTER TABLE payments ADD COLUMN key_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE payments ADD COLUMN data_key BLOB NOT NULL DEFAULT x'';
+ALTER TABLE payments ADD COLUMN encrypted_authorization_code BLOB NOT NULL DEFAULT x'';
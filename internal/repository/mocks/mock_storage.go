@@ -7,6 +7,7 @@ package mock_repository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	models "github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
 	gomock "github.com/golang/mock/gomock"
@@ -50,11 +51,12 @@ func (mr *MockPaymentsRepositoryMockRecorder) AddPayment(ctx, payment interface{
 }
 
 // GetPayment mocks base method.
-func (m *MockPaymentsRepository) GetPayment(ctx context.Context, id string) *models.Payment {
+func (m *MockPaymentsRepository) GetPayment(ctx context.Context, id string) (*models.Payment, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetPayment", ctx, id)
 	ret0, _ := ret[0].(*models.Payment)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetPayment indicates an expected call of GetPayment.
@@ -62,3 +64,81 @@ func (mr *MockPaymentsRepositoryMockRecorder) GetPayment(ctx, id interface{}) *g
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayment", reflect.TypeOf((*MockPaymentsRepository)(nil).GetPayment), ctx, id)
 }
+
+// ListPayments mocks base method.
+func (m *MockPaymentsRepository) ListPayments(ctx context.Context, query models.PaymentQuery) ([]models.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPayments", ctx, query)
+	ret0, _ := ret[0].([]models.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPayments indicates an expected call of ListPayments.
+func (mr *MockPaymentsRepositoryMockRecorder) ListPayments(ctx, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPayments", reflect.TypeOf((*MockPaymentsRepository)(nil).ListPayments), ctx, query)
+}
+
+// MarkEventPublished mocks base method.
+func (m *MockPaymentsRepository) MarkEventPublished(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkEventPublished", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkEventPublished indicates an expected call of MarkEventPublished.
+func (mr *MockPaymentsRepositoryMockRecorder) MarkEventPublished(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkEventPublished", reflect.TypeOf((*MockPaymentsRepository)(nil).MarkEventPublished), ctx, id)
+}
+
+// PendingPayments mocks base method.
+func (m *MockPaymentsRepository) PendingPayments(ctx context.Context, createdBefore time.Time) ([]models.Payment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PendingPayments", ctx, createdBefore)
+	ret0, _ := ret[0].([]models.Payment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PendingPayments indicates an expected call of PendingPayments.
+func (mr *MockPaymentsRepositoryMockRecorder) PendingPayments(ctx, createdBefore interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PendingPayments", reflect.TypeOf((*MockPaymentsRepository)(nil).PendingPayments), ctx, createdBefore)
+}
+
+// UnpublishedEvents mocks base method.
+func (m *MockPaymentsRepository) UnpublishedEvents(ctx context.Context, createdBefore time.Time, limit int) ([]models.PaymentEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnpublishedEvents", ctx, createdBefore, limit)
+	ret0, _ := ret[0].([]models.PaymentEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UnpublishedEvents indicates an expected call of UnpublishedEvents.
+func (mr *MockPaymentsRepositoryMockRecorder) UnpublishedEvents(ctx, createdBefore, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnpublishedEvents", reflect.TypeOf((*MockPaymentsRepository)(nil).UnpublishedEvents), ctx, createdBefore, limit)
+}
+
+// UpdatePayment mocks base method.
+func (m *MockPaymentsRepository) UpdatePayment(ctx context.Context, payment models.Payment, event *models.PaymentEvent, ops ...models.Operation) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, payment, event}
+	for _, a := range ops {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "UpdatePayment", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePayment indicates an expected call of UpdatePayment.
+func (mr *MockPaymentsRepositoryMockRecorder) UpdatePayment(ctx, payment, event interface{}, ops ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, payment, event}, ops...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePayment", reflect.TypeOf((*MockPaymentsRepository)(nil).UpdatePayment), varargs...)
+}
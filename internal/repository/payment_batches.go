@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// PaymentBatchesRepository keeps the progress and the line results of
+// payment batches. The payment requests of the lines are never stored.
+type PaymentBatchesRepository interface {
+	AddBatch(ctx context.Context, batch models.PaymentBatch) error
+	// GetBatch returns models.ErrPaymentBatchNotFound if no batch has the given ID.
+	GetBatch(ctx context.Context, id string) (*models.PaymentBatch, error)
+	// UpdateBatch returns models.ErrPaymentBatchNotFound if no batch has batch.Id.
+	UpdateBatch(ctx context.Context, batch models.PaymentBatch) error
+	AddBatchResults(ctx context.Context, batchID string, results []models.PaymentBatchResult) error
+	// ListBatchResults returns the results of the batch in line order.
+	ListBatchResults(ctx context.Context, batchID string) ([]models.PaymentBatchResult, error)
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type inMemPaymentBatchesStore struct {
+	mu      sync.Mutex
+	batches map[string]models.PaymentBatch
+	results map[string][]models.PaymentBatchResult
+}
+
+func NewPaymentBatchesRepository() PaymentBatchesRepository {
+	return &inMemPaymentBatchesStore{
+		batches: make(map[string]models.PaymentBatch),
+		results: make(map[string][]models.PaymentBatchResult),
+	}
+}
+
+func (s *inMemPaymentBatchesStore) AddBatch(ctx context.Context, batch models.PaymentBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batches[batch.Id] = batch
+	return nil
+}
+
+func (s *inMemPaymentBatchesStore) GetBatch(ctx context.Context, id string) (*models.PaymentBatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch, exists := s.batches[id]
+	if !exists {
+		return nil, models.ErrPaymentBatchNotFound
+	}
+	return &batch, nil
+}
+
+func (s *inMemPaymentBatchesStore) UpdateBatch(ctx context.Context, batch models.PaymentBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.batches[batch.Id]; !exists {
+		return models.ErrPaymentBatchNotFound
+	}
+	s.batches[batch.Id] = batch
+	return nil
+}
+
+func (s *inMemPaymentBatchesStore) AddBatchResults(ctx context.Context, batchID string, results []models.PaymentBatchResult) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, result := range results {
+		result.Errors = append([]models.ValidationError(nil), result.Errors...)
+		s.results[batchID] = append(s.results[batchID], result)
+	}
+	return nil
+}
+
+func (s *inMemPaymentBatchesStore) ListBatchResults(ctx context.Context, batchID string) ([]models.PaymentBatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]models.PaymentBatchResult, len(s.results[batchID]))
+	for i, result := range s.results[batchID] {
+		result.Errors = append([]models.ValidationError(nil), result.Errors...)
+		results[i] = result
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Line < results[j].Line })
+	return results, nil
+}
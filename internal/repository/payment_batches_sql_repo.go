@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// batchColumns are the payment_batches columns read by scanBatch, in order.
+const batchColumns = `id, merchant_id, status, total, processed, authorized, declined, pending, rejected, failed,
+	created_at, updated_at, completed_at`
+
+type sqlPaymentBatchesStore struct {
+	*Database
+}
+
+func NewSQLPaymentBatchesRepository(db *Database) PaymentBatchesRepository {
+	return &sqlPaymentBatchesStore{Database: db}
+}
+
+func (s *sqlPaymentBatchesStore) AddBatch(ctx context.Context, b models.PaymentBatch) error {
+	if _, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO payment_batches (`+batchColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		b.Id,
+		b.MerchantId,
+		b.Status,
+		b.Total,
+		b.Processed,
+		b.Authorized,
+		b.Declined,
+		b.Pending,
+		b.Rejected,
+		b.Failed,
+		b.CreatedAt.UnixMicro(),
+		b.UpdatedAt.UnixMicro(),
+		unixMicro(b.CompletedAt),
+	); err != nil {
+		return fmt.Errorf("failed to insert payment batch: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlPaymentBatchesStore) GetBatch(ctx context.Context, id string) (*models.PaymentBatch, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`
+		SELECT `+batchColumns+`
+		FROM payment_batches
+		WHERE id = ?`), id)
+
+	b, err := scanBatch(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrPaymentBatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payment batch: %w", err)
+	}
+	return b, nil
+}
+
+func (s *sqlPaymentBatchesStore) UpdateBatch(ctx context.Context, b models.PaymentBatch) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(`
+		UPDATE payment_batches
+		SET status = ?, processed = ?, authorized = ?, declined = ?, pending = ?, rejected = ?, failed = ?,
+			updated_at = ?, completed_at = ?
+		WHERE id = ?`),
+		b.Status,
+		b.Processed,
+		b.Authorized,
+		b.Declined,
+		b.Pending,
+		b.Rejected,
+		b.Failed,
+		b.UpdatedAt.UnixMicro(),
+		unixMicro(b.CompletedAt),
+		b.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update payment batch: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ErrPaymentBatchNotFound
+	}
+	return nil
+}
+
+func (s *sqlPaymentBatchesStore) AddBatchResults(ctx context.Context, batchID string, results []models.PaymentBatchResult) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, r := range results {
+		validationErrors, err := json.Marshal(r.Errors)
+		if err != nil {
+			return fmt.Errorf("failed to marshal validation errors: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(`
+			INSERT INTO payment_batch_results (batch_id, line, client_reference, status, payment_id, decline_reason, errors, error)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+			batchID,
+			r.Line,
+			r.ClientReference,
+			r.Status,
+			r.PaymentId,
+			r.DeclineReason,
+			string(validationErrors),
+			r.Error,
+		); err != nil {
+			return fmt.Errorf("failed to insert payment batch result: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit payment batch results: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlPaymentBatchesStore) ListBatchResults(ctx context.Context, batchID string) ([]models.PaymentBatchResult, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT line, client_reference, status, payment_id, decline_reason, errors, error
+		FROM payment_batch_results
+		WHERE batch_id = ?
+		ORDER BY line`), batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payment batch results: %w", err)
+	}
+	defer rows.Close()
+
+	results := []models.PaymentBatchResult{}
+	for rows.Next() {
+		var (
+			r                models.PaymentBatchResult
+			validationErrors string
+		)
+		if err := rows.Scan(&r.Line, &r.ClientReference, &r.Status, &r.PaymentId, &r.DeclineReason, &validationErrors, &r.Error); err != nil {
+			return nil, fmt.Errorf("failed to list payment batch results: %w", err)
+		}
+		if err := json.Unmarshal([]byte(validationErrors), &r.Errors); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal validation errors: %w", err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list payment batch results: %w", err)
+	}
+	return results, nil
+}
+
+// scanBatch reads a row of batchColumns.
+func scanBatch(row interface{ Scan(...any) error }) (*models.PaymentBatch, error) {
+	var (
+		b                                 models.PaymentBatch
+		createdAt, updatedAt, completedAt int64
+	)
+	err := row.Scan(
+		&b.Id,
+		&b.MerchantId,
+		&b.Status,
+		&b.Total,
+		&b.Processed,
+		&b.Authorized,
+		&b.Declined,
+		&b.Pending,
+		&b.Rejected,
+		&b.Failed,
+		&createdAt,
+		&updatedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	b.CreatedAt = time.UnixMicro(createdAt).UTC()
+	b.UpdatedAt = time.UnixMicro(updatedAt).UTC()
+	if completedAt != 0 {
+		b.CompletedAt = time.UnixMicro(completedAt).UTC()
+	}
+	return &b, nil
+}
+
+// unixMicro is t in microseconds, or 0 for the zero time.
+func unixMicro(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMicro()
+}
@@ -2,11 +2,41 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
 )
 
 type PaymentsRepository interface {
-	GetPayment(ctx context.Context, id string) *models.Payment
+	// GetPayment returns models.ErrPaymentNotFound if no payment has the given ID.
+	GetPayment(ctx context.Context, id string) (*models.Payment, error)
 	AddPayment(ctx context.Context, payment models.Payment) error
+	// UpdatePayment stores the new state of payment together with the operations
+	// that produced it, if any. It fails with models.ErrPaymentVersionConflict if
+	// the stored payment no longer has payment.Version, and bumps the version otherwise.
+	// A non-nil event is queued in the event outbox as part of the same update.
+	UpdatePayment(ctx context.Context, payment models.Payment, event *models.PaymentEvent, ops ...models.Operation) error
+	// UnpublishedEvents returns up to limit events of the outbox queued before
+	// the given time, oldest first.
+	UnpublishedEvents(ctx context.Context, createdBefore time.Time, limit int) ([]models.PaymentEvent, error)
+	// MarkEventPublished removes an event from the outbox. Removing an event
+	// that is not there is not an error.
+	MarkEventPublished(ctx context.Context, id string) error
+	// PendingPayments returns the payments still pending that were created
+	// before the given time, oldest first, without their operations.
+	PendingPayments(ctx context.Context, createdBefore time.Time) ([]models.Payment, error)
+	// ListPayments returns up to query.Limit payments matching the query,
+	// newest first with ties broken by descending ID, without their operations.
+	ListPayments(ctx context.Context, query models.PaymentQuery) ([]models.Payment, error)
+}
+
+// AuthorizationCodesRepository moves the sealed authorization codes of the
+// SQL payments store to another key.
+type AuthorizationCodesRepository interface {
+	// ListAuthorizationCodes returns the codes of up to limit payments with an
+	// ID greater than after, in ID order. Payments without a code are skipped.
+	ListAuthorizationCodes(ctx context.Context, after string, limit int) ([]models.SealedAuthorizationCode, error)
+	// UpdateAuthorizationCode stores a code sealed again under another key. It
+	// returns models.ErrPaymentNotFound if no payment has code.PaymentId.
+	UpdateAuthorizationCode(ctx context.Context, code models.SealedAuthorizationCode) error
 }
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
 )
@@ -10,6 +13,7 @@ import (
 type inMemStore struct {
 	mu       sync.RWMutex
 	payments map[string]models.Payment
+	outbox   []models.PaymentEvent
 }
 
 func NewPaymentsRepository() PaymentsRepository {
@@ -18,21 +22,118 @@ func NewPaymentsRepository() PaymentsRepository {
 	}
 }
 
-func (ps *inMemStore) GetPayment(ctx context.Context, id string) *models.Payment {
+func (ps *inMemStore) GetPayment(ctx context.Context, id string) (*models.Payment, error) {
 	ps.mu.RLock()
 	defer ps.mu.RUnlock()
 
 	if payment, exists := ps.payments[id]; exists {
-		return &payment
+		payment.Operations = append([]models.Operation(nil), payment.Operations...)
+		return &payment, nil
 	}
-	return nil
+	return nil, models.ErrPaymentNotFound
 }
 
 func (ps *inMemStore) AddPayment(ctx context.Context, payment models.Payment) error {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
+	if _, exists := ps.payments[payment.Id]; exists {
+		return models.ErrPaymentAlreadyExists
+	}
+	payment.Operations = append([]models.Operation(nil), payment.Operations...)
+	ps.payments[payment.Id] = payment
+
+	return nil
+}
+
+func (ps *inMemStore) UpdatePayment(ctx context.Context, payment models.Payment, event *models.PaymentEvent, ops ...models.Operation) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	stored, exists := ps.payments[payment.Id]
+	if !exists {
+		return models.ErrPaymentNotFound
+	}
+	if stored.Version != payment.Version {
+		return models.ErrPaymentVersionConflict
+	}
+
+	payment.Version++
+	payment.Operations = append(append([]models.Operation(nil), stored.Operations...), ops...)
 	ps.payments[payment.Id] = payment
+	if event != nil {
+		ps.outbox = append(ps.outbox, *event)
+	}
 
 	return nil
 }
+
+func (ps *inMemStore) UnpublishedEvents(ctx context.Context, createdBefore time.Time, limit int) ([]models.PaymentEvent, error) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	var events []models.PaymentEvent
+	for _, event := range ps.outbox {
+		if event.CreatedAt.Before(createdBefore) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+func (ps *inMemStore) MarkEventPublished(ctx context.Context, id string) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.outbox = slices.DeleteFunc(ps.outbox, func(event models.PaymentEvent) bool {
+		return event.Id == id
+	})
+	return nil
+}
+
+func (ps *inMemStore) PendingPayments(ctx context.Context, createdBefore time.Time) ([]models.Payment, error) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	var pending []models.Payment
+	for _, payment := range ps.payments {
+		if payment.Status == models.StatusPending && payment.CreatedAt.Before(createdBefore) {
+			payment.Operations = nil
+			pending = append(pending, payment)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].CreatedAt.Before(pending[j].CreatedAt)
+	})
+
+	return pending, nil
+}
+
+func (ps *inMemStore) ListPayments(ctx context.Context, query models.PaymentQuery) ([]models.Payment, error) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	var matches []models.Payment
+	for _, payment := range ps.payments {
+		if !query.Matches(payment) || (query.After != nil && !query.After.Before(payment)) {
+			continue
+		}
+		payment.Operations = nil
+		matches = append(matches, payment)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return models.PaymentCursor{CreatedAt: matches[i].CreatedAt, Id: matches[i].Id}.Before(matches[j])
+	})
+
+	if query.Limit > 0 && len(matches) > query.Limit {
+		matches = matches[:query.Limit]
+	}
+	return matches, nil
+}
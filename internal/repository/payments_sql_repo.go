@@ -0,0 +1,474 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/vault"
+)
+
+// paymentColumns are the payments columns read by scanPayment, in order.
+const paymentColumns = `id, merchant_id, status, card_number_last_four, card_scheme, expiry_month, expiry_year, currency, amount,
+	authorization_code, key_id, data_key, encrypted_authorization_code, acquirer, decline_reason, captured_amount, refunded_amount,
+	reference, created_at, version`
+
+type sqlPaymentsStore struct {
+	*Database
+	// keyring seals the authorization codes, which are stored in the clear
+	// when it is nil
+	keyring *vault.Keyring
+}
+
+// NewSQLPaymentsRepository returns the payments store of db. Authorization
+// codes are sealed with keyring, bound to the ID of their payment, unless
+// keyring is nil.
+func NewSQLPaymentsRepository(db *Database, keyring *vault.Keyring) PaymentsRepository {
+	return &sqlPaymentsStore{Database: db, keyring: keyring}
+}
+
+// NewSQLAuthorizationCodesRepository returns the sealed authorization codes
+// of the payments store of db.
+func NewSQLAuthorizationCodesRepository(db *Database) AuthorizationCodesRepository {
+	return &sqlPaymentsStore{Database: db}
+}
+
+func (s *sqlPaymentsStore) GetPayment(ctx context.Context, id string) (*models.Payment, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE id = ?`), id)
+
+	payment, err := s.scanPayment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payment: %w", err)
+	}
+
+	payment.Operations, err = s.operations(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (s *sqlPaymentsStore) PendingPayments(ctx context.Context, createdBefore time.Time) ([]models.Payment, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT `+paymentColumns+`
+		FROM payments
+		WHERE status = ? AND created_at < ?
+		ORDER BY created_at, id`), models.StatusPending, createdBefore.UnixMicro())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending payments: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []models.Payment
+	for rows.Next() {
+		payment, err := s.scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pending payments: %w", err)
+		}
+		pending = append(pending, *payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pending payments: %w", err)
+	}
+
+	return pending, nil
+}
+
+func (s *sqlPaymentsStore) ListPayments(ctx context.Context, query models.PaymentQuery) ([]models.Payment, error) {
+	var (
+		where []string
+		args  []any
+	)
+	add := func(cond string, condArgs ...any) {
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	f := query.PaymentFilter
+	if f.MerchantId != "" {
+		add("merchant_id = ?", f.MerchantId)
+	}
+	if f.Status != "" {
+		add("status = ?", f.Status)
+	}
+	if f.Currency != "" {
+		add("currency = ?", f.Currency)
+	}
+	if f.MinAmount > 0 {
+		add("amount >= ?", f.MinAmount)
+	}
+	if f.MaxAmount > 0 {
+		add("amount <= ?", f.MaxAmount)
+	}
+	if f.CardNumberLastFour != "" {
+		add("card_number_last_four = ?", f.CardNumberLastFour)
+	}
+	if !f.CreatedFrom.IsZero() {
+		add("created_at >= ?", f.CreatedFrom.UnixMicro())
+	}
+	if !f.CreatedTo.IsZero() {
+		add("created_at < ?", f.CreatedTo.UnixMicro())
+	}
+	if f.Reference != "" {
+		add("reference = ?", f.Reference)
+	}
+	if query.After != nil {
+		after := query.After.CreatedAt.UnixMicro()
+		add("(created_at < ? OR (created_at = ? AND id < ?))", after, after, query.After.Id)
+	}
+
+	q := `SELECT ` + paymentColumns + ` FROM payments`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	q += ` ORDER BY created_at DESC, id DESC`
+	if query.Limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(q), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		payment, err := s.scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list payments: %w", err)
+		}
+		payments = append(payments, *payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// scanPayment reads a row of paymentColumns and opens its authorization code.
+func (s *sqlPaymentsStore) scanPayment(row interface{ Scan(...any) error }) (*models.Payment, error) {
+	var (
+		payment   models.Payment
+		sealed    models.SealedAuthorizationCode
+		createdAt int64
+	)
+	err := row.Scan(
+		&payment.Id,
+		&payment.MerchantId,
+		&payment.Status,
+		&payment.CardNumberLastFour,
+		&payment.CardScheme,
+		&payment.ExpiryMonth,
+		&payment.ExpiryYear,
+		&payment.Currency,
+		&payment.Amount,
+		&payment.AuthorizationCode,
+		&sealed.KeyID,
+		&sealed.DataKey,
+		&sealed.Ciphertext,
+		&payment.Acquirer,
+		&payment.DeclineReason,
+		&payment.CapturedAmount,
+		&payment.RefundedAmount,
+		&payment.Reference,
+		&createdAt,
+		&payment.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	payment.CreatedAt = time.UnixMicro(createdAt).UTC()
+
+	if sealed.KeyID != "" {
+		if s.keyring == nil {
+			return nil, fmt.Errorf("authorization code of payment %s is sealed and no vault key is configured", payment.Id)
+		}
+		code, err := s.keyring.Open(vault.Envelope{
+			KeyID:      sealed.KeyID,
+			DataKey:    sealed.DataKey,
+			Ciphertext: sealed.Ciphertext,
+		}, []byte(payment.Id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt authorization code of payment %s: %w", payment.Id, err)
+		}
+		payment.AuthorizationCode = string(code)
+	}
+
+	return &payment, nil
+}
+
+// sealAuthorizationCode returns the authorization code of payment as it is
+// stored: in the clear without a keyring, sealed otherwise.
+func (s *sqlPaymentsStore) sealAuthorizationCode(payment models.Payment) (models.SealedAuthorizationCode, string, error) {
+	sealed := models.SealedAuthorizationCode{PaymentId: payment.Id, DataKey: []byte{}, Ciphertext: []byte{}}
+	if s.keyring == nil || payment.AuthorizationCode == "" {
+		return sealed, payment.AuthorizationCode, nil
+	}
+
+	env, err := s.keyring.Seal([]byte(payment.AuthorizationCode), []byte(payment.Id))
+	if err != nil {
+		return sealed, "", fmt.Errorf("failed to encrypt authorization code: %w", err)
+	}
+	sealed.KeyID, sealed.DataKey, sealed.Ciphertext = env.KeyID, env.DataKey, env.Ciphertext
+	return sealed, "", nil
+}
+
+func (s *sqlPaymentsStore) AddPayment(ctx context.Context, payment models.Payment) error {
+	sealed, code, err := s.sealAuthorizationCode(payment)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO payments (`+paymentColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING`),
+		payment.Id,
+		payment.MerchantId,
+		payment.Status,
+		payment.CardNumberLastFour,
+		payment.CardScheme,
+		payment.ExpiryMonth,
+		payment.ExpiryYear,
+		payment.Currency,
+		payment.Amount,
+		code,
+		sealed.KeyID,
+		sealed.DataKey,
+		sealed.Ciphertext,
+		payment.Acquirer,
+		payment.DeclineReason,
+		payment.CapturedAmount,
+		payment.RefundedAmount,
+		payment.Reference,
+		payment.CreatedAt.UnixMicro(),
+		payment.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert payment: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ErrPaymentAlreadyExists
+	}
+
+	return nil
+}
+
+func (s *sqlPaymentsStore) UpdatePayment(ctx context.Context, payment models.Payment, event *models.PaymentEvent, ops ...models.Operation) error {
+	sealed, code, err := s.sealAuthorizationCode(payment)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, s.rebind(`
+		UPDATE payments
+		SET status = ?, authorization_code = ?, key_id = ?, data_key = ?, encrypted_authorization_code = ?, acquirer = ?,
+			decline_reason = ?, captured_amount = ?, refunded_amount = ?, version = version + 1
+		WHERE id = ? AND version = ?`),
+		payment.Status,
+		code,
+		sealed.KeyID,
+		sealed.DataKey,
+		sealed.Ciphertext,
+		payment.Acquirer,
+		payment.DeclineReason,
+		payment.CapturedAmount,
+		payment.RefundedAmount,
+		payment.Id,
+		payment.Version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		var exists int
+		err := tx.QueryRowContext(ctx, s.rebind(`SELECT 1 FROM payments WHERE id = ?`), payment.Id).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrPaymentNotFound
+		}
+		return models.ErrPaymentVersionConflict
+	}
+
+	for _, op := range ops {
+		if _, err := tx.ExecContext(ctx, s.rebind(`
+			INSERT INTO payment_operations (id, payment_id, type, amount, created_at)
+			VALUES (?, ?, ?, ?, ?)`),
+			op.Id,
+			payment.Id,
+			op.Type,
+			op.Amount,
+			op.CreatedAt.UnixMicro(),
+		); err != nil {
+			return fmt.Errorf("failed to insert payment operation: %w", err)
+		}
+	}
+
+	if event != nil {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payment event: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(`
+			INSERT INTO payment_events (id, merchant_id, payload, created_at)
+			VALUES (?, ?, ?, ?)`),
+			event.Id,
+			event.MerchantId,
+			string(payload),
+			event.CreatedAt.UnixMicro(),
+		); err != nil {
+			return fmt.Errorf("failed to insert payment event: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit payment update: %w", err)
+	}
+
+	return nil
+}
+
+func (s *sqlPaymentsStore) UnpublishedEvents(ctx context.Context, createdBefore time.Time, limit int) ([]models.PaymentEvent, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT merchant_id, payload
+		FROM payment_events
+		WHERE created_at < ?
+		ORDER BY created_at, id
+		LIMIT ?`), createdBefore.UnixMicro(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payment events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.PaymentEvent
+	for rows.Next() {
+		var (
+			event   models.PaymentEvent
+			payload string
+		)
+		if err := rows.Scan(&event.MerchantId, &payload); err != nil {
+			return nil, fmt.Errorf("failed to read payment events: %w", err)
+		}
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return nil, fmt.Errorf("failed to decode payment event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read payment events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (s *sqlPaymentsStore) MarkEventPublished(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM payment_events WHERE id = ?`), id); err != nil {
+		return fmt.Errorf("failed to delete payment event: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlPaymentsStore) ListAuthorizationCodes(ctx context.Context, after string, limit int) ([]models.SealedAuthorizationCode, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT id, authorization_code, key_id, data_key, encrypted_authorization_code
+		FROM payments
+		WHERE id > ? AND (authorization_code <> '' OR key_id <> '')
+		ORDER BY id
+		LIMIT ?`), after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list authorization codes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []models.SealedAuthorizationCode
+	for rows.Next() {
+		var (
+			code      models.SealedAuthorizationCode
+			plaintext string
+		)
+		if err := rows.Scan(&code.PaymentId, &plaintext, &code.KeyID, &code.DataKey, &code.Ciphertext); err != nil {
+			return nil, fmt.Errorf("failed to list authorization codes: %w", err)
+		}
+		if code.KeyID == "" {
+			code.DataKey, code.Ciphertext = nil, []byte(plaintext)
+		}
+		codes = append(codes, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list authorization codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// UpdateAuthorizationCode leaves the version of the payment alone: the code
+// it stores is the same, only sealed under another key.
+func (s *sqlPaymentsStore) UpdateAuthorizationCode(ctx context.Context, code models.SealedAuthorizationCode) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(`
+		UPDATE payments
+		SET authorization_code = '', key_id = ?, data_key = ?, encrypted_authorization_code = ?
+		WHERE id = ?`),
+		code.KeyID,
+		code.DataKey,
+		code.Ciphertext,
+		code.PaymentId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update authorization code: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ErrPaymentNotFound
+	}
+	return nil
+}
+
+func (s *sqlPaymentsStore) operations(ctx context.Context, paymentID string) ([]models.Operation, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT id, payment_id, type, amount, created_at
+		FROM payment_operations
+		WHERE payment_id = ?
+		ORDER BY created_at, id`), paymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payment operations: %w", err)
+	}
+	defer rows.Close()
+
+	var ops []models.Operation
+	for rows.Next() {
+		var (
+			op        models.Operation
+			createdAt int64
+		)
+		if err := rows.Scan(&op.Id, &op.PaymentId, &op.Type, &op.Amount, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to read payment operations: %w", err)
+		}
+		op.CreatedAt = time.UnixMicro(createdAt).UTC()
+		ops = append(ops, op)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read payment operations: %w", err)
+	}
+
+	return ops, nil
+}
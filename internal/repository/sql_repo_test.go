@@ -0,0 +1,592 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	db, err := OpenDatabase(context.Background(), DriverSQLite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestSQLPaymentsRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLPaymentsRepository(openTestDatabase(t), nil)
+
+	payment := models.Payment{
+		Id:                 "payment-id",
+		MerchantId:         "merchant-1",
+		Status:             "Authorized",
+		CardNumberLastFour: "8877",
+		CardScheme:         card.SchemeMastercard,
+		ExpiryMonth:        4,
+		ExpiryYear:         2030,
+		Currency:           "GBP",
+		Amount:             100,
+		AuthorizationCode:  "auth-code",
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		got, err := repo.GetPayment(ctx, "missing")
+		assert.Nil(t, got)
+		assert.ErrorIs(t, err, models.ErrPaymentNotFound)
+	})
+
+	t.Run("add and get", func(t *testing.T) {
+		require.NoError(t, repo.AddPayment(ctx, payment))
+
+		got, err := repo.GetPayment(ctx, payment.Id)
+		require.NoError(t, err)
+		assert.Equal(t, payment, *got)
+	})
+
+	t.Run("duplicate id", func(t *testing.T) {
+		assert.ErrorIs(t, repo.AddPayment(ctx, payment), models.ErrPaymentAlreadyExists)
+	})
+
+	t.Run("update with operation", func(t *testing.T) {
+		stored, err := repo.GetPayment(ctx, payment.Id)
+		require.NoError(t, err)
+
+		op, err := stored.Capture("op-id", 40, time.Now().UTC().Truncate(time.Microsecond))
+		require.NoError(t, err)
+		require.NoError(t, repo.UpdatePayment(ctx, *stored, nil, op))
+
+		got, err := repo.GetPayment(ctx, payment.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusPartiallyCaptured, got.Status)
+		assert.Equal(t, 40, got.CapturedAmount)
+		assert.Equal(t, 1, got.Version)
+		assert.Equal(t, []models.Operation{op}, got.Operations)
+
+		// the stale copy must not overwrite the newer state
+		assert.ErrorIs(t, repo.UpdatePayment(ctx, *stored, nil, op), models.ErrPaymentVersionConflict)
+	})
+
+	t.Run("update unknown payment", func(t *testing.T) {
+		err := repo.UpdatePayment(ctx, models.Payment{Id: "missing"}, nil, models.Operation{Id: "op"})
+		assert.ErrorIs(t, err, models.ErrPaymentNotFound)
+	})
+
+	t.Run("pending payments", func(t *testing.T) {
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		for i, age := range []time.Duration{time.Minute, time.Hour, time.Second} {
+			pending := payment
+			pending.Id = fmt.Sprintf("pending-%d", i)
+			pending.Status = models.StatusPending
+			pending.AuthorizationCode = ""
+			pending.CreatedAt = now.Add(-age)
+			require.NoError(t, repo.AddPayment(ctx, pending))
+		}
+
+		got, err := repo.PendingPayments(ctx, now.Add(-30*time.Second))
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		assert.Equal(t, "pending-1", got[0].Id)
+		assert.Equal(t, "pending-0", got[1].Id)
+		assert.Equal(t, now.Add(-time.Hour), got[0].CreatedAt)
+
+		// settling a payment records no operation
+		settled := got[0]
+		require.NoError(t, settled.Settle("simulator", true, "auth-code", ""))
+		require.NoError(t, repo.UpdatePayment(ctx, settled, nil))
+
+		stored, err := repo.GetPayment(ctx, settled.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusAuthorized, stored.Status)
+		assert.Equal(t, "simulator", stored.Acquirer)
+		assert.Empty(t, stored.Operations)
+
+		declined := got[1]
+		require.NoError(t, declined.Settle("simulator", false, "", models.DeclineSuspectedFraud))
+		require.NoError(t, repo.UpdatePayment(ctx, declined, nil))
+
+		stored, err = repo.GetPayment(ctx, declined.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusDeclined, stored.Status)
+		assert.Equal(t, models.DeclineSuspectedFraud, stored.DeclineReason)
+	})
+}
+
+func TestSQLPaymentsRepositorySealsAuthorizationCodes(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDatabase(t)
+	key := func(b byte) []byte { return bytes.Repeat([]byte{b}, vault.KeySize) }
+	v1, err := vault.NewKeyring("v1", map[string][]byte{"v1": key(1)})
+	require.NoError(t, err)
+
+	// a payment stored before the vault key was configured
+	require.NoError(t, NewSQLPaymentsRepository(db, nil).AddPayment(ctx, models.Payment{Id: "payment-1", AuthorizationCode: "code-1"}))
+
+	repo := NewSQLPaymentsRepository(db, v1)
+	payment := models.Payment{Id: "payment-2", Status: models.StatusPending}
+	require.NoError(t, repo.AddPayment(ctx, payment))
+	require.NoError(t, payment.Settle("simulator", true, "code-2", ""))
+	require.NoError(t, repo.UpdatePayment(ctx, payment, nil))
+	require.NoError(t, repo.AddPayment(ctx, models.Payment{Id: "payment-3", Status: models.StatusPending}))
+
+	var plaintext string
+	require.NoError(t, db.db.QueryRowContext(ctx, `SELECT authorization_code FROM payments WHERE id = 'payment-2'`).Scan(&plaintext))
+	assert.Empty(t, plaintext, "the code is not stored in the clear")
+
+	for id, code := range map[string]string{"payment-1": "code-1", "payment-2": "code-2"} {
+		got, err := repo.GetPayment(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, code, got.AuthorizationCode)
+	}
+
+	_, err = NewSQLPaymentsRepository(db, nil).GetPayment(ctx, "payment-2")
+	assert.Error(t, err, "a sealed code cannot be read without the vault key")
+
+	codes := NewSQLAuthorizationCodesRepository(db)
+	listed, err := codes.ListAuthorizationCodes(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 2, "payments without a code are skipped")
+	assert.Equal(t, models.SealedAuthorizationCode{PaymentId: "payment-1", Ciphertext: []byte("code-1")}, listed[0])
+	assert.Equal(t, "payment-2", listed[1].PaymentId)
+	assert.Equal(t, "v1", listed[1].KeyID)
+
+	// a code sealed for another payment does not open
+	moved := listed[1]
+	moved.PaymentId = "payment-1"
+	require.NoError(t, codes.UpdateAuthorizationCode(ctx, moved))
+	_, err = repo.GetPayment(ctx, "payment-1")
+	assert.ErrorIs(t, err, vault.ErrDecrypt)
+
+	listed, err = codes.ListAuthorizationCodes(ctx, "payment-1", 10)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "payment-2", listed[0].PaymentId)
+
+	moved.PaymentId = "missing"
+	assert.ErrorIs(t, codes.UpdateAuthorizationCode(ctx, moved), models.ErrPaymentNotFound)
+}
+
+func TestListPayments(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	repos := map[string]PaymentsRepository{
+		"memory": NewPaymentsRepository(),
+		"sqlite": NewSQLPaymentsRepository(openTestDatabase(t), nil),
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 6; i++ {
+				require.NoError(t, repo.AddPayment(ctx, models.Payment{
+					Id:                 fmt.Sprintf("payment-%d", i),
+					MerchantId:         []string{"merchant-1", "merchant-2"}[i%2],
+					Status:             []models.PaymentStatus{models.StatusAuthorized, models.StatusDeclined}[i/3],
+					CardNumberLastFour: "8877",
+					Currency:           []string{"GBP", "EUR"}[i/4],
+					Amount:             (i + 1) * 100,
+					Reference:          fmt.Sprintf("order-%d", i),
+					CreatedAt:          start.Add(time.Duration(i/2) * time.Minute),
+				}))
+			}
+
+			ids := func(payments []models.Payment) []string {
+				var result []string
+				for _, p := range payments {
+					result = append(result, p.Id)
+				}
+				return result
+			}
+
+			tests := []struct {
+				name  string
+				query models.PaymentQuery
+				want  []string
+			}{
+				{"everything newest first", models.PaymentQuery{},
+					[]string{"payment-5", "payment-4", "payment-3", "payment-2", "payment-1", "payment-0"}},
+				{"merchant", models.PaymentQuery{PaymentFilter: models.PaymentFilter{MerchantId: "merchant-1"}},
+					[]string{"payment-4", "payment-2", "payment-0"}},
+				{"status", models.PaymentQuery{PaymentFilter: models.PaymentFilter{Status: models.StatusDeclined}},
+					[]string{"payment-5", "payment-4", "payment-3"}},
+				{"currency", models.PaymentQuery{PaymentFilter: models.PaymentFilter{Currency: "EUR"}},
+					[]string{"payment-5", "payment-4"}},
+				{"amount range", models.PaymentQuery{PaymentFilter: models.PaymentFilter{MinAmount: 200, MaxAmount: 400}},
+					[]string{"payment-3", "payment-2", "payment-1"}},
+				{"card last four", models.PaymentQuery{PaymentFilter: models.PaymentFilter{CardNumberLastFour: "1111"}},
+					nil},
+				{"created range", models.PaymentQuery{PaymentFilter: models.PaymentFilter{CreatedFrom: start.Add(time.Minute), CreatedTo: start.Add(2 * time.Minute)}},
+					[]string{"payment-3", "payment-2"}},
+				{"reference", models.PaymentQuery{PaymentFilter: models.PaymentFilter{Reference: "order-1"}},
+					[]string{"payment-1"}},
+				{"limit", models.PaymentQuery{Limit: 2},
+					[]string{"payment-5", "payment-4"}},
+				{"after cursor with tied timestamp", models.PaymentQuery{After: &models.PaymentCursor{CreatedAt: start.Add(time.Minute), Id: "payment-3"}},
+					[]string{"payment-2", "payment-1", "payment-0"}},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					got, err := repo.ListPayments(ctx, tt.query)
+					require.NoError(t, err)
+					assert.Equal(t, tt.want, ids(got))
+				})
+			}
+		})
+	}
+}
+
+func TestPaymentEventOutbox(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	repos := map[string]PaymentsRepository{
+		"memory": NewPaymentsRepository(),
+		"sqlite": NewSQLPaymentsRepository(openTestDatabase(t), nil),
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			payment := models.Payment{Id: "payment-id", MerchantId: "merchant-1", Status: models.StatusPending, Amount: 100}
+			require.NoError(t, repo.AddPayment(ctx, payment))
+
+			require.NoError(t, payment.Settle("simulator", true, "auth-code", ""))
+			authorized := models.PaymentEvent{
+				Id:         "event-1",
+				Type:       models.EventPaymentAuthorized,
+				CreatedAt:  now,
+				Data:       models.PaymentResponse{Id: payment.Id, Status: payment.Status, Amount: 100},
+				MerchantId: "merchant-1",
+			}
+			require.NoError(t, repo.UpdatePayment(ctx, payment, &authorized))
+
+			// a rejected update queues nothing
+			stale := authorized
+			stale.Id = "event-stale"
+			assert.ErrorIs(t, repo.UpdatePayment(ctx, payment, &stale), models.ErrPaymentVersionConflict)
+
+			payment.Version++
+			op, err := payment.Void("op-id", now)
+			require.NoError(t, err)
+			voided := authorized
+			voided.Id = "event-2"
+			voided.Type = models.EventPaymentVoided
+			voided.CreatedAt = now.Add(time.Minute)
+			require.NoError(t, repo.UpdatePayment(ctx, payment, &voided, op))
+
+			events, err := repo.UnpublishedEvents(ctx, now.Add(time.Hour), 10)
+			require.NoError(t, err)
+			assert.Equal(t, []models.PaymentEvent{authorized, voided}, events)
+
+			events, err = repo.UnpublishedEvents(ctx, now.Add(time.Second), 10)
+			require.NoError(t, err)
+			assert.Equal(t, []models.PaymentEvent{authorized}, events)
+
+			require.NoError(t, repo.MarkEventPublished(ctx, authorized.Id))
+			require.NoError(t, repo.MarkEventPublished(ctx, authorized.Id))
+
+			events, err = repo.UnpublishedEvents(ctx, now.Add(time.Hour), 1)
+			require.NoError(t, err)
+			assert.Equal(t, []models.PaymentEvent{voided}, events)
+		})
+	}
+}
+
+func TestSQLIdempotencyRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLIdempotencyRepository(openTestDatabase(t))
+
+	record, created, err := repo.Reserve(ctx, "key", "hash", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.False(t, record.Completed)
+
+	record, created, err = repo.Reserve(ctx, "key", "other-hash", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "hash", record.RequestHash)
+
+	require.NoError(t, repo.Complete(ctx, "key", 200, "application/json", []byte(`{"id":"1"}`)))
+
+	record, created, err = repo.Reserve(ctx, "key", "hash", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.True(t, record.Completed)
+	assert.Equal(t, 200, record.StatusCode)
+	assert.Equal(t, `{"id":"1"}`, string(record.Body))
+
+	require.NoError(t, repo.Release(ctx, "key"))
+	_, created, err = repo.Reserve(ctx, "key", "hash", -time.Second)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	// an expired key can be claimed again
+	_, created, err = repo.Reserve(ctx, "key", "other-hash", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, created)
+}
+
+func TestSQLWebhooksRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLWebhooksRepository(openTestDatabase(t))
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	endpoint := models.WebhookEndpoint{
+		Id:         "endpoint-1",
+		MerchantId: "merchant-1",
+		URL:        "https://merchant.example/webhooks",
+		Secret:     "whsec_test",
+		EventTypes: []models.EventType{models.EventPaymentAuthorized, models.EventPaymentRefunded},
+		CreatedAt:  now,
+	}
+	require.NoError(t, repo.AddEndpoint(ctx, endpoint))
+
+	got, err := repo.GetEndpoint(ctx, endpoint.Id)
+	require.NoError(t, err)
+	assert.Equal(t, endpoint, *got)
+
+	endpoints, err := repo.ListEndpoints(ctx, "merchant-1")
+	require.NoError(t, err)
+	assert.Equal(t, []models.WebhookEndpoint{endpoint}, endpoints)
+
+	delivery := models.WebhookDelivery{
+		Id:            "delivery-1",
+		EndpointId:    endpoint.Id,
+		MerchantId:    "merchant-1",
+		EventId:       "event-1",
+		EventType:     models.EventPaymentAuthorized,
+		Payload:       []byte(`{"id":"event-1"}`),
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	later := delivery
+	later.Id = "delivery-2"
+	later.NextAttemptAt = now.Add(time.Hour)
+	require.NoError(t, repo.AddDeliveries(ctx, []models.WebhookDelivery{delivery, later}))
+
+	stored, err := repo.GetDelivery(ctx, delivery.Id)
+	require.NoError(t, err)
+	assert.Equal(t, delivery, *stored)
+
+	t.Run("claim leases due deliveries", func(t *testing.T) {
+		leaseUntil := now.Add(time.Minute)
+		claimed, err := repo.ClaimDueDeliveries(ctx, now, leaseUntil, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		assert.Equal(t, delivery.Id, claimed[0].Id)
+
+		claimed, err = repo.ClaimDueDeliveries(ctx, now, leaseUntil, 10)
+		require.NoError(t, err)
+		assert.Empty(t, claimed)
+	})
+
+	t.Run("update and list by status", func(t *testing.T) {
+		stored.Status = models.DeliveryDead
+		stored.Attempts = 3
+		stored.LastStatusCode = 500
+		stored.LastError = "endpoint returned status 500"
+		require.NoError(t, repo.UpdateDelivery(ctx, *stored))
+
+		dead, err := repo.ListDeliveries(ctx, "merchant-1", models.DeliveryDead, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []models.WebhookDelivery{*stored}, dead)
+
+		all, err := repo.ListDeliveries(ctx, "merchant-1", "", 10)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		none, err := repo.ListDeliveries(ctx, "merchant-2", "", 10)
+		require.NoError(t, err)
+		assert.Empty(t, none)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := repo.GetDelivery(ctx, "missing")
+		assert.ErrorIs(t, err, models.ErrWebhookDeliveryNotFound)
+		assert.ErrorIs(t, repo.UpdateDelivery(ctx, models.WebhookDelivery{Id: "missing"}), models.ErrWebhookDeliveryNotFound)
+
+		require.NoError(t, repo.DeleteEndpoint(ctx, endpoint.Id))
+		_, err = repo.GetEndpoint(ctx, endpoint.Id)
+		assert.ErrorIs(t, err, models.ErrWebhookEndpointNotFound)
+		assert.ErrorIs(t, repo.DeleteEndpoint(ctx, endpoint.Id), models.ErrWebhookEndpointNotFound)
+	})
+}
+
+func TestCardTokensRepositories(t *testing.T) {
+	repos := map[string]CardTokensRepository{
+		"memory": NewCardTokensRepository(),
+		"sqlite": NewSQLCardTokensRepository(openTestDatabase(t)),
+	}
+
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			token := models.CardToken{
+				Id:                  "tok_1",
+				MerchantId:          "merchant-1",
+				CardNumberLastFour:  "8877",
+				CardScheme:          card.SchemeMastercard,
+				ExpiryMonth:         4,
+				ExpiryYear:          2030,
+				KeyID:               "k1",
+				DataKey:             []byte{1, 2, 3},
+				EncryptedCardNumber: []byte{4, 5, 6},
+				CreatedAt:           time.Now().UTC().Truncate(time.Microsecond),
+			}
+			require.NoError(t, repo.AddToken(ctx, token))
+
+			got, err := repo.GetToken(ctx, token.Id)
+			require.NoError(t, err)
+			assert.Equal(t, token, *got)
+
+			_, err = repo.GetToken(ctx, "tok_missing")
+			assert.ErrorIs(t, err, models.ErrCardTokenNotFound)
+
+			for _, id := range []string{"tok_3", "tok_2"} {
+				other := token
+				other.Id = id
+				require.NoError(t, repo.AddToken(ctx, other))
+			}
+			tokens, err := repo.ListTokens(ctx, "", 2)
+			require.NoError(t, err)
+			require.Len(t, tokens, 2)
+			assert.Equal(t, []string{"tok_1", "tok_2"}, []string{tokens[0].Id, tokens[1].Id})
+			tokens, err = repo.ListTokens(ctx, "tok_2", 2)
+			require.NoError(t, err)
+			require.Len(t, tokens, 1)
+			assert.Equal(t, "tok_3", tokens[0].Id)
+
+			rotated := token
+			rotated.KeyID, rotated.DataKey, rotated.EncryptedCardNumber = "k2", []byte{7}, []byte{8}
+			rotated.MerchantId = "merchant-2"
+			require.NoError(t, repo.UpdateTokenKey(ctx, rotated))
+			got, err = repo.GetToken(ctx, token.Id)
+			require.NoError(t, err)
+			assert.Equal(t, "k2", got.KeyID)
+			assert.Equal(t, []byte{7}, got.DataKey)
+			assert.Equal(t, []byte{8}, got.EncryptedCardNumber)
+			assert.Equal(t, "merchant-1", got.MerchantId, "only the key fields are updated")
+
+			rotated.Id = "tok_missing"
+			assert.ErrorIs(t, repo.UpdateTokenKey(ctx, rotated), models.ErrCardTokenNotFound)
+		})
+	}
+}
+
+func TestKeyRotationsRepositories(t *testing.T) {
+	repos := map[string]KeyRotationsRepository{
+		"memory": NewKeyRotationsRepository(),
+		"sqlite": NewSQLKeyRotationsRepository(openTestDatabase(t)),
+	}
+
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := repo.GetRotation(ctx, "card_tokens")
+			assert.ErrorIs(t, err, models.ErrKeyRotationNotFound)
+
+			now := time.Now().UTC().Truncate(time.Microsecond)
+			rotation := models.KeyRotation{Name: "card_tokens", KeyID: "v2", LastID: "tok_1", Reencrypted: 1, StartedAt: now, UpdatedAt: now}
+			require.NoError(t, repo.SaveRotation(ctx, rotation))
+
+			rotation.LastID, rotation.Reencrypted, rotation.Completed = "tok_9", 9, true
+			rotation.UpdatedAt = now.Add(time.Second)
+			require.NoError(t, repo.SaveRotation(ctx, rotation))
+
+			got, err := repo.GetRotation(ctx, "card_tokens")
+			require.NoError(t, err)
+			assert.Equal(t, rotation, *got)
+		})
+	}
+}
+
+func TestPaymentBatchesRepositories(t *testing.T) {
+	repos := map[string]PaymentBatchesRepository{
+		"memory": NewPaymentBatchesRepository(),
+		"sqlite": NewSQLPaymentBatchesRepository(openTestDatabase(t)),
+	}
+
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := repo.GetBatch(ctx, "batch-id")
+			assert.ErrorIs(t, err, models.ErrPaymentBatchNotFound)
+			assert.ErrorIs(t, repo.UpdateBatch(ctx, models.PaymentBatch{Id: "batch-id"}), models.ErrPaymentBatchNotFound)
+
+			now := time.Now().UTC().Truncate(time.Microsecond)
+			batch := models.PaymentBatch{Id: "batch-id", MerchantId: "merchant-a", Status: models.BatchProcessing, Total: 3, CreatedAt: now, UpdatedAt: now}
+			require.NoError(t, repo.AddBatch(ctx, batch))
+
+			got, err := repo.GetBatch(ctx, "batch-id")
+			require.NoError(t, err)
+			assert.Equal(t, batch, *got)
+
+			results := []models.PaymentBatchResult{
+				{Line: 3, ClientReference: "c", Status: models.StatusFailed, Error: "acquirer unavailable"},
+				{Line: 1, ClientReference: "a", Status: models.StatusDeclined, PaymentId: "payment-1", DeclineReason: models.DeclineInsufficientFunds},
+				{Line: 2, Status: models.StatusRejected, Errors: []models.ValidationError{{Field: "cvv", Message: "is required"}}},
+			}
+			require.NoError(t, repo.AddBatchResults(ctx, "batch-id", results[:1]))
+			require.NoError(t, repo.AddBatchResults(ctx, "batch-id", results[1:]))
+
+			batch.Status, batch.Processed, batch.Declined, batch.Rejected, batch.Failed = models.BatchCompleted, 3, 1, 1, 1
+			batch.UpdatedAt, batch.CompletedAt = now.Add(time.Second), now.Add(time.Second)
+			require.NoError(t, repo.UpdateBatch(ctx, batch))
+
+			got, err = repo.GetBatch(ctx, "batch-id")
+			require.NoError(t, err)
+			assert.Equal(t, batch, *got)
+
+			listed, err := repo.ListBatchResults(ctx, "batch-id")
+			require.NoError(t, err)
+			assert.Equal(t, []models.PaymentBatchResult{results[1], results[2], results[0]}, listed, "results are in line order")
+
+			listed, err = repo.ListBatchResults(ctx, "other-batch")
+			require.NoError(t, err)
+			assert.Empty(t, listed)
+		})
+	}
+}
+
+func TestMigrationsAreAppliedOnce(t *testing.T) {
+	ctx := context.Background()
+	dsn := "file:" + filepath.Join(t.TempDir(), "payments.db")
+
+	db, err := OpenDatabase(ctx, DriverSQLite, dsn)
+	require.NoError(t, err)
+	require.NoError(t, NewSQLPaymentsRepository(db, nil).AddPayment(ctx, models.Payment{Id: "payment-id"}))
+	db.Close()
+
+	db, err = OpenDatabase(ctx, DriverSQLite, dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = NewSQLPaymentsRepository(db, nil).GetPayment(ctx, "payment-id")
+	assert.NoError(t, err)
+
+	migrations, err := loadMigrations(DriverSQLite)
+	require.NoError(t, err)
+
+	var applied int
+	require.NoError(t, db.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations`).Scan(&applied))
+	assert.Equal(t, len(migrations), applied)
+}
+
+func TestRebind(t *testing.T) {
+	pg := &Database{driver: DriverPostgres}
+	assert.Equal(t, "SELECT * FROM t WHERE a = $1 AND b = $2", pg.rebind("SELECT * FROM t WHERE a = ? AND b = ?"))
+
+	lite := &Database{driver: DriverSQLite}
+	assert.Equal(t, "SELECT * FROM t WHERE a = ?", lite.rebind("SELECT * FROM t WHERE a = ?"))
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type WebhooksRepository interface {
+	AddEndpoint(ctx context.Context, endpoint models.WebhookEndpoint) error
+	// GetEndpoint returns models.ErrWebhookEndpointNotFound if no endpoint has the given ID.
+	GetEndpoint(ctx context.Context, id string) (*models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, merchantID string) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, id string) error
+
+	AddDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error
+	// GetDelivery returns models.ErrWebhookDeliveryNotFound if no delivery has the given ID.
+	GetDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error)
+	// ListDeliveries returns up to limit of the merchant's deliveries, newest
+	// first. An empty status matches every delivery.
+	ListDeliveries(ctx context.Context, merchantID string, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+	// ClaimDueDeliveries returns up to limit pending deliveries due at now,
+	// oldest first, and postpones them to leaseUntil so that they are not
+	// claimed again while being sent.
+	ClaimDueDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+type inMemWebhooksStore struct {
+	mu         sync.Mutex
+	endpoints  map[string]models.WebhookEndpoint
+	deliveries map[string]models.WebhookDelivery
+}
+
+func NewWebhooksRepository() WebhooksRepository {
+	return &inMemWebhooksStore{
+		endpoints:  make(map[string]models.WebhookEndpoint),
+		deliveries: make(map[string]models.WebhookDelivery),
+	}
+}
+
+func (s *inMemWebhooksStore) AddEndpoint(ctx context.Context, endpoint models.WebhookEndpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint.EventTypes = append([]models.EventType(nil), endpoint.EventTypes...)
+	s.endpoints[endpoint.Id] = endpoint
+	return nil
+}
+
+func (s *inMemWebhooksStore) GetEndpoint(ctx context.Context, id string) (*models.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	endpoint, exists := s.endpoints[id]
+	if !exists {
+		return nil, models.ErrWebhookEndpointNotFound
+	}
+	endpoint.EventTypes = append([]models.EventType(nil), endpoint.EventTypes...)
+	return &endpoint, nil
+}
+
+func (s *inMemWebhooksStore) ListEndpoints(ctx context.Context, merchantID string) ([]models.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var endpoints []models.WebhookEndpoint
+	for _, endpoint := range s.endpoints {
+		if endpoint.MerchantId == merchantID {
+			endpoint.EventTypes = append([]models.EventType(nil), endpoint.EventTypes...)
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].CreatedAt.Before(endpoints[j].CreatedAt)
+	})
+	return endpoints, nil
+}
+
+func (s *inMemWebhooksStore) DeleteEndpoint(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.endpoints[id]; !exists {
+		return models.ErrWebhookEndpointNotFound
+	}
+	delete(s.endpoints, id)
+	return nil
+}
+
+func (s *inMemWebhooksStore) AddDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, d := range deliveries {
+		s.deliveries[d.Id] = d
+	}
+	return nil
+}
+
+func (s *inMemWebhooksStore) GetDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, exists := s.deliveries[id]
+	if !exists {
+		return nil, models.ErrWebhookDeliveryNotFound
+	}
+	return &d, nil
+}
+
+func (s *inMemWebhooksStore) ListDeliveries(ctx context.Context, merchantID string, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.MerchantId == merchantID && (status == "" || d.Status == status) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].Id > deliveries[j].Id
+		}
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (s *inMemWebhooksStore) ClaimDueDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []models.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.Status == models.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, d := range due {
+		leased := d
+		leased.NextAttemptAt = leaseUntil
+		s.deliveries[d.Id] = leased
+	}
+	return due, nil
+}
+
+func (s *inMemWebhooksStore) UpdateDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.deliveries[delivery.Id]; !exists {
+		return models.ErrWebhookDeliveryNotFound
+	}
+	s.deliveries[delivery.Id] = delivery
+	return nil
+}
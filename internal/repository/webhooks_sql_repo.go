@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+)
+
+// deliveryColumns are the webhook_deliveries columns read by scanDelivery, in order.
+const deliveryColumns = `id, endpoint_id, merchant_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	last_status_code, last_error, created_at, updated_at`
+
+type sqlWebhooksStore struct {
+	*Database
+}
+
+func NewSQLWebhooksRepository(db *Database) WebhooksRepository {
+	return &sqlWebhooksStore{Database: db}
+}
+
+func (s *sqlWebhooksStore) AddEndpoint(ctx context.Context, endpoint models.WebhookEndpoint) error {
+	eventTypes := make([]string, len(endpoint.EventTypes))
+	for i, t := range endpoint.EventTypes {
+		eventTypes[i] = string(t)
+	}
+
+	if _, err := s.db.ExecContext(ctx, s.rebind(`
+		INSERT INTO webhook_endpoints (id, merchant_id, url, secret, event_types, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`),
+		endpoint.Id,
+		endpoint.MerchantId,
+		endpoint.URL,
+		endpoint.Secret,
+		strings.Join(eventTypes, ","),
+		endpoint.CreatedAt.UnixMicro(),
+	); err != nil {
+		return fmt.Errorf("failed to insert webhook endpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlWebhooksStore) GetEndpoint(ctx context.Context, id string) (*models.WebhookEndpoint, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`
+		SELECT id, merchant_id, url, secret, event_types, created_at
+		FROM webhook_endpoints
+		WHERE id = ?`), id)
+
+	endpoint, err := scanEndpoint(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrWebhookEndpointNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook endpoint: %w", err)
+	}
+	return endpoint, nil
+}
+
+func (s *sqlWebhooksStore) ListEndpoints(ctx context.Context, merchantID string) ([]models.WebhookEndpoint, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`
+		SELECT id, merchant_id, url, secret, event_types, created_at
+		FROM webhook_endpoints
+		WHERE merchant_id = ?
+		ORDER BY created_at, id`), merchantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []models.WebhookEndpoint
+	for rows.Next() {
+		endpoint, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+		}
+		endpoints = append(endpoints, *endpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list webhook endpoints: %w", err)
+	}
+	return endpoints, nil
+}
+
+func (s *sqlWebhooksStore) DeleteEndpoint(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(`DELETE FROM webhook_endpoints WHERE id = ?`), id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook endpoint: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ErrWebhookEndpointNotFound
+	}
+	return nil
+}
+
+func (s *sqlWebhooksStore) AddDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, d := range deliveries {
+		if _, err := tx.ExecContext(ctx, s.rebind(`
+			INSERT INTO webhook_deliveries (`+deliveryColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			d.Id,
+			d.EndpointId,
+			d.MerchantId,
+			d.EventId,
+			d.EventType,
+			d.Payload,
+			d.Status,
+			d.Attempts,
+			d.NextAttemptAt.UnixMicro(),
+			d.LastStatusCode,
+			d.LastError,
+			d.CreatedAt.UnixMicro(),
+			d.UpdatedAt.UnixMicro(),
+		); err != nil {
+			return fmt.Errorf("failed to insert webhook delivery: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlWebhooksStore) GetDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	row := s.db.QueryRowContext(ctx, s.rebind(`
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE id = ?`), id)
+
+	d, err := scanDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+func (s *sqlWebhooksStore) ListDeliveries(ctx context.Context, merchantID string, status models.DeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	q := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE merchant_id = ?`
+	args := []any{merchantID}
+	if status != "" {
+		q += ` AND status = ?`
+		args = append(args, status)
+	}
+	q += ` ORDER BY created_at DESC, id DESC`
+	if limit > 0 {
+		q += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	return s.queryDeliveries(ctx, q, args...)
+}
+
+func (s *sqlWebhooksStore) ClaimDueDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	due, err := s.queryDeliveries(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT ?`, models.DeliveryPending, now.UnixMicro(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only keep the deliveries whose lease we took, another dispatcher may
+	// have claimed some of them since they were read.
+	var claimed []models.WebhookDelivery
+	for _, d := range due {
+		res, err := s.db.ExecContext(ctx, s.rebind(`
+			UPDATE webhook_deliveries
+			SET next_attempt_at = ?
+			WHERE id = ? AND status = ? AND next_attempt_at = ?`),
+			leaseUntil.UnixMicro(), d.Id, models.DeliveryPending, d.NextAttemptAt.UnixMicro())
+		if err != nil {
+			return nil, fmt.Errorf("failed to claim webhook delivery: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			claimed = append(claimed, d)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *sqlWebhooksStore) UpdateDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, updated_at = ?
+		WHERE id = ?`),
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt.UnixMicro(),
+		d.LastStatusCode,
+		d.LastError,
+		d.UpdatedAt.UnixMicro(),
+		d.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return models.ErrWebhookDeliveryNotFound
+	}
+	return nil
+}
+
+func (s *sqlWebhooksStore) queryDeliveries(ctx context.Context, q string, args ...any) ([]models.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(q), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook deliveries: %w", err)
+		}
+		deliveries = append(deliveries, *d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func scanEndpoint(row interface{ Scan(...any) error }) (*models.WebhookEndpoint, error) {
+	var (
+		endpoint   models.WebhookEndpoint
+		eventTypes string
+		createdAt  int64
+	)
+	if err := row.Scan(&endpoint.Id, &endpoint.MerchantId, &endpoint.URL, &endpoint.Secret, &eventTypes, &createdAt); err != nil {
+		return nil, err
+	}
+	for _, t := range strings.Split(eventTypes, ",") {
+		if t != "" {
+			endpoint.EventTypes = append(endpoint.EventTypes, models.EventType(t))
+		}
+	}
+	endpoint.CreatedAt = time.UnixMicro(createdAt).UTC()
+	return &endpoint, nil
+}
+
+// scanDelivery reads a row of deliveryColumns.
+func scanDelivery(row interface{ Scan(...any) error }) (*models.WebhookDelivery, error) {
+	var (
+		d                                   models.WebhookDelivery
+		nextAttemptAt, createdAt, updatedAt int64
+	)
+	err := row.Scan(
+		&d.Id,
+		&d.EndpointId,
+		&d.MerchantId,
+		&d.EventId,
+		&d.EventType,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&nextAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.NextAttemptAt = time.UnixMicro(nextAttemptAt).UTC()
+	d.CreatedAt = time.UnixMicro(createdAt).UTC()
+	d.UpdatedAt = time.UnixMicro(updatedAt).UTC()
+	return &d, nil
+}
@@ -0,0 +1,357 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// BatchConfig controls how payment batches are processed
+type BatchConfig struct {
+	// Concurrency is the number of payments of a batch made at the same time
+	Concurrency int
+	// MaxLines is the largest number of lines a batch may have
+	MaxLines int
+	// MaxLineSize is the largest size of a line in bytes
+	MaxLineSize int
+	// QueueSize is the number of batches that may wait to be processed
+	QueueSize int
+}
+
+// DefaultBatchConfig makes 4 payments at a time from batches of up to 10000 lines.
+var DefaultBatchConfig = BatchConfig{
+	Concurrency: 4,
+	MaxLines:    10000,
+	MaxLineSize: 64 * 1024,
+	QueueSize:   16,
+}
+
+type BatchService interface {
+	// SubmitBatch reads the newline-delimited models.PaymentBatchLine records
+	// of body and queues them to be paid for the authenticated merchant.
+	// Blank lines are skipped but still counted, so results refer to the
+	// lines of the upload.
+	SubmitBatch(ctx context.Context, body io.Reader) (*models.PaymentBatchResponse, error)
+	GetBatch(ctx context.Context, id string) (*models.PaymentBatchResponse, error)
+	// ListBatchResults returns the results of the lines processed so far, in line order.
+	ListBatchResults(ctx context.Context, id string) ([]models.PaymentBatchResult, error)
+	// Run processes the submitted batches in turn until ctx is cancelled. The
+	// payments in flight are then finished, and the lines left and the
+	// batches still queued are failed.
+	Run(ctx context.Context)
+}
+
+// batchLine is a line of a queued batch.
+type batchLine struct {
+	models.PaymentBatchLine
+	// n is the number of the line in the upload
+	n int
+	// invalid is set when the line is not a JSON object
+	invalid bool
+}
+
+type batchJob struct {
+	batch models.PaymentBatch
+	lines []batchLine
+}
+
+type batchService struct {
+	storage   repository.PaymentBatchesRepository
+	payments  PaymentService
+	validator ValidationService
+	cfg       BatchConfig
+	queue     chan batchJob
+	now       func() time.Time
+}
+
+func NewBatchService(repo repository.PaymentBatchesRepository, payments PaymentService, validator ValidationService, cfg BatchConfig) BatchService {
+	return &batchService{
+		storage:   repo,
+		payments:  payments,
+		validator: validator,
+		cfg:       cfg,
+		queue:     make(chan batchJob, cfg.QueueSize),
+		now:       time.Now,
+	}
+}
+
+// SubmitBatch returns models.ErrPaymentBatchQueueFull when the batch cannot
+// be queued, in which case nothing is stored. The card details of the lines
+// are only kept in memory until they are paid.
+func (s *batchService) SubmitBatch(ctx context.Context, body io.Reader) (*models.PaymentBatchResponse, error) {
+	// a full queue is checked first so that a refused upload is not read
+	if len(s.queue) == cap(s.queue) {
+		return nil, models.ErrPaymentBatchQueueFull
+	}
+
+	lines, err := s.readLines(body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC().Truncate(time.Microsecond)
+	batch := models.PaymentBatch{
+		Id:         uuid.New().String(),
+		MerchantId: auth.MerchantID(ctx),
+		Status:     models.BatchProcessing,
+		Total:      len(lines),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.storage.AddBatch(ctx, batch); err != nil {
+		return nil, fmt.Errorf("failed to store payment batch: %w", err)
+	}
+
+	select {
+	case s.queue <- batchJob{batch: batch, lines: lines}:
+	default:
+		// another batch took the last place while this one was read
+		s.interrupt(context.WithoutCancel(ctx), batch, lines)
+		return nil, models.ErrPaymentBatchQueueFull
+	}
+	return toPaymentBatchResponse(batch), nil
+}
+
+// readLines parses the lines of body. Lines that are not a JSON object are
+// kept to be rejected rather than failing the whole batch.
+func (s *batchService) readLines(body io.Reader) ([]batchLine, error) {
+	scanner := bufio.NewScanner(body)
+	// the size of the initial buffer is a limit of its own
+	scanner.Buffer(make([]byte, 0, min(4096, s.cfg.MaxLineSize)), s.cfg.MaxLineSize)
+
+	var lines []batchLine
+	for n := 1; scanner.Scan(); n++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if len(lines) == s.cfg.MaxLines {
+			return nil, fmt.Errorf("%w, the limit is %d", models.ErrPaymentBatchTooLarge, s.cfg.MaxLines)
+		}
+
+		line := batchLine{n: n}
+		if err := json.Unmarshal(text, &line.PaymentBatchLine); err != nil {
+			line = batchLine{n: n, invalid: true}
+		}
+		lines = append(lines, line)
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return nil, fmt.Errorf("%w, the limit is %d bytes", models.ErrPaymentBatchLineTooLong, s.cfg.MaxLineSize)
+	}
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("failed to read payment batch: %w", scanner.Err())
+	}
+	if len(lines) == 0 {
+		return nil, models.ErrEmptyPaymentBatch
+	}
+	return lines, nil
+}
+
+func (s *batchService) GetBatch(ctx context.Context, id string) (*models.PaymentBatchResponse, error) {
+	batch, err := s.getOwnedBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toPaymentBatchResponse(*batch), nil
+}
+
+func (s *batchService) ListBatchResults(ctx context.Context, id string) ([]models.PaymentBatchResult, error) {
+	if _, err := s.getOwnedBatch(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.storage.ListBatchResults(ctx, id)
+}
+
+// getOwnedBatch returns the batch with the given ID if it belongs to the
+// authenticated merchant, and models.ErrPaymentBatchNotFound otherwise.
+func (s *batchService) getOwnedBatch(ctx context.Context, id string) (*models.PaymentBatch, error) {
+	batch, err := s.storage.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.MerchantId != auth.MerchantID(ctx) {
+		return nil, models.ErrPaymentBatchNotFound
+	}
+	return batch, nil
+}
+
+func (s *batchService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			// the batches still queued will not be processed by this instance
+			for {
+				select {
+				case job := <-s.queue:
+					s.interrupt(context.WithoutCancel(ctx), job.batch, job.lines)
+				default:
+					return
+				}
+			}
+		case job := <-s.queue:
+			s.process(ctx, job)
+		}
+	}
+}
+
+// process pays the lines of job with cfg.Concurrency workers, and records
+// the result of each line as it comes. The payments are made with a context
+// that is not cancelled, so that no payment is left half way; once ctx is
+// cancelled no further line is started and the batch is interrupted.
+func (s *batchService) process(ctx context.Context, job batchJob) {
+	merchantCtx := auth.WithMerchantID(context.WithoutCancel(ctx), job.batch.MerchantId)
+
+	next := make(chan batchLine)
+	results := make(chan models.PaymentBatchResult)
+	var wg sync.WaitGroup
+	for i := 0; i < max(s.cfg.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range next {
+				results <- s.payLine(merchantCtx, line)
+			}
+		}()
+	}
+
+	// started is the number of lines handed out, it is final once results is closed
+	started := 0
+	go func() {
+		defer func() {
+			close(next)
+			wg.Wait()
+			close(results)
+		}()
+		for _, line := range job.lines {
+			// a worker may be free as well once ctx is cancelled
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case next <- line:
+				started++
+			}
+		}
+	}()
+
+	batch := job.batch
+	for result := range results {
+		if err := s.storage.AddBatchResults(merchantCtx, batch.Id, []models.PaymentBatchResult{result}); err != nil {
+			slog.ErrorContext(ctx, "failed to store payment batch result",
+				slog.String("batch_id", batch.Id), slog.Int("line", result.Line), slog.Any("error", err))
+		}
+		batch.Count(result)
+		batch.UpdatedAt = s.now().UTC().Truncate(time.Microsecond)
+		if batch.Processed == batch.Total {
+			batch.Status = models.BatchCompleted
+			batch.CompletedAt = batch.UpdatedAt
+		}
+		if err := s.storage.UpdateBatch(merchantCtx, batch); err != nil {
+			slog.ErrorContext(ctx, "failed to store payment batch", slog.String("batch_id", batch.Id), slog.Any("error", err))
+		}
+	}
+	if started < len(job.lines) {
+		s.interrupt(merchantCtx, batch, job.lines[started:])
+		return
+	}
+
+	slog.InfoContext(ctx, "processed payment batch",
+		slog.String("batch_id", batch.Id),
+		slog.Int("authorized", batch.Authorized),
+		slog.Int("declined", batch.Declined),
+		slog.Int("pending", batch.Pending),
+		slog.Int("rejected", batch.Rejected),
+		slog.Int("failed", batch.Failed))
+}
+
+// payLine validates the payment request of line and makes the payment.
+func (s *batchService) payLine(ctx context.Context, line batchLine) models.PaymentBatchResult {
+	result := models.PaymentBatchResult{Line: line.n, ClientReference: line.ClientReference}
+	if line.invalid {
+		result.Status, result.Error = StatusRejected, "line is not a valid payment request"
+		return result
+	}
+
+	if errs := s.validator.ValidatePaymentRequest(ctx, line.PaymentRequest); len(errs) > 0 {
+		result.Status, result.Errors = StatusRejected, errs
+		return result
+	}
+
+	response, err := s.payments.CreatePayment(ctx, line.PaymentRequest)
+	switch {
+	case errors.Is(err, models.ErrCardTokenNotFound), errors.Is(err, models.ErrCardExpired):
+		result.Status = StatusRejected
+		result.Errors = []models.ValidationError{{Field: "source", Message: err.Error()}}
+	case errors.Is(err, models.ErrAcquirerUnavailable):
+		result.Status, result.Error = models.StatusFailed, "acquirer unavailable"
+	case err != nil:
+		// results are served to the merchant, the error is only logged
+		slog.ErrorContext(ctx, "payment batch line failed", slog.Int("line", line.n), slog.Any("error", err))
+		result.Status, result.Error = models.StatusFailed, "payment could not be processed"
+	default:
+		result.Status = response.Status
+		result.PaymentId = response.Id
+		result.DeclineReason = response.DeclineReason
+	}
+	return result
+}
+
+// interrupt fails lines, the lines of batch that were not started, and
+// marks the batch as interrupted.
+func (s *batchService) interrupt(ctx context.Context, batch models.PaymentBatch, lines []batchLine) {
+	results := make([]models.PaymentBatchResult, len(lines))
+	for i, line := range lines {
+		results[i] = models.PaymentBatchResult{
+			Line:            line.n,
+			ClientReference: line.ClientReference,
+			Status:          models.StatusFailed,
+			Error:           "the gateway shut down before the payment was made",
+		}
+		batch.Count(results[i])
+	}
+	if err := s.storage.AddBatchResults(ctx, batch.Id, results); err != nil {
+		slog.ErrorContext(ctx, "failed to store payment batch results", slog.String("batch_id", batch.Id), slog.Any("error", err))
+	}
+
+	batch.Status = models.BatchInterrupted
+	batch.UpdatedAt = s.now().UTC().Truncate(time.Microsecond)
+	batch.CompletedAt = batch.UpdatedAt
+	if err := s.storage.UpdateBatch(ctx, batch); err != nil {
+		slog.ErrorContext(ctx, "failed to store payment batch", slog.String("batch_id", batch.Id), slog.Any("error", err))
+	}
+	slog.WarnContext(ctx, "interrupted payment batch", slog.String("batch_id", batch.Id), slog.Int("failed", len(lines)))
+}
+
+func toPaymentBatchResponse(batch models.PaymentBatch) *models.PaymentBatchResponse {
+	response := &models.PaymentBatchResponse{
+		Id:         batch.Id,
+		Status:     batch.Status,
+		Total:      batch.Total,
+		Processed:  batch.Processed,
+		Authorized: batch.Authorized,
+		Declined:   batch.Declined,
+		Pending:    batch.Pending,
+		Rejected:   batch.Rejected,
+		Failed:     batch.Failed,
+		CreatedAt:  batch.CreatedAt,
+	}
+	if !batch.CompletedAt.IsZero() {
+		completedAt := batch.CompletedAt
+		response.CompletedAt = &completedAt
+	}
+	return response
+}
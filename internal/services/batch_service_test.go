@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingBank authorizes payments once release is closed, and tells about
+// every call on started.
+type blockingBank struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBank) ProcessPayment(ctx context.Context, reference string, req models.PaymentRequest) (*bank.BankResponse, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return &bank.BankResponse{Authorized: true, AuthorizationCode: "auth-code"}, nil
+}
+
+func (b *blockingBank) QueryPayment(ctx context.Context, reference string) (*bank.BankResponse, error) {
+	return nil, bank.ErrBankPaymentNotFound
+}
+
+func batchBody(t *testing.T, lines ...any) string {
+	t.Helper()
+	var b strings.Builder
+	for _, line := range lines {
+		if text, ok := line.(string); ok {
+			b.WriteString(text + "\n")
+			continue
+		}
+		encoded, err := json.Marshal(line)
+		require.NoError(t, err)
+		b.Write(encoded)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func waitForBatch(t *testing.T, svc BatchService, ctx context.Context, id string) *models.PaymentBatchResponse {
+	t.Helper()
+	var batch *models.PaymentBatchResponse
+	require.Eventually(t, func() bool {
+		var err error
+		batch, err = svc.GetBatch(ctx, id)
+		require.NoError(t, err)
+		return batch.Status != models.BatchProcessing
+	}, 5*time.Second, 5*time.Millisecond)
+	return batch
+}
+
+func TestBatchService(t *testing.T) {
+	ctx := auth.WithMerchantID(context.Background(), "merchant-a")
+	payments := repository.NewPaymentsRepository()
+	svc := NewBatchService(
+		repository.NewPaymentBatchesRepository(),
+		NewPaymentService(payments, &stubBank{resp: &bank.BankResponse{Authorized: true, AuthorizationCode: "auth-code"}}),
+		NewValidationService(),
+		DefaultBatchConfig,
+	)
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go svc.Run(runCtx)
+
+	invalidCvv := testPaymentRequest
+	invalidCvv.Cvv = "12"
+	submitted, err := svc.SubmitBatch(ctx, strings.NewReader(batchBody(t,
+		models.PaymentBatchLine{ClientReference: "first", PaymentRequest: testPaymentRequest},
+		"",
+		`{"card_number": `,
+		models.PaymentBatchLine{ClientReference: "bad-cvv", PaymentRequest: invalidCvv},
+		models.PaymentBatchLine{PaymentRequest: testPaymentRequest},
+	)))
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchProcessing, submitted.Status)
+	assert.Equal(t, 4, submitted.Total, "blank lines are not counted")
+
+	batch := waitForBatch(t, svc, ctx, submitted.Id)
+	assert.Equal(t, models.BatchCompleted, batch.Status)
+	assert.Equal(t, 4, batch.Processed)
+	assert.Equal(t, 2, batch.Authorized)
+	assert.Equal(t, 2, batch.Rejected)
+	assert.NotNil(t, batch.CompletedAt)
+
+	results, err := svc.ListBatchResults(ctx, submitted.Id)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, 1, results[0].Line)
+	assert.Equal(t, "first", results[0].ClientReference)
+	assert.Equal(t, StatusAuthorized, results[0].Status)
+	payment, err := payments.GetPayment(ctx, results[0].PaymentId)
+	require.NoError(t, err)
+	assert.Equal(t, "merchant-a", payment.MerchantId, "payments are made for the merchant of the batch")
+
+	assert.Equal(t, 3, results[1].Line, "lines are numbered as in the upload")
+	assert.Equal(t, StatusRejected, results[1].Status)
+	assert.NotEmpty(t, results[1].Error)
+
+	assert.Equal(t, "bad-cvv", results[2].ClientReference)
+	assert.Equal(t, StatusRejected, results[2].Status)
+	assert.Equal(t, "cvv", results[2].Errors[0].Field)
+	assert.Empty(t, results[2].PaymentId)
+
+	assert.Equal(t, 5, results[3].Line)
+	assert.Equal(t, StatusAuthorized, results[3].Status)
+
+	t.Run("other merchants cannot see the batch", func(t *testing.T) {
+		other := auth.WithMerchantID(context.Background(), "merchant-b")
+		_, err := svc.GetBatch(other, submitted.Id)
+		assert.ErrorIs(t, err, models.ErrPaymentBatchNotFound)
+		_, err = svc.ListBatchResults(other, submitted.Id)
+		assert.ErrorIs(t, err, models.ErrPaymentBatchNotFound)
+	})
+}
+
+func TestBatchServiceRejectsUploads(t *testing.T) {
+	ctx := auth.WithMerchantID(context.Background(), "merchant-a")
+	cfg := BatchConfig{Concurrency: 1, MaxLines: 2, MaxLineSize: 1024, QueueSize: 1}
+	svc := NewBatchService(repository.NewPaymentBatchesRepository(), newTestPaymentService(t, true), NewValidationService(), cfg)
+	line := models.PaymentBatchLine{PaymentRequest: testPaymentRequest}
+
+	_, err := svc.SubmitBatch(ctx, strings.NewReader("\n \n"))
+	assert.ErrorIs(t, err, models.ErrEmptyPaymentBatch)
+
+	_, err = svc.SubmitBatch(ctx, strings.NewReader(batchBody(t, line, line, line)))
+	assert.ErrorIs(t, err, models.ErrPaymentBatchTooLarge)
+
+	_, err = svc.SubmitBatch(ctx, strings.NewReader(strings.Repeat("x", 2048)))
+	assert.ErrorIs(t, err, models.ErrPaymentBatchLineTooLong)
+
+	// nothing runs the queue, so the second batch does not fit
+	_, err = svc.SubmitBatch(ctx, strings.NewReader(batchBody(t, line)))
+	require.NoError(t, err)
+	_, err = svc.SubmitBatch(ctx, iotest.ErrReader(errors.New("a refused upload is not read")))
+	assert.ErrorIs(t, err, models.ErrPaymentBatchQueueFull)
+}
+
+func TestBatchServiceShutdown(t *testing.T) {
+	ctx := auth.WithMerchantID(context.Background(), "merchant-a")
+	acquirer := &blockingBank{started: make(chan struct{}), release: make(chan struct{})}
+	cfg := DefaultBatchConfig
+	cfg.Concurrency = 1
+	svc := NewBatchService(repository.NewPaymentBatchesRepository(), NewPaymentService(repository.NewPaymentsRepository(), acquirer), NewValidationService(), cfg)
+	line := models.PaymentBatchLine{PaymentRequest: testPaymentRequest}
+
+	running, err := svc.SubmitBatch(ctx, strings.NewReader(batchBody(t, line, line, line)))
+	require.NoError(t, err)
+	queued, err := svc.SubmitBatch(ctx, strings.NewReader(batchBody(t, line, line)))
+	require.NoError(t, err)
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		svc.Run(runCtx)
+		close(done)
+	}()
+
+	// the first payment is in flight when the gateway shuts down
+	<-acquirer.started
+	cancel()
+	close(acquirer.release)
+	<-done
+
+	batch, err := svc.GetBatch(ctx, running.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchInterrupted, batch.Status)
+	assert.Equal(t, 3, batch.Processed)
+	assert.Equal(t, 1, batch.Authorized, "the payment in flight is finished")
+	assert.Equal(t, 2, batch.Failed)
+
+	results, err := svc.ListBatchResults(ctx, running.Id)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, StatusAuthorized, results[0].Status)
+	assert.Equal(t, models.StatusFailed, results[2].Status)
+	assert.NotEmpty(t, results[2].Error)
+
+	batch, err = svc.GetBatch(ctx, queued.Id)
+	require.NoError(t, err)
+	assert.Equal(t, models.BatchInterrupted, batch.Status)
+	assert.Equal(t, 2, batch.Failed)
+}
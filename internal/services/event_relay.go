@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+)
+
+// EventRelayConfig controls how events left in the outbox are published
+type EventRelayConfig struct {
+	// Interval is the time between two sweeps of the outbox
+	Interval time.Duration
+	// MinAge is how old an event must be before it is swept, so that events
+	// still being published by the change that stored them are left alone
+	MinAge time.Duration
+	// BatchSize is the number of events published per sweep
+	BatchSize int
+}
+
+// DefaultEventRelayConfig sweeps the outbox every 30 seconds.
+var DefaultEventRelayConfig = EventRelayConfig{
+	Interval:  30 * time.Second,
+	MinAge:    30 * time.Second,
+	BatchSize: 100,
+}
+
+// EventRelay publishes the payment events that are still in the outbox
+// because publishing them right after the change they announce failed.
+// An event is published at least once: it is removed from the outbox only
+// after it was published.
+type EventRelay struct {
+	storage repository.PaymentsRepository
+	events  EventPublisher
+	cfg     EventRelayConfig
+	now     func() time.Time
+}
+
+func NewEventRelay(repo repository.PaymentsRepository, events EventPublisher, cfg EventRelayConfig) *EventRelay {
+	return &EventRelay{
+		storage: repo,
+		events:  events,
+		cfg:     cfg,
+		now:     time.Now,
+	}
+}
+
+// Run sweeps the outbox every cfg.Interval until ctx is cancelled.
+func (r *EventRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := r.Relay(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "payment event relay failed", slog.Any("error", err))
+			}
+			if n > 0 {
+				slog.InfoContext(ctx, "relayed payment events", slog.Int("published", n))
+			}
+		}
+	}
+}
+
+// Relay publishes a batch of events older than cfg.MinAge and removes them
+// from the outbox. It carries on past failures of single events, which stay
+// in the outbox, and returns the number of events published.
+func (r *EventRelay) Relay(ctx context.Context) (int, error) {
+	events, err := r.storage.UnpublishedEvents(ctx, r.now().UTC().Add(-r.cfg.MinAge), r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		published int
+		errs      []error
+	)
+	for _, event := range events {
+		if ctx.Err() != nil {
+			return published, ctx.Err()
+		}
+
+		err := r.events.Publish(ctx, event)
+		if err == nil {
+			err = r.storage.MarkEventPublished(ctx, event.Id)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("event %s: %w", event.Id, err))
+			continue
+		}
+		published++
+	}
+
+	return published, errors.Join(errs...)
+}
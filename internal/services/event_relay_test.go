@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventRelay(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := EventRelayConfig{Interval: time.Second, MinAge: time.Minute, BatchSize: 10}
+
+	// queueEvent stores a change of a new payment together with its event
+	queueEvent := func(t *testing.T, repo repository.PaymentsRepository, id string, age time.Duration) {
+		t.Helper()
+		payment := models.Payment{Id: id, MerchantId: "merchant-1", Status: StatusPending, Amount: 1000}
+		require.NoError(t, repo.AddPayment(ctx, payment))
+		require.NoError(t, payment.Settle("simulator", true, "auth-code", ""))
+		require.NoError(t, repo.UpdatePayment(ctx, payment, &models.PaymentEvent{
+			Id:         "event-" + id,
+			Type:       models.EventPaymentAuthorized,
+			CreatedAt:  now.Add(-age),
+			Data:       *toPaymentResponse(payment),
+			MerchantId: payment.MerchantId,
+		}))
+	}
+
+	newRelay := func(repo repository.PaymentsRepository, events EventPublisher) *EventRelay {
+		r := NewEventRelay(repo, events, cfg)
+		r.now = func() time.Time { return now }
+		return r
+	}
+
+	t.Run("publishes events left in the outbox", func(t *testing.T) {
+		repo := repository.NewPaymentsRepository()
+		queueEvent(t, repo, "old", 5*time.Minute)
+		queueEvent(t, repo, "recent", time.Second)
+
+		events := &recordingPublisher{}
+		n, err := newRelay(repo, events).Relay(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		require.Len(t, events.events, 1)
+		assert.Equal(t, "event-old", events.events[0].Id)
+		assert.Equal(t, "merchant-1", events.events[0].MerchantId)
+
+		// the recent event may still be published by the change that stored it
+		unpublished, err := repo.UnpublishedEvents(ctx, now.Add(time.Hour), 10)
+		require.NoError(t, err)
+		require.Len(t, unpublished, 1)
+		assert.Equal(t, "event-recent", unpublished[0].Id)
+	})
+
+	t.Run("keeps events that fail to publish", func(t *testing.T) {
+		repo := repository.NewPaymentsRepository()
+		queueEvent(t, repo, "old", 5*time.Minute)
+
+		n, err := newRelay(repo, &recordingPublisher{err: errors.New("webhook store unavailable")}).Relay(ctx)
+		assert.Error(t, err)
+		assert.Zero(t, n)
+
+		events := &recordingPublisher{}
+		n, err = newRelay(repo, events).Relay(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Len(t, events.events, 1)
+	})
+}
@@ -0,0 +1,94 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: batch_service.go
+
+// Package mock_services is a generated GoMock package.
+package mock_services
+
+import (
+	context "context"
+	io "io"
+	reflect "reflect"
+
+	models "github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockBatchService is a mock of BatchService interface.
+type MockBatchService struct {
+	ctrl     *gomock.Controller
+	recorder *MockBatchServiceMockRecorder
+}
+
+// MockBatchServiceMockRecorder is the mock recorder for MockBatchService.
+type MockBatchServiceMockRecorder struct {
+	mock *MockBatchService
+}
+
+// NewMockBatchService creates a new mock instance.
+func NewMockBatchService(ctrl *gomock.Controller) *MockBatchService {
+	mock := &MockBatchService{ctrl: ctrl}
+	mock.recorder = &MockBatchServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBatchService) EXPECT() *MockBatchServiceMockRecorder {
+	return m.recorder
+}
+
+// GetBatch mocks base method.
+func (m *MockBatchService) GetBatch(ctx context.Context, id string) (*models.PaymentBatchResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBatch", ctx, id)
+	ret0, _ := ret[0].(*models.PaymentBatchResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBatch indicates an expected call of GetBatch.
+func (mr *MockBatchServiceMockRecorder) GetBatch(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBatch", reflect.TypeOf((*MockBatchService)(nil).GetBatch), ctx, id)
+}
+
+// ListBatchResults mocks base method.
+func (m *MockBatchService) ListBatchResults(ctx context.Context, id string) ([]models.PaymentBatchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBatchResults", ctx, id)
+	ret0, _ := ret[0].([]models.PaymentBatchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBatchResults indicates an expected call of ListBatchResults.
+func (mr *MockBatchServiceMockRecorder) ListBatchResults(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBatchResults", reflect.TypeOf((*MockBatchService)(nil).ListBatchResults), ctx, id)
+}
+
+// Run mocks base method.
+func (m *MockBatchService) Run(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Run", ctx)
+}
+
+// Run indicates an expected call of Run.
+func (mr *MockBatchServiceMockRecorder) Run(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Run", reflect.TypeOf((*MockBatchService)(nil).Run), ctx)
+}
+
+// SubmitBatch mocks base method.
+func (m *MockBatchService) SubmitBatch(ctx context.Context, body io.Reader) (*models.PaymentBatchResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubmitBatch", ctx, body)
+	ret0, _ := ret[0].(*models.PaymentBatchResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubmitBatch indicates an expected call of SubmitBatch.
+func (mr *MockBatchServiceMockRecorder) SubmitBatch(ctx, body interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubmitBatch", reflect.TypeOf((*MockBatchService)(nil).SubmitBatch), ctx, body)
+}
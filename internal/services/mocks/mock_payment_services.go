@@ -35,6 +35,21 @@ func (m *MockPaymentService) EXPECT() *MockPaymentServiceMockRecorder {
 	return m.recorder
 }
 
+// CapturePayment mocks base method.
+func (m *MockPaymentService) CapturePayment(ctx context.Context, id string, amount int) (*models.OperationResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CapturePayment", ctx, id, amount)
+	ret0, _ := ret[0].(*models.OperationResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CapturePayment indicates an expected call of CapturePayment.
+func (mr *MockPaymentServiceMockRecorder) CapturePayment(ctx, id, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CapturePayment", reflect.TypeOf((*MockPaymentService)(nil).CapturePayment), ctx, id, amount)
+}
+
 // CreatePayment mocks base method.
 func (m *MockPaymentService) CreatePayment(ctx context.Context, req models.PaymentRequest) (*models.PaymentResponse, error) {
 	m.ctrl.T.Helper()
@@ -64,3 +79,48 @@ func (mr *MockPaymentServiceMockRecorder) GetPayment(ctx, id interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPayment", reflect.TypeOf((*MockPaymentService)(nil).GetPayment), ctx, id)
 }
+
+// ListPayments mocks base method.
+func (m *MockPaymentService) ListPayments(ctx context.Context, filter models.PaymentFilter, cursor string, limit int) (*models.PaymentListResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPayments", ctx, filter, cursor, limit)
+	ret0, _ := ret[0].(*models.PaymentListResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPayments indicates an expected call of ListPayments.
+func (mr *MockPaymentServiceMockRecorder) ListPayments(ctx, filter, cursor, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPayments", reflect.TypeOf((*MockPaymentService)(nil).ListPayments), ctx, filter, cursor, limit)
+}
+
+// RefundPayment mocks base method.
+func (m *MockPaymentService) RefundPayment(ctx context.Context, id string, amount int) (*models.OperationResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundPayment", ctx, id, amount)
+	ret0, _ := ret[0].(*models.OperationResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundPayment indicates an expected call of RefundPayment.
+func (mr *MockPaymentServiceMockRecorder) RefundPayment(ctx, id, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundPayment", reflect.TypeOf((*MockPaymentService)(nil).RefundPayment), ctx, id, amount)
+}
+
+// VoidPayment mocks base method.
+func (m *MockPaymentService) VoidPayment(ctx context.Context, id string) (*models.OperationResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidPayment", ctx, id)
+	ret0, _ := ret[0].(*models.OperationResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VoidPayment indicates an expected call of VoidPayment.
+func (mr *MockPaymentServiceMockRecorder) VoidPayment(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidPayment", reflect.TypeOf((*MockPaymentService)(nil).VoidPayment), ctx, id)
+}
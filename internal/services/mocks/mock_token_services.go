@@ -0,0 +1,66 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: token_service.go
+
+// Package mock_services is a generated GoMock package.
+package mock_services
+
+import (
+	context "context"
+	reflect "reflect"
+
+	models "github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTokenService is a mock of TokenService interface.
+type MockTokenService struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenServiceMockRecorder
+}
+
+// MockTokenServiceMockRecorder is the mock recorder for MockTokenService.
+type MockTokenServiceMockRecorder struct {
+	mock *MockTokenService
+}
+
+// NewMockTokenService creates a new mock instance.
+func NewMockTokenService(ctrl *gomock.Controller) *MockTokenService {
+	mock := &MockTokenService{ctrl: ctrl}
+	mock.recorder = &MockTokenServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenService) EXPECT() *MockTokenServiceMockRecorder {
+	return m.recorder
+}
+
+// Detokenize mocks base method.
+func (m *MockTokenService) Detokenize(ctx context.Context, req models.PaymentRequest) (models.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Detokenize", ctx, req)
+	ret0, _ := ret[0].(models.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Detokenize indicates an expected call of Detokenize.
+func (mr *MockTokenServiceMockRecorder) Detokenize(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Detokenize", reflect.TypeOf((*MockTokenService)(nil).Detokenize), ctx, req)
+}
+
+// Tokenize mocks base method.
+func (m *MockTokenService) Tokenize(ctx context.Context, req models.CardTokenRequest) (*models.CardTokenResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Tokenize", ctx, req)
+	ret0, _ := ret[0].(*models.CardTokenResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Tokenize indicates an expected call of Tokenize.
+func (mr *MockTokenServiceMockRecorder) Tokenize(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tokenize", reflect.TypeOf((*MockTokenService)(nil).Tokenize), ctx, req)
+}
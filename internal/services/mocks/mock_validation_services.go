@@ -35,6 +35,20 @@ func (m *MockValidationService) EXPECT() *MockValidationServiceMockRecorder {
 	return m.recorder
 }
 
+// ValidateCardTokenRequest mocks base method.
+func (m *MockValidationService) ValidateCardTokenRequest(ctx context.Context, req models.CardTokenRequest) []models.ValidationError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateCardTokenRequest", ctx, req)
+	ret0, _ := ret[0].([]models.ValidationError)
+	return ret0
+}
+
+// ValidateCardTokenRequest indicates an expected call of ValidateCardTokenRequest.
+func (mr *MockValidationServiceMockRecorder) ValidateCardTokenRequest(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateCardTokenRequest", reflect.TypeOf((*MockValidationService)(nil).ValidateCardTokenRequest), ctx, req)
+}
+
 // ValidatePaymentRequest mocks base method.
 func (m *MockValidationService) ValidatePaymentRequest(ctx context.Context, req models.PaymentRequest) []models.ValidationError {
 	m.ctrl.T.Helper()
@@ -48,3 +62,17 @@ func (mr *MockValidationServiceMockRecorder) ValidatePaymentRequest(ctx, req int
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidatePaymentRequest", reflect.TypeOf((*MockValidationService)(nil).ValidatePaymentRequest), ctx, req)
 }
+
+// ValidateWebhookEndpoint mocks base method.
+func (m *MockValidationService) ValidateWebhookEndpoint(ctx context.Context, req models.WebhookEndpointRequest) []models.ValidationError {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateWebhookEndpoint", ctx, req)
+	ret0, _ := ret[0].([]models.ValidationError)
+	return ret0
+}
+
+// ValidateWebhookEndpoint indicates an expected call of ValidateWebhookEndpoint.
+func (mr *MockValidationServiceMockRecorder) ValidateWebhookEndpoint(ctx, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateWebhookEndpoint", reflect.TypeOf((*MockValidationService)(nil).ValidateWebhookEndpoint), ctx, req)
+}
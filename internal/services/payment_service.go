@@ -2,107 +2,415 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/currency"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/metrics"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/utils"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type PaymentService interface {
 	CreatePayment(ctx context.Context, req models.PaymentRequest) (*models.PaymentResponse, error)
 	GetPayment(ctx context.Context, id string) (*models.PaymentResponse, error)
+	ListPayments(ctx context.Context, filter models.PaymentFilter, cursor string, limit int) (*models.PaymentListResponse, error)
+	CapturePayment(ctx context.Context, id string, amount int) (*models.OperationResponse, error)
+	VoidPayment(ctx context.Context, id string) (*models.OperationResponse, error)
+	RefundPayment(ctx context.Context, id string, amount int) (*models.OperationResponse, error)
 }
 
 type paymentService struct {
 	storage    repository.PaymentsRepository
 	bankClient bank.Bank
+	events     EventPublisher
+	tokens     TokenService
+	metrics    *metrics.Metrics
+	now        func() time.Time
 }
 
-type Status string
+// PaymentServiceOption configures optional PaymentService behaviour.
+type PaymentServiceOption func(*paymentService)
+
+// WithMetrics counts the outcome of every payment processed in m.
+func WithMetrics(m *metrics.Metrics) PaymentServiceOption {
+	return func(p *paymentService) {
+		p.metrics = m
+	}
+}
+
+// WithEventPublisher makes the service publish an event every time it changes
+// the status of a payment.
+func WithEventPublisher(events EventPublisher) PaymentServiceOption {
+	return func(p *paymentService) {
+		p.events = events
+	}
+}
+
+// WithCardTokens lets payments be paid with card tokens from tokens.
+func WithCardTokens(tokens TokenService) PaymentServiceOption {
+	return func(p *paymentService) {
+		p.tokens = tokens
+	}
+}
+
+type Status = models.PaymentStatus
 
 const (
-	StatusAuthorized Status = "Authorized"
-	StatusDeclined   Status = "Declined"
-	StatusRejected   Status = "Rejected"
+	StatusPending           = models.StatusPending
+	StatusAuthorized        = models.StatusAuthorized
+	StatusDeclined          = models.StatusDeclined
+	StatusRejected          = models.StatusRejected
+	StatusPartiallyCaptured = models.StatusPartiallyCaptured
+	StatusCaptured          = models.StatusCaptured
+	StatusVoided            = models.StatusVoided
+	StatusPartiallyRefunded = models.StatusPartiallyRefunded
+	StatusRefunded          = models.StatusRefunded
 )
 
-func NewPaymentService(repo repository.PaymentsRepository, bankClient bank.Bank) PaymentService {
-	return &paymentService{
+func NewPaymentService(repo repository.PaymentsRepository, bankClient bank.Bank, opts ...PaymentServiceOption) PaymentService {
+	p := &paymentService{
 		storage:    repo,
 		bankClient: bankClient,
+		now:        time.Now,
 	}
-}
 
-func (p *paymentService) CreatePayment(ctx context.Context, req models.PaymentRequest) (*models.PaymentResponse, error) {
-
-	// Process payment with bank
-	bankResp, err := p.bankClient.ProcessPayment(ctx, req)
-	if err != nil {
-		// If bank returns an error, treat as declined
-		return nil, fmt.Errorf("bank processing error: %v", err)
+	for _, opt := range opts {
+		opt(p)
 	}
 
-	// Determine payment status based on bank response
-	var status Status
-	if bankResp.Authorized {
-		status = StatusAuthorized
-	} else {
-		status = StatusDeclined
-	}
+	return p
+}
 
-	// Generate payment ID
+func (p *paymentService) CreatePayment(ctx context.Context, req models.PaymentRequest) (_ *models.PaymentResponse, err error) {
+
+	// Generate payment ID, which is also the payment reference at the bank
 	paymentID := uuid.New().String()
 
+	ctx, span := tracing.Start(ctx, "PaymentService.CreatePayment",
+		trace.WithAttributes(tracing.PaymentAttributes(paymentID, req.Currency, req.Amount)...))
+	defer func() { tracing.End(span, err) }()
+
+	// The card of a token is only decrypted now, and only ever kept in req
+	if req.Source != "" {
+		if p.tokens == nil {
+			return nil, models.ErrCardTokenNotFound
+		}
+		if req, err = p.tokens.Detokenize(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
 	// Get last four digits of card
 	lastFour := utils.GetLastFourDigits(req.CardNumber)
 
-	// Create payment record
+	// Store the payment as pending before the bank sees it, so that an
+	// authorization is never lost when the bank call fails half way. Stores
+	// keep microseconds, so CreatedAt is truncated to match later reads.
 	payment := models.Payment{
 		Id:                 paymentID,
-		Status:             string(status),
+		MerchantId:         auth.MerchantID(ctx),
+		Status:             StatusPending,
 		CardNumberLastFour: lastFour,
+		CardScheme:         card.DetectScheme(req.CardNumber),
 		ExpiryMonth:        req.ExpiryMonth,
 		ExpiryYear:         req.ExpiryYear,
 		Currency:           req.Currency,
 		Amount:             req.Amount,
-		AuthorizationCode:  bankResp.AuthorizationCode,
+		Reference:          req.Reference,
+		CreatedAt:          p.now().UTC().Truncate(time.Microsecond),
 	}
 
-	// Store payment
-	paymentErr := p.storage.AddPayment(ctx, payment)
-	if paymentErr != nil {
-		return nil, fmt.Errorf("failed to store payment: %v", paymentErr)
+	if err := p.storage.AddPayment(ctx, payment); err != nil {
+		return nil, fmt.Errorf("failed to store payment: %w", err)
 	}
 
-	return &models.PaymentResponse{
-		Id:                 paymentID,
-		Status:             string(status),
-		CardNumberLastFour: lastFour,
-		ExpiryMonth:        req.ExpiryMonth,
-		ExpiryYear:         req.ExpiryYear,
-		Currency:           req.Currency,
-		Amount:             req.Amount,
-	}, nil
+	// Process payment with bank
+	bankResp, bankErr := p.bankClient.ProcessPayment(ctx, paymentID, req)
+	if bank.OutcomeUnknown(bankErr) {
+		// The bank may have received the payment, so its outcome is left to the reconciler
+		p.observePayment(ctx, payment)
+		return toPaymentResponse(payment), nil
+	}
+	if bankErr != nil {
+		// Nothing was authorized, so there is nothing left for the reconciler to find
+		p.voidUnsent(context.WithoutCancel(ctx), payment)
+		return nil, fmt.Errorf("%w: %w", models.ErrAcquirerFailed, bankErr)
+	}
+
+	pending := payment
+	if err := payment.Settle(bankResp.Acquirer, bankResp.Authorized, bankResp.AuthorizationCode, bankResp.DeclineReason); err != nil {
+		return nil, err
+	}
+
+	// The authorization has to be recorded even if the merchant went away meanwhile
+	event := statusChangeEvent(p.events, payment, p.now())
+	if err := p.storage.UpdatePayment(context.WithoutCancel(ctx), payment, event); err != nil {
+		// The bank has answered, so failing the request would let a retry charge
+		// the card again. The stored payment is still pending and the reconciler
+		// settles it from the bank's answer.
+		slog.ErrorContext(ctx, "failed to store bank outcome", slog.String("payment_id", payment.Id), slog.Any("error", err))
+		p.observePayment(ctx, pending)
+		return toPaymentResponse(pending), nil
+	}
+	publishStatusChange(context.WithoutCancel(ctx), p.storage, p.events, event)
+	p.observePayment(ctx, payment)
+
+	return toPaymentResponse(payment), nil
+}
+
+// voidUnsent voids a pending payment the bank did not authorize. The merchant
+// is answered with an error and never learns its ID, so no event is published.
+// If the void cannot be stored the reconciler voids the payment once it expires.
+func (p *paymentService) voidUnsent(ctx context.Context, payment models.Payment) {
+	op, err := payment.VoidPending(uuid.New().String(), p.now().UTC())
+	if err == nil {
+		err = p.storage.UpdatePayment(ctx, payment, nil, op)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to void payment", slog.String("payment_id", payment.Id), slog.Any("error", err))
+	}
+}
+
+// observePayment records the outcome of a newly processed payment on the
+// current span and in the metrics.
+func (p *paymentService) observePayment(ctx context.Context, payment models.Payment) {
+	scheme := string(payment.CardScheme)
+	if scheme == "" {
+		scheme = "unknown"
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("payment.status", string(payment.Status)),
+		attribute.String("payment.card_scheme", scheme),
+		attribute.String("payment.acquirer", payment.Acquirer),
+	)
+	p.metrics.ObservePayment(string(payment.Status), payment.Currency, scheme)
+	if payment.Status == StatusDeclined {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("payment.decline_reason", string(payment.DeclineReason)))
+		p.metrics.ObserveDecline(payment.Acquirer, string(payment.DeclineReason))
+	}
 }
 
 func (p *paymentService) GetPayment(ctx context.Context, id string) (*models.PaymentResponse, error) {
 
-	payment := p.storage.GetPayment(ctx, id)
-	if payment == nil {
-		return nil, models.ErrPaymentNotFound
+	payment, err := p.getOwnedPayment(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 	// Convert internal payment to response format
-	response := models.PaymentResponse{
+	return toPaymentResponse(*payment), nil
+}
+
+// ListPayments returns a page of the authenticated merchant's payments
+// matching filter, newest first. cursor is the NextCursor of the previous page,
+// or empty for the first page.
+func (p *paymentService) ListPayments(ctx context.Context, filter models.PaymentFilter, cursor string, limit int) (*models.PaymentListResponse, error) {
+	if limit <= 0 {
+		limit = models.DefaultPageSize
+	}
+	if limit > models.MaxPageSize {
+		limit = models.MaxPageSize
+	}
+
+	query := models.PaymentQuery{PaymentFilter: filter, Limit: limit + 1}
+	query.MerchantId = auth.MerchantID(ctx)
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query.After = &after
+	}
+
+	// one payment more than asked for tells whether there is a next page
+	payments, err := p.storage.ListPayments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.PaymentListResponse{Data: []models.PaymentResponse{}}
+	if len(payments) > limit {
+		payments = payments[:limit]
+		last := payments[limit-1]
+		response.NextCursor = encodeCursor(models.PaymentCursor{CreatedAt: last.CreatedAt, Id: last.Id})
+	}
+	for _, payment := range payments {
+		response.Data = append(response.Data, *toPaymentResponse(payment))
+	}
+
+	return response, nil
+}
+
+// CapturePayment captures amount of an authorized payment, or everything left
+// to capture when amount is zero.
+func (p *paymentService) CapturePayment(ctx context.Context, id string, amount int) (*models.OperationResponse, error) {
+	return p.applyOperation(ctx, id, func(payment *models.Payment, opID string, now time.Time) (models.Operation, error) {
+		return payment.Capture(opID, amount, now)
+	})
+}
+
+// VoidPayment cancels an authorized payment before any funds are captured.
+func (p *paymentService) VoidPayment(ctx context.Context, id string) (*models.OperationResponse, error) {
+	return p.applyOperation(ctx, id, func(payment *models.Payment, opID string, now time.Time) (models.Operation, error) {
+		return payment.Void(opID, now)
+	})
+}
+
+// RefundPayment refunds amount of the captured funds, or everything left to
+// refund when amount is zero.
+func (p *paymentService) RefundPayment(ctx context.Context, id string, amount int) (*models.OperationResponse, error) {
+	return p.applyOperation(ctx, id, func(payment *models.Payment, opID string, now time.Time) (models.Operation, error) {
+		return payment.Refund(opID, amount, now)
+	})
+}
+
+// applyOperation loads the payment, runs transition against it and stores the
+// result. The acquiring bank simulator has no capture, void or refund API, so
+// these operations are only recorded by the gateway.
+func (p *paymentService) applyOperation(ctx context.Context, id string, transition func(*models.Payment, string, time.Time) (models.Operation, error)) (*models.OperationResponse, error) {
+	payment, err := p.getOwnedPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := transition(payment, uuid.New().String(), p.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	event := statusChangeEvent(p.events, *payment, p.now())
+	if err := p.storage.UpdatePayment(ctx, *payment, event, op); err != nil {
+		return nil, err
+	}
+	publishStatusChange(context.WithoutCancel(ctx), p.storage, p.events, event)
+
+	return toOperationResponse(op, payment.Currency, payment.Status), nil
+}
+
+// getOwnedPayment loads a payment belonging to the merchant authenticated in
+// ctx. Payments of other merchants are reported as not found so their IDs
+// cannot be probed.
+func (p *paymentService) getOwnedPayment(ctx context.Context, id string) (*models.Payment, error) {
+	payment, err := p.storage.GetPayment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if payment.MerchantId != auth.MerchantID(ctx) {
+		return nil, models.ErrPaymentNotFound
+	}
+	return payment, nil
+}
+
+// statusChangeEvent returns the event for the status payment just moved to,
+// or nil when there is no publisher or the status has no event. The event is
+// stored in the outbox together with the change it announces.
+func statusChangeEvent(events EventPublisher, payment models.Payment, at time.Time) *models.PaymentEvent {
+	if events == nil {
+		return nil
+	}
+	eventType, ok := models.EventTypeFor(payment.Status)
+	if !ok {
+		return nil
+	}
+
+	return &models.PaymentEvent{
+		Id:         uuid.New().String(),
+		Type:       eventType,
+		CreatedAt:  at.UTC().Truncate(time.Microsecond),
+		Data:       *toPaymentResponse(payment),
+		MerchantId: payment.MerchantId,
+	}
+}
+
+// publishStatusChange publishes an event stored in the outbox and removes it
+// from there. An event that fails to publish stays in the outbox, where the
+// EventRelay picks it up again, so a failure is only reported.
+func publishStatusChange(ctx context.Context, storage repository.PaymentsRepository, events EventPublisher, event *models.PaymentEvent) {
+	if event == nil {
+		return
+	}
+
+	err := events.Publish(ctx, *event)
+	if err == nil {
+		err = storage.MarkEventPublished(ctx, event.Id)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to publish payment event",
+			slog.String("event_id", event.Id),
+			slog.String("event_type", string(event.Type)),
+			slog.String("payment_id", event.Data.Id),
+			slog.Any("error", err))
+	}
+}
+
+func toPaymentResponse(payment models.Payment) *models.PaymentResponse {
+	response := &models.PaymentResponse{
 		Id:                 payment.Id,
 		Status:             payment.Status,
 		CardNumberLastFour: payment.CardNumberLastFour,
+		CardScheme:         payment.CardScheme,
 		ExpiryMonth:        payment.ExpiryMonth,
 		ExpiryYear:         payment.ExpiryYear,
 		Currency:           payment.Currency,
 		Amount:             payment.Amount,
+		AmountFormatted:    currency.FormatAmount(payment.Currency, payment.Amount),
+		DeclineReason:      payment.DeclineReason,
+		CapturedAmount:     payment.CapturedAmount,
+		RefundedAmount:     payment.RefundedAmount,
+		Reference:          payment.Reference,
+		CreatedAt:          payment.CreatedAt,
+	}
+
+	for _, op := range payment.Operations {
+		response.Operations = append(response.Operations, *toOperationResponse(op, payment.Currency, ""))
+	}
+
+	return response
+}
+
+func toOperationResponse(op models.Operation, currencyCode string, status Status) *models.OperationResponse {
+	return &models.OperationResponse{
+		Id:              op.Id,
+		PaymentId:       op.PaymentId,
+		Type:            op.Type,
+		Amount:          op.Amount,
+		AmountFormatted: currency.FormatAmount(currencyCode, op.Amount),
+		CreatedAt:       op.CreatedAt,
+		PaymentStatus:   status,
+	}
+}
+
+// encodeCursor makes an opaque page token out of the position of a payment.
+func encodeCursor(c models.PaymentCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.CreatedAt.UnixMicro(), 10) + ":" + c.Id))
+}
+
+func decodeCursor(cursor string) (models.PaymentCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return models.PaymentCursor{}, models.ErrInvalidCursor
+	}
+
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok || id == "" {
+		return models.PaymentCursor{}, models.ErrInvalidCursor
 	}
-	return &response, nil
+	createdAt, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return models.PaymentCursor{}, models.ErrInvalidCursor
+	}
+
+	return models.PaymentCursor{CreatedAt: time.UnixMicro(createdAt).UTC(), Id: id}, nil
 }
@@ -1 +1,371 @@
 package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubBank struct {
+	resp *bank.BankResponse
+	err  error
+}
+
+func (b *stubBank) ProcessPayment(ctx context.Context, reference string, req models.PaymentRequest) (*bank.BankResponse, error) {
+	return b.resp, b.err
+}
+
+func (b *stubBank) QueryPayment(ctx context.Context, reference string) (*bank.BankResponse, error) {
+	return b.resp, b.err
+}
+
+// failingUpdates is a payments store that cannot store changes of payments.
+type failingUpdates struct {
+	repository.PaymentsRepository
+}
+
+func (failingUpdates) UpdatePayment(ctx context.Context, payment models.Payment, event *models.PaymentEvent, ops ...models.Operation) error {
+	return errors.New("database unavailable")
+}
+
+func newTestPaymentService(t *testing.T, authorized bool) PaymentService {
+	t.Helper()
+	return NewPaymentService(repository.NewPaymentsRepository(), &stubBank{
+		resp: &bank.BankResponse{Authorized: authorized, AuthorizationCode: "auth-code"},
+	})
+}
+
+var testPaymentRequest = models.PaymentRequest{
+	CardNumber:  "2222405343248877",
+	ExpiryMonth: 4,
+	ExpiryYear:  2035,
+	Currency:    "GBP",
+	Amount:      1000,
+	Cvv:         "123",
+}
+
+func TestCreatePayment(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("authorized", func(t *testing.T) {
+		svc := newTestPaymentService(t, true)
+
+		created, err := svc.CreatePayment(ctx, testPaymentRequest)
+		require.NoError(t, err)
+		assert.Equal(t, StatusAuthorized, created.Status)
+		assert.Equal(t, "8877", created.CardNumberLastFour)
+		assert.Equal(t, card.SchemeMastercard, created.CardScheme)
+		assert.Equal(t, "10.00", created.AmountFormatted)
+
+		got, err := svc.GetPayment(ctx, created.Id)
+		require.NoError(t, err)
+		assert.Equal(t, created, got)
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		svc := NewPaymentService(repository.NewPaymentsRepository(), &stubBank{
+			resp: &bank.BankResponse{Authorized: false, DeclineReason: models.DeclineInsufficientFunds},
+		})
+
+		created, err := svc.CreatePayment(ctx, testPaymentRequest)
+		require.NoError(t, err)
+		assert.Equal(t, StatusDeclined, created.Status)
+		assert.Equal(t, models.DeclineInsufficientFunds, created.DeclineReason)
+
+		got, err := svc.GetPayment(ctx, created.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeclineInsufficientFunds, got.DeclineReason)
+	})
+
+	t.Run("unknown bank outcome leaves payment pending", func(t *testing.T) {
+		for _, bankErr := range []error{
+			context.DeadlineExceeded,
+			errors.New("bank returned error status 500: {}"),
+			errors.New("failed to unmarshal response"),
+		} {
+			svc := NewPaymentService(repository.NewPaymentsRepository(), &stubBank{err: bankErr})
+
+			created, err := svc.CreatePayment(ctx, testPaymentRequest)
+			require.NoError(t, err, bankErr)
+			assert.Equal(t, StatusPending, created.Status, bankErr)
+
+			got, err := svc.GetPayment(ctx, created.Id)
+			require.NoError(t, err)
+			assert.Equal(t, StatusPending, got.Status, bankErr)
+		}
+	})
+
+	t.Run("bank failure voids payment", func(t *testing.T) {
+		for _, bankErr := range []error{models.ErrAcquirerUnavailable, bank.ErrBankUnavailable, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}} {
+			repo := repository.NewPaymentsRepository()
+			svc := NewPaymentService(repo, &stubBank{err: bankErr})
+
+			_, err := svc.CreatePayment(ctx, testPaymentRequest)
+			assert.ErrorIs(t, err, bankErr)
+			assert.ErrorIs(t, err, models.ErrAcquirerFailed)
+
+			pending, err := repo.PendingPayments(ctx, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+			assert.Empty(t, pending, bankErr)
+
+			payments, err := svc.ListPayments(ctx, models.PaymentFilter{}, "", 10)
+			require.NoError(t, err)
+			require.Len(t, payments.Data, 1)
+			assert.Equal(t, StatusVoided, payments.Data[0].Status)
+		}
+	})
+
+	t.Run("failure to store bank outcome leaves payment pending", func(t *testing.T) {
+		repo := repository.NewPaymentsRepository()
+		svc := NewPaymentService(failingUpdates{repo}, &stubBank{
+			resp: &bank.BankResponse{Authorized: true, AuthorizationCode: "auth-code"},
+		})
+
+		created, err := svc.CreatePayment(ctx, testPaymentRequest)
+		require.NoError(t, err)
+		assert.Equal(t, StatusPending, created.Status)
+
+		// the reconciler settles the payment from the bank's answer
+		pending, err := repo.PendingPayments(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, created.Id, pending[0].Id)
+	})
+
+	t.Run("unknown payment", func(t *testing.T) {
+		svc := newTestPaymentService(t, true)
+
+		_, err := svc.GetPayment(ctx, "missing")
+		assert.ErrorIs(t, err, models.ErrPaymentNotFound)
+	})
+
+	t.Run("payment of another merchant", func(t *testing.T) {
+		svc := newTestPaymentService(t, true)
+
+		created, err := svc.CreatePayment(auth.WithMerchantID(ctx, "merchant-1"), testPaymentRequest)
+		require.NoError(t, err)
+
+		_, err = svc.GetPayment(auth.WithMerchantID(ctx, "merchant-1"), created.Id)
+		assert.NoError(t, err)
+
+		_, err = svc.GetPayment(auth.WithMerchantID(ctx, "merchant-2"), created.Id)
+		assert.ErrorIs(t, err, models.ErrPaymentNotFound)
+
+		_, err = svc.VoidPayment(auth.WithMerchantID(ctx, "merchant-2"), created.Id)
+		assert.ErrorIs(t, err, models.ErrPaymentNotFound)
+	})
+}
+
+func TestPaymentOperations(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("capture and refund", func(t *testing.T) {
+		svc := newTestPaymentService(t, true)
+		created, err := svc.CreatePayment(ctx, testPaymentRequest)
+		require.NoError(t, err)
+
+		capture, err := svc.CapturePayment(ctx, created.Id, 600)
+		require.NoError(t, err)
+		assert.Equal(t, models.OperationCapture, capture.Type)
+		assert.Equal(t, StatusPartiallyCaptured, capture.PaymentStatus)
+		assert.NotEmpty(t, capture.Id)
+
+		refund, err := svc.RefundPayment(ctx, created.Id, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 600, refund.Amount)
+		assert.Equal(t, StatusRefunded, refund.PaymentStatus)
+
+		got, err := svc.GetPayment(ctx, created.Id)
+		require.NoError(t, err)
+		assert.Equal(t, StatusRefunded, got.Status)
+		assert.Equal(t, 600, got.CapturedAmount)
+		assert.Equal(t, 600, got.RefundedAmount)
+		require.Len(t, got.Operations, 2)
+		assert.Equal(t, capture.Id, got.Operations[0].Id)
+		assert.Equal(t, refund.Id, got.Operations[1].Id)
+	})
+
+	t.Run("void pending payment", func(t *testing.T) {
+		svc := NewPaymentService(repository.NewPaymentsRepository(), &stubBank{err: context.DeadlineExceeded})
+		created, err := svc.CreatePayment(ctx, testPaymentRequest)
+		require.NoError(t, err)
+		require.Equal(t, StatusPending, created.Status)
+
+		// the bank may still authorize it, so only the reconciler voids it
+		_, err = svc.VoidPayment(ctx, created.Id)
+		assert.ErrorIs(t, err, models.ErrInvalidTransition)
+	})
+
+	t.Run("refund voided payment", func(t *testing.T) {
+		svc := newTestPaymentService(t, true)
+		created, err := svc.CreatePayment(ctx, testPaymentRequest)
+		require.NoError(t, err)
+
+		_, err = svc.VoidPayment(ctx, created.Id)
+		require.NoError(t, err)
+
+		_, err = svc.RefundPayment(ctx, created.Id, 100)
+		assert.ErrorIs(t, err, models.ErrInvalidTransition)
+	})
+
+	t.Run("capture declined payment", func(t *testing.T) {
+		svc := newTestPaymentService(t, false)
+		created, err := svc.CreatePayment(ctx, testPaymentRequest)
+		require.NoError(t, err)
+
+		_, err = svc.CapturePayment(ctx, created.Id, 0)
+		assert.ErrorIs(t, err, models.ErrInvalidTransition)
+	})
+
+	t.Run("unknown payment", func(t *testing.T) {
+		svc := newTestPaymentService(t, true)
+
+		_, err := svc.VoidPayment(ctx, "missing")
+		assert.ErrorIs(t, err, models.ErrPaymentNotFound)
+	})
+}
+
+func TestListPayments(t *testing.T) {
+	merchant := auth.WithMerchantID(context.Background(), "merchant-1")
+
+	svc := newTestPaymentService(t, true).(*paymentService)
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return clock }
+
+	// two payments share a timestamp to check that ties are ordered too
+	var ids []string
+	for i := 0; i < 5; i++ {
+		if i != 3 {
+			clock = clock.Add(time.Second)
+		}
+		req := testPaymentRequest
+		req.Reference = fmt.Sprintf("order-%d", i)
+		created, err := svc.CreatePayment(merchant, req)
+		require.NoError(t, err)
+		ids = append(ids, created.Id)
+	}
+	_, err := svc.CreatePayment(auth.WithMerchantID(context.Background(), "merchant-2"), testPaymentRequest)
+	require.NoError(t, err)
+
+	t.Run("pages through all payments newest first", func(t *testing.T) {
+		var (
+			got    []models.PaymentResponse
+			cursor string
+			pages  int
+		)
+		for {
+			page, err := svc.ListPayments(merchant, models.PaymentFilter{}, cursor, 2)
+			require.NoError(t, err)
+			got = append(got, page.Data...)
+			pages++
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+
+		assert.Equal(t, 3, pages)
+		require.Len(t, got, 5)
+		for i := 1; i < len(got); i++ {
+			assert.False(t, got[i].CreatedAt.After(got[i-1].CreatedAt))
+		}
+		assert.ElementsMatch(t, ids, []string{got[0].Id, got[1].Id, got[2].Id, got[3].Id, got[4].Id})
+	})
+
+	t.Run("filters by reference", func(t *testing.T) {
+		page, err := svc.ListPayments(merchant, models.PaymentFilter{Reference: "order-2"}, "", 0)
+		require.NoError(t, err)
+		require.Len(t, page.Data, 1)
+		assert.Equal(t, ids[2], page.Data[0].Id)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("rejects malformed cursor", func(t *testing.T) {
+		_, err := svc.ListPayments(merchant, models.PaymentFilter{}, "not a cursor", 2)
+		assert.ErrorIs(t, err, models.ErrInvalidCursor)
+	})
+}
+
+type recordingPublisher struct {
+	events []models.PaymentEvent
+	err    error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event models.PaymentEvent) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func TestPaymentEvents(t *testing.T) {
+	ctx := auth.WithMerchantID(context.Background(), "merchant-1")
+	events := &recordingPublisher{}
+	repo := repository.NewPaymentsRepository()
+	svc := NewPaymentService(repo, &stubBank{
+		resp: &bank.BankResponse{Authorized: true, AuthorizationCode: "auth-code"},
+	}, WithEventPublisher(events))
+
+	created, err := svc.CreatePayment(ctx, testPaymentRequest)
+	require.NoError(t, err)
+	_, err = svc.CapturePayment(ctx, created.Id, 400)
+	require.NoError(t, err)
+	_, err = svc.RefundPayment(ctx, created.Id, 0)
+	require.NoError(t, err)
+
+	var types []models.EventType
+	for _, event := range events.events {
+		types = append(types, event.Type)
+		assert.NotEmpty(t, event.Id)
+		assert.Equal(t, "merchant-1", event.MerchantId)
+		assert.Equal(t, created.Id, event.Data.Id)
+	}
+	assert.Equal(t, []models.EventType{models.EventPaymentAuthorized, models.EventPaymentCaptured, models.EventPaymentRefunded}, types)
+	assert.Equal(t, StatusPartiallyCaptured, events.events[1].Data.Status)
+	assert.Equal(t, StatusRefunded, events.events[2].Data.Status)
+
+	unpublished, err := repo.UnpublishedEvents(ctx, time.Now().Add(time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, unpublished, "published events leave the outbox")
+
+	t.Run("failed publish stays in the outbox", func(t *testing.T) {
+		events := &recordingPublisher{err: errors.New("webhook store unavailable")}
+		repo := repository.NewPaymentsRepository()
+		svc := NewPaymentService(repo, &stubBank{
+			resp: &bank.BankResponse{Authorized: true, AuthorizationCode: "auth-code"},
+		}, WithEventPublisher(events))
+
+		created, err := svc.CreatePayment(ctx, testPaymentRequest)
+		require.NoError(t, err)
+
+		unpublished, err := repo.UnpublishedEvents(ctx, time.Now().Add(time.Hour), 10)
+		require.NoError(t, err)
+		require.Len(t, unpublished, 1)
+		assert.Equal(t, models.EventPaymentAuthorized, unpublished[0].Type)
+		assert.Equal(t, created.Id, unpublished[0].Data.Id)
+		assert.Equal(t, "merchant-1", unpublished[0].MerchantId)
+	})
+
+	t.Run("pending payments publish nothing", func(t *testing.T) {
+		events := &recordingPublisher{}
+		svc := NewPaymentService(repository.NewPaymentsRepository(), &stubBank{err: context.DeadlineExceeded}, WithEventPublisher(events))
+
+		created, err := svc.CreatePayment(ctx, testPaymentRequest)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusPending, created.Status)
+		assert.Empty(t, events.events)
+	})
+}
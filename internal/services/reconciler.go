@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ReconcilerConfig controls how pending payments are settled
+type ReconcilerConfig struct {
+	// Interval is the time between two reconciliation passes
+	Interval time.Duration
+	// MinAge is how old a pending payment must be before the bank is queried,
+	// so that payments whose bank call is still in flight are left alone
+	MinAge time.Duration
+	// VoidAfter is how long a payment may stay pending before it is voided
+	VoidAfter time.Duration
+}
+
+// DefaultReconcilerConfig queries the bank every minute and voids payments
+// that are still unresolved after an hour.
+var DefaultReconcilerConfig = ReconcilerConfig{
+	Interval:  time.Minute,
+	MinAge:    time.Minute,
+	VoidAfter: time.Hour,
+}
+
+// ReconcileResult counts what a reconciliation pass did
+type ReconcileResult struct {
+	Settled    int
+	Voided     int
+	Unresolved int
+}
+
+// Reconciler settles payments left pending when the bank call failed without
+// a definitive answer, by querying the bank for the outcome.
+type Reconciler struct {
+	storage    repository.PaymentsRepository
+	bankClient bank.Bank
+	events     EventPublisher
+	cfg        ReconcilerConfig
+	now        func() time.Time
+}
+
+// NewReconciler returns a Reconciler publishing status changes to events,
+// which may be nil.
+func NewReconciler(repo repository.PaymentsRepository, bankClient bank.Bank, events EventPublisher, cfg ReconcilerConfig) *Reconciler {
+	return &Reconciler{
+		storage:    repo,
+		bankClient: bankClient,
+		events:     events,
+		cfg:        cfg,
+		now:        time.Now,
+	}
+}
+
+// Run reconciles pending payments every cfg.Interval until ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := r.Reconcile(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "reconciliation failed", slog.Any("error", err))
+			}
+			if result.Settled > 0 || result.Voided > 0 {
+				slog.InfoContext(ctx, "reconciled pending payments",
+					slog.Int("settled", result.Settled),
+					slog.Int("voided", result.Voided),
+					slog.Int("unresolved", result.Unresolved))
+			}
+		}
+	}
+}
+
+// Reconcile makes a single pass over the pending payments. Payments the bank
+// answered for are settled to Authorized or Declined, and payments still
+// unresolved after cfg.VoidAfter are voided. It carries on past failures of
+// single payments and returns them joined.
+func (r *Reconciler) Reconcile(ctx context.Context) (ReconcileResult, error) {
+	var result ReconcileResult
+
+	now := r.now().UTC()
+	pending, err := r.storage.PendingPayments(ctx, now.Add(-r.cfg.MinAge))
+	if err != nil {
+		return result, err
+	}
+
+	var errs []error
+	for _, payment := range pending {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		bankResp, err := r.bankClient.QueryPayment(ctx, payment.Id)
+		switch {
+		case err == nil:
+			if err := payment.Settle(bankResp.Acquirer, bankResp.Authorized, bankResp.AuthorizationCode, bankResp.DeclineReason); err != nil {
+				errs = append(errs, fmt.Errorf("payment %s: %w", payment.Id, err))
+				continue
+			}
+			event := statusChangeEvent(r.events, payment, now)
+			if err := r.storage.UpdatePayment(ctx, payment, event); err != nil {
+				errs = append(errs, fmt.Errorf("payment %s: %w", payment.Id, err))
+				continue
+			}
+			publishStatusChange(ctx, r.storage, r.events, event)
+			result.Settled++
+		case now.Sub(payment.CreatedAt) >= r.cfg.VoidAfter:
+			var event *models.PaymentEvent
+			op, err := payment.VoidPending(uuid.New().String(), now)
+			if err == nil {
+				event = statusChangeEvent(r.events, payment, now)
+				err = r.storage.UpdatePayment(ctx, payment, event, op)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("payment %s: %w", payment.Id, err))
+				continue
+			}
+			publishStatusChange(ctx, r.storage, r.events, event)
+			result.Voided++
+		default:
+			if !errors.Is(err, bank.ErrBankPaymentNotFound) {
+				errs = append(errs, fmt.Errorf("payment %s: %w", payment.Id, err))
+			}
+			result.Unresolved++
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciler(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cfg := ReconcilerConfig{Interval: time.Minute, MinAge: time.Minute, VoidAfter: time.Hour}
+
+	newPending := func(t *testing.T, repo repository.PaymentsRepository, id string, age time.Duration) {
+		t.Helper()
+		require.NoError(t, repo.AddPayment(ctx, models.Payment{
+			Id:        id,
+			Status:    StatusPending,
+			Currency:  "GBP",
+			Amount:    1000,
+			CreatedAt: now.Add(-age),
+		}))
+	}
+
+	newReconciler := func(repo repository.PaymentsRepository, b bank.Bank) *Reconciler {
+		r := NewReconciler(repo, b, nil, cfg)
+		r.now = func() time.Time { return now }
+		return r
+	}
+
+	t.Run("settles payments the bank answered for", func(t *testing.T) {
+		repo := repository.NewPaymentsRepository()
+		newPending(t, repo, "authorized", 5*time.Minute)
+
+		r := newReconciler(repo, &stubBank{resp: &bank.BankResponse{Authorized: true, AuthorizationCode: "auth-code"}})
+		result, err := r.Reconcile(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ReconcileResult{Settled: 1}, result)
+
+		payment, err := repo.GetPayment(ctx, "authorized")
+		require.NoError(t, err)
+		assert.Equal(t, StatusAuthorized, payment.Status)
+		assert.Equal(t, "auth-code", payment.AuthorizationCode)
+	})
+
+	t.Run("settles declined payments", func(t *testing.T) {
+		repo := repository.NewPaymentsRepository()
+		newPending(t, repo, "declined", 5*time.Minute)
+
+		r := newReconciler(repo, &stubBank{resp: &bank.BankResponse{Authorized: false, DeclineReason: models.DeclineExpiredCard}})
+		_, err := r.Reconcile(ctx)
+		require.NoError(t, err)
+
+		payment, err := repo.GetPayment(ctx, "declined")
+		require.NoError(t, err)
+		assert.Equal(t, StatusDeclined, payment.Status)
+		assert.Equal(t, models.DeclineExpiredCard, payment.DeclineReason)
+	})
+
+	t.Run("leaves recent payments alone", func(t *testing.T) {
+		repo := repository.NewPaymentsRepository()
+		newPending(t, repo, "in-flight", 10*time.Second)
+
+		r := newReconciler(repo, &stubBank{resp: &bank.BankResponse{Authorized: true}})
+		result, err := r.Reconcile(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ReconcileResult{}, result)
+	})
+
+	t.Run("keeps unresolved payments pending within the window", func(t *testing.T) {
+		repo := repository.NewPaymentsRepository()
+		newPending(t, repo, "unknown", 5*time.Minute)
+
+		r := newReconciler(repo, &stubBank{err: bank.ErrBankPaymentNotFound})
+		result, err := r.Reconcile(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ReconcileResult{Unresolved: 1}, result)
+
+		payment, err := repo.GetPayment(ctx, "unknown")
+		require.NoError(t, err)
+		assert.Equal(t, StatusPending, payment.Status)
+	})
+
+	t.Run("voids payments unresolved past the window", func(t *testing.T) {
+		repo := repository.NewPaymentsRepository()
+		newPending(t, repo, "expired", 2*time.Hour)
+
+		r := newReconciler(repo, &stubBank{err: bank.ErrBankUnavailable})
+		result, err := r.Reconcile(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, ReconcileResult{Voided: 1}, result)
+
+		payment, err := repo.GetPayment(ctx, "expired")
+		require.NoError(t, err)
+		assert.Equal(t, StatusVoided, payment.Status)
+		require.Len(t, payment.Operations, 1)
+		assert.Equal(t, models.OperationVoid, payment.Operations[0].Type)
+	})
+
+	t.Run("reports bank failures", func(t *testing.T) {
+		repo := repository.NewPaymentsRepository()
+		newPending(t, repo, "unknown", 5*time.Minute)
+
+		r := newReconciler(repo, &stubBank{err: bank.ErrBankUnavailable})
+		result, err := r.Reconcile(ctx)
+		assert.ErrorIs(t, err, bank.ErrBankUnavailable)
+		assert.Equal(t, ReconcileResult{Unresolved: 1}, result)
+	})
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/vault"
+)
+
+const (
+	// CardTokensRotation names the progress record of the card token re-encryption.
+	CardTokensRotation = "card_tokens"
+	// PaymentsRotation names the progress record of the re-encryption of the
+	// payments' authorization codes.
+	PaymentsRotation = "payments"
+)
+
+// ReencryptorConfig controls how stored records are re-encrypted
+type ReencryptorConfig struct {
+	// BatchSize is the number of records read at once. Progress is saved
+	// after every batch.
+	BatchSize int
+}
+
+// DefaultReencryptorConfig saves progress every 100 records.
+var DefaultReencryptorConfig = ReencryptorConfig{
+	BatchSize: 100,
+}
+
+// Reencryptor moves the card tokens and the authorization codes sealed with
+// older versions of the vault key to the newest one, so that the older
+// versions can be retired. Authorization codes stored before a vault key was
+// configured are sealed on the way.
+type Reencryptor struct {
+	tokens    repository.CardTokensRepository
+	codes     repository.AuthorizationCodesRepository
+	rotations repository.KeyRotationsRepository
+	keyring   *vault.Keyring
+	cfg       ReencryptorConfig
+	now       func() time.Time
+}
+
+// NewReencryptor returns a Reencryptor of tokens and codes. codes is nil when
+// the payments store keeps nothing at rest.
+func NewReencryptor(tokens repository.CardTokensRepository, codes repository.AuthorizationCodesRepository, rotations repository.KeyRotationsRepository, keyring *vault.Keyring, cfg ReencryptorConfig) *Reencryptor {
+	return &Reencryptor{
+		tokens:    tokens,
+		codes:     codes,
+		rotations: rotations,
+		keyring:   keyring,
+		cfg:       cfg,
+		now:       time.Now,
+	}
+}
+
+// Run re-encrypts every card token, then every authorization code, not
+// sealed with the primary key of the keyring, and returns the rotations it
+// completed. It stops at the first rotation that fails and returns it too.
+func (r *Reencryptor) Run(ctx context.Context) ([]models.KeyRotation, error) {
+	rotation, err := r.rotate(ctx, CardTokensRotation, r.reencryptTokens)
+	if err != nil || r.codes == nil {
+		return []models.KeyRotation{rotation}, err
+	}
+
+	codesRotation, err := r.rotate(ctx, PaymentsRotation, r.reencryptCodes)
+	return []models.KeyRotation{rotation, codesRotation}, err
+}
+
+// rotate runs the rotation called name in record ID order, calling batch
+// until it reads fewer than cfg.BatchSize records. batch moves the records
+// after rotation.LastID and counts them in rotation as it goes. When the
+// previous run of the rotation for the same key was interrupted, rotate
+// resumes after the last record it saved as done.
+func (r *Reencryptor) rotate(ctx context.Context, name string, batch func(ctx context.Context, rotation *models.KeyRotation) (int, error)) (rotation models.KeyRotation, err error) {
+	target := r.keyring.Primary()
+
+	previous, err := r.rotations.GetRotation(ctx, name)
+	switch {
+	case err == nil && previous.KeyID == target && !previous.Completed:
+		rotation = *previous
+		slog.InfoContext(ctx, "resuming re-encryption",
+			slog.String("rotation", name),
+			slog.String("key_id", target),
+			slog.String("after", rotation.LastID),
+			slog.Int("reencrypted", rotation.Reencrypted))
+	case err == nil || errors.Is(err, models.ErrKeyRotationNotFound):
+		rotation = models.KeyRotation{Name: name, KeyID: target, StartedAt: r.now().UTC()}
+	default:
+		return rotation, err
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		// keep what was done even when the run is cancelled
+		rotation.UpdatedAt = r.now().UTC()
+		if saveErr := r.rotations.SaveRotation(context.WithoutCancel(ctx), rotation); saveErr != nil {
+			slog.ErrorContext(ctx, "failed to save re-encryption progress", slog.Any("error", saveErr))
+		}
+	}()
+
+	for !rotation.Completed {
+		n, err := batch(ctx, &rotation)
+		if err != nil {
+			return rotation, err
+		}
+
+		rotation.Completed = n < r.cfg.BatchSize
+		rotation.UpdatedAt = r.now().UTC()
+		if err := r.rotations.SaveRotation(ctx, rotation); err != nil {
+			return rotation, err
+		}
+	}
+
+	slog.InfoContext(ctx, "re-encryption completed",
+		slog.String("rotation", name),
+		slog.String("key_id", target),
+		slog.Int("reencrypted", rotation.Reencrypted))
+	return rotation, nil
+}
+
+// reencryptTokens moves a batch of card tokens to rotation.KeyID.
+func (r *Reencryptor) reencryptTokens(ctx context.Context, rotation *models.KeyRotation) (int, error) {
+	tokens, err := r.tokens.ListTokens(ctx, rotation.LastID, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, token := range tokens {
+		if token.KeyID != rotation.KeyID {
+			if err := r.reencryptToken(ctx, token); err != nil {
+				return 0, err
+			}
+			rotation.Reencrypted++
+		}
+		rotation.LastID = token.Id
+	}
+	return len(tokens), nil
+}
+
+func (r *Reencryptor) reencryptToken(ctx context.Context, token models.CardToken) error {
+	env, err := r.keyring.Reseal(vault.Envelope{
+		KeyID:      token.KeyID,
+		DataKey:    token.DataKey,
+		Ciphertext: token.EncryptedCardNumber,
+	}, tokenAdditionalData(token))
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt card token %s: %w", token.Id, err)
+	}
+
+	token.KeyID, token.DataKey, token.EncryptedCardNumber = env.KeyID, env.DataKey, env.Ciphertext
+	return r.tokens.UpdateTokenKey(ctx, token)
+}
+
+// reencryptCodes moves a batch of authorization codes to rotation.KeyID.
+func (r *Reencryptor) reencryptCodes(ctx context.Context, rotation *models.KeyRotation) (int, error) {
+	codes, err := r.codes.ListAuthorizationCodes(ctx, rotation.LastID, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, code := range codes {
+		if code.KeyID != rotation.KeyID {
+			if err := r.reencryptCode(ctx, code); err != nil {
+				return 0, err
+			}
+			rotation.Reencrypted++
+		}
+		rotation.LastID = code.PaymentId
+	}
+	return len(codes), nil
+}
+
+// reencryptCode seals code with the primary key, bound to the ID of its
+// payment like the payments store does.
+func (r *Reencryptor) reencryptCode(ctx context.Context, code models.SealedAuthorizationCode) error {
+	var (
+		env vault.Envelope
+		err error
+	)
+	if code.KeyID == "" {
+		env, err = r.keyring.Seal(code.Ciphertext, []byte(code.PaymentId))
+	} else {
+		env, err = r.keyring.Reseal(vault.Envelope{
+			KeyID:      code.KeyID,
+			DataKey:    code.DataKey,
+			Ciphertext: code.Ciphertext,
+		}, []byte(code.PaymentId))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt authorization code of payment %s: %w", code.PaymentId, err)
+	}
+
+	code.KeyID, code.DataKey, code.Ciphertext = env.KeyID, env.DataKey, env.Ciphertext
+	return r.codes.UpdateAuthorizationCode(ctx, code)
+}
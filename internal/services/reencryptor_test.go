@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingTokens fails UpdateTokenKey once it has succeeded failAfter times.
+type failingTokens struct {
+	repository.CardTokensRepository
+	failAfter int
+}
+
+func (r *failingTokens) UpdateTokenKey(ctx context.Context, token models.CardToken) error {
+	if r.failAfter == 0 {
+		return errors.New("connection reset")
+	}
+	r.failAfter--
+	return r.CardTokensRepository.UpdateTokenKey(ctx, token)
+}
+
+func TestReencryptor(t *testing.T) {
+	merchant := auth.WithMerchantID(context.Background(), "merchant-1")
+	key := func(b byte) []byte { return bytes.Repeat([]byte{b}, vault.KeySize) }
+
+	v1, err := vault.NewKeyring("v1", map[string][]byte{"v1": key(1)})
+	require.NoError(t, err)
+	v2, err := vault.NewKeyring("v2", map[string][]byte{"v1": key(1), "v2": key(2)})
+	require.NoError(t, err)
+	onlyV2, err := vault.NewKeyring("v2", map[string][]byte{"v2": key(2)})
+	require.NoError(t, err)
+
+	tokensRepo := repository.NewCardTokensRepository()
+	var tokens []string
+	for i := 0; i < 5; i++ {
+		keyring := v1
+		if i == 2 {
+			keyring = v2
+		}
+		created, err := NewTokenService(tokensRepo, keyring).Tokenize(merchant, testCardTokenRequest)
+		require.NoError(t, err)
+		tokens = append(tokens, created.Token)
+	}
+
+	rotations := repository.NewKeyRotationsRepository()
+	cfg := ReencryptorConfig{BatchSize: 2}
+
+	// the first run stops after re-encrypting two tokens
+	_, err = NewReencryptor(&failingTokens{CardTokensRepository: tokensRepo, failAfter: 2}, nil, rotations, v2, cfg).Run(merchant)
+	require.Error(t, err)
+
+	saved, err := rotations.GetRotation(merchant, CardTokensRotation)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", saved.KeyID)
+	assert.Equal(t, 2, saved.Reencrypted)
+	assert.False(t, saved.Completed)
+
+	// the next one resumes and re-encrypts the rest
+	done, err := NewReencryptor(tokensRepo, nil, rotations, v2, cfg).Run(merchant)
+	require.NoError(t, err)
+	require.Len(t, done, 1, "there are no authorization codes to re-encrypt")
+	rotation := done[0]
+	assert.True(t, rotation.Completed)
+	assert.Equal(t, 4, rotation.Reencrypted, "the token already under v2 is left alone")
+	assert.Equal(t, saved.StartedAt, rotation.StartedAt)
+
+	detokenizer := NewTokenService(tokensRepo, onlyV2)
+	for _, token := range tokens {
+		stored, err := tokensRepo.GetToken(merchant, token)
+		require.NoError(t, err)
+		assert.Equal(t, "v2", stored.KeyID, "token %s", token)
+
+		req, err := detokenizer.Detokenize(merchant, models.PaymentRequest{Source: token})
+		require.NoError(t, err)
+		assert.Equal(t, testCardTokenRequest.CardNumber, req.CardNumber)
+	}
+
+	// a run after a completed one starts over and finds nothing to do
+	done, err = NewReencryptor(tokensRepo, nil, rotations, v2, cfg).Run(merchant)
+	require.NoError(t, err)
+	assert.True(t, done[0].Completed)
+	assert.Equal(t, 0, done[0].Reencrypted)
+}
+
+func TestReencryptorAuthorizationCodes(t *testing.T) {
+	ctx := context.Background()
+	key := func(b byte) []byte { return bytes.Repeat([]byte{b}, vault.KeySize) }
+
+	v1, err := vault.NewKeyring("v1", map[string][]byte{"v1": key(1)})
+	require.NoError(t, err)
+	v2, err := vault.NewKeyring("v2", map[string][]byte{"v1": key(1), "v2": key(2)})
+	require.NoError(t, err)
+	onlyV2, err := vault.NewKeyring("v2", map[string][]byte{"v2": key(2)})
+	require.NoError(t, err)
+
+	db, err := repository.OpenDatabase(ctx, repository.DriverSQLite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// codes stored without a vault key, under v1 and under v2
+	for i, keyring := range []*vault.Keyring{nil, v1, v2, nil} {
+		require.NoError(t, repository.NewSQLPaymentsRepository(db, keyring).AddPayment(ctx, models.Payment{
+			Id:                fmt.Sprintf("payment-%d", i),
+			AuthorizationCode: fmt.Sprintf("code-%d", i),
+		}))
+	}
+
+	rotations := repository.NewKeyRotationsRepository()
+	done, err := NewReencryptor(repository.NewCardTokensRepository(), repository.NewSQLAuthorizationCodesRepository(db), rotations, v2, ReencryptorConfig{BatchSize: 3}).Run(ctx)
+	require.NoError(t, err)
+	require.Len(t, done, 2)
+	assert.Equal(t, PaymentsRotation, done[1].Name)
+	assert.True(t, done[1].Completed)
+	assert.Equal(t, 3, done[1].Reencrypted, "the code already under v2 is left alone")
+
+	saved, err := rotations.GetRotation(ctx, PaymentsRotation)
+	require.NoError(t, err)
+	assert.Equal(t, done[1], *saved)
+
+	payments := repository.NewSQLPaymentsRepository(db, onlyV2)
+	for i := 0; i < 4; i++ {
+		payment, err := payments.GetPayment(ctx, fmt.Sprintf("payment-%d", i))
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("code-%d", i), payment.AuthorizationCode)
+	}
+}
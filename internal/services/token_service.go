@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/utils"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/vault"
+)
+
+// CardTokenPrefix starts every card token.
+const CardTokenPrefix = "tok_"
+
+type TokenService interface {
+	// Tokenize stores the card in the vault for the authenticated merchant and
+	// returns the token standing for it.
+	Tokenize(ctx context.Context, req models.CardTokenRequest) (*models.CardTokenResponse, error)
+	// Detokenize returns req with the card fields filled from the vault for
+	// its source token. It returns models.ErrCardTokenNotFound if the token
+	// does not belong to the authenticated merchant, and models.ErrCardExpired
+	// once the card has expired.
+	Detokenize(ctx context.Context, req models.PaymentRequest) (models.PaymentRequest, error)
+}
+
+type tokenService struct {
+	storage repository.CardTokensRepository
+	keyring *vault.Keyring
+	now     func() time.Time
+}
+
+func NewTokenService(repo repository.CardTokensRepository, keyring *vault.Keyring) TokenService {
+	return &tokenService{
+		storage: repo,
+		keyring: keyring,
+		now:     time.Now,
+	}
+}
+
+func (s *tokenService) Tokenize(ctx context.Context, req models.CardTokenRequest) (_ *models.CardTokenResponse, err error) {
+	ctx, span := tracing.Start(ctx, "TokenService.Tokenize")
+	defer func() { tracing.End(span, err) }()
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate card token: %w", err)
+	}
+
+	token := models.CardToken{
+		Id:                 CardTokenPrefix + hex.EncodeToString(random),
+		MerchantId:         auth.MerchantID(ctx),
+		CardNumberLastFour: utils.GetLastFourDigits(req.CardNumber),
+		CardScheme:         card.DetectScheme(req.CardNumber),
+		ExpiryMonth:        req.ExpiryMonth,
+		ExpiryYear:         req.ExpiryYear,
+		CreatedAt:          s.now().UTC().Truncate(time.Microsecond),
+	}
+
+	env, err := s.keyring.Seal([]byte(req.CardNumber), tokenAdditionalData(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt card: %w", err)
+	}
+	token.KeyID, token.DataKey, token.EncryptedCardNumber = env.KeyID, env.DataKey, env.Ciphertext
+
+	if err := s.storage.AddToken(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &models.CardTokenResponse{
+		Token:              token.Id,
+		CardNumberLastFour: token.CardNumberLastFour,
+		CardScheme:         token.CardScheme,
+		ExpiryMonth:        token.ExpiryMonth,
+		ExpiryYear:         token.ExpiryYear,
+		CreatedAt:          token.CreatedAt,
+	}, nil
+}
+
+func (s *tokenService) Detokenize(ctx context.Context, req models.PaymentRequest) (_ models.PaymentRequest, err error) {
+	ctx, span := tracing.Start(ctx, "TokenService.Detokenize")
+	defer func() { tracing.End(span, err) }()
+
+	token, err := s.storage.GetToken(ctx, req.Source)
+	if err != nil {
+		return req, err
+	}
+	if token.MerchantId != auth.MerchantID(ctx) {
+		return req, models.ErrCardTokenNotFound
+	}
+	if token.Expired(s.now()) {
+		return req, models.ErrCardExpired
+	}
+
+	cardNumber, err := s.keyring.Open(vault.Envelope{
+		KeyID:      token.KeyID,
+		DataKey:    token.DataKey,
+		Ciphertext: token.EncryptedCardNumber,
+	}, tokenAdditionalData(*token))
+	if err != nil {
+		return req, fmt.Errorf("failed to decrypt card token %s: %w", token.Id, err)
+	}
+
+	req.CardNumber = string(cardNumber)
+	req.ExpiryMonth = token.ExpiryMonth
+	req.ExpiryYear = token.ExpiryYear
+	return req, nil
+}
+
+// tokenAdditionalData binds the encrypted card number to its token and
+// merchant, so that it cannot be moved to another record.
+func tokenAdditionalData(token models.CardToken) []byte {
+	return []byte(token.Id + "\x00" + token.MerchantId)
+}
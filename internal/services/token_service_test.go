@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTokenService(t *testing.T, repo repository.CardTokensRepository) *tokenService {
+	t.Helper()
+	keyring, err := vault.NewKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, vault.KeySize)})
+	require.NoError(t, err)
+	return NewTokenService(repo, keyring).(*tokenService)
+}
+
+var testCardTokenRequest = models.CardTokenRequest{
+	CardNumber:  "2222405343248877",
+	ExpiryMonth: 4,
+	ExpiryYear:  2035,
+}
+
+func TestTokenService(t *testing.T) {
+	merchant := auth.WithMerchantID(context.Background(), "merchant-1")
+	other := auth.WithMerchantID(context.Background(), "merchant-2")
+
+	repo := repository.NewCardTokensRepository()
+	svc := newTestTokenService(t, repo)
+
+	created, err := svc.Tokenize(merchant, testCardTokenRequest)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Token, CardTokenPrefix))
+	assert.Equal(t, "8877", created.CardNumberLastFour)
+	assert.Equal(t, card.SchemeMastercard, created.CardScheme)
+
+	t.Run("stores the card number encrypted", func(t *testing.T) {
+		stored, err := repo.GetToken(merchant, created.Token)
+		require.NoError(t, err)
+		assert.Equal(t, "merchant-1", stored.MerchantId)
+		assert.Equal(t, "k1", stored.KeyID)
+		assert.NotContains(t, string(stored.EncryptedCardNumber), testCardTokenRequest.CardNumber)
+	})
+
+	t.Run("detokenize fills the card fields", func(t *testing.T) {
+		req, err := svc.Detokenize(merchant, models.PaymentRequest{Source: created.Token, Currency: "GBP", Amount: 1000})
+		require.NoError(t, err)
+		assert.Equal(t, testCardTokenRequest.CardNumber, req.CardNumber)
+		assert.Equal(t, 4, req.ExpiryMonth)
+		assert.Equal(t, 2035, req.ExpiryYear)
+		assert.Equal(t, 1000, req.Amount)
+	})
+
+	t.Run("other merchant", func(t *testing.T) {
+		_, err := svc.Detokenize(other, models.PaymentRequest{Source: created.Token})
+		assert.ErrorIs(t, err, models.ErrCardTokenNotFound)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, err := svc.Detokenize(merchant, models.PaymentRequest{Source: "tok_unknown"})
+		assert.ErrorIs(t, err, models.ErrCardTokenNotFound)
+	})
+
+	t.Run("expired card", func(t *testing.T) {
+		svc := newTestTokenService(t, repo)
+		svc.now = func() time.Time { return time.Date(2035, time.May, 1, 0, 0, 0, 0, time.UTC) }
+
+		_, err := svc.Detokenize(merchant, models.PaymentRequest{Source: created.Token})
+		assert.ErrorIs(t, err, models.ErrCardExpired)
+	})
+
+	t.Run("record moved to another merchant", func(t *testing.T) {
+		stored, err := repo.GetToken(merchant, created.Token)
+		require.NoError(t, err)
+		stored.Id = "tok_moved"
+		stored.MerchantId = "merchant-2"
+		require.NoError(t, repo.AddToken(other, *stored))
+
+		_, err = svc.Detokenize(other, models.PaymentRequest{Source: "tok_moved"})
+		assert.ErrorIs(t, err, vault.ErrDecrypt)
+	})
+}
+
+func TestCreatePaymentWithCardToken(t *testing.T) {
+	merchant := auth.WithMerchantID(context.Background(), "merchant-1")
+	tokens := newTestTokenService(t, repository.NewCardTokensRepository())
+	created, err := tokens.Tokenize(merchant, testCardTokenRequest)
+	require.NoError(t, err)
+
+	req := models.PaymentRequest{Source: created.Token, Currency: "GBP", Amount: 1000}
+
+	t.Run("pays with the stored card", func(t *testing.T) {
+		svc := NewPaymentService(repository.NewPaymentsRepository(), &stubBank{
+			resp: &bank.BankResponse{Authorized: true, AuthorizationCode: "auth-code"},
+		}, WithCardTokens(tokens))
+
+		payment, err := svc.CreatePayment(merchant, req)
+		require.NoError(t, err)
+		assert.Equal(t, StatusAuthorized, payment.Status)
+		assert.Equal(t, "8877", payment.CardNumberLastFour)
+		assert.Equal(t, card.SchemeMastercard, payment.CardScheme)
+		assert.Equal(t, 4, payment.ExpiryMonth)
+		assert.Equal(t, 2035, payment.ExpiryYear)
+	})
+
+	t.Run("tokenization disabled", func(t *testing.T) {
+		svc := newTestPaymentService(t, true)
+
+		_, err := svc.CreatePayment(merchant, req)
+		assert.ErrorIs(t, err, models.ErrCardTokenNotFound)
+	})
+}
@@ -2,45 +2,162 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"net/url"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/card"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/currency"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
-	// Supported currencies
-	supportedCurrencies = map[string]bool{
-		"USD": true,
-		"GBP": true,
-		"EUR": true,
-	}
-
 	numericRegex = regexp.MustCompile(`^[0-9]+$`)
 )
 
+const maxReferenceLength = 50
+
 type ValidationService interface {
 	ValidatePaymentRequest(ctx context.Context, req models.PaymentRequest) []models.ValidationError
+	ValidateWebhookEndpoint(ctx context.Context, req models.WebhookEndpointRequest) []models.ValidationError
+	ValidateCardTokenRequest(ctx context.Context, req models.CardTokenRequest) []models.ValidationError
+}
+
+type validationService struct {
+	currencies *currency.Set
+	// cardTokens allows payments with a card token as source
+	cardTokens bool
+	// tokenCvvRequired requires a CVV with payments by card token
+	tokenCvvRequired bool
+	// insecureWebhooks accepts webhook endpoints that are not public https URLs
+	insecureWebhooks bool
+}
+
+// ValidationOption configures optional ValidationService behaviour.
+type ValidationOption func(*validationService)
+
+// WithCurrencies sets the currencies payments may be made in. It defaults to
+// currency.DefaultCodes.
+func WithCurrencies(currencies *currency.Set) ValidationOption {
+	return func(v *validationService) {
+		v.currencies = currencies
+	}
+}
+
+// WithCardTokenPayments accepts payments with a card token as source instead of the
+// card fields. The CVV of such payments is optional unless cvvRequired.
+func WithCardTokenPayments(cvvRequired bool) ValidationOption {
+	return func(v *validationService) {
+		v.cardTokens = true
+		v.tokenCvvRequired = cvvRequired
+	}
+}
+
+// WithInsecureWebhookEndpoints accepts plain http webhook endpoints and
+// endpoints on loopback, private and link-local addresses, for development.
+func WithInsecureWebhookEndpoints() ValidationOption {
+	return func(v *validationService) {
+		v.insecureWebhooks = true
+	}
 }
 
-type validationService struct{}
+func NewValidationService(opts ...ValidationOption) ValidationService {
+	v := &validationService{}
+	v.currencies, _ = currency.NewSet(currency.DefaultCodes...)
 
-func NewValidationService() ValidationService {
-	return &validationService{}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
 }
 
 // ValidatePaymentRequest validates all fields in a payment request
-func (v *validationService) ValidatePaymentRequest(ctx context.Context, req models.PaymentRequest) []models.ValidationError {
+func (v *validationService) ValidatePaymentRequest(ctx context.Context, req models.PaymentRequest) (errs []models.ValidationError) {
+	_, span := tracing.Start(ctx, "ValidationService.ValidatePaymentRequest")
+	defer func() {
+		span.SetAttributes(attribute.Int("validation.errors", len(errs)))
+		span.End()
+	}()
+
+	if req.Source != "" {
+		return concatErrors(
+			v.validateSource(req),
+			v.validateAmount(req.Amount, req.Currency),
+			v.validateCurrency(req.Currency),
+			v.validateTokenCvv(req.Cvv),
+			validateReference(req.Reference),
+		)
+	}
+
+	scheme := card.DetectScheme(req.CardNumber)
+
+	return concatErrors(
+		validateCardNumber(req.CardNumber, scheme),
+		validateExpiryDate(req.ExpiryMonth, req.ExpiryYear),
+		v.validateAmount(req.Amount, req.Currency),
+		v.validateCurrency(req.Currency),
+		validateCvv(req.Cvv, scheme),
+		validateReference(req.Reference),
+	)
+}
+
+// ValidateCardTokenRequest validates the card to store in the vault.
+func (v *validationService) ValidateCardTokenRequest(ctx context.Context, req models.CardTokenRequest) []models.ValidationError {
 	return concatErrors(
-		validateCardNumber(req.CardNumber),
+		validateCardNumber(req.CardNumber, card.DetectScheme(req.CardNumber)),
 		validateExpiryDate(req.ExpiryMonth, req.ExpiryYear),
-		validateAmount(req.Amount),
-		validateCurrency(req.Currency),
-		validateCvv(req.Cvv),
 	)
 }
 
-func validateAmount(amount int) []models.ValidationError {
+// validateSource checks a payment paid with a card token, which must not
+// carry card fields of its own.
+func (v *validationService) validateSource(req models.PaymentRequest) []models.ValidationError {
+	var errors []models.ValidationError
+
+	if !v.cardTokens {
+		return []models.ValidationError{{
+			Field:   "source",
+			Message: "payments by card token are not enabled",
+		}}
+	}
+	if !strings.HasPrefix(req.Source, CardTokenPrefix) {
+		errors = append(errors, models.ValidationError{
+			Field:   "source",
+			Message: "source must be a card token",
+		})
+	}
+	if req.CardNumber != "" {
+		errors = append(errors, models.ValidationError{Field: "card_number", Message: "card_number must not be given with source"})
+	}
+	if req.ExpiryMonth != 0 {
+		errors = append(errors, models.ValidationError{Field: "expiry_month", Message: "expiry_month must not be given with source"})
+	}
+	if req.ExpiryYear != 0 {
+		errors = append(errors, models.ValidationError{Field: "expiry_year", Message: "expiry_year must not be given with source"})
+	}
+
+	return errors
+}
+
+// validateTokenCvv checks the CVV of a payment by card token. The card scheme
+// is not known before the card is read from the vault.
+func (v *validationService) validateTokenCvv(cvv string) []models.ValidationError {
+	if cvv == "" && !v.tokenCvvRequired {
+		return nil
+	}
+	return validateCvv(cvv, card.SchemeUnknown)
+}
+
+// validateAmount checks an amount given in the minor units of currencyCode.
+// The upper bound depends on the currency's exponent, so it is only checked
+// for enabled currencies.
+func (v *validationService) validateAmount(amount int, currencyCode string) []models.ValidationError {
 	var errors []models.ValidationError
 
 	if amount <= 0 {
@@ -48,12 +165,17 @@ func validateAmount(amount int) []models.ValidationError {
 			Field:   "amount",
 			Message: "amount must be a positive integer",
 		})
+	} else if c, ok := v.currencies.Get(currencyCode); ok && amount > c.MaxAmount() {
+		errors = append(errors, models.ValidationError{
+			Field:   "amount",
+			Message: fmt.Sprintf("amount must not exceed %s %s", c.Format(c.MaxAmount()), c.Code),
+		})
 	}
 
 	return errors
 }
 
-func validateCardNumber(cardNumber string) []models.ValidationError {
+func validateCardNumber(cardNumber string, scheme card.Scheme) []models.ValidationError {
 	var errors []models.ValidationError
 
 	if cardNumber == "" {
@@ -61,17 +183,27 @@ func validateCardNumber(cardNumber string) []models.ValidationError {
 			Field:   "card_number",
 			Message: "card number is required",
 		})
+	} else if !numericRegex.MatchString(cardNumber) {
+		errors = append(errors, models.ValidationError{
+			Field:   "card_number",
+			Message: "card number must contain only numeric characters",
+		})
+	} else if rules, ok := scheme.Rules(); !ok {
+		errors = append(errors, models.ValidationError{
+			Field:   "card_number",
+			Message: "card scheme is not supported",
+		})
 	} else {
-		if len(cardNumber) < 14 || len(cardNumber) > 19 {
+		if !scheme.ValidLength(len(cardNumber)) {
 			errors = append(errors, models.ValidationError{
 				Field:   "card_number",
-				Message: "card number must be between 14-19 characters long",
+				Message: fmt.Sprintf("%s card number must be %s digits long", scheme.DisplayName(), joinInts(rules.Lengths)),
 			})
 		}
-		if !numericRegex.MatchString(cardNumber) {
+		if !card.Luhn(cardNumber) {
 			errors = append(errors, models.ValidationError{
 				Field:   "card_number",
-				Message: "card number must contain only numeric characters",
+				Message: "card number is invalid",
 			})
 		}
 	}
@@ -131,7 +263,7 @@ func validateExpiryYear(year int) []models.ValidationError {
 	return errors
 }
 
-func validateCurrency(currency string) []models.ValidationError {
+func (v *validationService) validateCurrency(currency string) []models.ValidationError {
 	var errors []models.ValidationError
 	if currency == "" {
 		errors = append(errors, models.ValidationError{
@@ -145,17 +277,17 @@ func validateCurrency(currency string) []models.ValidationError {
 				Message: "currency must be 3 characters",
 			})
 		}
-		if !supportedCurrencies[currency] {
+		if _, ok := v.currencies.Get(currency); !ok {
 			errors = append(errors, models.ValidationError{
 				Field:   "currency",
-				Message: "currency must be one of: USD, GBP, EUR",
+				Message: "currency must be one of: " + strings.Join(v.currencies.Codes(), ", "),
 			})
 		}
 	}
 	return errors
 }
 
-func validateCvv(cvv string) []models.ValidationError {
+func validateCvv(cvv string, scheme card.Scheme) []models.ValidationError {
 	var errors []models.ValidationError
 	if cvv == "" {
 		errors = append(errors, models.ValidationError{
@@ -163,7 +295,14 @@ func validateCvv(cvv string) []models.ValidationError {
 			Message: "cvv is required",
 		})
 	} else {
-		if len(cvv) < 3 || len(cvv) > 4 {
+		if rules, ok := scheme.Rules(); ok {
+			if len(cvv) != rules.CvvLength {
+				errors = append(errors, models.ValidationError{
+					Field:   "cvv",
+					Message: fmt.Sprintf("cvv must be %d characters long for %s cards", rules.CvvLength, scheme.DisplayName()),
+				})
+			}
+		} else if len(cvv) < 3 || len(cvv) > 4 {
 			errors = append(errors, models.ValidationError{
 				Field:   "cvv",
 				Message: "cvv must be 3-4 characters long",
@@ -179,6 +318,71 @@ func validateCvv(cvv string) []models.ValidationError {
 	return errors
 }
 
+// ValidateWebhookEndpoint checks the URL and event types of an endpoint registration.
+func (v *validationService) ValidateWebhookEndpoint(ctx context.Context, req models.WebhookEndpointRequest) []models.ValidationError {
+	var errors []models.ValidationError
+
+	u, err := url.Parse(req.URL)
+	switch {
+	case err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "":
+		errors = append(errors, models.ValidationError{
+			Field:   "url",
+			Message: "url must be an absolute http or https URL",
+		})
+	case v.insecureWebhooks:
+	case u.Scheme != "https":
+		errors = append(errors, models.ValidationError{
+			Field:   "url",
+			Message: "url must use https",
+		})
+	case !publicHost(u.Hostname()):
+		// host names are checked again once resolved, when deliveries are sent
+		errors = append(errors, models.ValidationError{
+			Field:   "url",
+			Message: "url must not point to a loopback, private or link-local address",
+		})
+	}
+
+	if len(req.Events) == 0 {
+		errors = append(errors, models.ValidationError{
+			Field:   "events",
+			Message: "events must list at least one event type",
+		})
+	}
+	for _, t := range req.Events {
+		if !slices.Contains(models.EventTypes, t) {
+			errors = append(errors, models.ValidationError{
+				Field:   "events",
+				Message: fmt.Sprintf("unknown event type %q", t),
+			})
+		}
+	}
+
+	return errors
+}
+
+func validateReference(reference string) []models.ValidationError {
+	if len(reference) > maxReferenceLength {
+		return []models.ValidationError{{
+			Field:   "reference",
+			Message: fmt.Sprintf("reference must be at most %d characters long", maxReferenceLength),
+		}}
+	}
+	return nil
+}
+
+// joinInts formats lengths as "16" or "16, 17 or 19".
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " or " + parts[len(parts)-1]
+}
+
 func concatErrors(slicesOfErrs ...[]models.ValidationError) []models.ValidationError {
 	var result []models.ValidationError
 	for _, s := range slicesOfErrs {
@@ -3,16 +3,19 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/currency"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestValidatePaymentRequest_ValidRequest(t *testing.T) {
 	req := models.PaymentRequest{
-		CardNumber:  "1234567812345678",
+		CardNumber:  "4111111111111111",
 		ExpiryMonth: 12,
 		ExpiryYear:  time.Now().Year() + 1,
 		Currency:    "USD",
@@ -76,7 +79,7 @@ func TestValidatePaymentRequest_InvalidExpiryMonth(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			req := models.PaymentRequest{
-				CardNumber:  "1234567812345678",
+				CardNumber:  "4111111111111111",
 				ExpiryMonth: tt.month,
 				ExpiryYear:  time.Now().Year() + 1,
 				Currency:    "USD",
@@ -119,7 +122,7 @@ func TestValidatePaymentRequest_ExpiryDateInPast(t *testing.T) {
 			}
 
 			req := models.PaymentRequest{
-				CardNumber:  "1234567812345678",
+				CardNumber:  "4111111111111111",
 				ExpiryMonth: tt.month,
 				ExpiryYear:  tt.year,
 				Currency:    "USD",
@@ -149,7 +152,7 @@ func TestValidatePaymentRequest_InvalidCurrency(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			req := models.PaymentRequest{
-				CardNumber:  "1234567812345678",
+				CardNumber:  "4111111111111111",
 				ExpiryMonth: 12,
 				ExpiryYear:  time.Now().Year() + 1,
 				Currency:    tt.currency,
@@ -179,7 +182,7 @@ func TestValidatePaymentRequest_SupportedCurrencies(t *testing.T) {
 	for _, currency := range currencies {
 		t.Run(currency, func(t *testing.T) {
 			req := models.PaymentRequest{
-				CardNumber:  "1234567812345678",
+				CardNumber:  "4111111111111111",
 				ExpiryMonth: 12,
 				ExpiryYear:  time.Now().Year() + 1,
 				Currency:    currency,
@@ -209,7 +212,7 @@ func TestValidatePaymentRequest_InvalidAmount(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			req := models.PaymentRequest{
-				CardNumber:  "1234567812345678",
+				CardNumber:  "4111111111111111",
 				ExpiryMonth: 12,
 				ExpiryYear:  time.Now().Year() + 1,
 				Currency:    "USD",
@@ -248,7 +251,7 @@ func TestValidatePaymentRequest_InvalidCvv(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			req := models.PaymentRequest{
-				CardNumber:  "1234567812345678",
+				CardNumber:  "4111111111111111",
 				ExpiryMonth: 12,
 				ExpiryYear:  time.Now().Year() + 1,
 				Currency:    "USD",
@@ -273,25 +276,350 @@ func TestValidatePaymentRequest_InvalidCvv(t *testing.T) {
 }
 
 func TestValidatePaymentRequest_ValidCvv(t *testing.T) {
-	tests := []string{"123", "1234"}
+	tests := []struct {
+		name       string
+		cardNumber string
+		cvv        string
+	}{
+		{"visa", "4111111111111111", "123"},
+		{"amex", "378282246310005", "1234"},
+	}
 	v := NewValidationService()
-	for _, cvv := range tests {
-		t.Run(cvv, func(t *testing.T) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
 			req := models.PaymentRequest{
-				CardNumber:  "1234567812345678",
+				CardNumber:  tt.cardNumber,
 				ExpiryMonth: 12,
 				ExpiryYear:  time.Now().Year() + 1,
 				Currency:    "USD",
 				Amount:      1000,
-				Cvv:         cvv,
+				Cvv:         tt.cvv,
+			}
+
+			ctx := context.Background()
+			errors := v.ValidatePaymentRequest(ctx, req)
+
+			for _, err := range errors {
+				assert.NotEqual(t, "cvv", err.Field, "CVV %s should be valid", tt.cvv)
+			}
+		})
+	}
+}
+
+func TestValidatePaymentRequest_CvvLengthFollowsScheme(t *testing.T) {
+	tests := []struct {
+		name       string
+		cardNumber string
+		cvv        string
+	}{
+		{"visa with 4 digits", "4111111111111111", "1234"},
+		{"mastercard with 4 digits", "5555555555554444", "1234"},
+		{"amex with 3 digits", "378282246310005", "123"},
+	}
+	v := NewValidationService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.PaymentRequest{
+				CardNumber:  tt.cardNumber,
+				ExpiryMonth: 12,
+				ExpiryYear:  time.Now().Year() + 1,
+				Currency:    "USD",
+				Amount:      1000,
+				Cvv:         tt.cvv,
+			}
+
+			ctx := context.Background()
+			errors := v.ValidatePaymentRequest(ctx, req)
+
+			require.Len(t, errors, 1)
+			assert.Equal(t, "cvv", errors[0].Field)
+		})
+	}
+}
+
+func TestValidatePaymentRequest_CardNumberFollowsScheme(t *testing.T) {
+	tests := []struct {
+		name       string
+		cardNumber string
+		message    string
+	}{
+		{"luhn failure", "4111111111111112", "card number is invalid"},
+		{"amex too long", "3782822463100051", "American Express card number must be 15 digits long"},
+		{"mastercard too short", "555555555555444", "Mastercard card number must be 16 digits long"},
+		{"unknown scheme", "9111111111111111", "card scheme is not supported"},
+	}
+	v := NewValidationService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.PaymentRequest{
+				CardNumber:  tt.cardNumber,
+				ExpiryMonth: 12,
+				ExpiryYear:  time.Now().Year() + 1,
+				Currency:    "USD",
+				Amount:      1000,
+				Cvv:         "123",
+			}
+
+			ctx := context.Background()
+			errors := v.ValidatePaymentRequest(ctx, req)
+
+			assert.Contains(t, errors, models.ValidationError{Field: "card_number", Message: tt.message})
+		})
+	}
+}
+
+func TestValidatePaymentRequest_ConfiguredCurrencies(t *testing.T) {
+	currencies, err := currency.NewSet("JPY", "BHD")
+	require.NoError(t, err)
+	v := NewValidationService(WithCurrencies(currencies))
+
+	tests := []struct {
+		name     string
+		currency string
+		amount   int
+		field    string
+	}{
+		{"zero exponent", "JPY", 1000, ""},
+		{"three digit exponent", "BHD", 1000, ""},
+		{"not enabled", "USD", 1000, "currency"},
+		{"above maximum for zero exponent", "JPY", 1_000_000_000, "amount"},
+		{"maximum for three digit exponent", "BHD", 999_999_999_999, ""},
+		{"above maximum for three digit exponent", "BHD", 1_000_000_000_000, "amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.PaymentRequest{
+				CardNumber:  "4111111111111111",
+				ExpiryMonth: 12,
+				ExpiryYear:  time.Now().Year() + 1,
+				Currency:    tt.currency,
+				Amount:      tt.amount,
+				Cvv:         "123",
 			}
 
 			ctx := context.Background()
 			errors := v.ValidatePaymentRequest(ctx, req)
 
+			if tt.field == "" {
+				assert.Empty(t, errors)
+				return
+			}
+			require.Len(t, errors, 1)
+			assert.Equal(t, tt.field, errors[0].Field)
+		})
+	}
+}
+
+func TestValidatePaymentRequest_ReferenceTooLong(t *testing.T) {
+	req := models.PaymentRequest{
+		CardNumber:  "4111111111111111",
+		ExpiryMonth: 12,
+		ExpiryYear:  time.Now().Year() + 1,
+		Currency:    "USD",
+		Amount:      1000,
+		Cvv:         "123",
+		Reference:   strings.Repeat("x", 51),
+	}
+
+	ctx := context.Background()
+	errors := NewValidationService().ValidatePaymentRequest(ctx, req)
+
+	require.Len(t, errors, 1)
+	assert.Equal(t, "reference", errors[0].Field)
+}
+
+func TestValidateWebhookEndpoint(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    models.WebhookEndpointRequest
+		fields []string
+	}{
+		{
+			name: "valid",
+			req:  models.WebhookEndpointRequest{URL: "https://merchant.example/webhooks", Events: []models.EventType{models.EventPaymentCaptured}},
+		},
+		{
+			name:   "relative url",
+			req:    models.WebhookEndpointRequest{URL: "/webhooks", Events: []models.EventType{models.EventPaymentCaptured}},
+			fields: []string{"url"},
+		},
+		{
+			name:   "unsupported scheme",
+			req:    models.WebhookEndpointRequest{URL: "ftp://merchant.example", Events: []models.EventType{models.EventPaymentCaptured}},
+			fields: []string{"url"},
+		},
+		{
+			name:   "plain http",
+			req:    models.WebhookEndpointRequest{URL: "http://merchant.example/webhooks", Events: []models.EventType{models.EventPaymentCaptured}},
+			fields: []string{"url"},
+		},
+		{
+			name:   "loopback",
+			req:    models.WebhookEndpointRequest{URL: "https://127.0.0.1:8080/webhooks", Events: []models.EventType{models.EventPaymentCaptured}},
+			fields: []string{"url"},
+		},
+		{
+			name:   "localhost",
+			req:    models.WebhookEndpointRequest{URL: "https://localhost/webhooks", Events: []models.EventType{models.EventPaymentCaptured}},
+			fields: []string{"url"},
+		},
+		{
+			name:   "private network",
+			req:    models.WebhookEndpointRequest{URL: "https://10.0.0.5/webhooks", Events: []models.EventType{models.EventPaymentCaptured}},
+			fields: []string{"url"},
+		},
+		{
+			name:   "link-local",
+			req:    models.WebhookEndpointRequest{URL: "https://[fe80::1]/webhooks", Events: []models.EventType{models.EventPaymentCaptured}},
+			fields: []string{"url"},
+		},
+		{
+			name:   "cloud metadata",
+			req:    models.WebhookEndpointRequest{URL: "https://169.254.169.254/latest", Events: []models.EventType{models.EventPaymentCaptured}},
+			fields: []string{"url"},
+		},
+		{
+			name:   "no events",
+			req:    models.WebhookEndpointRequest{URL: "https://merchant.example/webhooks"},
+			fields: []string{"events"},
+		},
+		{
+			name:   "unknown event",
+			req:    models.WebhookEndpointRequest{URL: "https://merchant.example/webhooks", Events: []models.EventType{"payment.lost"}},
+			fields: []string{"events"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := NewValidationService().ValidateWebhookEndpoint(context.Background(), tt.req)
+
+			var fields []string
+			for _, err := range errors {
+				fields = append(fields, err.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+
+	t.Run("insecure endpoints allowed", func(t *testing.T) {
+		v := NewValidationService(WithInsecureWebhookEndpoints())
+		for _, u := range []string{"http://localhost:9000/webhooks", "https://10.0.0.5/webhooks"} {
+			errors := v.ValidateWebhookEndpoint(context.Background(), models.WebhookEndpointRequest{
+				URL:    u,
+				Events: []models.EventType{models.EventPaymentCaptured},
+			})
+			assert.Empty(t, errors, u)
+		}
+	})
+}
+
+func TestValidatePaymentRequest_CardTokenSource(t *testing.T) {
+	payment := func(modify func(*models.PaymentRequest)) models.PaymentRequest {
+		req := models.PaymentRequest{Source: "tok_0123", Currency: "GBP", Amount: 1000}
+		modify(&req)
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		options []ValidationOption
+		req     models.PaymentRequest
+		fields  []string
+	}{
+		{
+			name:    "valid",
+			options: []ValidationOption{WithCardTokenPayments(false)},
+			req:     payment(func(r *models.PaymentRequest) {}),
+		},
+		{
+			name:    "valid with cvv",
+			options: []ValidationOption{WithCardTokenPayments(false)},
+			req:     payment(func(r *models.PaymentRequest) { r.Cvv = "1234" }),
+		},
+		{
+			name:   "tokenization disabled",
+			req:    payment(func(r *models.PaymentRequest) {}),
+			fields: []string{"source"},
+		},
+		{
+			name:    "not a card token",
+			options: []ValidationOption{WithCardTokenPayments(false)},
+			req:     payment(func(r *models.PaymentRequest) { r.Source = "pay_0123" }),
+			fields:  []string{"source"},
+		},
+		{
+			name:    "card fields given",
+			options: []ValidationOption{WithCardTokenPayments(false)},
+			req: payment(func(r *models.PaymentRequest) {
+				r.CardNumber, r.ExpiryMonth, r.ExpiryYear = "4111111111111111", 12, time.Now().Year()+1
+			}),
+			fields: []string{"card_number", "expiry_month", "expiry_year"},
+		},
+		{
+			name:    "cvv required",
+			options: []ValidationOption{WithCardTokenPayments(true)},
+			req:     payment(func(r *models.PaymentRequest) {}),
+			fields:  []string{"cvv"},
+		},
+		{
+			name:    "invalid cvv",
+			options: []ValidationOption{WithCardTokenPayments(false)},
+			req:     payment(func(r *models.PaymentRequest) { r.Cvv = "12" }),
+			fields:  []string{"cvv"},
+		},
+		{
+			name:    "amount and currency still checked",
+			options: []ValidationOption{WithCardTokenPayments(false)},
+			req:     payment(func(r *models.PaymentRequest) { r.Amount, r.Currency = 0, "XXX" }),
+			fields:  []string{"amount", "currency"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := NewValidationService(tt.options...).ValidatePaymentRequest(context.Background(), tt.req)
+
+			var fields []string
+			for _, err := range errors {
+				fields = append(fields, err.Field)
+			}
+			assert.ElementsMatch(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestValidateCardTokenRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    models.CardTokenRequest
+		fields []string
+	}{
+		{
+			name: "valid",
+			req:  models.CardTokenRequest{CardNumber: "4111111111111111", ExpiryMonth: 12, ExpiryYear: time.Now().Year() + 1},
+		},
+		{
+			name:   "invalid card number",
+			req:    models.CardTokenRequest{CardNumber: "4111111111111112", ExpiryMonth: 12, ExpiryYear: time.Now().Year() + 1},
+			fields: []string{"card_number"},
+		},
+		{
+			name:   "expired",
+			req:    models.CardTokenRequest{CardNumber: "4111111111111111", ExpiryMonth: 1, ExpiryYear: time.Now().Year() - 1},
+			fields: []string{"expiry_year"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := NewValidationService().ValidateCardTokenRequest(context.Background(), tt.req)
+
+			var fields []string
 			for _, err := range errors {
-				assert.NotEqual(t, "cvv", err.Field, "CVV %s should be valid", cvv)
+				fields = append(fields, err.Field)
 			}
+			assert.ElementsMatch(t, tt.fields, fields)
 		})
 	}
 }
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+)
+
+// Headers sent with every webhook delivery. The signature is the hex encoded
+// HMAC-SHA256 of the timestamp, a dot and the body, keyed with the endpoint
+// secret, so receivers can reject replays of old deliveries.
+const (
+	WebhookIdHeader        = "Webhook-Id"
+	WebhookEventHeader     = "Webhook-Event"
+	WebhookTimestampHeader = "Webhook-Timestamp"
+	WebhookSignatureHeader = "Webhook-Signature"
+)
+
+// DispatcherConfig controls how webhook deliveries are sent and retried
+type DispatcherConfig struct {
+	// Interval is the time between two polls of the delivery queue
+	Interval time.Duration
+	// BatchSize is the number of deliveries claimed per poll
+	BatchSize int
+	// Timeout bounds a single delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts before a delivery is dead-lettered
+	MaxAttempts int
+	// BaseDelay is the backoff after the first failed attempt. It doubles on
+	// every further failure, is capped by MaxDelay and randomised with jitter.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// AllowInsecureEndpoints sends deliveries to plain http URLs and to
+	// loopback, private and link-local addresses, which only development
+	// environments want
+	AllowInsecureEndpoints bool
+}
+
+// DefaultDispatcherConfig retries a failing endpoint for roughly a day.
+var DefaultDispatcherConfig = DispatcherConfig{
+	Interval:    time.Second,
+	BatchSize:   50,
+	Timeout:     10 * time.Second,
+	MaxAttempts: 10,
+	BaseDelay:   30 * time.Second,
+	MaxDelay:    6 * time.Hour,
+}
+
+// errEndpointNotAllowed fails the deliveries to endpoints the dispatcher
+// refuses to call. They are dead-lettered right away.
+var errEndpointNotAllowed = errors.New("webhook endpoint not allowed")
+
+// WebhookDispatcher sends queued webhook deliveries to merchant endpoints.
+type WebhookDispatcher struct {
+	storage    repository.WebhooksRepository
+	httpClient *http.Client
+	cfg        DispatcherConfig
+	now        func() time.Time
+}
+
+func NewWebhookDispatcher(repo repository.WebhooksRepository, cfg DispatcherConfig) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		storage:    repo,
+		httpClient: newWebhookClient(cfg),
+		cfg:        cfg,
+		now:        time.Now,
+	}
+}
+
+// newWebhookClient returns the client deliveries are sent with. Merchants
+// choose the URLs, so the client does not follow redirects and, unless
+// cfg.AllowInsecureEndpoints, only connects to public addresses. These are
+// checked on the address dialled, once the host name is resolved, so that
+// DNS cannot point an endpoint into the gateway's own network.
+func newWebhookClient(cfg DispatcherConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowInsecureEndpoints {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addr.Addr()) {
+				return fmt.Errorf("%w: %s is not a public address", errEndpointNotAllowed, addr.Addr())
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would dial the endpoint past the address check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicAddr reports whether addr is a unicast address outside the loopback,
+// private and link-local ranges.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// publicHost reports whether host, the host name of a URL, may be public. Only
+// IP addresses and localhost can be told apart before they are resolved.
+func publicHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return publicAddr(addr)
+	}
+	return true
+}
+
+// SignWebhook returns the Webhook-Signature header value for body sent at timestamp.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run sends due deliveries every cfg.Interval until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.Dispatch(ctx); err != nil {
+				slog.ErrorContext(ctx, "webhook dispatch failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Dispatch claims a batch of due deliveries and attempts each of them once.
+// It returns the number of deliveries attempted.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := d.now().UTC()
+	// the lease outlives an attempt, so a dispatcher that dies mid-batch only
+	// delays its deliveries instead of losing them
+	leaseUntil := now.Add(time.Duration(d.cfg.BatchSize+1) * d.cfg.Timeout)
+
+	due, err := d.storage.ClaimDueDeliveries(ctx, now, leaseUntil, d.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	for _, delivery := range due {
+		if err := d.attempt(ctx, delivery); err != nil {
+			errs = append(errs, fmt.Errorf("delivery %s: %w", delivery.Id, err))
+		}
+	}
+	return len(due), errors.Join(errs...)
+}
+
+// attempt sends delivery once and records the outcome.
+func (d *WebhookDispatcher) attempt(ctx context.Context, delivery models.WebhookDelivery) error {
+	statusCode, sendErr := d.send(ctx, delivery)
+
+	now := d.now().UTC()
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.UpdatedAt = now
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+	case errors.Is(sendErr, models.ErrWebhookEndpointNotFound), errors.Is(sendErr, errEndpointNotAllowed),
+		delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.LastError = sendErr.Error()
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+
+	return d.storage.UpdateDelivery(ctx, delivery)
+}
+
+// send posts the delivery payload to its endpoint. Any response other than
+// 2xx is a failure, redirects included.
+func (d *WebhookDispatcher) send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	endpoint, err := d.storage.GetEndpoint(ctx, delivery.EndpointId)
+	if err != nil {
+		return 0, err
+	}
+	if u, err := url.Parse(endpoint.URL); !d.cfg.AllowInsecureEndpoints && (err != nil || u.Scheme != "https") {
+		return 0, fmt.Errorf("%w: the URL must use https", errEndpointNotAllowed)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookIdHeader, delivery.Id)
+	req.Header.Set(WebhookEventHeader, string(delivery.EventType))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the attempt following the given number of
+// failed attempts: BaseDelay doubled per failure, capped at MaxDelay, with
+// up to half of it randomised away so that retries of many deliveries spread out.
+func (d *WebhookDispatcher) backoff(failures int) time.Duration {
+	delay := d.cfg.BaseDelay
+	for i := 1; i < failures && delay < d.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > d.cfg.MaxDelay {
+		delay = d.cfg.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDispatcher(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// the test servers listen on loopback
+	cfg := DispatcherConfig{
+		Interval:               time.Second,
+		BatchSize:              10,
+		Timeout:                time.Second,
+		MaxAttempts:            3,
+		BaseDelay:              time.Minute,
+		MaxDelay:               time.Hour,
+		AllowInsecureEndpoints: true,
+	}
+
+	setupWith := func(t *testing.T, cfg DispatcherConfig, server *httptest.Server) (repository.WebhooksRepository, *WebhookDispatcher) {
+		t.Helper()
+		t.Cleanup(server.Close)
+
+		repo := repository.NewWebhooksRepository()
+		require.NoError(t, repo.AddEndpoint(ctx, models.WebhookEndpoint{
+			Id:         "endpoint-1",
+			MerchantId: "merchant-1",
+			URL:        server.URL,
+			Secret:     "whsec_test",
+			EventTypes: models.EventTypes,
+		}))
+		require.NoError(t, repo.AddDeliveries(ctx, []models.WebhookDelivery{{
+			Id:            "delivery-1",
+			EndpointId:    "endpoint-1",
+			MerchantId:    "merchant-1",
+			EventId:       "event-1",
+			EventType:     models.EventPaymentCaptured,
+			Payload:       []byte(`{"id":"event-1"}`),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		}}))
+
+		d := NewWebhookDispatcher(repo, cfg)
+		d.now = func() time.Time { return now }
+		return repo, d
+	}
+	setup := func(t *testing.T, handler http.HandlerFunc) (repository.WebhooksRepository, *WebhookDispatcher) {
+		t.Helper()
+		return setupWith(t, cfg, httptest.NewServer(handler))
+	}
+
+	t.Run("signs deliveries", func(t *testing.T) {
+		var received *http.Request
+		var body []byte
+		repo, d := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			body, _ = io.ReadAll(r.Body)
+		})
+
+		n, err := d.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		require.NotNil(t, received)
+		assert.Equal(t, "delivery-1", received.Header.Get(WebhookIdHeader))
+		assert.Equal(t, "payment.captured", received.Header.Get(WebhookEventHeader))
+		timestamp, err := strconv.ParseInt(received.Header.Get(WebhookTimestampHeader), 10, 64)
+		require.NoError(t, err)
+		assert.Equal(t, now.Unix(), timestamp)
+		assert.Equal(t, SignWebhook("whsec_test", timestamp, body), received.Header.Get(WebhookSignatureHeader))
+		assert.NotEqual(t, SignWebhook("whsec_other", timestamp, body), received.Header.Get(WebhookSignatureHeader))
+
+		delivery, err := repo.GetDelivery(ctx, "delivery-1")
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+		assert.Equal(t, 1, delivery.Attempts)
+		assert.Equal(t, http.StatusOK, delivery.LastStatusCode)
+
+		n, err = d.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("retries failures with backoff then dead-letters them", func(t *testing.T) {
+		calls := 0
+		repo, d := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+
+		for attempt := 1; attempt <= cfg.MaxAttempts; attempt++ {
+			n, err := d.Dispatch(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+
+			delivery, err := repo.GetDelivery(ctx, "delivery-1")
+			require.NoError(t, err)
+			assert.Equal(t, attempt, delivery.Attempts)
+			assert.Equal(t, http.StatusServiceUnavailable, delivery.LastStatusCode)
+			assert.NotEmpty(t, delivery.LastError)
+
+			if attempt == cfg.MaxAttempts {
+				assert.Equal(t, models.DeliveryDead, delivery.Status)
+				break
+			}
+			assert.Equal(t, models.DeliveryPending, delivery.Status)
+
+			// not due before its backoff has elapsed
+			n, err = d.Dispatch(ctx)
+			require.NoError(t, err)
+			require.Zero(t, n)
+
+			now = delivery.NextAttemptAt
+		}
+		assert.Equal(t, cfg.MaxAttempts, calls)
+	})
+
+	t.Run("does not follow redirects", func(t *testing.T) {
+		redirected := false
+		repo, d := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/internal" {
+				redirected = true
+				return
+			}
+			http.Redirect(w, r, "/internal", http.StatusTemporaryRedirect)
+		})
+
+		_, err := d.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.False(t, redirected)
+
+		delivery, err := repo.GetDelivery(ctx, "delivery-1")
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryPending, delivery.Status)
+		assert.Equal(t, http.StatusTemporaryRedirect, delivery.LastStatusCode)
+	})
+
+	t.Run("dead-letters deliveries to private addresses", func(t *testing.T) {
+		secure := cfg
+		secure.AllowInsecureEndpoints = false
+
+		called := false
+		repo, d := setupWith(t, secure, httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})))
+
+		_, err := d.Dispatch(ctx)
+		require.NoError(t, err)
+		assert.False(t, called)
+
+		delivery, err := repo.GetDelivery(ctx, "delivery-1")
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryDead, delivery.Status)
+		assert.Contains(t, delivery.LastError, "127.0.0.1 is not a public address")
+	})
+
+	t.Run("dead-letters deliveries to http endpoints", func(t *testing.T) {
+		secure := cfg
+		secure.AllowInsecureEndpoints = false
+
+		repo, d := setupWith(t, secure, httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+		_, err := d.Dispatch(ctx)
+		require.NoError(t, err)
+
+		delivery, err := repo.GetDelivery(ctx, "delivery-1")
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryDead, delivery.Status)
+		assert.Contains(t, delivery.LastError, "https")
+	})
+
+	t.Run("dead-letters deliveries of deleted endpoints", func(t *testing.T) {
+		repo, d := setup(t, func(w http.ResponseWriter, r *http.Request) {})
+		require.NoError(t, repo.DeleteEndpoint(ctx, "endpoint-1"))
+
+		_, err := d.Dispatch(ctx)
+		require.NoError(t, err)
+
+		delivery, err := repo.GetDelivery(ctx, "delivery-1")
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryDead, delivery.Status)
+	})
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	d := NewWebhookDispatcher(repository.NewWebhooksRepository(), DispatcherConfig{
+		BaseDelay: time.Minute,
+		MaxDelay:  10 * time.Minute,
+	})
+
+	tests := []struct {
+		failures int
+		max      time.Duration
+	}{
+		{failures: 1, max: time.Minute},
+		{failures: 2, max: 2 * time.Minute},
+		{failures: 3, max: 4 * time.Minute},
+		{failures: 4, max: 8 * time.Minute},
+		{failures: 5, max: 10 * time.Minute},
+		{failures: 50, max: 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.failures), func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				delay := d.backoff(tt.failures)
+				assert.GreaterOrEqual(t, delay, tt.max/2)
+				assert.LessOrEqual(t, delay, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// maxListedDeliveries caps the number of deliveries returned by ListDeliveries.
+const maxListedDeliveries = 100
+
+// EventPublisher receives an event every time a payment changes status.
+type EventPublisher interface {
+	Publish(ctx context.Context, event models.PaymentEvent) error
+}
+
+type WebhookService interface {
+	EventPublisher
+	RegisterEndpoint(ctx context.Context, req models.WebhookEndpointRequest) (*models.WebhookEndpointResponse, error)
+	ListEndpoints(ctx context.Context) ([]models.WebhookEndpointResponse, error)
+	DeleteEndpoint(ctx context.Context, id string) error
+	ListDeliveries(ctx context.Context, status models.DeliveryStatus) ([]models.WebhookDeliveryResponse, error)
+	GetDelivery(ctx context.Context, id string) (*models.WebhookDeliveryResponse, error)
+	// ReplayDelivery queues a delivery to be sent again right away, whatever
+	// its status, with a fresh set of attempts.
+	ReplayDelivery(ctx context.Context, id string) (*models.WebhookDeliveryResponse, error)
+}
+
+type webhookService struct {
+	storage repository.WebhooksRepository
+	now     func() time.Time
+}
+
+func NewWebhookService(repo repository.WebhooksRepository) WebhookService {
+	return &webhookService{
+		storage: repo,
+		now:     time.Now,
+	}
+}
+
+// RegisterEndpoint stores a new endpoint for the authenticated merchant with a
+// freshly generated signing secret, which is only returned here.
+func (s *webhookService) RegisterEndpoint(ctx context.Context, req models.WebhookEndpointRequest) (*models.WebhookEndpointResponse, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	endpoint := models.WebhookEndpoint{
+		Id:         uuid.New().String(),
+		MerchantId: auth.MerchantID(ctx),
+		URL:        req.URL,
+		Secret:     "whsec_" + hex.EncodeToString(secret),
+		EventTypes: req.Events,
+		CreatedAt:  s.now().UTC().Truncate(time.Microsecond),
+	}
+	if err := s.storage.AddEndpoint(ctx, endpoint); err != nil {
+		return nil, err
+	}
+
+	response := toEndpointResponse(endpoint)
+	response.Secret = endpoint.Secret
+	return &response, nil
+}
+
+func (s *webhookService) ListEndpoints(ctx context.Context) ([]models.WebhookEndpointResponse, error) {
+	endpoints, err := s.storage.ListEndpoints(ctx, auth.MerchantID(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	response := []models.WebhookEndpointResponse{}
+	for _, endpoint := range endpoints {
+		response = append(response, toEndpointResponse(endpoint))
+	}
+	return response, nil
+}
+
+func (s *webhookService) DeleteEndpoint(ctx context.Context, id string) error {
+	endpoint, err := s.storage.GetEndpoint(ctx, id)
+	if err != nil {
+		return err
+	}
+	if endpoint.MerchantId != auth.MerchantID(ctx) {
+		return models.ErrWebhookEndpointNotFound
+	}
+	return s.storage.DeleteEndpoint(ctx, id)
+}
+
+func (s *webhookService) ListDeliveries(ctx context.Context, status models.DeliveryStatus) ([]models.WebhookDeliveryResponse, error) {
+	deliveries, err := s.storage.ListDeliveries(ctx, auth.MerchantID(ctx), status, maxListedDeliveries)
+	if err != nil {
+		return nil, err
+	}
+
+	response := []models.WebhookDeliveryResponse{}
+	for _, d := range deliveries {
+		response = append(response, toDeliveryResponse(d))
+	}
+	return response, nil
+}
+
+func (s *webhookService) GetDelivery(ctx context.Context, id string) (*models.WebhookDeliveryResponse, error) {
+	d, err := s.getOwnedDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	response := toDeliveryResponse(*d)
+	return &response, nil
+}
+
+func (s *webhookService) ReplayDelivery(ctx context.Context, id string) (*models.WebhookDeliveryResponse, error) {
+	d, err := s.getOwnedDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	d.Status = models.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	d.UpdatedAt = now
+	if err := s.storage.UpdateDelivery(ctx, *d); err != nil {
+		return nil, err
+	}
+
+	response := toDeliveryResponse(*d)
+	return &response, nil
+}
+
+// Publish queues a delivery of event for every endpoint of the merchant
+// subscribed to its type.
+func (s *webhookService) Publish(ctx context.Context, event models.PaymentEvent) error {
+	endpoints, err := s.storage.ListEndpoints(ctx, event.MerchantId)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	now := s.now().UTC()
+	var deliveries []models.WebhookDelivery
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			Id:            uuid.New().String(),
+			EndpointId:    endpoint.Id,
+			MerchantId:    event.MerchantId,
+			EventId:       event.Id,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	return s.storage.AddDeliveries(ctx, deliveries)
+}
+
+func (s *webhookService) getOwnedDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	d, err := s.storage.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.MerchantId != auth.MerchantID(ctx) {
+		return nil, models.ErrWebhookDeliveryNotFound
+	}
+	return d, nil
+}
+
+func toEndpointResponse(endpoint models.WebhookEndpoint) models.WebhookEndpointResponse {
+	return models.WebhookEndpointResponse{
+		Id:        endpoint.Id,
+		URL:       endpoint.URL,
+		Events:    endpoint.EventTypes,
+		CreatedAt: endpoint.CreatedAt,
+	}
+}
+
+func toDeliveryResponse(d models.WebhookDelivery) models.WebhookDeliveryResponse {
+	response := models.WebhookDeliveryResponse{
+		Id:             d.Id,
+		EndpointId:     d.EndpointId,
+		EventId:        d.EventId,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+	if d.Status == models.DeliveryPending {
+		next := d.NextAttemptAt
+		response.NextAttemptAt = &next
+	}
+	return response
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/auth"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/models"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookService(t *testing.T) {
+	merchant := auth.WithMerchantID(context.Background(), "merchant-1")
+	other := auth.WithMerchantID(context.Background(), "merchant-2")
+
+	repo := repository.NewWebhooksRepository()
+	svc := NewWebhookService(repo)
+
+	captures, err := svc.RegisterEndpoint(merchant, models.WebhookEndpointRequest{
+		URL:    "https://merchant.example/captures",
+		Events: []models.EventType{models.EventPaymentCaptured},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, captures.Secret, "whsec_")
+
+	all, err := svc.RegisterEndpoint(merchant, models.WebhookEndpointRequest{
+		URL:    "https://merchant.example/all",
+		Events: models.EventTypes,
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, captures.Secret, all.Secret)
+
+	t.Run("secrets are not listed", func(t *testing.T) {
+		endpoints, err := svc.ListEndpoints(merchant)
+		require.NoError(t, err)
+		require.Len(t, endpoints, 2)
+		for _, endpoint := range endpoints {
+			assert.Empty(t, endpoint.Secret)
+		}
+
+		endpoints, err = svc.ListEndpoints(other)
+		require.NoError(t, err)
+		assert.Empty(t, endpoints)
+	})
+
+	event := models.PaymentEvent{
+		Id:         "event-1",
+		Type:       models.EventPaymentAuthorized,
+		Data:       models.PaymentResponse{Id: "payment-1", Status: models.StatusAuthorized},
+		MerchantId: "merchant-1",
+	}
+
+	t.Run("publish queues a delivery per subscribed endpoint", func(t *testing.T) {
+		require.NoError(t, svc.Publish(context.Background(), event))
+
+		deliveries, err := svc.ListDeliveries(merchant, "")
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, all.Id, deliveries[0].EndpointId)
+		assert.Equal(t, models.DeliveryPending, deliveries[0].Status)
+		assert.NotNil(t, deliveries[0].NextAttemptAt)
+
+		stored, err := repo.GetDelivery(context.Background(), deliveries[0].Id)
+		require.NoError(t, err)
+		var payload models.PaymentEvent
+		require.NoError(t, json.Unmarshal(stored.Payload, &payload))
+		assert.Equal(t, "event-1", payload.Id)
+		assert.Equal(t, "payment-1", payload.Data.Id)
+		assert.Empty(t, payload.MerchantId)
+	})
+
+	t.Run("replay resets dead deliveries", func(t *testing.T) {
+		deliveries, err := svc.ListDeliveries(merchant, models.DeliveryPending)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+
+		stored, err := repo.GetDelivery(context.Background(), deliveries[0].Id)
+		require.NoError(t, err)
+		stored.Status = models.DeliveryDead
+		stored.Attempts = 10
+		require.NoError(t, repo.UpdateDelivery(context.Background(), *stored))
+
+		_, err = svc.ReplayDelivery(other, stored.Id)
+		assert.ErrorIs(t, err, models.ErrWebhookDeliveryNotFound)
+
+		replayed, err := svc.ReplayDelivery(merchant, stored.Id)
+		require.NoError(t, err)
+		assert.Equal(t, models.DeliveryPending, replayed.Status)
+		assert.Zero(t, replayed.Attempts)
+	})
+
+	t.Run("endpoints of other merchants cannot be deleted", func(t *testing.T) {
+		assert.ErrorIs(t, svc.DeleteEndpoint(other, captures.Id), models.ErrWebhookEndpointNotFound)
+		require.NoError(t, svc.DeleteEndpoint(merchant, captures.Id))
+		assert.ErrorIs(t, svc.DeleteEndpoint(merchant, captures.Id), models.ErrWebhookEndpointNotFound)
+	})
+}
@@ -0,0 +1,134 @@
+// Package tracing sets up OpenTelemetry tracing for the gateway. Trace
+// context is propagated with the W3C traceparent and tracestate headers.
+//
+// Spans must never carry card data. Attributes are only ever set from fields
+// that are safe to log, and error messages recorded on spans are redacted
+// like log records.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/cko-recruitment/payment-gateway-challenge-go"
+
+// Exporter names where spans are sent.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Config selects the span exporter.
+type Config struct {
+	// Exporter is one of ExporterNone, ExporterStdout or ExporterOTLP
+	Exporter string
+	// File receives the spans of the stdout exporter instead of stdout when set
+	File string
+	// Endpoint is the OTLP/HTTP collector URL, such as http://localhost:4318.
+	// When empty the standard OTEL_EXPORTER_OTLP_* variables apply.
+	Endpoint string
+	// ServiceVersion is reported in the resource of every span
+	ServiceVersion string
+}
+
+// Setup installs the global tracer provider and W3C propagators described by
+// cfg. The returned function flushes and stops the exporter. With
+// ExporterNone spans are still propagated but never recorded.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+	)
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		var w io.Writer = os.Stdout
+		if cfg.File != "" {
+			f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open trace file: %w", err)
+			}
+			w, closer = f, f
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("payment-gateway"),
+		semconv.ServiceVersion(cfg.ServiceVersion),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// Start starts a span named name as a child of the span in ctx.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End ends span, marking it as failed with a redacted description of err
+// when err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		msg := logging.RedactString(err.Error())
+		span.AddEvent("exception", trace.WithAttributes(semconv.ExceptionMessage(msg)))
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
+
+// PaymentAttributes returns the span attributes describing a payment. They
+// are the only payment attributes spans carry, and hold no card data.
+func PaymentAttributes(id, currency string, amount int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("payment.id", id),
+		attribute.String("payment.currency", currency),
+		attribute.Int("payment.amount", amount),
+	}
+}
@@ -0,0 +1,118 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/handlers"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/services"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	testCardNumber  = "2222405343248877"
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testTraceparent = "00-" + testTraceID + "-00f067aa0ba902b7-01"
+)
+
+func TestPaymentTrace(t *testing.T) {
+	ctx := context.Background()
+	_, err := tracing.Setup(ctx, tracing.Config{Exporter: tracing.ExporterNone})
+	require.NoError(t, err)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(ctx) })
+
+	var bankTraceparent string
+	bankServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bankTraceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"authorized":true,"authorization_code":"auth-code"}`))
+	}))
+	t.Cleanup(bankServer.Close)
+
+	storage := repository.InstrumentPayments(repository.NewPaymentsRepository(), nil)
+	payments := handlers.NewPaymentsHandler(
+		services.NewValidationService(),
+		services.NewPaymentService(storage, bank.NewClient(&bankServer.URL)),
+	)
+	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Post("/api/payments", payments.PostHandler())
+
+	body := `{"card_number":"` + testCardNumber + `","expiry_month":4,"expiry_year":2035,"currency":"GBP","amount":100,"cvv":"123"}`
+	req := httptest.NewRequest("POST", "/api/payments", bytes.NewBufferString(body))
+	req.Header.Set("traceparent", testTraceparent)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		assert.Equal(t, testTraceID, span.SpanContext().TraceID().String(), span.Name())
+		spans[span.Name()] = span
+	}
+
+	parents := map[string]string{
+		"POST /api/payments":                       "",
+		"PaymentsHandler.PostHandler":              "POST /api/payments",
+		"ValidationService.ValidatePaymentRequest": "PaymentsHandler.PostHandler",
+		"PaymentService.CreatePayment":             "PaymentsHandler.PostHandler",
+		"PaymentsRepository.AddPayment":            "PaymentService.CreatePayment",
+		"bank.Client.ProcessPayment":               "PaymentService.CreatePayment",
+		"POST":                                     "bank.Client.ProcessPayment",
+		"PaymentsRepository.UpdatePayment":         "PaymentService.CreatePayment",
+	}
+	for name, parent := range parents {
+		span, ok := spans[name]
+		require.True(t, ok, "missing span %s", name)
+		if parent != "" {
+			assert.Equal(t, spans[parent].SpanContext().SpanID(), span.Parent().SpanID(), name)
+		}
+	}
+
+	// the bank continues the trace from the attempt's client span
+	assert.Equal(t, "00-"+testTraceID+"-"+spans["POST"].SpanContext().SpanID().String()+"-01", bankTraceparent)
+
+	for _, span := range recorder.Ended() {
+		for _, attr := range span.Attributes() {
+			assert.NotContains(t, attr.Value.Emit(), testCardNumber, span.Name())
+			assert.NotContains(t, strings.ToLower(string(attr.Key)), "cvv", span.Name())
+			assert.NotContains(t, strings.ToLower(string(attr.Key)), "expiry", span.Name())
+		}
+	}
+}
+
+func TestSetupRejectsUnknownExporters(t *testing.T) {
+	_, err := tracing.Setup(context.Background(), tracing.Config{Exporter: "zipkin"})
+	assert.Error(t, err)
+}
+
+func TestStdoutExporter(t *testing.T) {
+	ctx := context.Background()
+	file := t.TempDir() + "/spans.json"
+	shutdown, err := tracing.Setup(ctx, tracing.Config{Exporter: tracing.ExporterStdout, File: file})
+	require.NoError(t, err)
+
+	_, span := tracing.Start(ctx, "test-span")
+	span.End()
+	require.NoError(t, shutdown(ctx))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"Name":"test-span"`)
+}
@@ -0,0 +1,87 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// KeyProvider supplies the KEKs of a keyring. A KMS would back it in
+// production; FileKeyProvider is enough for development.
+type KeyProvider interface {
+	// Keys returns every KEK by ID along with the ID of the newest version,
+	// which seals new records.
+	Keys() (newest string, keys map[string][]byte, err error)
+}
+
+// StaticKey provides a single KEK, such as the one set in the gateway's config.
+type StaticKey struct {
+	ID  string
+	Key []byte
+}
+
+func (k StaticKey) Keys() (string, map[string][]byte, error) {
+	return k.ID, map[string][]byte{k.ID: k.Key}, nil
+}
+
+// FileKeyProvider reads the versions of the KEK from a JSON file listing
+// them oldest first:
+//
+//	{"keys": [{"id": "2025-01", "key": "<base64>"}, {"id": "2026-10", "key": "<base64>"}]}
+//
+// The last key is the newest version. Rotating the KEK is appending a new
+// key to the list, restarting the gateway and re-encrypting the stored
+// records; an old key can be removed once no record uses it anymore.
+type FileKeyProvider struct {
+	Path string
+}
+
+type keyFile struct {
+	Keys []struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	} `json:"keys"`
+}
+
+func (p FileKeyProvider) Keys() (string, map[string][]byte, error) {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read keys file: %w", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return "", nil, fmt.Errorf("failed to parse keys file: %w", err)
+	}
+	if len(file.Keys) == 0 {
+		return "", nil, errors.New("keys file has no keys")
+	}
+
+	keys := make(map[string][]byte, len(file.Keys))
+	for i, k := range file.Keys {
+		if k.ID == "" {
+			return "", nil, fmt.Errorf("key %d of keys file has no id", i)
+		}
+		if _, exists := keys[k.ID]; exists {
+			return "", nil, fmt.Errorf("key %q is listed twice in keys file", k.ID)
+		}
+		key, err := ParseKey(k.Key)
+		if err != nil {
+			return "", nil, fmt.Errorf("key %q: %w", k.ID, err)
+		}
+		keys[k.ID] = key
+	}
+
+	return file.Keys[len(file.Keys)-1].ID, keys, nil
+}
+
+// LoadKeyring returns a keyring with the keys of p, sealing new records with
+// the newest one.
+func LoadKeyring(p KeyProvider) (*Keyring, error) {
+	newest, keys, err := p.Keys()
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyring(newest, keys)
+}
@@ -0,0 +1,70 @@
+package vault
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(testKey(1))
+	k2 := base64.StdEncoding.EncodeToString(testKey(2))
+
+	path := writeKeyFile(t, `{"keys": [{"id": "v1", "key": "`+k1+`"}, {"id": "v2", "key": "`+k2+`"}]}`)
+	keyring, err := LoadKeyring(FileKeyProvider{Path: path})
+	require.NoError(t, err)
+	assert.Equal(t, "v2", keyring.Primary())
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "no keys", content: `{"keys": []}`},
+		{name: "missing id", content: `{"keys": [{"key": "` + k1 + `"}]}`},
+		{name: "duplicate id", content: `{"keys": [{"id": "v1", "key": "` + k1 + `"}, {"id": "v1", "key": "` + k2 + `"}]}`},
+		{name: "short key", content: `{"keys": [{"id": "v1", "key": "c2hvcnQ="}]}`},
+		{name: "not json", content: `keys`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadKeyring(FileKeyProvider{Path: writeKeyFile(t, tt.content)})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestReseal(t *testing.T) {
+	old, err := LoadKeyring(StaticKey{ID: "v1", Key: testKey(1)})
+	require.NoError(t, err)
+	aad := []byte("tok_1|merchant-1")
+	env, err := old.Seal([]byte("2222405343248877"), aad)
+	require.NoError(t, err)
+
+	rotated, err := NewKeyring("v2", map[string][]byte{"v1": testKey(1), "v2": testKey(2)})
+	require.NoError(t, err)
+	resealed, err := rotated.Reseal(env, aad)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", resealed.KeyID)
+
+	// the old key is no longer needed
+	current, err := NewKeyring("v2", map[string][]byte{"v2": testKey(2)})
+	require.NoError(t, err)
+	got, err := current.Open(resealed, aad)
+	require.NoError(t, err)
+	assert.Equal(t, "2222405343248877", string(got))
+
+	_, err = current.Reseal(env, aad)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
@@ -0,0 +1,163 @@
+// Package vault encrypts card data at rest with envelope encryption. Every
+// record is sealed with AES-256-GCM under a random data key of its own, and
+// the data key is stored next to it wrapped by a key encryption key (KEK).
+// The KEKs only ever live in the gateway's configuration, and a Keyring holds
+// every version of them still in use so that they can be rotated.
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeySize is the size in bytes of key encryption keys and data keys.
+const KeySize = 32
+
+var (
+	ErrUnknownKey = errors.New("unknown key encryption key")
+	// ErrDecrypt is returned when a record does not decrypt, because it was
+	// tampered with or sealed for other additional data.
+	ErrDecrypt = errors.New("failed to decrypt record")
+)
+
+// Envelope is a sealed record.
+type Envelope struct {
+	// KeyID identifies the KEK that wrapped DataKey
+	KeyID string
+	// DataKey is the record's data key encrypted with the KEK, nonce first
+	DataKey []byte
+	// Ciphertext is the record encrypted with the data key, nonce first
+	Ciphertext []byte
+}
+
+// Keyring holds the KEKs by ID. New records are sealed with the primary one.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring returns a keyring with keys by ID, sealing new records with the
+// key named primary. Every key must be KeySize bytes long.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	k := &Keyring{primary: primary, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.keys[id] = aead
+	}
+	if _, ok := k.keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q: %w", primary, ErrUnknownKey)
+	}
+	return k, nil
+}
+
+// Primary returns the ID of the KEK sealing new records.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// ParseKey decodes a base64 encoded KEK, such as the output of
+// `openssl rand -base64 32`.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.New("key must be base64 encoded")
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes long, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext under a new data key wrapped by the primary KEK.
+// additionalData is authenticated but not encrypted: Open fails unless it is
+// given the same, which binds the envelope to the record it belongs to.
+func (k *Keyring) Seal(plaintext, additionalData []byte) (Envelope, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return Envelope{}, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return Envelope{}, err
+	}
+	ciphertext, err := seal(aead, plaintext, additionalData)
+	if err != nil {
+		return Envelope{}, err
+	}
+	wrapped, err := seal(k.keys[k.primary], dataKey, additionalData)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{KeyID: k.primary, DataKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// Open decrypts an envelope sealed by Seal with the same additionalData.
+func (k *Keyring) Open(env Envelope, additionalData []byte) ([]byte, error) {
+	kek, ok := k.keys[env.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("key %q: %w", env.KeyID, ErrUnknownKey)
+	}
+
+	dataKey, err := open(kek, env.DataKey, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return open(aead, env.Ciphertext, additionalData)
+}
+
+// Reseal re-encrypts a record sealed with additionalData under a new data
+// key wrapped by the primary KEK, so that the KEK it was sealed with can be
+// retired.
+func (k *Keyring) Reseal(env Envelope, additionalData []byte) (Envelope, error) {
+	plaintext, err := k.Open(env, additionalData)
+	if err != nil {
+		return Envelope{}, err
+	}
+	return k.Seal(plaintext, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes long, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce, which it prepends to the result.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
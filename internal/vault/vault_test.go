@@ -0,0 +1,107 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestSealOpen(t *testing.T) {
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	require.NoError(t, err)
+
+	plaintext := []byte("2222405343248877")
+	aad := []byte("tok_1|merchant-1")
+
+	env, err := keyring.Seal(plaintext, aad)
+	require.NoError(t, err)
+	assert.Equal(t, "k1", env.KeyID)
+	assert.NotContains(t, string(env.Ciphertext), string(plaintext))
+	assert.NotContains(t, string(env.DataKey), string(testKey(1)))
+
+	got, err := keyring.Open(env, aad)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, got)
+
+	// every record gets its own data key and nonces
+	other, err := keyring.Seal(plaintext, aad)
+	require.NoError(t, err)
+	assert.NotEqual(t, env.DataKey, other.DataKey)
+	assert.NotEqual(t, env.Ciphertext, other.Ciphertext)
+}
+
+func TestOpenFailures(t *testing.T) {
+	keyring, err := NewKeyring("k1", map[string][]byte{"k1": testKey(1)})
+	require.NoError(t, err)
+	aad := []byte("tok_1|merchant-1")
+	env, err := keyring.Seal([]byte("2222405343248877"), aad)
+	require.NoError(t, err)
+
+	tamper := func(b []byte) []byte {
+		b = append([]byte(nil), b...)
+		b[len(b)-1] ^= 1
+		return b
+	}
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		env     Envelope
+		aad     []byte
+		wantErr error
+	}{
+		{name: "other record", keyring: keyring, env: env, aad: []byte("tok_2|merchant-1"), wantErr: ErrDecrypt},
+		{name: "tampered ciphertext", keyring: keyring, env: Envelope{KeyID: "k1", DataKey: env.DataKey, Ciphertext: tamper(env.Ciphertext)}, aad: aad, wantErr: ErrDecrypt},
+		{name: "tampered data key", keyring: keyring, env: Envelope{KeyID: "k1", DataKey: tamper(env.DataKey), Ciphertext: env.Ciphertext}, aad: aad, wantErr: ErrDecrypt},
+		{name: "truncated", keyring: keyring, env: Envelope{KeyID: "k1", DataKey: env.DataKey[:4], Ciphertext: env.Ciphertext}, aad: aad, wantErr: ErrDecrypt},
+		{name: "unknown key", keyring: keyring, env: Envelope{KeyID: "k9", DataKey: env.DataKey, Ciphertext: env.Ciphertext}, aad: aad, wantErr: ErrUnknownKey},
+		{
+			name:    "wrong key",
+			keyring: must(NewKeyring("k1", map[string][]byte{"k1": testKey(2)})),
+			env:     env,
+			aad:     aad,
+			wantErr: ErrDecrypt,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.keyring.Open(tt.env, tt.aad)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func must(k *Keyring, err error) *Keyring {
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func TestNewKeyring(t *testing.T) {
+	_, err := NewKeyring("k2", map[string][]byte{"k1": testKey(1)})
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	_, err = NewKeyring("k1", map[string][]byte{"k1": []byte("short")})
+	assert.Error(t, err)
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := ParseKey(base64.StdEncoding.EncodeToString(testKey(7)))
+	require.NoError(t, err)
+	assert.Equal(t, testKey(7), key)
+
+	_, err = ParseKey("not base64!")
+	assert.Error(t, err)
+
+	_, err = ParseKey(base64.StdEncoding.EncodeToString([]byte("too short")))
+	assert.Error(t, err)
+}
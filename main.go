@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,8 +14,15 @@ import (
 	"github.com/cko-recruitment/payment-gateway-challenge-go/docs"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/api"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/bank"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/config"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/currency"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/health"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/logging"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/metrics"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/repository"
 	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/services"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/tracing"
+	"github.com/cko-recruitment/payment-gateway-challenge-go/internal/vault"
 )
 
 var (
@@ -28,46 +38,429 @@ var (
 //	@BasePath	/
 
 // @securityDefinitions.basic	BasicAuth
+
+// @securityDefinitions.apikey	AdminToken
+// @in							header
+// @name						Authorization
 func main() {
-	fmt.Printf("version %s, commit %s, built at %s\n", version, commit, date)
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "vault" {
+		os.Exit(vaultCommand(os.Args[2:]))
+	}
+
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger := logging.New(os.Stdout, cfg.Log.Level)
+	slog.SetDefault(logger)
+
+	logger.Info("starting payment gateway", slog.String("version", version), slog.String("commit", commit), slog.String("built_at", date))
 	docs.SwaggerInfo.Version = version
 
-	err := run()
+	err = run(cfg, logger)
+	if err != nil {
+		logger.Error("fatal API error", slog.Any("error", err))
+	}
+}
+
+// configCommand runs the config subcommand, which only has "print": it
+// writes the configuration the gateway would run with, secrets redacted.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: "+os.Args[0]+" config print [flags]")
+		return 2
+	}
+
+	cfg, err := config.Load(os.Args[0]+" config print", args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
 	if err != nil {
-		fmt.Printf("fatal API error: %v\n", err)
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
+	if err := cfg.Print(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
 }
 
-func run() error {
+// vaultCommand runs the vault subcommand, which only has "reencrypt": it
+// re-encrypts the stored card tokens and authorization codes with the newest
+// version of the vault key. An interrupted run resumes where it stopped when
+// started again.
+func vaultCommand(args []string) int {
+	if len(args) == 0 || args[0] != "reencrypt" {
+		fmt.Fprintln(os.Stderr, "usage: "+os.Args[0]+" vault reencrypt [flags]")
+		return 2
+	}
+
+	cfg, err := config.Load(os.Args[0]+" vault reencrypt", args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	logger := logging.New(os.Stderr, cfg.Log.Level)
+	slog.SetDefault(logger)
+
+	if cfg.Store.Driver == "memory" {
+		logger.Error("vault reencrypt needs a sqlite or postgres store, set with -store")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	keyring, err := loadKeyring(cfg.Vault)
+	if err != nil {
+		logger.Error("failed to load vault keys", slog.Any("error", err))
+		return 1
+	}
+	stores, err := openStores(ctx, cfg.Store.Driver, cfg.Store.DSN, keyring)
+	if err != nil {
+		logger.Error("failed to open store", slog.Any("error", err))
+		return 1
+	}
+	defer stores.close()
+
+	rotations, err := services.NewReencryptor(stores.cardTokens, stores.authorizationCodes, stores.keyRotations, keyring, services.DefaultReencryptorConfig).Run(ctx)
+	if err != nil {
+		rotation := rotations[len(rotations)-1]
+		logger.Error("re-encryption stopped, run again to resume",
+			slog.Any("error", err),
+			slog.String("rotation", rotation.Name),
+			slog.String("last_id", rotation.LastID),
+			slog.Int("reencrypted", rotation.Reencrypted))
+		return 1
+	}
+	for _, rotation := range rotations {
+		fmt.Printf("re-encrypted %d %s records with key %s\n", rotation.Reencrypted, rotation.Name, rotation.KeyID)
+	}
+	return 0
+}
+
+// loadKeyring returns the keyring with the configured vault keys.
+func loadKeyring(cfg config.VaultConfig) (*vault.Keyring, error) {
+	provider, err := cfg.KeyProvider()
+	if err != nil {
+		return nil, err
+	}
+	return vault.LoadKeyring(provider)
+}
+
+func run(cfg config.Config, logger *slog.Logger) error {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	healthConfig := health.DefaultConfig
+	healthConfig.CacheTTL = cfg.Server.HealthCacheTTL
+	checker := health.NewChecker(healthConfig)
+
 	go func() {
-		// graceful shutdown
+		// graceful shutdown: fail the readiness probe first so load balancers
+		// stop sending traffic, then stop serving. A second signal skips the wait.
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		<-c
-		fmt.Printf("sigterm/interrupt signal\n")
+		logger.Info("sigterm/interrupt signal", slog.Duration("shutdown_delay", cfg.Server.ShutdownDelay))
+		checker.StartShutdown()
+		select {
+		case <-time.After(cfg.Server.ShutdownDelay):
+		case <-c:
+		}
 		cancel()
 	}()
 
 	defer func() {
 		// recover after panic
 		if x := recover(); x != nil {
-			fmt.Printf("run time panic:\n%v\n", x)
+			logger.Error("run time panic", slog.Any("panic", x))
 			panic(x)
 		}
 	}()
 
-	storage := repository.NewPaymentsRepository()
-	bankService := bank.NewClient(nil)
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:       cfg.Tracing.Exporter,
+		File:           cfg.Tracing.File,
+		Endpoint:       cfg.Tracing.Endpoint,
+		ServiceVersion: version,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// the run context is cancelled by now, the last spans still need flushing
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Error("failed to flush spans", slog.Any("error", err))
+		}
+	}()
 
-	validationService := services.NewValidationService()
-	paymentService := services.NewPaymentService(storage, bankService)
+	// a nil *metrics.Metrics records nothing
+	var gatewayMetrics *metrics.Metrics
+	if cfg.Features.Metrics {
+		gatewayMetrics = metrics.New()
+	}
 
-	api := api.New(validationService, paymentService)
-	if err := api.Run(ctx, ":8090"); err != nil {
+	// the vault key, when there is one, seals the authorization codes too
+	keyring, err := loadKeyring(cfg.Vault)
+	if err != nil && !errors.Is(err, config.ErrNoVaultKey) {
+		return err
+	}
+	stores, err := openStores(ctx, cfg.Store.Driver, cfg.Store.DSN, keyring)
+	if err != nil {
 		return err
 	}
+	defer stores.close()
+	stores.instrument(gatewayMetrics)
+	checker.Register("store", stores.ping)
+
+	merchantsRepo, err := repository.LoadMerchantsRepository(cfg.Auth.MerchantsFile)
+	if err != nil {
+		return err
+	}
+
+	retryPolicy := bank.DefaultRetryPolicy
+	retryPolicy.MaxAttempts = cfg.Bank.MaxAttempts
+	breakerConfig := bank.DefaultBreakerConfig
+	breakerConfig.FailureRateThreshold = cfg.Bank.Breaker.FailureRate
+	breakerConfig.SlowCallThreshold = cfg.Bank.Breaker.SlowCall
+	breakerConfig.OpenTimeout = cfg.Bank.Breaker.OpenTimeout
+
+	// without a routing file every payment goes to the configured bank
+	routingConfig := bank.RoutingConfig{
+		Acquirers: []bank.AcquirerConfig{{Name: bank.DefaultRoutingConfig.Default, URL: cfg.Bank.URL}},
+		Default:   bank.DefaultRoutingConfig.Default,
+	}
+	if cfg.Bank.RoutingFile != "" {
+		if routingConfig, err = bank.LoadRoutingConfig(cfg.Bank.RoutingFile); err != nil {
+			return err
+		}
+	}
+
+	apiOptions := []api.Option{api.WithRequestTimeout(cfg.Server.RequestTimeout), api.WithDrainTimeout(cfg.Server.DrainTimeout)}
+
+	// the faults go in below the circuit breakers, so they trip them as real
+	// outages would
+	var chaos *bank.Chaos
+	if cfg.Features.Chaos {
+		chaos = bank.NewChaos(cfg.Bank.Chaos.Bank(cfg.Bank.Timeout))
+		logger.Warn("injecting faults in bank calls")
+		if cfg.Bank.Chaos.AdminToken != "" {
+			apiOptions = append(apiOptions, api.WithChaos(chaos, cfg.Bank.Chaos.AdminToken))
+		}
+	}
+
+	// every acquirer gets its own circuit breaker so one failing bank does not
+	// stop payments to the others
+	acquirers := bank.NewRegistry()
+	var acquirerChecks []health.CheckFunc
+	for _, acquirer := range routingConfig.Acquirers {
+		client := bank.NewClient(&acquirer.URL,
+			bank.WithRetryPolicy(retryPolicy),
+			bank.WithTimeout(cfg.Bank.Timeout),
+			bank.WithMetrics(gatewayMetrics, acquirer.Name),
+		)
+		var acquirerBank bank.Bank = client
+		if chaos != nil {
+			acquirerBank = chaos.Wrap(client)
+		}
+		breaker := bank.NewCircuitBreaker(acquirerBank, breakerConfig)
+		if err := acquirers.Register(acquirer.Name, breaker); err != nil {
+			return err
+		}
+		apiOptions = append(apiOptions, api.WithStatus("acquirer_circuit:"+acquirer.Name, func() string { return string(breaker.State()) }))
+		circuitCheck := func(context.Context) error {
+			if breaker.State() == bank.BreakerOpen {
+				return errCircuitOpen
+			}
+			return nil
+		}
+		// payments fail over and other currencies go through, so a single
+		// acquirer being down does not make the gateway unready
+		checker.RegisterInformational("acquirer:"+acquirer.Name, client.Ping)
+		checker.RegisterInformational("acquirer_circuit:"+acquirer.Name, circuitCheck)
+		name := acquirer.Name
+		acquirerChecks = append(acquirerChecks, func(ctx context.Context) error {
+			if err := circuitCheck(ctx); err != nil {
+				return fmt.Errorf("acquirer %s: %w", name, err)
+			}
+			if err := client.Ping(ctx); err != nil {
+				return fmt.Errorf("acquirer %s: %w", name, err)
+			}
+			return nil
+		})
+	}
+	checker.Register("acquirers", anyCheck(acquirerChecks))
+
+	bankService, err := bank.NewRouter(acquirers, routingConfig)
+	if err != nil {
+		return err
+	}
+
+	enabledCurrencies, err := currency.NewSet(cfg.Payments.Currencies...)
+	if err != nil {
+		return err
+	}
+
+	validationOptions := []services.ValidationOption{services.WithCurrencies(enabledCurrencies)}
+	paymentOptions := []services.PaymentServiceOption{services.WithMetrics(gatewayMetrics)}
+	if cfg.Features.Tokenization {
+		tokenService := services.NewTokenService(stores.cardTokens, keyring)
+		validationOptions = append(validationOptions, services.WithCardTokenPayments(cfg.Vault.RequireCvv))
+		paymentOptions = append(paymentOptions, services.WithCardTokens(tokenService))
+		apiOptions = append(apiOptions, api.WithCardTokens(tokenService))
+	}
+	if cfg.Webhooks.AllowInsecureEndpoints {
+		validationOptions = append(validationOptions, services.WithInsecureWebhookEndpoints())
+	}
+	validationService := services.NewValidationService(validationOptions...)
+
+	// events stays a nil interface when webhooks are off, so no event is published
+	var events services.EventPublisher
+	if cfg.Features.Webhooks {
+		webhookService := services.NewWebhookService(stores.webhooks)
+		events = webhookService
+		paymentOptions = append(paymentOptions, services.WithEventPublisher(webhookService))
+		apiOptions = append(apiOptions, api.WithWebhooks(webhookService))
+		dispatcherConfig := services.DefaultDispatcherConfig
+		dispatcherConfig.AllowInsecureEndpoints = cfg.Webhooks.AllowInsecureEndpoints
+		go services.NewWebhookDispatcher(stores.webhooks, dispatcherConfig).Run(ctx)
+		go services.NewEventRelay(stores.payments, webhookService, services.DefaultEventRelayConfig).Run(ctx)
+	}
+	paymentService := services.NewPaymentService(stores.payments, bankService, paymentOptions...)
+
+	if cfg.Features.Reconciliation {
+		reconcilerConfig := services.DefaultReconcilerConfig
+		reconcilerConfig.Interval = cfg.Payments.ReconcileInterval
+		reconcilerConfig.VoidAfter = cfg.Payments.ReconcileVoidAfter
+		go services.NewReconciler(stores.payments, bankService, events, reconcilerConfig).Run(ctx)
+	}
+
+	// batchesDone is closed once the batch in progress has stopped. It is
+	// waited for on every return, before the deferred stores.close, so that
+	// the stores are not closed under the batch.
+	batchesDone := make(chan struct{})
+	defer func() {
+		cancel()
+		<-batchesDone
+	}()
+	if cfg.Features.Batches {
+		batchConfig := services.DefaultBatchConfig
+		batchConfig.Concurrency = cfg.Payments.BatchConcurrency
+		batchConfig.MaxLines = cfg.Payments.BatchMaxLines
+		batchConfig.QueueSize = cfg.Payments.BatchQueueSize
+		batchService := services.NewBatchService(stores.paymentBatches, paymentService, validationService, batchConfig)
+		apiOptions = append(apiOptions, api.WithPaymentBatches(batchService, int64(cfg.Payments.BatchMaxSize)))
+		go func() {
+			batchService.Run(ctx)
+			close(batchesDone)
+		}()
+	} else {
+		close(batchesDone)
+	}
+
+	apiOptions = append(apiOptions,
+		api.WithMerchantAuth(merchantsRepo),
+		api.WithLogger(logger),
+		api.WithHealth(checker),
+	)
+	if cfg.Features.Idempotency {
+		apiOptions = append(apiOptions, api.WithIdempotency(stores.idempotency, cfg.Store.IdempotencyTTL))
+	}
+	if cfg.Features.Metrics {
+		apiOptions = append(apiOptions, api.WithMetrics(gatewayMetrics))
+	}
+	api := api.New(validationService, paymentService, apiOptions...)
+	return api.Run(ctx, cfg.Server.Addr)
+}
+
+var errCircuitOpen = errors.New("circuit breaker is open")
+
+// anyCheck passes as soon as one of checks passes.
+func anyCheck(checks []health.CheckFunc) health.CheckFunc {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, check := range checks {
+			err := check(ctx)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}
+}
+
+type stores struct {
+	payments     repository.PaymentsRepository
+	idempotency  repository.IdempotencyRepository
+	webhooks     repository.WebhooksRepository
+	cardTokens   repository.CardTokensRepository
+	keyRotations repository.KeyRotationsRepository
+	// authorizationCodes is nil for the memory backend, which keeps nothing at rest
+	authorizationCodes repository.AuthorizationCodesRepository
+	// paymentBatches only holds the progress and results of batches, the
+	// payments of their lines are in payments
+	paymentBatches repository.PaymentBatchesRepository
+	// ping checks that the backend can still be reached
+	ping  health.CheckFunc
+	close func()
+}
+
+// instrument records the latency of every store operation in m.
+func (s *stores) instrument(m *metrics.Metrics) {
+	s.payments = repository.InstrumentPayments(s.payments, m)
+	s.idempotency = repository.InstrumentIdempotency(s.idempotency, m)
+	s.webhooks = repository.InstrumentWebhooks(s.webhooks, m)
+	s.cardTokens = repository.InstrumentCardTokens(s.cardTokens, m)
+	s.keyRotations = repository.InstrumentKeyRotations(s.keyRotations, m)
+	s.paymentBatches = repository.InstrumentPaymentBatches(s.paymentBatches, m)
+}
+
+// openStores creates the repositories for the configured backend. The SQL
+// payments store seals authorization codes with keyring unless it is nil.
+func openStores(ctx context.Context, driver, dsn string, keyring *vault.Keyring) (*stores, error) {
+	if driver == "memory" {
+		return &stores{
+			payments:       repository.NewPaymentsRepository(),
+			idempotency:    repository.NewIdempotencyRepository(),
+			webhooks:       repository.NewWebhooksRepository(),
+			cardTokens:     repository.NewCardTokensRepository(),
+			keyRotations:   repository.NewKeyRotationsRepository(),
+			paymentBatches: repository.NewPaymentBatchesRepository(),
+			ping:           func(context.Context) error { return nil },
+			close:          func() {},
+		}, nil
+	}
+
+	db, err := repository.OpenDatabase(ctx, repository.Driver(driver), dsn)
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
+	return &stores{
+		payments:           repository.NewSQLPaymentsRepository(db, keyring),
+		idempotency:        repository.NewSQLIdempotencyRepository(db),
+		webhooks:           repository.NewSQLWebhooksRepository(db),
+		cardTokens:         repository.NewSQLCardTokensRepository(db),
+		keyRotations:       repository.NewSQLKeyRotationsRepository(db),
+		authorizationCodes: repository.NewSQLAuthorizationCodesRepository(db),
+		paymentBatches:     repository.NewSQLPaymentBatchesRepository(db),
+		ping:               db.Ping,
+		close:              func() { db.Close() },
+	}, nil
 }